@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AddCars creates the cars of a batch. Each item is validated on its own;
+// with atomic set, no car is created unless all of them can be.
+func AddCars(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody entities.InsertBatchRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(requestBody.Items) == 0 {
+			return errEmptyBatch
+		}
+
+		items := make([]*entities.Car, len(requestBody.Items))
+		for i := range requestBody.Items {
+			items[i] = &requestBody.Items[i]
+		}
+
+		results, err := service.InsertCarsService(c.UserContext(), items, requestBody.Atomic)
+		if err != nil {
+			return err
+		}
+		return batchResponse(c, requestBody.Atomic, results, http.StatusCreated)
+	}
+}
+
+// UpdateCars changes the cars of a batch. Each item names a car, the
+// version it must be at (0 for any) and the fields to change.
+func UpdateCars(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody entities.UpdateBatchRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(requestBody.Items) == 0 {
+			return errEmptyBatch
+		}
+
+		results, err := service.UpdateCarsService(c.UserContext(), requestBody.Items, requestBody.Atomic)
+		if err != nil {
+			return err
+		}
+		return batchResponse(c, requestBody.Atomic, results, http.StatusOK)
+	}
+}
+
+// RemoveCars deletes the cars of a batch.
+func RemoveCars(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody entities.DeleteBatchRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(requestBody.Items) == 0 {
+			return errEmptyBatch
+		}
+
+		results, err := service.RemoveCarsService(c.UserContext(), requestBody.Items, requestBody.Atomic)
+		if err != nil {
+			return err
+		}
+		return batchResponse(c, requestBody.Atomic, results, http.StatusNoContent)
+	}
+}
+
+var errEmptyBatch = fiber.NewError(fiber.StatusBadRequest, "batch has no items")
+
+// batchResponse reports every item with the status it would have got as a
+// request of its own: okStatus when it succeeded, the status of its error
+// otherwise. The response is 200 when all items succeeded and 207 Multi
+// Status when some did not.
+func batchResponse(c *fiber.Ctx, atomic bool, results []cars.BatchResult, okStatus int) error {
+	items := make([]presenters.BatchItem, len(results))
+	status := http.StatusOK
+
+	for i, result := range results {
+		if result.Err == nil {
+			items[i] = presenters.NewBatchItem(i, okStatus, result.Car)
+			continue
+		}
+
+		problem := newProblem(c, result.Err)
+		items[i] = presenters.BatchItem{Index: i, Status: problem.Status, Error: problem}
+		status = http.StatusMultiStatus
+	}
+
+	return c.Status(status).JSON(presenters.BatchResponse(atomic, items))
+}
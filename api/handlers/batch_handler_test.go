@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type batchBody struct {
+	Status bool `json:"status"`
+	Data   []struct {
+		Index  int              `json:"index"`
+		Status int              `json:"status"`
+		Data   *entities.Car    `json:"data"`
+		Error  *json.RawMessage `json:"error"`
+	} `json:"data"`
+	Meta struct {
+		Atomic    bool `json:"atomic"`
+		Succeeded int  `json:"succeeded"`
+		Failed    int  `json:"failed"`
+	} `json:"meta"`
+}
+
+func batchApp(service cars.Service) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/cars\\:batchCreate", AddCars(service))
+	app.Post("/cars\\:batchUpdate", UpdateCars(service))
+	app.Post("/cars\\:batchDelete", RemoveCars(service))
+	return app
+}
+
+func postBatch(t *testing.T, app *fiber.App, route, body string) (*http.Response, *batchBody) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, route, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var decoded batchBody
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	return resp, &decoded
+}
+
+func TestAddCarsHandler(t *testing.T) {
+	mockService := new(mockService)
+	mockService.On("InsertCarsService", mock.Anything, []*entities.Car{
+		{CarName: "CX-5", Company: "Mazda"},
+		{CarName: "", Company: "Toyota"},
+	}, false).Return([]cars.BatchResult{
+		{Car: &entities.Car{ID: "64a4c6181955b6923fff02b5", CarName: "CX-5", Company: "Mazda", Version: 1}},
+		{Err: &cars.ValidationError{Fields: []validation.FieldError{{Field: "carName", Message: "is required"}}}},
+	}, nil)
+
+	resp, body := postBatch(t, batchApp(mockService), "/cars:batchCreate",
+		`{"items":[{"carName":"CX-5","company":"Mazda"},{"carName":"","company":"Toyota"}]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.False(t, body.Status)
+	require.Len(t, body.Data, 2)
+	assert.Equal(t, http.StatusCreated, body.Data[0].Status)
+	assert.Equal(t, entities.CarID("64a4c6181955b6923fff02b5"), body.Data[0].Data.ID)
+	assert.Nil(t, body.Data[0].Error)
+	assert.Equal(t, 1, body.Data[1].Index)
+	assert.Equal(t, http.StatusUnprocessableEntity, body.Data[1].Status)
+	assert.Contains(t, string(*body.Data[1].Error), "urn:testingfiber:problem:validation")
+	assert.Equal(t, 1, body.Meta.Succeeded)
+	assert.Equal(t, 1, body.Meta.Failed)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateCarsHandlerAtomic(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+	name := "CX-30"
+
+	mockService := new(mockService)
+	mockService.On("UpdateCarsService", mock.Anything, []entities.CarUpdate{
+		{ID: carID, Version: 3, CarPatch: entities.CarPatch{CarName: &name}},
+		{ID: "64a4c6181955b6923fff02b6", CarPatch: entities.CarPatch{CarName: &name}},
+	}, true).Return([]cars.BatchResult{
+		{Err: cars.ErrBatchAborted},
+		{Err: cars.ErrCarNotFound},
+	}, nil)
+
+	resp, body := postBatch(t, batchApp(mockService), "/cars:batchUpdate",
+		`{"atomic":true,"items":[{"id":"64a4c6181955b6923fff02b5","version":3,"carName":"CX-30"},{"id":"64a4c6181955b6923fff02b6","carName":"CX-30"}]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.True(t, body.Meta.Atomic)
+	require.Len(t, body.Data, 2)
+	assert.Equal(t, http.StatusFailedDependency, body.Data[0].Status)
+	assert.Equal(t, http.StatusNotFound, body.Data[1].Status)
+	assert.Equal(t, 0, body.Meta.Succeeded)
+	mockService.AssertExpectations(t)
+}
+
+func TestRemoveCarsHandler(t *testing.T) {
+	mockService := new(mockService)
+	mockService.On("RemoveCarsService", mock.Anything, []entities.CarDelete{
+		{ID: "64a4c6181955b6923fff02b5", Version: 2},
+	}, false).Return([]cars.BatchResult{{}}, nil)
+
+	resp, body := postBatch(t, batchApp(mockService), "/cars:batchDelete",
+		`{"items":[{"id":"64a4c6181955b6923fff02b5","version":2}]}`)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, body.Status)
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, http.StatusNoContent, body.Data[0].Status)
+	assert.Nil(t, body.Data[0].Data)
+	mockService.AssertExpectations(t)
+}
+
+func TestBatchHandlerErrors(t *testing.T) {
+	tests := []struct {
+		description  string
+		requestBody  string
+		serviceErr   error
+		expectedCode int
+	}{
+		{description: "malformed", requestBody: `{"items":`, expectedCode: 400},
+		{description: "empty", requestBody: `{"items":[]}`, expectedCode: 400},
+		{description: "tooLarge", requestBody: `{"items":[{"id":"64a4c6181955b6923fff02b5"}]}`, serviceErr: cars.ErrBatchTooLarge, expectedCode: 413},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+			if test.serviceErr != nil {
+				mockService.On("RemoveCarsService", mock.Anything, mock.Anything, false).Return(nil, test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/cars:batchDelete", strings.NewReader(test.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := batchApp(mockService).Test(req)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
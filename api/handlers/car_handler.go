@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
-	"net/http"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"testingfiber/api/presenters"
 	"testingfiber/pkg/cars"
 	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,44 +22,43 @@ func AddCar(service cars.Service) fiber.Handler {
 		err := c.BodyParser(&requestBody)
 
 		if err != nil {
-			c.Status(http.StatusBadRequest)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
 
-		if requestBody.CarName == "" || requestBody.Company == "" {
-			c.Status(http.StatusInternalServerError)
-			return c.JSON(presenters.CarErrorResponse(errors.New(
-				"Please specify title and author")))
-		}
-
-		result, err := service.InsertCarService(&requestBody)
+		result, err := service.InsertCarService(c.UserContext(), &requestBody)
 		if err != nil {
-			c.Status(http.StatusInternalServerError)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return err
 		}
 
+		setETag(c, result)
 		return c.JSON(presenters.CarSuccessResponse(result))
 	}
 }
 
+// UpdateCar changes the fields supplied in the body of the car whose ID
+// is given in the body. Fields left out keep their stored values.
 func UpdateCar(service cars.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var requestBody entities.Car
+		var requestBody entities.UpdateRequest
 
 		err := c.BodyParser(&requestBody)
 
 		if err != nil {
-			c.Status(http.StatusBadRequest)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
 		}
 
-		result, err := service.UpdateCarService(&requestBody)
+		result, err := service.UpdateCarService(c.UserContext(), requestBody.ID, version, &requestBody.CarPatch)
 
 		if err != nil {
-			c.Status(http.StatusInternalServerError)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return err
 		}
 
+		setETag(c, result)
 		return c.JSON(presenters.CarSuccessResponse(result))
 	}
 }
@@ -64,33 +69,365 @@ func RemoveCar(service cars.Service) fiber.Handler {
 		err := c.BodyParser(&requestBody)
 
 		if err != nil {
-			c.Status(http.StatusBadRequest)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
 		}
 
 		carId := requestBody.ID
-		err = service.RemoveCarService(carId)
+		err = service.RemoveCarService(c.UserContext(), carId, version)
+
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(&fiber.Map{
+			"status": true,
+			"data":   "deleted successfully",
+			"error":  nil,
+		})
+	}
+}
+
+func GetCar(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carId, err := parseCarID(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.GetCarByIDService(c.UserContext(), carId)
+		if err != nil {
+			return err
+		}
+
+		setETag(c, result)
+		return c.JSON(presenters.CarSuccessResponse(result))
+	}
+}
+
+// ReplaceCar overwrites the car named in the path with the request body.
+// Optional fields the body leaves out are cleared, and a body without a
+// carName, company or madeAt is rejected. The company may be named by
+// companyId instead. The status and the reservation and sale times belong
+// to the lifecycle transitions: they are kept, and may only be restated.
+func ReplaceCar(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carId, err := parseCarID(c)
+		if err != nil {
+			return err
+		}
+
+		var requestBody entities.Car
+		err = c.BodyParser(&requestBody)
+
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if requestBody.MadeAt.IsZero() {
+			return &cars.ValidationError{Fields: []validation.FieldError{{Field: "madeAt", Message: "is required"}}}
+		}
+
+		patch := &entities.CarPatch{
+			VIN:        &requestBody.VIN,
+			CarName:    &requestBody.CarName,
+			CompanyID:  supplied(&requestBody.CompanyID),
+			Company:    &requestBody.Company,
+			ModelYear:  &requestBody.ModelYear,
+			Trim:       &requestBody.Trim,
+			Color:      &requestBody.Color,
+			Mileage:    &requestBody.Mileage,
+			ListPrice:  &requestBody.ListPrice,
+			Currency:   &requestBody.Currency,
+			Condition:  &requestBody.Condition,
+			Status:     supplied(&requestBody.Status),
+			MadeAt:     &requestBody.MadeAt,
+			ReservedAt: supplied(&requestBody.ReservedAt),
+			SoldAt:     supplied(&requestBody.SoldAt),
+		}
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.UpdateCarService(c.UserContext(), carId, version, patch)
+
+		if err != nil {
+			return err
+		}
+
+		setETag(c, result)
+		return c.JSON(presenters.CarSuccessResponse(result))
+	}
+}
+
+// supplied returns value, or nil when the request body left it empty.
+func supplied[T comparable](value *T) *T {
+	var zero T
+	if *value == zero {
+		return nil
+	}
+	return value
+}
+
+// PatchCar applies a JSON Merge Patch (RFC 7396) to the car named in the
+// path. Optional fields are cleared by setting them to their empty value;
+// members set to null are rejected, since required and server managed
+// fields cannot be removed. Members that are not car fields, such as the
+// ID, are ignored.
+func PatchCar(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carId, err := parseCarID(c)
+		if err != nil {
+			return err
+		}
+
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(c.Body(), &members); err != nil || members == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "merge patch must be a JSON object")
+		}
+
+		var removed []validation.FieldError
+		for name, value := range members {
+			if string(value) == "null" {
+				removed = append(removed, validation.FieldError{Field: name, Message: "cannot be removed"})
+			}
+		}
+		if len(removed) > 0 {
+			sort.Slice(removed, func(i, j int) bool { return removed[i].Field < removed[j].Field })
+			return &cars.ValidationError{Fields: removed}
+		}
+
+		var patch entities.CarPatch
+		if err := json.Unmarshal(c.Body(), &patch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.UpdateCarService(c.UserContext(), carId, version, &patch)
+		if err != nil {
+			return err
+		}
+
+		setETag(c, result)
+		return c.JSON(presenters.CarSuccessResponse(result))
+	}
+}
 
+// TransitionCar moves the car named in the path one step along its sale
+// lifecycle, as in POST /cars/:id/sell. A step the car's status does not
+// allow fails with 409 Conflict.
+func TransitionCar(service cars.Service, transition cars.Transition) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carId, err := parseCarID(c)
+		if err != nil {
+			return err
+		}
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.TransitionCarService(c.UserContext(), carId, version, transition)
+		if err != nil {
+			return err
+		}
+
+		setETag(c, result)
+		return c.JSON(presenters.CarSuccessResponse(result))
+	}
+}
+
+func RemoveCarByID(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carId, err := parseCarID(c)
 		if err != nil {
-			c.Status(http.StatusInternalServerError)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return err
+		}
+
+		version, err := ifMatchVersion(c)
+		if err != nil {
+			return err
+		}
+
+		err = service.RemoveCarService(c.UserContext(), carId, version)
+
+		if err != nil {
+			return err
 		}
 
 		return c.JSON(&fiber.Map{
 			"status": true,
-			"data":   "updated succesfully",
-			"err":    nil,
+			"data":   "deleted successfully",
+			"error":  nil,
 		})
 	}
 }
 
+// SearchCars finds cars by the words of their name and company, tolerating
+// typos, best match first. Each hit carries the fields that matched, with
+// the matching words in <em> tags.
+func SearchCars(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		search := &entities.CarSearch{Query: strings.TrimSpace(c.Query("q"))}
+		if search.Query == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "q is required")
+		}
+
+		var err error
+		if search.Limit, search.Offset, err = parsePaging(c); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		page, err := service.SearchCarsService(c.UserContext(), search)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.SearchSuccessResponse(page))
+	}
+}
+
 func GetCars(service cars.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		fetched, err := service.CheckCarService()
+		query, err := parseCarQuery(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		fetched, err := service.CheckCarService(c.UserContext(), query)
 		if err != nil {
-			c.Status(http.StatusInternalServerError)
-			return c.JSON(presenters.CarErrorResponse(err))
+			return err
 		}
 		return c.JSON(presenters.CarsSuccessResponse(fetched))
 	}
 }
+
+// parseCarID validates the car ID in the path, so malformed IDs are
+// rejected before they reach the database.
+func parseCarID(c *fiber.Ctx) (entities.CarID, error) {
+	carId, err := entities.ParseCarID(c.Params("id"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", cars.ErrInvalidID, err)
+	}
+	return carId, nil
+}
+
+// parseCarQuery reads the paging, filtering, sorting and embedding
+// parameters of GET /cars.
+func parseCarQuery(c *fiber.Ctx) (*entities.CarQuery, error) {
+	query := &entities.CarQuery{
+		Company:       c.Query("company"),
+		CarNamePrefix: c.Query("carName"),
+		Status:        entities.InventoryStatus(c.Query("status")),
+	}
+
+	if raw := c.Query("companyId"); raw != "" {
+		companyId, err := entities.ParseCompanyID(raw)
+		if err != nil {
+			return nil, err
+		}
+		query.CompanyID = companyId
+	}
+
+	switch embed := c.Query("embed"); embed {
+	case "":
+	case "company":
+		query.EmbedCompany = true
+	default:
+		return nil, fmt.Errorf("cannot embed %q", embed)
+	}
+
+	switch query.Status {
+	case "", entities.InStock, entities.Reserved, entities.Sold, entities.Returned, entities.Archived:
+	default:
+		return nil, fmt.Errorf("unknown status %q", query.Status)
+	}
+
+	var err error
+	if query.Limit, query.Offset, err = parsePaging(c); err != nil {
+		return nil, err
+	}
+
+	for _, param := range []struct {
+		key string
+		dst *time.Time
+	}{
+		{"madeFrom", &query.MadeFrom},
+		{"madeTo", &query.MadeTo},
+		{"soldFrom", &query.SoldFrom},
+		{"soldTo", &query.SoldTo},
+	} {
+		if *param.dst, err = parseTime(c, param.key); err != nil {
+			return nil, err
+		}
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		query.SortBy = strings.TrimPrefix(sort, "-")
+		query.Descending = strings.HasPrefix(sort, "-")
+		if !cars.IsSortField(query.SortBy) {
+			return nil, fmt.Errorf("cannot sort by %q", query.SortBy)
+		}
+	}
+
+	return query, nil
+}
+
+// parsePaging reads the limit and offset of a paged endpoint, the offset
+// either as such or as the cursor handed out with the previous page.
+func parsePaging(c *fiber.Ctx) (limit, offset int64, err error) {
+	if limit, err = parseInt(c, "limit"); err != nil {
+		return 0, 0, err
+	}
+	if offset, err = parseInt(c, "offset"); err != nil {
+		return 0, 0, err
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if c.Query("offset") != "" {
+			return 0, 0, errors.New("cursor and offset cannot be combined")
+		}
+		if offset, err = cars.DecodeCursor(cursor); err != nil {
+			return 0, 0, err
+		}
+	}
+	return limit, offset, nil
+}
+
+func parseInt(c *fiber.Ctx, key string) (int64, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return value, nil
+}
+
+// parseTime accepts RFC 3339 timestamps as well as plain dates.
+func parseTime(c *fiber.Ctx, key string) (time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if value, err := time.Parse(layout, raw); err == nil {
+			return value, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a date", key)
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/cars"
 	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -18,8 +24,8 @@ type mockService struct {
 	mock.Mock
 }
 
-func (m *mockService) InsertCarService(car *entities.Car) (*entities.Car, error) {
-	args := m.Called(car)
+func (m *mockService) InsertCarService(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	args := m.Called(ctx, car)
 	result := args.Get(0)
 	err := args.Error(1)
 	if result != nil {
@@ -28,8 +34,8 @@ func (m *mockService) InsertCarService(car *entities.Car) (*entities.Car, error)
 	return nil, err
 }
 
-func (m *mockService) UpdateCarService(car *entities.Car) (*entities.Car, error) {
-	args := m.Called(car)
+func (m *mockService) UpdateCarService(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	args := m.Called(ctx, ID, version, patch)
 	result := args.Get(0)
 	err := args.Error(1)
 	if result != nil {
@@ -38,17 +44,83 @@ func (m *mockService) UpdateCarService(car *entities.Car) (*entities.Car, error)
 	return nil, err
 }
 
-func (m *mockService) RemoveCarService(ID string) error {
-	args := m.Called(ID)
+func (m *mockService) GetCarByIDService(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	args := m.Called(ctx, ID)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Car), err
+	}
+	return nil, err
+}
+
+func (m *mockService) TransitionCarService(ctx context.Context, ID entities.CarID, version int64, transition cars.Transition) (*entities.Car, error) {
+	args := m.Called(ctx, ID, version, transition)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Car), err
+	}
+	return nil, err
+}
+
+func (m *mockService) RemoveCarService(ctx context.Context, ID entities.CarID, version int64) error {
+	args := m.Called(ctx, ID, version)
 	return args.Error(0)
 }
 
-func (m *mockService) CheckCarService() (*[]entities.Car, error) {
-	args := m.Called()
+func (m *mockService) CheckCarService(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	args := m.Called(ctx, query)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.CarPage), err
+	}
+	return nil, err
+}
+
+// ScanCarsService visits the cars given to Return, then returns its error.
+func (m *mockService) ScanCarsService(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	args := m.Called(ctx, query)
+	list, _ := args.Get(0).([]entities.Car)
+	for i := range list {
+		if err := visit(&list[i]); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *mockService) SearchCarsService(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	args := m.Called(ctx, search)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.SearchPage), err
+	}
+	return nil, err
+}
+
+func (m *mockService) InsertCarsService(ctx context.Context, batch []*entities.Car, atomic bool) ([]cars.BatchResult, error) {
+	args := m.Called(ctx, batch, atomic)
+	return batchResults(args)
+}
+
+func (m *mockService) UpdateCarsService(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]cars.BatchResult, error) {
+	args := m.Called(ctx, updates, atomic)
+	return batchResults(args)
+}
+
+func (m *mockService) RemoveCarsService(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]cars.BatchResult, error) {
+	args := m.Called(ctx, deletes, atomic)
+	return batchResults(args)
+}
+
+func batchResults(args mock.Arguments) ([]cars.BatchResult, error) {
 	result := args.Get(0)
 	err := args.Error(1)
 	if result != nil {
-		return result.(*[]entities.Car), err
+		return result.([]cars.BatchResult), err
 	}
 	return nil, err
 }
@@ -69,10 +141,10 @@ func TestAddBookHandler(t *testing.T) {
 		},
 		{
 			//failed test case 2
-			description:  "postHTTP500",
+			description:  "postHTTP422",
 			route:        "/cars",
 			requestBody:  `{}`,
-			expectedCode: 500,
+			expectedCode: 422,
 		},
 		{
 			//failed test case 3
@@ -88,14 +160,20 @@ func TestAddBookHandler(t *testing.T) {
 			mockService := new(mockService)
 			handler := AddCar(mockService)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Post(test.route, handler)
 
 			if test.expectedCode == 200 {
 				var requestBody *entities.Car
 				err := json.Unmarshal([]byte(test.requestBody), &requestBody)
 				require.NoError(t, err)
-				mockService.On("InsertCarService", requestBody).Return(requestBody, nil)
+				mockService.On("InsertCarService", mock.Anything, requestBody).Return(requestBody, nil)
+			}
+
+			if test.expectedCode == 422 {
+				mockService.On("InsertCarService", mock.Anything, mock.Anything).Return(nil, &cars.ValidationError{
+					Fields: []validation.FieldError{{Field: "carName", Message: "is required"}},
+				})
 			}
 
 			req := httptest.NewRequest(http.MethodPost, test.route, strings.NewReader(test.requestBody))
@@ -119,7 +197,7 @@ func TestUpdateCarHandler(t *testing.T) {
 			//success test case
 			description:  "putHTTP200",
 			route:        "/cars",
-			requestBody:  `{"carName":"Mazda","company":"Toyota"}`,
+			requestBody:  `{"id":"64a4c6181955b6923fff02b5","carName":"Mazda"}`,
 			expectedCode: 200,
 		},
 		{
@@ -147,13 +225,17 @@ func TestUpdateCarHandler(t *testing.T) {
 			mockService := new(mockService)
 			handler := UpdateCar(mockService)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Put(test.route, handler)
 
-			var requestBody *entities.Car
-			err := json.Unmarshal([]byte(test.requestBody), &requestBody)
-			require.NoError(t, err)
-			mockService.On("UpdateCarService", requestBody).Return(requestBody, nil)
+			if test.expectedCode == 200 {
+				var requestBody entities.UpdateRequest
+				err := json.Unmarshal([]byte(test.requestBody), &requestBody)
+				require.NoError(t, err)
+				// Only the supplied field is passed on; company stays unset.
+				require.Nil(t, requestBody.Company)
+				mockService.On("UpdateCarService", mock.Anything, requestBody.ID, int64(0), &requestBody.CarPatch).Return(&entities.Car{}, nil)
+			}
 
 			req := httptest.NewRequest(http.MethodPut, test.route, strings.NewReader(test.requestBody))
 			req.Header.Set("Content-Type", "application/json")
@@ -186,7 +268,7 @@ func TestRemoveCarHandler(t *testing.T) {
 			mockService := new(mockService)
 			handler := RemoveCar(mockService)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Delete(test.route, handler)
 
 			if test.expectedCode == 200 {
@@ -194,7 +276,7 @@ func TestRemoveCarHandler(t *testing.T) {
 				err := json.Unmarshal([]byte(test.requestBody), &requestBody)
 				require.NoError(t, err)
 
-				mockService.On("RemoveCarService", requestBody).Return(nil)
+				mockService.On("RemoveCarService", mock.Anything, requestBody.ID, int64(0)).Return(nil)
 			}
 
 			req := httptest.NewRequest(http.MethodDelete, test.route, strings.NewReader(test.requestBody))
@@ -225,7 +307,7 @@ func TestGetCarHandle(t *testing.T) {
 			mockService := new(mockService)
 			handler := GetCars(mockService)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Get(test.route, handler)
 
 			cars := []entities.Car{
@@ -233,7 +315,315 @@ func TestGetCarHandle(t *testing.T) {
 				{CarName: "Car 2"},
 			}
 
-			mockService.On("CheckCarService").Return(&cars, nil)
+			mockService.On("CheckCarService", mock.Anything, mock.Anything).Return(&entities.CarPage{Cars: cars}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, test.route, nil)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCarHandlerTimeout(t *testing.T) {
+	mockService := new(mockService)
+	mockService.On("CheckCarService", mock.Anything, mock.Anything).Return(nil, context.DeadlineExceeded)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/cars", RequestTimeout(time.Second), GetCars(mockService))
+
+	req := httptest.NewRequest(http.MethodGet, "/cars", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestRequestTimeoutSetsDeadline(t *testing.T) {
+	mockService := new(mockService)
+	mockService.On("CheckCarService", mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	}), mock.Anything).Return(&entities.CarPage{}, nil)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/cars", RequestTimeout(time.Second), GetCars(mockService))
+
+	req := httptest.NewRequest(http.MethodGet, "/cars", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetCarsQueryParams(t *testing.T) {
+	madeFrom := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description   string
+		target        string
+		expectedCode  int
+		expectedQuery *entities.CarQuery
+	}{
+		{
+			description:  "filtersAndSort",
+			target:       "/cars?limit=5&offset=10&company=Mazda&carName=CX&status=in_stock&madeFrom=2023-01-01&sort=-madeAt",
+			expectedCode: 200,
+			expectedQuery: &entities.CarQuery{
+				Limit:         5,
+				Offset:        10,
+				Company:       "Mazda",
+				CarNamePrefix: "CX",
+				Status:        entities.InStock,
+				MadeFrom:      madeFrom,
+				SortBy:        "madeAt",
+				Descending:    true,
+			},
+		},
+		{
+			description:   "sortByListPrice",
+			target:        "/cars?sort=listPrice",
+			expectedCode:  200,
+			expectedQuery: &entities.CarQuery{SortBy: "listPrice"},
+		},
+		{
+			description:   "cursor",
+			target:        "/cars?cursor=" + cars.EncodeCursor(40),
+			expectedCode:  200,
+			expectedQuery: &entities.CarQuery{Offset: 40},
+		},
+		{
+			description:  "badLimit",
+			target:       "/cars?limit=-1",
+			expectedCode: 400,
+		},
+		{
+			description:  "badSortField",
+			target:       "/cars?sort=horsepower",
+			expectedCode: 400,
+		},
+		{
+			description:  "badStatus",
+			target:       "/cars?status=lost",
+			expectedCode: 400,
+		},
+		{
+			description:  "badTime",
+			target:       "/cars?soldTo=yesterday",
+			expectedCode: 400,
+		},
+		{
+			description:  "cursorWithOffset",
+			target:       "/cars?offset=1&cursor=" + cars.EncodeCursor(40),
+			expectedCode: 400,
+		},
+		{
+			description:   "companyIdAndEmbed",
+			target:        "/cars?companyId=64A4C6181955B6923FFF0001&embed=company",
+			expectedCode:  200,
+			expectedQuery: &entities.CarQuery{CompanyID: "64a4c6181955b6923fff0001", EmbedCompany: true},
+		},
+		{
+			description:  "badCompanyId",
+			target:       "/cars?companyId=toyota",
+			expectedCode: 400,
+		},
+		{
+			description:  "badEmbed",
+			target:       "/cars?embed=owner",
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/cars", GetCars(mockService))
+
+			if test.expectedQuery != nil {
+				mockService.On("CheckCarService", mock.Anything, test.expectedQuery).Return(&entities.CarPage{
+					Total:      100,
+					NextCursor: "next",
+				}, nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			mockService.AssertExpectations(t)
+
+			if test.expectedCode == 200 {
+				var body struct {
+					Data []entities.Car
+					Meta struct {
+						Total      int64
+						NextCursor string
+					}
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.NotNil(t, body.Data)
+				assert.Equal(t, int64(100), body.Meta.Total)
+				assert.Equal(t, "next", body.Meta.NextCursor)
+			}
+		})
+	}
+}
+
+func TestGetCarsEmbedsCompanies(t *testing.T) {
+	toyota := entities.Company{ID: "64a4c6181955b6923fff0001", Name: "Toyota", Country: "JP", Version: 1}
+	mockService := new(mockService)
+	mockService.On("CheckCarService", mock.Anything, mock.Anything).Return(&entities.CarPage{
+		Cars: []entities.Car{
+			{ID: "64a4c6181955b6923fff02b5", CarName: "Corolla", CompanyID: toyota.ID, Company: "Toyota"},
+			{ID: "64a4c6181955b6923fff02b6", CarName: "Civic", Company: "Honda"},
+		},
+		Total:     2,
+		Companies: map[entities.CompanyID]entities.Company{toyota.ID: toyota},
+	}, nil)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/cars", GetCars(mockService))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/cars?embed=company", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []struct {
+			ID        entities.CarID
+			CompanyID entities.CompanyID
+			Embedded  *struct {
+				Company struct {
+					ID      entities.CompanyID
+					Name    string
+					Country string
+				}
+			} `json:"_embedded"`
+		}
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 2)
+	assert.Equal(t, toyota.ID, body.Data[0].CompanyID)
+	require.NotNil(t, body.Data[0].Embedded)
+	assert.Equal(t, "Toyota", body.Data[0].Embedded.Company.Name)
+	assert.Equal(t, "JP", body.Data[0].Embedded.Company.Country)
+	assert.Nil(t, body.Data[1].Embedded)
+}
+
+func TestSearchCarsHandler(t *testing.T) {
+	tests := []struct {
+		description    string
+		target         string
+		expectedCode   int
+		expectedSearch *entities.CarSearch
+	}{
+		{
+			description:    "paged",
+			target:         "/cars/search?q=mazda+cx&limit=5&cursor=" + cars.EncodeCursor(10),
+			expectedCode:   200,
+			expectedSearch: &entities.CarSearch{Query: "mazda cx", Limit: 5, Offset: 10},
+		},
+		{
+			description:  "missingQuery",
+			target:       "/cars/search?q=+",
+			expectedCode: 400,
+		},
+		{
+			description:  "badLimit",
+			target:       "/cars/search?q=mazda&limit=x",
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+			if test.expectedSearch != nil {
+				mockService.On("SearchCarsService", mock.Anything, test.expectedSearch).Return(&entities.SearchPage{
+					Hits: []entities.SearchHit{{
+						Car:        entities.Car{ID: "64a4c6181955b6923fff02b5", CarName: "CX-5", Company: "Mazda"},
+						Score:      2.5,
+						Highlights: map[string]string{"carName": "<em>CX</em>-5"},
+					}},
+					Total: 11,
+				}, nil)
+			}
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/cars/search", SearchCars(mockService))
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, test.target, nil))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+
+			if test.expectedCode == 200 {
+				var body struct {
+					Data []struct {
+						Car        entities.Car      `json:"car"`
+						Score      float64           `json:"score"`
+						Highlights map[string]string `json:"highlights"`
+					} `json:"data"`
+					Meta struct {
+						Total int64 `json:"total"`
+					} `json:"meta"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				require.Len(t, body.Data, 1)
+				assert.Equal(t, "CX-5", body.Data[0].Car.CarName)
+				assert.Equal(t, 2.5, body.Data[0].Score)
+				assert.Equal(t, "<em>CX</em>-5", body.Data[0].Highlights["carName"])
+				assert.Equal(t, int64(11), body.Meta.Total)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCarByIDHandler(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+
+	tests := []struct {
+		description  string
+		route        string
+		serviceErr   error
+		expectedCode int
+	}{
+		{
+			description:  "GetHTTP200",
+			route:        "/cars/" + carID.String(),
+			expectedCode: 200,
+		},
+		{
+			description:  "GetHTTP404",
+			route:        "/cars/" + carID.String(),
+			serviceErr:   cars.ErrCarNotFound,
+			expectedCode: 404,
+		},
+		{
+			description:  "GetHTTP400",
+			route:        "/cars/not-an-id",
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/cars/:id", GetCar(mockService))
+
+			if test.expectedCode != 400 {
+				var car *entities.Car
+				if test.serviceErr == nil {
+					car = &entities.Car{ID: carID, CarName: "CX-5", Company: "Mazda"}
+				}
+				mockService.On("GetCarByIDService", mock.Anything, carID).Return(car, test.serviceErr)
+			}
 
 			req := httptest.NewRequest(http.MethodGet, test.route, nil)
 			resp, _ := app.Test(req)
@@ -243,3 +633,432 @@ func TestGetCarHandle(t *testing.T) {
 		})
 	}
 }
+
+func TestReplaceCarHandler(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+
+	tests := []struct {
+		description  string
+		route        string
+		requestBody  string
+		serviceErr   error
+		expectedCode int
+	}{
+		{
+			description:  "putHTTP200",
+			route:        "/cars/" + carID.String(),
+			requestBody:  `{"carName":"CX-5","company":"Mazda","trim":"","madeAt":"2020-01-02T00:00:00Z"}`,
+			expectedCode: 200,
+		},
+		{
+			description:  "putHTTP404",
+			route:        "/cars/" + carID.String(),
+			requestBody:  `{"carName":"CX-5","company":"Mazda","trim":"","madeAt":"2020-01-02T00:00:00Z"}`,
+			serviceErr:   cars.ErrCarNotFound,
+			expectedCode: 404,
+		},
+		{
+			description:  "putMissingFieldHTTP422",
+			route:        "/cars/" + carID.String(),
+			requestBody:  `{"carName":"CX-5","company":"Mazda","trim":"","madeAt":"2020-01-02T00:00:00Z"}`,
+			serviceErr:   &cars.ValidationError{Fields: []validation.FieldError{{Field: "company", Message: "is required"}}},
+			expectedCode: 422,
+		},
+		{
+			description:  "putConflictHTTP409",
+			route:        "/cars/" + carID.String(),
+			requestBody:  `{"carName":"CX-5","company":"Mazda","trim":"","madeAt":"2020-01-02T00:00:00Z"}`,
+			serviceErr:   cars.ErrConflict,
+			expectedCode: 409,
+		},
+		{
+			description:  "putUnavailableHTTP503",
+			route:        "/cars/" + carID.String(),
+			requestBody:  `{"carName":"CX-5","company":"Mazda","trim":"","madeAt":"2020-01-02T00:00:00Z"}`,
+			serviceErr:   cars.ErrUnavailable,
+			expectedCode: 503,
+		},
+		{
+			description:  "putBadIDHTTP400",
+			route:        "/cars/123",
+			requestBody:  `{"carName":"CX-5","company":"Mazda","trim":"","madeAt":"2020-01-02T00:00:00Z"}`,
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Put("/cars/:id", ReplaceCar(mockService))
+
+			if test.expectedCode != 400 {
+				name, company := "CX-5", "Mazda"
+				madeAt := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+				expected := replacement(entities.Car{CarName: name, Company: company, MadeAt: madeAt})
+				var result *entities.Car
+				if test.serviceErr == nil {
+					result = &entities.Car{ID: carID, CarName: name, Company: company}
+				}
+				mockService.On("UpdateCarService", mock.Anything, carID, int64(0), expected).Return(result, test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPut, test.route, strings.NewReader(test.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReplaceCarHandlerRequiresMadeAt(t *testing.T) {
+	mockService := new(mockService)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Put("/cars/:id", ReplaceCar(mockService))
+
+	req := httptest.NewRequest(http.MethodPut, "/cars/64a4c6181955b6923fff02b5", strings.NewReader(`{"carName":"CX-5","company":"Mazda"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", presenters.ProblemContentType)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	var problem presenters.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, []validation.FieldError{{Field: "madeAt", Message: "is required"}}, problem.Errors)
+	mockService.AssertExpectations(t)
+}
+
+// replacement is the patch ReplaceCar sends for car: every field a client
+// owns is set, so that the ones car leaves empty are cleared.
+func replacement(car entities.Car) *entities.CarPatch {
+	return &entities.CarPatch{
+		VIN:       &car.VIN,
+		CarName:   &car.CarName,
+		Company:   &car.Company,
+		ModelYear: &car.ModelYear,
+		Trim:      &car.Trim,
+		Color:     &car.Color,
+		Mileage:   &car.Mileage,
+		ListPrice: &car.ListPrice,
+		Currency:  &car.Currency,
+		Condition: &car.Condition,
+		MadeAt:    &car.MadeAt,
+	}
+}
+
+func TestPatchCarHandler(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+
+	t.Run("patchHTTP200", func(t *testing.T) {
+		mockService := new(mockService)
+
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Patch("/cars/:id", PatchCar(mockService))
+
+		company := "Toyota"
+		expected := &entities.CarPatch{Company: &company}
+		mockService.On("UpdateCarService", mock.Anything, carID, int64(0), expected).Return(
+			&entities.Car{ID: carID, CarName: "CX-5", Company: "Toyota"}, nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/cars/"+carID.String(),
+			strings.NewReader(`{"company":"Toyota","id":"000000000000000000000000"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, 200, resp.StatusCode)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("patchHTTP404", func(t *testing.T) {
+		mockService := new(mockService)
+
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Patch("/cars/:id", PatchCar(mockService))
+
+		mockService.On("UpdateCarService", mock.Anything, carID, int64(0), mock.Anything).Return(nil, cars.ErrCarNotFound)
+
+		req := httptest.NewRequest(http.MethodPatch, "/cars/"+carID.String(), strings.NewReader(`{"company":"Toyota"}`))
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, 404, resp.StatusCode)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("patchRemoveHTTP422", func(t *testing.T) {
+		mockService := new(mockService)
+
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Patch("/cars/:id", PatchCar(mockService))
+
+		req := httptest.NewRequest(http.MethodPatch, "/cars/"+carID.String(), strings.NewReader(`{"company":null}`))
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, 422, resp.StatusCode)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("patchHTTP400", func(t *testing.T) {
+		for _, body := range []string{`[]`, `null`, `{"carName":`, `{"carName":5}`} {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Patch("/cars/:id", PatchCar(mockService))
+
+			req := httptest.NewRequest(http.MethodPatch, "/cars/"+carID.String(), strings.NewReader(body))
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, 400, resp.StatusCode, body)
+			mockService.AssertExpectations(t)
+		}
+	})
+}
+
+func TestRemoveCarByIDHandler(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+
+	tests := []struct {
+		description  string
+		route        string
+		serviceErr   error
+		expectedCode int
+	}{
+		{
+			description:  "DeleteHTTP200",
+			route:        "/cars/" + carID.String(),
+			expectedCode: 200,
+		},
+		{
+			description:  "DeleteHTTP404",
+			route:        "/cars/" + carID.String(),
+			serviceErr:   cars.ErrCarNotFound,
+			expectedCode: 404,
+		},
+		{
+			description:  "DeleteHTTP400",
+			route:        "/cars/xyz",
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Delete("/cars/:id", RemoveCarByID(mockService))
+
+			if test.expectedCode != 400 {
+				mockService.On("RemoveCarService", mock.Anything, carID, int64(0)).Return(test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, test.route, nil)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTransitionCarHandler(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+	soldAt := time.Date(2023, 7, 5, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description  string
+		route        string
+		ifMatch      string
+		version      int64
+		serviceErr   error
+		expectedCode int
+		problemType  string
+	}{
+		{
+			description:  "sellHTTP200",
+			route:        "/cars/" + carID.String() + "/sell",
+			ifMatch:      `"3"`,
+			version:      3,
+			expectedCode: 200,
+		},
+		{
+			description:  "sellHTTP409",
+			route:        "/cars/" + carID.String() + "/sell",
+			serviceErr:   fmt.Errorf("%w: cannot sell a car that is sold", cars.ErrInvalidTransition),
+			expectedCode: 409,
+			problemType:  "urn:testingfiber:problem:invalid-transition",
+		},
+		{
+			description:  "sellHTTP412",
+			route:        "/cars/" + carID.String() + "/sell",
+			serviceErr:   cars.ErrVersionMismatch,
+			expectedCode: 412,
+			problemType:  "urn:testingfiber:problem:version-mismatch",
+		},
+		{
+			description:  "sellHTTP400",
+			route:        "/cars/xyz/sell",
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("/cars/:id/sell", TransitionCar(mockService, cars.Sell))
+
+			if test.expectedCode != 400 {
+				var result *entities.Car
+				if test.serviceErr == nil {
+					result = &entities.Car{ID: carID, CarName: "CX-5", Company: "Mazda", Status: entities.Sold, SoldAt: soldAt, Version: 4}
+				}
+				mockService.On("TransitionCarService", mock.Anything, carID, test.version, cars.Sell).Return(result, test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, test.route, nil)
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			var body map[string]json.RawMessage
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			if test.expectedCode == 200 {
+				assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
+				assert.JSONEq(t, `{"id":"64a4c6181955b6923fff02b5","carName":"CX-5","company":"Mazda","mileage":0,"status":"sold","soldAt":"2023-07-05T10:00:00Z","version":4}`, string(body["data"]))
+			}
+			if test.problemType != "" {
+				assert.Contains(t, string(body["error"]), test.problemType)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReleaseCarHandler(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+
+	tests := []struct {
+		description  string
+		serviceErr   error
+		expectedCode int
+	}{
+		{description: "releaseHTTP200", expectedCode: 200},
+		{
+			description:  "releaseHTTP409",
+			serviceErr:   fmt.Errorf("%w: cannot release a car that is in stock", cars.ErrInvalidTransition),
+			expectedCode: 409,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("/cars/:id/release", TransitionCar(mockService, cars.Release))
+
+			var result *entities.Car
+			if test.serviceErr == nil {
+				result = &entities.Car{ID: carID, CarName: "CX-5", Company: "Mazda", Status: entities.InStock, Version: 5}
+			}
+			mockService.On("TransitionCarService", mock.Anything, carID, int64(0), cars.Release).Return(result, test.serviceErr)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/cars/"+carID.String()+"/release", nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == 200 {
+				var body map[string]json.RawMessage
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.JSONEq(t, `{"id":"64a4c6181955b6923fff02b5","carName":"CX-5","company":"Mazda","mileage":0,"status":"in_stock","version":5}`, string(body["data"]))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCarSetsETag(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+	mockService := new(mockService)
+	mockService.On("GetCarByIDService", mock.Anything, carID).Return(
+		&entities.Car{ID: carID, CarName: "CX-5", Company: "Mazda", Version: 7}, nil)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/cars/:id", GetCar(mockService))
+
+	req := httptest.NewRequest(http.MethodGet, "/cars/"+carID.String(), nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"7"`, resp.Header.Get("ETag"))
+	mockService.AssertExpectations(t)
+}
+
+func TestIfMatchHeader(t *testing.T) {
+	carID := entities.CarID("64a4c6181955b6923fff02b5")
+
+	tests := []struct {
+		description     string
+		ifMatch         string
+		expectedVersion int64
+		serviceErr      error
+		expectedCode    int
+	}{
+		{description: "noHeader", expectedVersion: 0, expectedCode: 200},
+		{description: "wildcard", ifMatch: "*", expectedVersion: 0, expectedCode: 200},
+		{description: "current", ifMatch: `"7"`, expectedVersion: 7, expectedCode: 200},
+		{description: "stale", ifMatch: `"6"`, expectedVersion: 6, serviceErr: cars.ErrVersionMismatch, expectedCode: 412},
+		{description: "weak", ifMatch: `W/"7"`, expectedCode: 412},
+		{description: "notAVersion", ifMatch: `"abc"`, expectedCode: 412},
+		{description: "list", ifMatch: `"6", "7"`, expectedCode: 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Patch("/cars/:id", PatchCar(mockService))
+			app.Delete("/cars/:id", RemoveCarByID(mockService))
+
+			callsService := test.expectedCode == 200 || test.serviceErr != nil
+			if callsService {
+				var result *entities.Car
+				if test.serviceErr == nil {
+					result = &entities.Car{ID: carID, Version: 8}
+				}
+				mockService.On("UpdateCarService", mock.Anything, carID, test.expectedVersion, mock.Anything).Return(result, test.serviceErr)
+				mockService.On("RemoveCarService", mock.Anything, carID, test.expectedVersion).Return(test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/cars/"+carID.String(), strings.NewReader(`{"carName":"CX-9"}`))
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			resp, _ := app.Test(req)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == 200 {
+				assert.Equal(t, `"8"`, resp.Header.Get("ETag"))
+			}
+
+			req = httptest.NewRequest(http.MethodDelete, "/cars/"+carID.String(), nil)
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			resp, _ = app.Test(req)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+
+			if callsService {
+				mockService.AssertExpectations(t)
+			}
+		})
+	}
+}
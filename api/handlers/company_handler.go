@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AddCompany(service companies.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody entities.Company
+		if err := c.BodyParser(&requestBody); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		result, err := service.InsertCompanyService(c.UserContext(), &requestBody)
+		if err != nil {
+			return err
+		}
+
+		setVersionETag(c, result.Version)
+		return c.JSON(presenters.CompanySuccessResponse(result))
+	}
+}
+
+// GetCompanies lists companies by name. The name parameter matches a
+// prefix of the name, regardless of case; country matches exactly.
+func GetCompanies(service companies.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query := &entities.CompanyQuery{
+			NamePrefix: c.Query("name"),
+			Country:    c.Query("country"),
+		}
+
+		var err error
+		if query.Limit, err = parseInt(c, "limit"); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if query.Offset, err = parseInt(c, "offset"); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		page, err := service.ListCompaniesService(c.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.CompaniesSuccessResponse(page))
+	}
+}
+
+func GetCompany(service companies.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		companyId, err := parseCompanyID(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.GetCompanyByIDService(c.UserContext(), companyId)
+		if err != nil {
+			return err
+		}
+
+		setVersionETag(c, result.Version)
+		return c.JSON(presenters.CompanySuccessResponse(result))
+	}
+}
+
+// PatchCompany changes the fields supplied in the body of the company
+// named in the path. A new name is carried over to the company's cars.
+func PatchCompany(service companies.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		companyId, err := parseCompanyID(c)
+		if err != nil {
+			return err
+		}
+
+		var patch entities.CompanyPatch
+		if err := json.Unmarshal(c.Body(), &patch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		version, err := ifMatch(c, companies.ErrVersionMismatch)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.UpdateCompanyService(c.UserContext(), companyId, version, &patch)
+		if err != nil {
+			return err
+		}
+
+		setVersionETag(c, result.Version)
+		return c.JSON(presenters.CompanySuccessResponse(result))
+	}
+}
+
+// RemoveCompany deletes the company named in the path. Companies that
+// cars still reference cannot be deleted.
+func RemoveCompany(service companies.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		companyId, err := parseCompanyID(c)
+		if err != nil {
+			return err
+		}
+
+		version, err := ifMatch(c, companies.ErrVersionMismatch)
+		if err != nil {
+			return err
+		}
+
+		if err := service.RemoveCompanyService(c.UserContext(), companyId, version); err != nil {
+			return err
+		}
+
+		return c.JSON(&fiber.Map{
+			"status": true,
+			"data":   "deleted successfully",
+			"error":  nil,
+		})
+	}
+}
+
+func parseCompanyID(c *fiber.Ctx) (entities.CompanyID, error) {
+	companyId, err := entities.ParseCompanyID(c.Params("id"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", companies.ErrInvalidID, err)
+	}
+	return companyId, nil
+}
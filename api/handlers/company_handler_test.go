@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCompanyService struct {
+	mock.Mock
+}
+
+func (m *mockCompanyService) InsertCompanyService(ctx context.Context, company *entities.Company) (*entities.Company, error) {
+	args := m.Called(ctx, company)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Company), err
+	}
+	return nil, err
+}
+
+func (m *mockCompanyService) ListCompaniesService(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error) {
+	args := m.Called(ctx, query)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.CompanyPage), err
+	}
+	return nil, err
+}
+
+func (m *mockCompanyService) GetCompanyByIDService(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	args := m.Called(ctx, ID)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Company), err
+	}
+	return nil, err
+}
+
+func (m *mockCompanyService) UpdateCompanyService(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error) {
+	args := m.Called(ctx, ID, version, patch)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Company), err
+	}
+	return nil, err
+}
+
+func (m *mockCompanyService) RemoveCompanyService(ctx context.Context, ID entities.CompanyID, version int64) error {
+	args := m.Called(ctx, ID, version)
+	return args.Error(0)
+}
+
+func companyApp(service companies.Service) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/companies", GetCompanies(service))
+	app.Post("/companies", AddCompany(service))
+	app.Get("/companies/:id", GetCompany(service))
+	app.Patch("/companies/:id", PatchCompany(service))
+	app.Delete("/companies/:id", RemoveCompany(service))
+	return app
+}
+
+const companyID = entities.CompanyID("64a4c6181955b6923fff0001")
+
+func TestAddCompanyHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		requestBody  string
+		serviceErr   error
+		expectedCode int
+	}{
+		{description: "created", requestBody: `{"name":"Toyota","country":"JP"}`, expectedCode: 200},
+		{description: "malformed", requestBody: `{"name":`, expectedCode: 400},
+		{description: "invalid", requestBody: `{"name":"Toyota","country":"Japan"}`, serviceErr: &companies.ValidationError{Fields: []validation.FieldError{{Field: "country", Message: "is malformed"}}}, expectedCode: 422},
+		{description: "taken", requestBody: `{"name":"toyota"}`, serviceErr: companies.ErrConflict, expectedCode: 409},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockCompanyService)
+			if test.expectedCode != 400 {
+				var result *entities.Company
+				if test.serviceErr == nil {
+					result = &entities.Company{ID: companyID, Name: "Toyota", Country: "JP", Version: 1}
+				}
+				mockService.On("InsertCompanyService", mock.Anything, mock.Anything).Return(result, test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/companies", strings.NewReader(test.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := companyApp(mockService).Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == 200 {
+				assert.Equal(t, `"1"`, resp.Header.Get(fiber.HeaderETag))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCompaniesHandler(t *testing.T) {
+	mockService := new(mockCompanyService)
+	mockService.On("ListCompaniesService", mock.Anything, &entities.CompanyQuery{Limit: 5, NamePrefix: "to", Country: "JP"}).Return(&entities.CompanyPage{
+		Companies: []entities.Company{{ID: companyID, Name: "Toyota", Country: "JP", Version: 1}},
+		Total:     1,
+		Limit:     5,
+	}, nil)
+
+	resp, err := companyApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/companies?limit=5&name=to&country=JP", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []entities.Company
+		Meta struct{ Total, Limit int64 }
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, "Toyota", body.Data[0].Name)
+	assert.Equal(t, int64(1), body.Meta.Total)
+	assert.Equal(t, int64(5), body.Meta.Limit)
+	mockService.AssertExpectations(t)
+
+	resp, err = companyApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/companies?offset=x", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetCompanyHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		ID           string
+		serviceErr   error
+		expectedCode int
+		expectedType string
+	}{
+		{description: "found", ID: string(companyID), expectedCode: 200},
+		{description: "missing", ID: string(companyID), serviceErr: companies.ErrCompanyNotFound, expectedCode: 404, expectedType: "urn:testingfiber:problem:company-not-found"},
+		{description: "invalidID", ID: "toyota", expectedCode: 400, expectedType: "urn:testingfiber:problem:invalid-id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockCompanyService)
+			if test.expectedCode != 400 {
+				var result *entities.Company
+				if test.serviceErr == nil {
+					result = &entities.Company{ID: companyID, Name: "Toyota", Version: 3}
+				}
+				mockService.On("GetCompanyByIDService", mock.Anything, companyID).Return(result, test.serviceErr)
+			}
+
+			resp, err := companyApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/companies/"+test.ID, nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedType != "" {
+				var body struct{ Error presenters.Problem }
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, test.expectedType, body.Error.Type)
+			} else {
+				assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatchCompanyHandler(t *testing.T) {
+	name := "Toyota"
+
+	mockService := new(mockCompanyService)
+	mockService.On("UpdateCompanyService", mock.Anything, companyID, int64(2), &entities.CompanyPatch{Name: &name}).
+		Return(&entities.Company{ID: companyID, Name: "Toyota", Version: 3}, nil)
+
+	req := httptest.NewRequest(http.MethodPatch, "/companies/"+string(companyID), strings.NewReader(`{"name":"Toyota"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fiber.HeaderIfMatch, `"2"`)
+	resp, err := companyApp(mockService).Test(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+	mockService.AssertExpectations(t)
+
+	// A weak tag never matches, and is reported as a company mismatch.
+	req = httptest.NewRequest(http.MethodPatch, "/companies/"+string(companyID), strings.NewReader(`{"name":"Toyota"}`))
+	req.Header.Set(fiber.HeaderIfMatch, `W/"2"`)
+	resp, err = companyApp(mockService).Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+func TestRemoveCompanyHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		serviceErr   error
+		expectedCode int
+	}{
+		{description: "deleted", expectedCode: 200},
+		{description: "inUse", serviceErr: companies.ErrCompanyInUse, expectedCode: 409},
+		{description: "stale", serviceErr: companies.ErrVersionMismatch, expectedCode: 412},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockCompanyService)
+			mockService.On("RemoveCompanyService", mock.Anything, companyID, int64(0)).Return(test.serviceErr)
+
+			resp, err := companyApp(mockService).Test(httptest.NewRequest(http.MethodDelete, "/companies/"+string(companyID), nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestTimeout bounds every downstream handler with a deadline. The
+// deadline is attached to the request's user context, which the handlers
+// hand to the service layer, so a slow database call is cancelled instead
+// of holding the connection open. A non-positive timeout disables it.
+func RequestTimeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AddCustomer(service customers.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody entities.Customer
+		if err := c.BodyParser(&requestBody); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		result, err := service.InsertCustomerService(c.UserContext(), &requestBody)
+		if err != nil {
+			return err
+		}
+
+		setVersionETag(c, result.Version)
+		return c.JSON(presenters.CustomerSuccessResponse(result))
+	}
+}
+
+// GetCustomers lists customers by name. The name parameter matches a
+// prefix of the name and email the whole email, both regardless of case.
+func GetCustomers(service customers.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query := &entities.CustomerQuery{
+			NamePrefix: c.Query("name"),
+			Email:      c.Query("email"),
+		}
+
+		var err error
+		if query.Limit, err = parseInt(c, "limit"); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if query.Offset, err = parseInt(c, "offset"); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		page, err := service.ListCustomersService(c.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.CustomersSuccessResponse(page))
+	}
+}
+
+func GetCustomer(service customers.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customerId, err := parseCustomerID(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.GetCustomerByIDService(c.UserContext(), customerId)
+		if err != nil {
+			return err
+		}
+
+		setVersionETag(c, result.Version)
+		return c.JSON(presenters.CustomerSuccessResponse(result))
+	}
+}
+
+// PatchCustomer changes the fields supplied in the body of the customer
+// named in the path.
+func PatchCustomer(service customers.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customerId, err := parseCustomerID(c)
+		if err != nil {
+			return err
+		}
+
+		var patch entities.CustomerPatch
+		if err := json.Unmarshal(c.Body(), &patch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		version, err := ifMatch(c, customers.ErrVersionMismatch)
+		if err != nil {
+			return err
+		}
+
+		result, err := service.UpdateCustomerService(c.UserContext(), customerId, version, &patch)
+		if err != nil {
+			return err
+		}
+
+		setVersionETag(c, result.Version)
+		return c.JSON(presenters.CustomerSuccessResponse(result))
+	}
+}
+
+// RemoveCustomer deletes the customer named in the path. Customers who
+// have placed orders cannot be deleted.
+func RemoveCustomer(service customers.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customerId, err := parseCustomerID(c)
+		if err != nil {
+			return err
+		}
+
+		version, err := ifMatch(c, customers.ErrVersionMismatch)
+		if err != nil {
+			return err
+		}
+
+		if err := service.RemoveCustomerService(c.UserContext(), customerId, version); err != nil {
+			return err
+		}
+
+		return c.JSON(&fiber.Map{
+			"status": true,
+			"data":   "deleted successfully",
+			"error":  nil,
+		})
+	}
+}
+
+func parseCustomerID(c *fiber.Ctx) (entities.CustomerID, error) {
+	customerId, err := entities.ParseCustomerID(c.Params("id"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", customers.ErrInvalidID, err)
+	}
+	return customerId, nil
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCustomerService struct {
+	mock.Mock
+}
+
+func (m *mockCustomerService) InsertCustomerService(ctx context.Context, customer *entities.Customer) (*entities.Customer, error) {
+	args := m.Called(ctx, customer)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Customer), err
+	}
+	return nil, err
+}
+
+func (m *mockCustomerService) ListCustomersService(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error) {
+	args := m.Called(ctx, query)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.CustomerPage), err
+	}
+	return nil, err
+}
+
+func (m *mockCustomerService) GetCustomerByIDService(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error) {
+	args := m.Called(ctx, ID)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Customer), err
+	}
+	return nil, err
+}
+
+func (m *mockCustomerService) UpdateCustomerService(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error) {
+	args := m.Called(ctx, ID, version, patch)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Customer), err
+	}
+	return nil, err
+}
+
+func (m *mockCustomerService) RemoveCustomerService(ctx context.Context, ID entities.CustomerID, version int64) error {
+	args := m.Called(ctx, ID, version)
+	return args.Error(0)
+}
+
+func customerApp(service customers.Service) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/customers", GetCustomers(service))
+	app.Post("/customers", AddCustomer(service))
+	app.Get("/customers/:id", GetCustomer(service))
+	app.Patch("/customers/:id", PatchCustomer(service))
+	app.Delete("/customers/:id", RemoveCustomer(service))
+	return app
+}
+
+const customerID = entities.CustomerID("64a4c6181955b6923fff0101")
+
+func TestAddCustomerHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		requestBody  string
+		serviceErr   error
+		expectedCode int
+	}{
+		{description: "created", requestBody: `{"name":"Ada","email":"ada@example.com"}`, expectedCode: 200},
+		{description: "malformed", requestBody: `{"name":`, expectedCode: 400},
+		{description: "invalid", requestBody: `{"name":"Ada","email":"ada"}`, serviceErr: &customers.ValidationError{Fields: []validation.FieldError{{Field: "email", Message: "is malformed"}}}, expectedCode: 422},
+		{description: "taken", requestBody: `{"name":"Ada","email":"ADA@example.com"}`, serviceErr: customers.ErrConflict, expectedCode: 409},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockCustomerService)
+			if test.expectedCode != 400 {
+				var result *entities.Customer
+				if test.serviceErr == nil {
+					result = &entities.Customer{ID: customerID, Name: "Ada", Email: "ada@example.com", Version: 1}
+				}
+				mockService.On("InsertCustomerService", mock.Anything, mock.Anything).Return(result, test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/customers", strings.NewReader(test.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := customerApp(mockService).Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == 200 {
+				assert.Equal(t, `"1"`, resp.Header.Get(fiber.HeaderETag))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCustomersHandler(t *testing.T) {
+	mockService := new(mockCustomerService)
+	mockService.On("ListCustomersService", mock.Anything, &entities.CustomerQuery{Limit: 5, NamePrefix: "ad", Email: "ada@example.com"}).Return(&entities.CustomerPage{
+		Customers: []entities.Customer{{ID: customerID, Name: "Ada", Email: "ada@example.com", Version: 1}},
+		Total:     1,
+		Limit:     5,
+	}, nil)
+
+	resp, err := customerApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/customers?limit=5&name=ad&email=ada@example.com", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []entities.Customer
+		Meta struct{ Total, Limit int64 }
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, "ada@example.com", body.Data[0].Email)
+	assert.Equal(t, int64(1), body.Meta.Total)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetCustomerHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		ID           string
+		serviceErr   error
+		expectedCode int
+		expectedType string
+	}{
+		{description: "found", ID: string(customerID), expectedCode: 200},
+		{description: "missing", ID: string(customerID), serviceErr: customers.ErrCustomerNotFound, expectedCode: 404, expectedType: "urn:testingfiber:problem:customer-not-found"},
+		{description: "invalidID", ID: "ada", expectedCode: 400, expectedType: "urn:testingfiber:problem:invalid-id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockCustomerService)
+			if test.expectedCode != 400 {
+				var result *entities.Customer
+				if test.serviceErr == nil {
+					result = &entities.Customer{ID: customerID, Name: "Ada", Email: "ada@example.com", Version: 3}
+				}
+				mockService.On("GetCustomerByIDService", mock.Anything, customerID).Return(result, test.serviceErr)
+			}
+
+			resp, err := customerApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/customers/"+test.ID, nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedType != "" {
+				var body struct{ Error presenters.Problem }
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, test.expectedType, body.Error.Type)
+			} else {
+				assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatchCustomerHandler(t *testing.T) {
+	phone := "555 0100"
+
+	mockService := new(mockCustomerService)
+	mockService.On("UpdateCustomerService", mock.Anything, customerID, int64(2), &entities.CustomerPatch{Phone: &phone}).
+		Return(&entities.Customer{ID: customerID, Name: "Ada", Email: "ada@example.com", Phone: phone, Version: 3}, nil)
+
+	req := httptest.NewRequest(http.MethodPatch, "/customers/"+string(customerID), strings.NewReader(`{"phone":"555 0100"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fiber.HeaderIfMatch, `"2"`)
+	resp, err := customerApp(mockService).Test(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+	mockService.AssertExpectations(t)
+}
+
+func TestRemoveCustomerHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		serviceErr   error
+		expectedCode int
+		expectedType string
+	}{
+		{description: "deleted", expectedCode: 200},
+		{description: "hasOrders", serviceErr: customers.ErrCustomerInUse, expectedCode: 409, expectedType: "urn:testingfiber:problem:customer-in-use"},
+		{description: "stale", serviceErr: customers.ErrVersionMismatch, expectedCode: 412, expectedType: "urn:testingfiber:problem:version-mismatch"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockCustomerService)
+			mockService.On("RemoveCustomerService", mock.Anything, customerID, int64(0)).Return(test.serviceErr)
+
+			resp, err := customerApp(mockService).Test(httptest.NewRequest(http.MethodDelete, "/customers/"+string(customerID), nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedType != "" {
+				var body struct{ Error presenters.Problem }
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, test.expectedType, body.Error.Type)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
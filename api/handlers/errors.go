@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/orders"
+	"testingfiber/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// problemTypes names the problem type of each domain error. Anything not
+// listed is described by its status code alone, as "about:blank".
+var problemTypes = []struct {
+	err         error
+	status      int
+	problemType string
+}{
+	{cars.ErrInvalidID, http.StatusBadRequest, "urn:testingfiber:problem:invalid-id"},
+	{cars.ErrCarNotFound, http.StatusNotFound, "urn:testingfiber:problem:car-not-found"},
+	{cars.ErrVersionMismatch, http.StatusPreconditionFailed, "urn:testingfiber:problem:version-mismatch"},
+	{cars.ErrInvalidTransition, http.StatusConflict, "urn:testingfiber:problem:invalid-transition"},
+	{cars.ErrConflict, http.StatusConflict, "urn:testingfiber:problem:conflict"},
+	{companies.ErrInvalidID, http.StatusBadRequest, "urn:testingfiber:problem:invalid-id"},
+	{companies.ErrCompanyNotFound, http.StatusNotFound, "urn:testingfiber:problem:company-not-found"},
+	{companies.ErrVersionMismatch, http.StatusPreconditionFailed, "urn:testingfiber:problem:version-mismatch"},
+	{companies.ErrConflict, http.StatusConflict, "urn:testingfiber:problem:conflict"},
+	{companies.ErrCompanyInUse, http.StatusConflict, "urn:testingfiber:problem:company-in-use"},
+	{companies.ErrValidation, http.StatusUnprocessableEntity, "urn:testingfiber:problem:validation"},
+	{customers.ErrInvalidID, http.StatusBadRequest, "urn:testingfiber:problem:invalid-id"},
+	{customers.ErrCustomerNotFound, http.StatusNotFound, "urn:testingfiber:problem:customer-not-found"},
+	{customers.ErrVersionMismatch, http.StatusPreconditionFailed, "urn:testingfiber:problem:version-mismatch"},
+	{customers.ErrConflict, http.StatusConflict, "urn:testingfiber:problem:conflict"},
+	{customers.ErrCustomerInUse, http.StatusConflict, "urn:testingfiber:problem:customer-in-use"},
+	{customers.ErrValidation, http.StatusUnprocessableEntity, "urn:testingfiber:problem:validation"},
+	{orders.ErrInvalidID, http.StatusBadRequest, "urn:testingfiber:problem:invalid-id"},
+	{orders.ErrOrderNotFound, http.StatusNotFound, "urn:testingfiber:problem:order-not-found"},
+	{orders.ErrValidation, http.StatusUnprocessableEntity, "urn:testingfiber:problem:validation"},
+	{cars.ErrValidation, http.StatusUnprocessableEntity, "urn:testingfiber:problem:validation"},
+	{cars.ErrBatchTooLarge, http.StatusRequestEntityTooLarge, "urn:testingfiber:problem:batch-too-large"},
+	{cars.ErrBatchAborted, http.StatusFailedDependency, "urn:testingfiber:problem:batch-aborted"},
+	{cars.ErrUnavailable, http.StatusServiceUnavailable, "urn:testingfiber:problem:unavailable"},
+}
+
+// ErrorHandler is the application's fiber.Config.ErrorHandler. Handlers
+// return errors instead of writing failure responses themselves, and this
+// turns them into RFC 7807 problem details. Clients that accept
+// application/problem+json get the bare document, everyone else gets it
+// inside the usual response envelope.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := newProblem(c, err)
+	c.Status(problem.Status)
+
+	if c.Accepts(fiber.MIMEApplicationJSON, presenters.ProblemContentType) == presenters.ProblemContentType {
+		if err := c.JSON(problem); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, presenters.ProblemContentType)
+		return nil
+	}
+	return c.JSON(presenters.CarErrorResponse(problem))
+}
+
+func newProblem(c *fiber.Ctx, err error) *presenters.Problem {
+	status := errorStatus(err)
+	problem := &presenters.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: c.OriginalURL(),
+	}
+
+	for _, known := range problemTypes {
+		if errors.Is(err, known.err) {
+			problem.Type = known.problemType
+			break
+		}
+	}
+
+	// Every package reports invalid payloads with its own error type.
+	var validationErr interface {
+		FieldErrors() []validation.FieldError
+	}
+	if errors.As(err, &validationErr) {
+		problem.Detail = "The request payload has invalid fields."
+		problem.Errors = validationErr.FieldErrors()
+	}
+
+	// Unclassified failures may carry driver internals, so they are logged
+	// rather than echoed to the client.
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Method(), c.OriginalURL(), err)
+		problem.Detail = ""
+	}
+
+	return problem
+}
+
+// errorStatus picks the HTTP status for an error returned by a handler.
+func errorStatus(err error) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+
+	for _, known := range problemTypes {
+		if errors.Is(err, known.err) {
+			return known.status
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/orders"
+	"testingfiber/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{fiber.NewError(fiber.StatusBadRequest, "bad body"), http.StatusBadRequest},
+		{fiber.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: %q", cars.ErrInvalidID, "xyz"), http.StatusBadRequest},
+		{cars.ErrCarNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: duplicate key", cars.ErrConflict), http.StatusConflict},
+		{&cars.ValidationError{Fields: []validation.FieldError{{Field: "carName", Message: "is required"}}}, http.StatusUnprocessableEntity},
+		{&customers.ValidationError{Fields: []validation.FieldError{{Field: "email", Message: "is malformed"}}}, http.StatusUnprocessableEntity},
+		{&orders.ValidationError{Fields: []validation.FieldError{{Field: "carId", Message: "is required"}}}, http.StatusUnprocessableEntity},
+		{fmt.Errorf("%w: no reachable servers", cars.ErrUnavailable), http.StatusServiceUnavailable},
+		{fmt.Errorf("find: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, errorStatus(test.err), test.err.Error())
+	}
+}
+
+func newErrorApp(err error) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/cars/:id", func(c *fiber.Ctx) error {
+		return err
+	})
+	return app
+}
+
+func TestErrorHandlerProblemJSON(t *testing.T) {
+	app := newErrorApp(&cars.ValidationError{Fields: []validation.FieldError{
+		{Field: "carName", Message: "is required"},
+		{Field: "company", Message: "is required"},
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "/cars/42?x=1", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(t, presenters.ProblemContentType, resp.Header.Get("Content-Type"))
+
+	var problem presenters.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, "urn:testingfiber:problem:validation", problem.Type)
+	assert.Equal(t, "Unprocessable Entity", problem.Title)
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "/cars/42?x=1", problem.Instance)
+	assert.Len(t, problem.Errors, 2)
+}
+
+func TestErrorHandlerEnvelope(t *testing.T) {
+	app := newErrorApp(cars.ErrCarNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/cars/42", nil)
+	req.Header.Set("Accept", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get("Content-Type"))
+
+	var body struct {
+		Status bool
+		Error  presenters.Problem
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.False(t, body.Status)
+	assert.Equal(t, "urn:testingfiber:problem:car-not-found", body.Error.Type)
+	assert.Equal(t, cars.ErrCarNotFound.Error(), body.Error.Detail)
+	assert.Equal(t, http.StatusNotFound, body.Error.Status)
+}
+
+func TestErrorHandlerHidesInternalDetail(t *testing.T) {
+	app := newErrorApp(errors.New("connection pool exhausted at 10.0.0.3"))
+
+	req := httptest.NewRequest(http.MethodGet, "/cars/42", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	var problem presenters.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Empty(t, problem.Detail)
+}
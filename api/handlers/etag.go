@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setETag publishes the car's version as a strong entity tag.
+func setETag(c *fiber.Ctx, car *entities.Car) {
+	setVersionETag(c, car.Version)
+}
+
+func setVersionETag(c *fiber.Ctx, version int64) {
+	c.Set(fiber.HeaderETag, strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatchVersion reads the version a write to a car is conditional on from
+// the If-Match header, see ifMatch.
+func ifMatchVersion(c *fiber.Ctx) (int64, error) {
+	return ifMatch(c, cars.ErrVersionMismatch)
+}
+
+// ifMatch reads the version a write is conditional on from the If-Match
+// header. A missing header or "*" yields 0, which makes the write
+// unconditional. Tags that cannot be a current version, including weak
+// ones, can never match and fail with mismatch.
+func ifMatch(c *fiber.Ctx, mismatch error) (int64, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if strings.Contains(header, ",") {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "If-Match must name a single entity tag")
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, fmt.Errorf("%w: If-Match %s", mismatch, header)
+	}
+
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: If-Match %s", mismatch, header)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/cars/carsio"
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var exportContentTypes = map[carsio.Format]string{
+	carsio.JSON:   fiber.MIMEApplicationJSONCharsetUTF8,
+	carsio.NDJSON: "application/x-ndjson",
+	carsio.CSV:    "text/csv; charset=utf-8",
+}
+
+// ExportCars streams every car matching the filters of GET /cars as JSON,
+// NDJSON or CSV, chosen by the format parameter. Cars are written as they
+// are read from storage, so the response never sits in memory whole; the
+// paging parameters are ignored.
+//
+// The body is written after the handler returns, once the status line has
+// gone out, so a storage failure midway can only cut the body short. It is
+// logged, and a JSON export is left without its closing bracket.
+func ExportCars(service cars.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := carsio.JSON
+		if name := c.Query("format"); name != "" {
+			var err error
+			if format, err = carsio.ParseFormat(name); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+
+		query, err := parseCarQuery(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		// Strings taken from the request point into buffers that fiber
+		// reuses once the handler returns.
+		query.CompanyID = entities.CompanyID(utils.CopyString(string(query.CompanyID)))
+		query.Company = utils.CopyString(query.Company)
+		query.CarNamePrefix = utils.CopyString(query.CarNamePrefix)
+		route := c.Method() + " " + utils.CopyString(c.OriginalURL())
+
+		c.Set(fiber.HeaderContentType, exportContentTypes[format])
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="cars.`+string(format)+`"`)
+
+		// The request deadline ends with the handler, before the body is
+		// written; a client that goes away stops the export instead.
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			writer := carsio.NewWriter(w, format)
+			err := service.ScanCarsService(context.Background(), query, writer.Write)
+			if err == nil {
+				err = writer.Close()
+			}
+			if err == nil {
+				err = w.Flush()
+			}
+			if err != nil {
+				log.Printf("%s: export stopped: %v", route, err)
+			}
+		})
+		return nil
+	}
+}
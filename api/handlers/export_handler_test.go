@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testingfiber/pkg/entities"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCarsHandler(t *testing.T) {
+	madeAt := time.Date(2023, 7, 5, 10, 0, 0, 0, time.UTC)
+	stored := []entities.Car{
+		{ID: "64a4c6181955b6923fff02b5", VIN: "JM3KFBCM0N0000001", CarName: "CX-5", Company: "Mazda", ModelYear: 2022,
+			Mileage: 1200, ListPrice: 2599900, Currency: "USD", Condition: entities.ConditionUsed, Status: entities.InStock,
+			MadeAt: madeAt, Version: 1},
+		{ID: "64a4c6181955b6923fff02b6", CarName: "CX-30", Company: "Mazda", MadeAt: madeAt, Version: 2},
+	}
+
+	tests := []struct {
+		description  string
+		target       string
+		query        *entities.CarQuery
+		expectedCode int
+		contentType  string
+		body         string
+	}{
+		{
+			description:  "defaultJSON",
+			target:       "/cars/export",
+			query:        &entities.CarQuery{},
+			expectedCode: 200,
+			contentType:  fiber.MIMEApplicationJSONCharsetUTF8,
+			body: `[
+{"id":"64a4c6181955b6923fff02b5","vin":"JM3KFBCM0N0000001","carName":"CX-5","companyId":"","company":"Mazda","modelYear":2022,"trim":"","color":"","mileage":1200,"listPrice":2599900,"currency":"USD","condition":"used","status":"in_stock","madeAt":"2023-07-05T10:00:00Z","reservedAt":"0001-01-01T00:00:00Z","soldAt":"0001-01-01T00:00:00Z","version":1},
+{"id":"64a4c6181955b6923fff02b6","vin":"","carName":"CX-30","companyId":"","company":"Mazda","modelYear":0,"trim":"","color":"","mileage":0,"listPrice":0,"currency":"","condition":"","status":"","madeAt":"2023-07-05T10:00:00Z","reservedAt":"0001-01-01T00:00:00Z","soldAt":"0001-01-01T00:00:00Z","version":2}
+]
+`,
+		},
+		{
+			description:  "csvWithFilters",
+			target:       "/cars/export?format=csv&company=Mazda&sort=-carName&limit=1",
+			query:        &entities.CarQuery{Company: "Mazda", SortBy: "carName", Descending: true, Limit: 1},
+			expectedCode: 200,
+			contentType:  "text/csv; charset=utf-8",
+			body: "id,vin,carName,companyId,company,modelYear,trim,color,mileage,listPrice,currency,condition,status,madeAt,reservedAt,soldAt,version\n" +
+				"64a4c6181955b6923fff02b5,JM3KFBCM0N0000001,CX-5,,Mazda,2022,,,1200,2599900,USD,used,in_stock,2023-07-05T10:00:00Z,,,1\n" +
+				"64a4c6181955b6923fff02b6,,CX-30,,Mazda,,,,0,0,,,,2023-07-05T10:00:00Z,,,2\n",
+		},
+		{
+			description:  "badFormat",
+			target:       "/cars/export?format=xml",
+			expectedCode: 400,
+		},
+		{
+			description:  "badSort",
+			target:       "/cars/export?sort=horsepower",
+			expectedCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockService)
+			if test.query != nil {
+				mockService.On("ScanCarsService", mock.Anything, test.query).Return(stored, nil)
+			}
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/cars/export", ExportCars(mockService))
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, test.target, nil))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+
+			if test.expectedCode == 200 {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.contentType, resp.Header.Get("Content-Type"))
+				assert.Equal(t, test.body, string(body))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExportCarsHandlerStorageFailure(t *testing.T) {
+	mockService := new(mockService)
+	mockService.On("ScanCarsService", mock.Anything, mock.Anything).Return(
+		[]entities.Car{{ID: "64a4c6181955b6923fff02b5", CarName: "CX-5", Company: "Mazda"}},
+		errors.New("connection reset"))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/cars/export", ExportCars(mockService))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/cars/export?format=json", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	// The status is already out; the missing bracket marks the failure.
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `"CX-5"`)
+	assert.NotContains(t, string(body), "]")
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"testingfiber/api/presenters"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Check probes one dependency and returns nil when it can serve requests.
+type Check func(ctx context.Context) error
+
+// Healthz answers the liveness probe. It checks no dependencies: an
+// instance whose database is down should be taken out of rotation, not
+// restarted.
+func Healthz() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(presenters.HealthResponse(nil))
+	}
+}
+
+// Readyz answers the readiness probe by running every check with the
+// request's context. It responds 503 when any check fails so that traffic
+// is routed away from the instance until it recovers. The probe is not
+// authenticated, so a failed check is reported as down and its error only
+// logged.
+func Readyz(checks map[string]Check) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		components := make(map[string]presenters.Component, len(checks))
+		for name, check := range checks {
+			component := presenters.Component{Status: presenters.StatusUp}
+			if err := check(c.UserContext()); err != nil {
+				log.Printf("%s %s: %s check failed: %v", c.Method(), c.OriginalURL(), name, err)
+				component = presenters.Component{Status: presenters.StatusDown}
+			}
+			components[name] = component
+		}
+
+		health := presenters.HealthResponse(components)
+		if health.Status != presenters.StatusUp {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(health)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testingfiber/api/presenters"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthz(t *testing.T) {
+	app := fiber.New()
+	app.Get("/healthz", Healthz())
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body presenters.Health
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, presenters.StatusUp, body.Status)
+}
+
+func TestReadyz(t *testing.T) {
+	up := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("no reachable servers") }
+
+	tests := []struct {
+		description string
+		checks      map[string]Check
+		status      int
+		expected    presenters.Health
+	}{
+		{
+			description: "allUp",
+			checks:      map[string]Check{"storage": up},
+			status:      http.StatusOK,
+			expected: presenters.Health{
+				Status:     presenters.StatusUp,
+				Components: map[string]presenters.Component{"storage": {Status: presenters.StatusUp}},
+			},
+		},
+		{
+			description: "storageDown",
+			checks:      map[string]Check{"storage": down, "other": up},
+			status:      http.StatusServiceUnavailable,
+			expected: presenters.Health{
+				Status: presenters.StatusDown,
+				Components: map[string]presenters.Component{
+					"storage": {Status: presenters.StatusDown},
+					"other":   {Status: presenters.StatusUp},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/readyz", Readyz(test.checks))
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			require.NoError(t, err)
+			assert.Equal(t, test.status, resp.StatusCode)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.NotContains(t, string(raw), "no reachable servers")
+
+			var body presenters.Health
+			require.NoError(t, json.Unmarshal(raw, &body))
+			assert.Equal(t, test.expected, body)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/orders"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AddOrder sells the car of the request to its customer. The car is
+// marked sold in the same write that stores the order.
+func AddOrder(service orders.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requestBody entities.OrderRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		result, err := service.PlaceOrderService(c.UserContext(), &requestBody)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.OrderSuccessResponse(result))
+	}
+}
+
+// GetOrders lists orders newest first. The customerId parameter limits
+// the listing to the orders of one customer.
+func GetOrders(service orders.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query, err := parseOrderQuery(c)
+		if err != nil {
+			return err
+		}
+		if id := c.Query("customerId"); id != "" {
+			if query.CustomerID, err = entities.ParseCustomerID(id); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+
+		page, err := service.ListOrdersService(c.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.OrdersSuccessResponse(page))
+	}
+}
+
+// GetCustomerOrders lists the order history of the customer named in the
+// path, newest first.
+func GetCustomerOrders(service orders.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customerId, err := parseCustomerID(c)
+		if err != nil {
+			return err
+		}
+
+		query, err := parseOrderQuery(c)
+		if err != nil {
+			return err
+		}
+		query.CustomerID = customerId
+
+		page, err := service.ListOrdersService(c.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.OrdersSuccessResponse(page))
+	}
+}
+
+func GetOrder(service orders.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orderId, err := entities.ParseOrderID(c.Params("id"))
+		if err != nil {
+			return fmt.Errorf("%w: %w", orders.ErrInvalidID, err)
+		}
+
+		result, err := service.GetOrderByIDService(c.UserContext(), orderId)
+		if err != nil {
+			return err
+		}
+		return c.JSON(presenters.OrderSuccessResponse(result))
+	}
+}
+
+func parseOrderQuery(c *fiber.Ctx) (*entities.OrderQuery, error) {
+	query := &entities.OrderQuery{}
+
+	var err error
+	if query.Limit, query.Offset, err = parsePaging(c); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return query, nil
+}
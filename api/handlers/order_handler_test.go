@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testingfiber/api/presenters"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/orders"
+	"testingfiber/pkg/validation"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockOrderService struct {
+	mock.Mock
+}
+
+func (m *mockOrderService) PlaceOrderService(ctx context.Context, request *entities.OrderRequest) (*entities.Order, error) {
+	args := m.Called(ctx, request)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Order), err
+	}
+	return nil, err
+}
+
+func (m *mockOrderService) GetOrderByIDService(ctx context.Context, ID entities.OrderID) (*entities.Order, error) {
+	args := m.Called(ctx, ID)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Order), err
+	}
+	return nil, err
+}
+
+func (m *mockOrderService) ListOrdersService(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	args := m.Called(ctx, query)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.OrderPage), err
+	}
+	return nil, err
+}
+
+func orderApp(service orders.Service) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/orders", GetOrders(service))
+	app.Post("/orders", AddOrder(service))
+	app.Get("/orders/:id", GetOrder(service))
+	app.Get("/customers/:id/orders", GetCustomerOrders(service))
+	return app
+}
+
+const orderID = entities.OrderID("64a4c6181955b6923fff0201")
+
+func testOrder() *entities.Order {
+	return &entities.Order{
+		ID:         orderID,
+		CustomerID: customerID,
+		CarID:      "64a4c6181955b6923fff0301",
+		CarName:    "CX-5",
+		Company:    "Mazda",
+		Currency:   "USD",
+		Subtotal:   3000000,
+		Discounts:  []entities.Discount{{Code: "SPRING", Percent: 500, Amount: 150000}},
+		Discount:   150000,
+		TaxRate:    825,
+		Tax:        235125,
+		Total:      3085125,
+		PlacedAt:   time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestAddOrderHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		requestBody  string
+		serviceErr   error
+		expectedCode int
+		expectedType string
+	}{
+		{description: "placed", requestBody: `{"customerId":"64a4c6181955b6923fff0101","carId":"64a4c6181955b6923fff0301","discounts":[{"code":"SPRING","percent":500}]}`, expectedCode: 200},
+		{description: "malformed", requestBody: `{"carId":`, expectedCode: 400},
+		{description: "unknownCustomer", requestBody: `{"customerId":"64a4c6181955b6923fff0102","carId":"64a4c6181955b6923fff0301"}`, serviceErr: &orders.ValidationError{Fields: []validation.FieldError{{Field: "customerId", Message: "names no known customer"}}}, expectedCode: 422, expectedType: "urn:testingfiber:problem:validation"},
+		{description: "sold", requestBody: `{"customerId":"64a4c6181955b6923fff0101","carId":"64a4c6181955b6923fff0301"}`, serviceErr: cars.ErrInvalidTransition, expectedCode: 409, expectedType: "urn:testingfiber:problem:invalid-transition"},
+		{description: "staleCar", requestBody: `{"customerId":"64a4c6181955b6923fff0101","carId":"64a4c6181955b6923fff0301","carVersion":2}`, serviceErr: cars.ErrVersionMismatch, expectedCode: 412, expectedType: "urn:testingfiber:problem:version-mismatch"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			mockService := new(mockOrderService)
+			if test.expectedCode != 400 {
+				var result *entities.Order
+				if test.serviceErr == nil {
+					result = testOrder()
+				}
+				mockService.On("PlaceOrderService", mock.Anything, mock.Anything).Return(result, test.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(test.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := orderApp(mockService).Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			switch {
+			case test.expectedType != "":
+				var body struct{ Error presenters.Problem }
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, test.expectedType, body.Error.Type)
+			case test.expectedCode == 200:
+				var body struct{ Data presenters.Order }
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, int64(3085125), body.Data.Total)
+				assert.Equal(t, []presenters.Discount{{Code: "SPRING", Percent: 500, Amount: 150000}}, body.Data.Discounts)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetOrderHandler(t *testing.T) {
+	mockService := new(mockOrderService)
+	mockService.On("GetOrderByIDService", mock.Anything, orderID).Return(testOrder(), nil)
+
+	resp, err := orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/orders/"+string(orderID), nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+
+	resp, err = orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/orders/latest", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetOrdersHandler(t *testing.T) {
+	mockService := new(mockOrderService)
+	mockService.On("ListOrdersService", mock.Anything, &entities.OrderQuery{Limit: 5, CustomerID: customerID}).Return(&entities.OrderPage{
+		Orders:     []entities.Order{*testOrder()},
+		Total:      2,
+		Limit:      5,
+		NextCursor: cars.EncodeCursor(1),
+	}, nil)
+
+	resp, err := orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/orders?limit=5&customerId="+string(customerID), nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []presenters.Order
+		Meta struct {
+			Total, Limit int64
+			NextCursor   string
+		}
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, orderID, body.Data[0].ID)
+	assert.Equal(t, int64(2), body.Meta.Total)
+	assert.Equal(t, cars.EncodeCursor(1), body.Meta.NextCursor)
+	mockService.AssertExpectations(t)
+
+	resp, err = orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/orders?customerId=ada", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetCustomerOrdersHandler(t *testing.T) {
+	mockService := new(mockOrderService)
+	mockService.On("ListOrdersService", mock.Anything, &entities.OrderQuery{Offset: 1, CustomerID: customerID}).Return(&entities.OrderPage{Total: 1, Offset: 1}, nil)
+
+	resp, err := orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/customers/"+string(customerID)+"/orders?offset=1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+
+	resp, err = orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/customers/"+string(customerID)+"/orders?cursor="+cars.EncodeCursor(1), nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	missing := entities.CustomerID("64a4c6181955b6923fff0102")
+	mockService.On("ListOrdersService", mock.Anything, &entities.OrderQuery{CustomerID: missing}).Return(nil, customers.ErrCustomerNotFound)
+
+	resp, err = orderApp(mockService).Test(httptest.NewRequest(http.MethodGet, "/customers/"+string(missing)+"/orders", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -2,43 +2,206 @@ package presenters
 
 import (
 	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Car struct {
-	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	CarName string             `json:"carName"`
-	Company string             `json:"company"`
+	ID         entities.CarID           `json:"id"`
+	VIN        string                   `json:"vin,omitempty"`
+	CarName    string                   `json:"carName"`
+	CompanyID  entities.CompanyID       `json:"companyId,omitempty"`
+	Company    string                   `json:"company"`
+	ModelYear  int                      `json:"modelYear,omitempty"`
+	Trim       string                   `json:"trim,omitempty"`
+	Color      string                   `json:"color,omitempty"`
+	Mileage    int64                    `json:"mileage"`
+	ListPrice  *Price                   `json:"listPrice,omitempty"`
+	Condition  entities.Condition       `json:"condition,omitempty"`
+	Status     entities.InventoryStatus `json:"status"`
+	ReservedAt *time.Time               `json:"reservedAt,omitempty"`
+	SoldAt     *time.Time               `json:"soldAt,omitempty"`
+	Version    int64                    `json:"version"`
 }
 
-func CarSuccessResponse(data *entities.Car) *fiber.Map {
-	car := Car{
-		ID:      data.ID,
-		CarName: data.CarName,
-		Company: data.Company,
+// Price is an amount of money in the minor unit of its currency, such as
+// cents for USD.
+type Price struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func newCar(data *entities.Car) *Car {
+	car := &Car{
+		ID:        data.ID,
+		VIN:       data.VIN,
+		CarName:   data.CarName,
+		CompanyID: data.CompanyID,
+		Company:   data.Company,
+		ModelYear: data.ModelYear,
+		Trim:      data.Trim,
+		Color:     data.Color,
+		Mileage:   data.Mileage,
+		Condition: data.Condition,
+		Status:    data.Status,
+		Version:   data.Version,
+	}
+	if data.ListPrice > 0 {
+		car.ListPrice = &Price{Amount: data.ListPrice, Currency: data.Currency}
+	}
+	car.ReservedAt = optionalTime(data.ReservedAt)
+	car.SoldAt = optionalTime(data.SoldAt)
+	return car
+}
+
+// optionalTime returns t, or nil when it was never set.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
+	return &t
+}
 
+func CarSuccessResponse(data *entities.Car) *fiber.Map {
 	return &fiber.Map{
 		"status": true,
-		"data":   car,
+		"data":   newCar(data),
 		"error":  nil,
 	}
 }
 
-func CarsSuccessResponse(datas *[]entities.Car) *fiber.Map {
+// BatchItem is the outcome of one item of a batch request, at the same
+// index as the item. Status is the HTTP status the item would have got as
+// a request of its own.
+type BatchItem struct {
+	Index  int      `json:"index"`
+	Status int      `json:"status"`
+	Data   *Car     `json:"data,omitempty"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+// NewBatchItem describes an item that succeeded. Data is nil for deletes.
+func NewBatchItem(index, status int, data *entities.Car) BatchItem {
+	item := BatchItem{Index: index, Status: status}
+	if data != nil {
+		item.Data = newCar(data)
+	}
+	return item
+}
+
+// BatchResponse reports every item of a batch. Status is true only when
+// all of them succeeded.
+func BatchResponse(atomic bool, items []BatchItem) *fiber.Map {
+	failed := 0
+	for _, item := range items {
+		if item.Error != nil {
+			failed++
+		}
+	}
+
+	return &fiber.Map{
+		"status": failed == 0,
+		"data":   items,
+		"meta": fiber.Map{
+			"atomic":    atomic,
+			"succeeded": len(items) - failed,
+			"failed":    failed,
+		},
+		"error": nil,
+	}
+}
+
+// EmbeddedCar is a car of a listing that embeds the companies of its cars,
+// as in GET /cars?embed=company. Cars whose company is unknown come
+// without it.
+type EmbeddedCar struct {
+	entities.Car
+	Embedded *CarEmbeds `json:"_embedded,omitempty"`
+}
+
+// CarEmbeds are the resources embedded in an EmbeddedCar.
+type CarEmbeds struct {
+	Company *Company `json:"company"`
+}
+
+func CarsSuccessResponse(page *entities.CarPage) *fiber.Map {
+	var datas any = page.Cars
+	if page.Cars == nil {
+		datas = []entities.Car{}
+	}
+	if page.Companies != nil {
+		embedded := make([]EmbeddedCar, len(page.Cars))
+		for i, car := range page.Cars {
+			embedded[i].Car = car
+			if company, ok := page.Companies[car.CompanyID]; ok {
+				embedded[i].Embedded = &CarEmbeds{Company: newCompany(&company)}
+			}
+		}
+		datas = embedded
+	}
+
 	return &fiber.Map{
 		"status": true,
 		"data":   datas,
-		"error":  nil,
+		"meta": fiber.Map{
+			"total":      page.Total,
+			"limit":      page.Limit,
+			"offset":     page.Offset,
+			"nextCursor": page.NextCursor,
+		},
+		"error": nil,
+	}
+}
+
+// SearchHit is a car found by a search, with its relevance score and its
+// matching fields, the matched words wrapped in <em> tags.
+type SearchHit struct {
+	Car        entities.Car      `json:"car"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+func SearchSuccessResponse(page *entities.SearchPage) *fiber.Map {
+	hits := make([]SearchHit, len(page.Hits))
+	for i, hit := range page.Hits {
+		hits[i] = SearchHit{Car: hit.Car, Score: hit.Score, Highlights: hit.Highlights}
+	}
+
+	return &fiber.Map{
+		"status": true,
+		"data":   hits,
+		"meta": fiber.Map{
+			"total":      page.Total,
+			"limit":      page.Limit,
+			"offset":     page.Offset,
+			"nextCursor": page.NextCursor,
+		},
+		"error": nil,
 	}
 }
 
-func CarErrorResponse(err error) *fiber.Map {
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document. Errors lists the
+// offending fields when a payload fails validation.
+type Problem struct {
+	Type     string                  `json:"type"`
+	Title    string                  `json:"title"`
+	Status   int                     `json:"status"`
+	Detail   string                  `json:"detail,omitempty"`
+	Instance string                  `json:"instance,omitempty"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+}
+
+// CarErrorResponse wraps a problem in the envelope shared with the success
+// responses, for clients that did not ask for problem+json.
+func CarErrorResponse(problem *Problem) *fiber.Map {
 	return &fiber.Map{
 		"status": false,
 		"data":   nil,
-		"error":  err,
+		"error":  problem,
 	}
 }
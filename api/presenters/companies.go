@@ -0,0 +1,49 @@
+package presenters
+
+import (
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Company struct {
+	ID      entities.CompanyID `json:"id"`
+	Name    string             `json:"name"`
+	Country string             `json:"country,omitempty"`
+	Version int64              `json:"version"`
+}
+
+func newCompany(data *entities.Company) *Company {
+	return &Company{
+		ID:      data.ID,
+		Name:    data.Name,
+		Country: data.Country,
+		Version: data.Version,
+	}
+}
+
+func CompanySuccessResponse(data *entities.Company) *fiber.Map {
+	return &fiber.Map{
+		"status": true,
+		"data":   newCompany(data),
+		"error":  nil,
+	}
+}
+
+func CompaniesSuccessResponse(page *entities.CompanyPage) *fiber.Map {
+	datas := make([]*Company, len(page.Companies))
+	for i := range page.Companies {
+		datas[i] = newCompany(&page.Companies[i])
+	}
+
+	return &fiber.Map{
+		"status": true,
+		"data":   datas,
+		"meta": fiber.Map{
+			"total":  page.Total,
+			"limit":  page.Limit,
+			"offset": page.Offset,
+		},
+		"error": nil,
+	}
+}
@@ -0,0 +1,51 @@
+package presenters
+
+import (
+	"testingfiber/pkg/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Customer struct {
+	ID      entities.CustomerID `json:"id"`
+	Name    string              `json:"name"`
+	Email   string              `json:"email"`
+	Phone   string              `json:"phone,omitempty"`
+	Version int64               `json:"version"`
+}
+
+func newCustomer(data *entities.Customer) *Customer {
+	return &Customer{
+		ID:      data.ID,
+		Name:    data.Name,
+		Email:   data.Email,
+		Phone:   data.Phone,
+		Version: data.Version,
+	}
+}
+
+func CustomerSuccessResponse(data *entities.Customer) *fiber.Map {
+	return &fiber.Map{
+		"status": true,
+		"data":   newCustomer(data),
+		"error":  nil,
+	}
+}
+
+func CustomersSuccessResponse(page *entities.CustomerPage) *fiber.Map {
+	datas := make([]*Customer, len(page.Customers))
+	for i := range page.Customers {
+		datas[i] = newCustomer(&page.Customers[i])
+	}
+
+	return &fiber.Map{
+		"status": true,
+		"data":   datas,
+		"meta": fiber.Map{
+			"total":  page.Total,
+			"limit":  page.Limit,
+			"offset": page.Offset,
+		},
+		"error": nil,
+	}
+}
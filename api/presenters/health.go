@@ -0,0 +1,29 @@
+package presenters
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Component is the state of one dependency checked by a readiness probe.
+// Why a check failed is only logged, since the probe is unauthenticated.
+type Component struct {
+	Status string `json:"status"`
+}
+
+// Health is the body of the liveness and readiness probes. Status is down
+// as soon as any component is down.
+type Health struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+func HealthResponse(components map[string]Component) *Health {
+	health := &Health{Status: StatusUp, Components: components}
+	for _, component := range components {
+		if component.Status != StatusUp {
+			health.Status = StatusDown
+		}
+	}
+	return health
+}
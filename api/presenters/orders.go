@@ -0,0 +1,84 @@
+package presenters
+
+import (
+	"testingfiber/pkg/entities"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Order is an order as clients see it. Amounts are in the minor unit of
+// the currency and the tax rate in basis points, as stored.
+type Order struct {
+	ID         entities.OrderID    `json:"id"`
+	CustomerID entities.CustomerID `json:"customerId"`
+	CarID      entities.CarID      `json:"carId"`
+	CarName    string              `json:"carName"`
+	Company    string              `json:"company"`
+	VIN        string              `json:"vin,omitempty"`
+	Currency   string              `json:"currency"`
+	Subtotal   int64               `json:"subtotal"`
+	Discounts  []Discount          `json:"discounts"`
+	Discount   int64               `json:"discount"`
+	TaxRate    int64               `json:"taxRate"`
+	Tax        int64               `json:"tax"`
+	Total      int64               `json:"total"`
+	PlacedAt   time.Time           `json:"placedAt"`
+}
+
+type Discount struct {
+	Code    string `json:"code,omitempty"`
+	Percent int64  `json:"percent,omitempty"`
+	Amount  int64  `json:"amount"`
+}
+
+func newOrder(data *entities.Order) *Order {
+	discounts := make([]Discount, len(data.Discounts))
+	for i, discount := range data.Discounts {
+		discounts[i] = Discount(discount)
+	}
+
+	return &Order{
+		ID:         data.ID,
+		CustomerID: data.CustomerID,
+		CarID:      data.CarID,
+		CarName:    data.CarName,
+		Company:    data.Company,
+		VIN:        data.VIN,
+		Currency:   data.Currency,
+		Subtotal:   data.Subtotal,
+		Discounts:  discounts,
+		Discount:   data.Discount,
+		TaxRate:    data.TaxRate,
+		Tax:        data.Tax,
+		Total:      data.Total,
+		PlacedAt:   data.PlacedAt,
+	}
+}
+
+func OrderSuccessResponse(data *entities.Order) *fiber.Map {
+	return &fiber.Map{
+		"status": true,
+		"data":   newOrder(data),
+		"error":  nil,
+	}
+}
+
+func OrdersSuccessResponse(page *entities.OrderPage) *fiber.Map {
+	datas := make([]*Order, len(page.Orders))
+	for i := range page.Orders {
+		datas[i] = newOrder(&page.Orders[i])
+	}
+
+	return &fiber.Map{
+		"status": true,
+		"data":   datas,
+		"meta": fiber.Map{
+			"total":      page.Total,
+			"limit":      page.Limit,
+			"offset":     page.Offset,
+			"nextCursor": page.NextCursor,
+		},
+		"error": nil,
+	}
+}
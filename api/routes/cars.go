@@ -12,4 +12,18 @@ func CarRouter(app fiber.Router, service cars.Service) {
 	app.Post("/cars", handlers.AddCar(service))
 	app.Put("/cars", handlers.UpdateCar(service))
 	app.Delete("/cars", handlers.RemoveCar(service))
+	app.Post("/cars\\:batchCreate", handlers.AddCars(service))
+	app.Post("/cars\\:batchUpdate", handlers.UpdateCars(service))
+	app.Post("/cars\\:batchDelete", handlers.RemoveCars(service))
+	app.Get("/cars/export", handlers.ExportCars(service))
+	app.Get("/cars/search", handlers.SearchCars(service))
+	app.Get("/cars/:id", handlers.GetCar(service))
+	app.Put("/cars/:id", handlers.ReplaceCar(service))
+	app.Patch("/cars/:id", handlers.PatchCar(service))
+	app.Delete("/cars/:id", handlers.RemoveCarByID(service))
+	app.Post("/cars/:id/reserve", handlers.TransitionCar(service, cars.Reserve))
+	app.Post("/cars/:id/release", handlers.TransitionCar(service, cars.Release))
+	app.Post("/cars/:id/sell", handlers.TransitionCar(service, cars.Sell))
+	app.Post("/cars/:id/return", handlers.TransitionCar(service, cars.Return))
+	app.Post("/cars/:id/archive", handlers.TransitionCar(service, cars.Archive))
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"testingfiber/api/handlers"
+	"testingfiber/pkg/companies"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CompanyRouter(app fiber.Router, service companies.Service) {
+	app.Get("/companies", handlers.GetCompanies(service))
+	app.Post("/companies", handlers.AddCompany(service))
+	app.Get("/companies/:id", handlers.GetCompany(service))
+	app.Patch("/companies/:id", handlers.PatchCompany(service))
+	app.Delete("/companies/:id", handlers.RemoveCompany(service))
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"testingfiber/api/handlers"
+	"testingfiber/pkg/customers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CustomerRouter(app fiber.Router, service customers.Service) {
+	app.Get("/customers", handlers.GetCustomers(service))
+	app.Post("/customers", handlers.AddCustomer(service))
+	app.Get("/customers/:id", handlers.GetCustomer(service))
+	app.Patch("/customers/:id", handlers.PatchCustomer(service))
+	app.Delete("/customers/:id", handlers.RemoveCustomer(service))
+}
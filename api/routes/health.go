@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"testingfiber/api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func HealthRouter(app fiber.Router, checks map[string]handlers.Check) {
+	app.Get("/healthz", handlers.Healthz())
+	app.Get("/readyz", handlers.Readyz(checks))
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"testingfiber/api/handlers"
+	"testingfiber/pkg/orders"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func OrderRouter(app fiber.Router, service orders.Service) {
+	app.Get("/orders", handlers.GetOrders(service))
+	app.Post("/orders", handlers.AddOrder(service))
+	app.Get("/orders/:id", handlers.GetOrder(service))
+	app.Get("/customers/:id/orders", handlers.GetCustomerOrders(service))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/cars/carsio"
+	"testingfiber/pkg/entities"
+	"text/tabwriter"
+	"time"
+)
+
+// carsCommand runs "cars list|get|delete", the operator's view of the
+// stored cars.
+func carsCommand(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: carshop cars list|get|delete [flags] [arguments]")
+	}
+
+	switch action, args := args[0], args[1:]; action {
+	case "list":
+		return listCars(ctx, c, args)
+	case "get":
+		return getCar(ctx, c, args)
+	case "delete":
+		return deleteCar(ctx, c, args)
+	default:
+		return fmt.Errorf("unknown cars action %q, want list, get or delete", action)
+	}
+}
+
+func listCars(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("cars list")
+	output := outputFlag(fs)
+	query := queryFlags(fs)
+	limit := fs.Int64("limit", cars.DefaultListLimit, "cars per page")
+	offset := fs.Int64("offset", 0, "cars to skip")
+	cfg, _, err := c.load(fs, "cars list [flags]", args, 0, 0)
+
+	if err != nil {
+		return err
+	}
+
+	carQuery, err := query()
+
+	if err != nil {
+		return err
+	}
+
+	carQuery.Limit, carQuery.Offset = *limit, *offset
+
+	return withService(ctx, cfg, func(service cars.Service) error {
+		page, err := service.CheckCarService(ctx, carQuery)
+		if err != nil {
+			return err
+		}
+
+		if err := writeCars(c.stdout, *output, page.Cars); err != nil {
+			return err
+		}
+		if *output == "table" && len(page.Cars) > 0 {
+			fmt.Fprintf(c.stdout, "\n%d-%d of %d cars\n", page.Offset+1, page.Offset+int64(len(page.Cars)), page.Total)
+		}
+		return nil
+	})
+}
+
+func getCar(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("cars get")
+	output := outputFlag(fs)
+	cfg, rest, err := c.load(fs, "cars get [flags] ID", args, 1, 1)
+
+	if err != nil {
+		return err
+	}
+
+	return withService(ctx, cfg, func(service cars.Service) error {
+		car, err := service.GetCarByIDService(ctx, entities.CarID(rest[0]))
+		if err != nil {
+			return err
+		}
+		return writeCars(c.stdout, *output, []entities.Car{*car})
+	})
+}
+
+func deleteCar(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("cars delete")
+	version := fs.Int64("version", 0, "only delete the car at this version (default: any version)")
+	cfg, rest, err := c.load(fs, "cars delete [flags] ID", args, 1, 1)
+
+	if err != nil {
+		return err
+	}
+
+	return withService(ctx, cfg, func(service cars.Service) error {
+		if err := service.RemoveCarService(ctx, entities.CarID(rest[0]), *version); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, "Deleted", rest[0])
+		return nil
+	})
+}
+
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", "table", "table, json, ndjson or csv")
+}
+
+// writeCars prints cars as an aligned table or in one of the file formats.
+func writeCars(w io.Writer, output string, list []entities.Car) error {
+	if output != "table" {
+		format, err := carsio.ParseFormat(output)
+		if err != nil {
+			return err
+		}
+
+		writer := carsio.NewWriter(w, format)
+		for i := range list {
+			if err := writer.Write(&list[i]); err != nil {
+				return err
+			}
+		}
+		return writer.Close()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCOMPANY\tCAR NAME\tMADE AT\tSOLD AT\tVERSION")
+	for _, car := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n",
+			car.ID, car.Company, car.CarName, tableTime(car.MadeAt), tableTime(car.SoldAt), car.Version)
+	}
+	return tw.Flush()
+}
+
+func tableTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/cars/carsio"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/config"
+	"text/tabwriter"
+)
+
+// cli carries the standard streams and environment of the process, so
+// that commands can run in tests without touching the real ones.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+// command is a subcommand of the car shop binary. Every command takes the
+// configuration flags on top of its own.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = []command{
+	{"serve", "run the HTTP server (the default)", serveCommand},
+	{"migrate", "apply, roll back or list schema migrations", migrateCommand},
+	{"seed", "load the fixture cars into empty storage", seedCommand},
+	{"import", "add cars from a JSON, NDJSON or CSV file", importCommand},
+	{"export", "write cars as JSON, NDJSON or CSV", exportCommand},
+	{"cars", "list, get or delete cars", carsCommand},
+}
+
+// runCommand runs the command named by the first argument. Without one,
+// or when the arguments start with a flag, the server is started as it
+// was before there were subcommands.
+func runCommand(ctx context.Context, c *cli, args []string) error {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		printUsage(c.stdout)
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(ctx, c, args)
+		}
+	}
+
+	printUsage(c.stderr)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: carshop <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Every command also takes the configuration flags; see "carshop <command> -h".`)
+}
+
+// load parses the arguments of a command: its own flags, declared on fs,
+// the configuration flags and its positional arguments, of which it takes
+// between min and max.
+func (c *cli) load(fs *flag.FlagSet, usage string, args []string, min, max int) (*config.Config, []string, error) {
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: carshop %s\n\n", usage)
+		fs.PrintDefaults()
+	}
+
+	cfg, rest, err := config.LoadFlags(fs, args, c.getenv)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rest) < min || len(rest) > max {
+		return nil, nil, fmt.Errorf("usage: carshop %s", usage)
+	}
+
+	return cfg, rest, nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("carshop "+name, flag.ContinueOnError)
+}
+
+// withService opens the configured storage, runs fn with a car service on
+// top of it and closes the storage again.
+func withService(ctx context.Context, cfg *config.Config, fn func(cars.Service) error) error {
+	return withServices(ctx, cfg, func(carService cars.Service, _ companies.Service) error {
+		return fn(carService)
+	})
+}
+
+// withServices is withService for commands that also manage companies.
+func withServices(ctx context.Context, cfg *config.Config, fn func(cars.Service, companies.Service) error) (err error) {
+	store, err := openStorage(ctx, cfg)
+
+	if err != nil {
+		return fmt.Errorf("database connection: %w", err)
+	}
+
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if closeErr := store.close(closeCtx); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("closing storage: %w", closeErr))
+		}
+	}()
+
+	return fn(newCarService(cfg, store), companies.NewService(store.companies, store.cars))
+}
+
+// formatFlag declares the -format flag of the commands that read or write
+// car files. An empty format is guessed from the file name.
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "", "json, ndjson or csv (default: from the file extension, else json)")
+}
+
+func fileFormat(name, path string) (carsio.Format, error) {
+	if name != "" {
+		return carsio.ParseFormat(name)
+	}
+	if format, ok := carsio.FormatFromPath(path); ok {
+		return format, nil
+	}
+	return carsio.JSON, nil
+}
+
+// openInput opens path for reading, with "" and "-" meaning stdin.
+func (c *cli) openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(c.stdin), nil
+	}
+	return os.Open(path)
+}
+
+// openOutput creates path for writing, with "" and "-" meaning stdout.
+func (c *cli) openOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{c.stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
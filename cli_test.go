@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// boltCLI runs commands against a bolt file that outlives each command,
+// the way an operator would use them.
+type boltCLI struct {
+	t   *testing.T
+	env map[string]string
+}
+
+func newBoltCLI(t *testing.T) *boltCLI {
+	return &boltCLI{t: t, env: map[string]string{
+		"CAR_STORAGE":   "bolt",
+		"CAR_BOLT_PATH": filepath.Join(t.TempDir(), "cars.db"),
+	}}
+}
+
+func (b *boltCLI) run(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	c := &cli{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(key string) string { return b.env[key] },
+	}
+	err := runCommand(context.Background(), c, args)
+	return stdout.String(), err
+}
+
+func (b *boltCLI) mustRun(stdin string, args ...string) string {
+	b.t.Helper()
+	out, err := b.run(stdin, args...)
+	require.NoError(b.t, err)
+	return out
+}
+
+func (b *boltCLI) list(args ...string) []entities.Car {
+	b.t.Helper()
+	var list []entities.Car
+	out := b.mustRun("", append([]string{"cars", "list", "-output", "json"}, args...)...)
+	require.NoError(b.t, json.Unmarshal([]byte(out), &list))
+	return list
+}
+
+func TestSeed(t *testing.T) {
+	b := newBoltCLI(t)
+
+	var fixtures []entities.Car
+	require.NoError(t, json.Unmarshal(fixtureCars, &fixtures))
+
+	assert.Equal(t, "Imported 8 cars\n", b.mustRun("", "seed"))
+	assert.Len(t, b.list(), len(fixtures))
+
+	_, err := b.run("", "seed")
+	assert.ErrorContains(t, err, "already holds 8 cars")
+
+	b.mustRun("", "seed", "-force")
+	assert.Len(t, b.list("-limit", "100"), 2*len(fixtures))
+}
+
+func TestImportExport(t *testing.T) {
+	b := newBoltCLI(t)
+
+	csv := "carName,company\nCX-5,Mazda\nCorolla,Toyota\nMX-5,mazda\n"
+	assert.Equal(t, "Imported 3 cars\n", b.mustRun(csv, "import", "-format", "csv"))
+
+	// Companies are created as cars name them, whatever the case.
+	mazdas := b.list("-company", "Mazda")
+	require.Len(t, mazdas, 2)
+	assert.Equal(t, mazdas[0].CompanyID, mazdas[1].CompanyID)
+
+	out := b.mustRun("", "export", "-format", "ndjson", "-company", "Mazda")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 2)
+
+	// The format follows the file extension, and flags may follow the file.
+	path := filepath.Join(t.TempDir(), "cars.csv")
+	b.mustRun("", "export", path, "-sort", "carName")
+	exported, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Regexp(t, `^id,vin,carName,companyId,company,modelYear,.*\n.*,,CX-5,\w+,Mazda,`, string(exported))
+
+	// Exported files import back.
+	other := newBoltCLI(t)
+	assert.Equal(t, "Imported 3 cars\n", other.mustRun("", "import", path))
+}
+
+func TestImportStopsAtInvalidCar(t *testing.T) {
+	b := newBoltCLI(t)
+
+	ndjson := `{"carName": "CX-5", "company": "Mazda"}` + "\n" + `{"carName": "", "company": "Mazda"}` + "\n"
+	_, err := b.run(ndjson, "import", "-format", "ndjson")
+
+	assert.ErrorContains(t, err, "imported 1 cars, then: record 2")
+	assert.Len(t, b.list(), 1)
+}
+
+func TestCarsCommand(t *testing.T) {
+	b := newBoltCLI(t)
+	b.mustRun("", "seed")
+
+	mazdas := b.list("-company", "Mazda")
+	require.Len(t, mazdas, 2)
+	id := mazdas[0].ID.String()
+
+	table := b.mustRun("", "cars", "list", "-company", "Mazda")
+	assert.Contains(t, table, "ID  ")
+	assert.Contains(t, table, id)
+	assert.Contains(t, table, "1-2 of 2 cars")
+
+	assert.Contains(t, b.mustRun("", "cars", "get", id), "CX-5")
+
+	_, err := b.run("", "cars", "delete", id, "-version", "7")
+	assert.ErrorContains(t, err, "version mismatch")
+
+	assert.Equal(t, "Deleted "+id+"\n", b.mustRun("", "cars", "delete", id))
+	_, err = b.run("", "cars", "get", id)
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	b := newBoltCLI(t)
+
+	tests := []struct {
+		args     []string
+		contains string
+	}{
+		{[]string{"launch"}, `unknown command "launch"`},
+		{[]string{"cars"}, "usage: carshop cars"},
+		{[]string{"cars", "paint"}, `unknown cars action "paint"`},
+		{[]string{"cars", "get"}, "usage: carshop cars get"},
+		{[]string{"serve", "extra"}, "usage: carshop serve"},
+		{[]string{"export", "-format", "xml"}, `unknown format "xml"`},
+		{[]string{"export", "-sort", "colour"}, `cannot sort by "colour"`},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			_, err := b.run("", test.args...)
+			assert.ErrorContains(t, err, test.contains)
+		})
+	}
+
+	_, err := b.run("", "seed", "-h")
+	assert.ErrorIs(t, err, flag.ErrHelp)
+
+	out := b.mustRun("", "help")
+	assert.Contains(t, out, "usage: carshop <command>")
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/cars/carsio"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/entities"
+	"time"
+)
+
+//go:embed fixtures/cars.json
+var fixtureCars []byte
+
+// seedCommand runs "seed [flags]". It refuses to add cars to storage that
+// already holds some, unless forced, so that running it twice does not
+// duplicate the fixtures.
+func seedCommand(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("seed")
+	file := fs.String("file", "", "fixture file to load instead of the built-in cars")
+	format := formatFlag(fs)
+	force := fs.Bool("force", false, "seed even if storage already holds cars")
+	cfg, _, err := c.load(fs, "seed [flags]", args, 0, 0)
+
+	if err != nil {
+		return err
+	}
+
+	in := io.NopCloser(bytes.NewReader(fixtureCars))
+	if *file != "" {
+		if in, err = c.openInput(*file); err != nil {
+			return err
+		}
+	}
+	defer in.Close()
+
+	reader, err := newCarReader(in, *format, *file)
+
+	if err != nil {
+		return err
+	}
+
+	return withServices(ctx, cfg, func(service cars.Service, companyService companies.Service) error {
+		if !*force {
+			page, err := service.CheckCarService(ctx, &entities.CarQuery{Limit: 1})
+			if err != nil {
+				return err
+			}
+			if page.Total > 0 {
+				return fmt.Errorf("storage already holds %d cars; use -force to seed anyway", page.Total)
+			}
+		}
+		return importCars(ctx, service, companyService, reader, c.stdout)
+	})
+}
+
+// importCommand runs "import [flags] [file]", reading stdin when no file
+// is given. Cars get new IDs, as when created through the API.
+func importCommand(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("import")
+	format := formatFlag(fs)
+	cfg, rest, err := c.load(fs, "import [flags] [file]", args, 0, 1)
+
+	if err != nil {
+		return err
+	}
+
+	path := ""
+	if len(rest) > 0 {
+		path = rest[0]
+	}
+
+	in, err := c.openInput(path)
+
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	reader, err := newCarReader(in, *format, path)
+
+	if err != nil {
+		return err
+	}
+
+	return withServices(ctx, cfg, func(service cars.Service, companyService companies.Service) error {
+		return importCars(ctx, service, companyService, reader, c.stdout)
+	})
+}
+
+func newCarReader(in io.Reader, format, path string) (carsio.Reader, error) {
+	fileFormat, err := fileFormat(format, path)
+	if err != nil {
+		return nil, err
+	}
+	return carsio.NewReader(in, fileFormat)
+}
+
+// importCars inserts every car of reader through the service, so imported
+// cars are validated like those created through the API and, like them,
+// come in stock whatever status they had. Cars are matched to companies by
+// name, since company IDs from another store mean nothing here, and
+// companies not known yet are created. It stops at the first car that
+// fails and reports how many went in before it.
+func importCars(ctx context.Context, service cars.Service, companyService companies.Service, reader carsio.Reader, out io.Writer) error {
+	imported := 0
+	known := map[string]bool{}
+	for {
+		car, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("imported %d cars, then: %w", imported, err)
+		}
+
+		car.CompanyID = ""
+		if key := strings.ToLower(car.Company); !known[key] {
+			if err := ensureCompany(ctx, companyService, car.Company); err != nil {
+				return fmt.Errorf("imported %d cars, then: record %d: %w", imported, imported+1, err)
+			}
+			known[key] = true
+		}
+
+		if _, err := service.InsertCarService(ctx, car); err != nil {
+			return fmt.Errorf("imported %d cars, then: record %d: %w", imported, imported+1, err)
+		}
+		imported++
+	}
+
+	fmt.Fprintf(out, "Imported %d cars\n", imported)
+	return nil
+}
+
+// ensureCompany creates the company named name unless it exists. Names no
+// company could have are left for InsertCarService to report.
+func ensureCompany(ctx context.Context, companyService companies.Service, name string) error {
+	_, err := companyService.InsertCompanyService(ctx, &entities.Company{Name: name})
+
+	if err == nil || errors.Is(err, companies.ErrConflict) || errors.Is(err, companies.ErrValidation) {
+		return nil
+	}
+	return err
+}
+
+// exportCommand runs "export [flags] [file]", writing to stdout when no
+// file is given.
+func exportCommand(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("export")
+	format := formatFlag(fs)
+	query := queryFlags(fs)
+	cfg, rest, err := c.load(fs, "export [flags] [file]", args, 0, 1)
+
+	if err != nil {
+		return err
+	}
+
+	path := ""
+	if len(rest) > 0 {
+		path = rest[0]
+	}
+
+	outFormat, err := fileFormat(*format, path)
+
+	if err != nil {
+		return err
+	}
+
+	carQuery, err := query()
+
+	if err != nil {
+		return err
+	}
+
+	out, err := c.openOutput(path)
+
+	if err != nil {
+		return err
+	}
+
+	err = withService(ctx, cfg, func(service cars.Service) error {
+		writer := carsio.NewWriter(out, outFormat)
+		if err := exportCars(ctx, service, carQuery, writer); err != nil {
+			return err
+		}
+		return writer.Close()
+	})
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// exportCars writes every car matching query as it is read from storage.
+func exportCars(ctx context.Context, service cars.Service, query *entities.CarQuery, writer carsio.Writer) error {
+	return service.ScanCarsService(ctx, query, writer.Write)
+}
+
+// queryFlags declares the listing filters shared by export and cars list.
+// The returned function builds the query once the flags are parsed.
+func queryFlags(fs *flag.FlagSet) func() (*entities.CarQuery, error) {
+	company := fs.String("company", "", "only cars of this company")
+	namePrefix := fs.String("name-prefix", "", "only cars whose name starts with this")
+	madeFrom := fs.String("made-from", "", "only cars made at or after this RFC 3339 time")
+	madeTo := fs.String("made-to", "", "only cars made at or before this RFC 3339 time")
+	sortBy := fs.String("sort", "id", "field to order by: "+strings.Join(cars.SortFields(), ", "))
+	descending := fs.Bool("desc", false, "order from last to first")
+
+	return func() (*entities.CarQuery, error) {
+		if !cars.IsSortField(*sortBy) {
+			return nil, fmt.Errorf("cannot sort by %q", *sortBy)
+		}
+
+		query := &entities.CarQuery{
+			Company:       *company,
+			CarNamePrefix: *namePrefix,
+			SortBy:        *sortBy,
+			Descending:    *descending,
+		}
+
+		var err error
+		if query.MadeFrom, err = parseTimeFlag("made-from", *madeFrom); err != nil {
+			return nil, err
+		}
+		if query.MadeTo, err = parseTimeFlag("made-to", *madeTo); err != nil {
+			return nil, err
+		}
+		return query, nil
+	}
+}
+
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-%s: %w", name, err)
+	}
+	return t, nil
+}
@@ -2,53 +2,394 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testingfiber/api/handlers"
 	"testingfiber/api/routes"
 	"testingfiber/pkg/cars"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/config"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/orders"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+	bolt "go.etcd.io/bbolt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func main() {
-	db, cancel, err := databaseConnection()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := runCommand(ctx, &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}, os.Args[1:])
+	stop()
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serveCommand runs "serve [flags]".
+func serveCommand(ctx context.Context, c *cli, args []string) error {
+	cfg, _, err := c.load(newFlagSet("serve"), "serve [flags]", args, 0, 0)
+
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", cfg.Server.Addr)
+
+	if err != nil {
+		return err
+	}
+
+	if err := run(ctx, cfg, ln); err != nil {
+		return err
+	}
+
+	log.Println("Shutdown complete")
+	return nil
+}
+
+// run serves the car shop on ln until ctx is done, then drains in-flight
+// requests and releases the storage backend. It owns ln and closes it on
+// every path.
+func run(ctx context.Context, cfg *config.Config, ln net.Listener) (err error) {
+	store, err := openStorage(ctx, cfg)
 
 	if err != nil {
-		log.Fatal("Database Connection Error $s", err)
+		ln.Close()
+		return fmt.Errorf("database connection: %w", err)
 	}
 
-	fmt.Println("Database connection success!")
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if closeErr := store.close(closeCtx); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("closing storage: %w", closeErr))
+		}
+	}()
+
+	carService := newCarService(cfg, store)
+	companyService := companies.NewService(store.companies, store.cars)
+	customerService := customers.NewService(store.customers, store.orders)
+	orderService := orders.NewService(store.orders, store.customers, store.cars,
+		orders.WithTaxRate(int64(cfg.Orders.TaxRate)))
+	checks := map[string]handlers.Check{"storage": store.cars.Ping}
+
+	app := newApp(cfg, carService, companyService, customerService, orderService, checks)
+	return serve(ctx, app, ln, cfg.Server.ShutdownTimeout)
+}
 
-	carCollection := db.Collection("cars")
-	carRepo := cars.NewRepo(carCollection)
-	carService := cars.NewService(carRepo)
+// newCarService builds the service shared by the HTTP API and the
+// commands that change cars, so they apply the same rules.
+func newCarService(cfg *config.Config, store *storage) cars.Service {
+	return cars.NewService(store.cars,
+		cars.WithValidator(cars.NewValidator(cfg.Companies...)),
+		cars.WithBatchLimit(cfg.Server.MaxBatchSize),
+		cars.WithCompanies(store.companies))
+}
 
-	app := fiber.New()
-	app.Use(cors.New())
+func newApp(cfg *config.Config, carService cars.Service, companyService companies.Service, customerService customers.Service, orderService orders.Service, checks map[string]handlers.Check) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: handlers.ErrorHandler,
+		// Shutdown only closes connections once they are idle; a
+		// keep-alive connection that has not sent a request yet is held
+		// until it times out.
+		ReadTimeout: cfg.Server.ReadTimeout,
+		IdleTimeout: cfg.Server.IdleTimeout,
+	})
+	if cfg.LogLevel == "debug" {
+		app.Use(logger.New())
+	}
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: strings.Join(cfg.Server.CORSOrigins, ","),
+		// Browser clients need the ETag to send If-Match on writes.
+		ExposeHeaders: fiber.HeaderETag,
+	}))
 	app.Get("/", func(ctx *fiber.Ctx) error {
 		return ctx.Send([]byte("Welcome to the clean-architecture mongo car shop!"))
 	})
 
-	api := app.Group("/api")
+	app.Use("/readyz", handlers.RequestTimeout(cfg.Server.RequestTimeout))
+	routes.HealthRouter(app, checks)
+
+	api := app.Group("/api", handlers.RequestTimeout(cfg.Server.RequestTimeout))
 	routes.CarRouter(api, carService)
-	defer cancel()
-	log.Fatal(app.Listen(":8080"))
+	routes.CompanyRouter(api, companyService)
+	routes.CustomerRouter(api, customerService)
+	routes.OrderRouter(api, orderService)
+	return app
+}
+
+// serve runs app on ln until ctx is done. It then stops accepting
+// connections and waits up to timeout for in-flight requests to finish;
+// requests still running after that are cut off and the deadline error is
+// returned.
+func serve(ctx context.Context, app *fiber.App, ln net.Listener, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- app.Listener(ln) }()
 
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, draining in-flight requests")
+	shutdownErr := app.ShutdownWithTimeout(timeout)
+	// A signal that arrives before the server has registered the listener
+	// leaves nothing for Shutdown to close, so close it here as well.
+	ln.Close()
+
+	if err := <-errc; err != nil {
+		return err
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("shutdown: %w", shutdownErr)
+	}
+	return nil
+}
+
+// storage is the configured storage backend: the repositories of cars,
+// their companies, the customers buying them and their orders, which live
+// side by side in it, and the function that releases it on shutdown.
+type storage struct {
+	cars      cars.Repository
+	companies companies.Repository
+	customers customers.Repository
+	orders    orders.Repository
+	close     func(context.Context) error
+}
+
+// openStorage opens the configured storage backend. It does not return
+// until the backend answers a ping.
+func openStorage(ctx context.Context, cfg *config.Config) (*storage, error) {
+	ids, err := entities.NewIDGenerator(cfg.IDFormat)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Storage {
+	case "memory":
+		log.Println("Using in-memory car storage")
+		carRepo := cars.NewMemoryRepo(cars.WithIDGenerator(ids))
+		return &storage{
+			cars:      carRepo,
+			companies: companies.NewMemoryRepo(companies.WithIDGenerator(ids)),
+			customers: customers.NewMemoryRepo(customers.WithIDGenerator(ids)),
+			orders:    orders.NewMemoryRepo(carRepo, orders.WithIDGenerator(ids)),
+			close:     func(context.Context) error { return nil },
+		}, nil
+	case "postgres":
+		return postgresStorage(ctx, cfg.Postgres, ids)
+	case "bolt":
+		return boltStorage(cfg.Bolt, ids)
+	}
+
+	client, err := mongoClient(ctx, cfg.Mongo)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db := client.Database(cfg.Mongo.Database)
+	carCollection := db.Collection(cfg.Mongo.Collection)
+
+	if cfg.Mongo.AutoMigrate {
+		if err := migrateUp(ctx, mongoMigrator(db, cfg), log.Writer()); err != nil {
+			client.Disconnect(context.Background())
+			return nil, err
+		}
+	}
+
+	companyRepo := companies.NewMongoRepo(db.Collection(cfg.Mongo.CompaniesCollection), companies.WithIDGenerator(ids))
+	customerRepo := customers.NewMongoRepo(db.Collection(cfg.Mongo.CustomersCollection), customers.WithIDGenerator(ids))
+	carRepo := cars.NewRepo(carCollection, cars.WithIDGenerator(ids))
+	orderRepo := orders.NewMongoRepo(db.Collection(cfg.Mongo.OrdersCollection), carRepo, orders.WithIDGenerator(ids))
+
+	log.Println("Database connection success!")
+
+	return &storage{
+		cars:      carRepo,
+		companies: companyRepo,
+		customers: customerRepo,
+		orders:    orderRepo,
+		close:     client.Disconnect,
+	}, nil
 }
 
-func databaseConnection() (*mongo.Database, context.CancelFunc, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(
-		"mongodb://localhost:27017/cars").SetServerSelectionTimeout(5*time.
-		Second))
+// postgresStorage connects to PostgreSQL and brings the schema up to date
+// before handing out the repositories.
+func postgresStorage(ctx context.Context, cfg config.Postgres, ids entities.IDGenerator) (*storage, error) {
+	pool, err := postgresPool(ctx, cfg)
+
 	if err != nil {
-		cancel()
-		return nil, nil, err
+		return nil, err
 	}
-	db := client.Database("cars")
-	return db, cancel, nil
+
+	if err := migrateUp(ctx, postgresMigrator(pool), log.Writer()); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	log.Println("Database connection success!")
+
+	carRepo := cars.NewPostgresRepo(pool, cars.WithIDGenerator(ids))
+	return &storage{
+		cars:      carRepo,
+		companies: companies.NewPostgresRepo(pool, companies.WithIDGenerator(ids)),
+		customers: customers.NewPostgresRepo(pool, customers.WithIDGenerator(ids)),
+		orders:    orders.NewPostgresRepo(pool, carRepo, orders.WithIDGenerator(ids)),
+		close: func(context.Context) error {
+			pool.Close()
+			return nil
+		},
+	}, nil
+}
+
+// boltStorage opens the embedded store's data file, creating it if needed.
+// The file is locked while the server runs.
+func boltStorage(cfg config.Bolt, ids entities.IDGenerator) (*storage, error) {
+	db, err := bolt.Open(cfg.Path, 0o600, &bolt.Options{Timeout: cfg.OpenTimeout})
+
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", cfg.Path, err)
+	}
+
+	carRepo, err := cars.NewBoltRepo(db, cars.WithIDGenerator(ids))
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	companyRepo, err := companies.NewBoltRepo(db, companies.WithIDGenerator(ids))
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	customerRepo, err := customers.NewBoltRepo(db, customers.WithIDGenerator(ids))
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	orderRepo, err := orders.NewBoltRepo(db, carRepo, orders.WithIDGenerator(ids))
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Println("Using embedded car storage in", cfg.Path)
+
+	return &storage{
+		cars:      carRepo,
+		companies: companyRepo,
+		customers: customerRepo,
+		orders:    orderRepo,
+		close:     func(context.Context) error { return db.Close() },
+	}, nil
+}
+
+// mongoClient connects to MongoDB and waits until the primary answers.
+func mongoClient(ctx context.Context, cfg config.Mongo) (*mongo.Client, error) {
+	client, err := databaseConnection(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ping := func(ctx context.Context) error { return client.Ping(ctx, readpref.Primary()) }
+
+	if err := pingWithRetry(ctx, ping, cfg.ConnectRetries, cfg.RetryBackoff); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// postgresPool connects to PostgreSQL and waits until the server answers.
+func postgresPool(ctx context.Context, cfg config.Postgres) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pingWithRetry(ctx, pool.Ping, cfg.ConnectRetries, cfg.RetryBackoff); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// maxRetryBackoff caps the doubling wait between startup pings.
+const maxRetryBackoff = 30 * time.Second
+
+// pingWithRetry calls ping until it succeeds, retrying up to retries times
+// and doubling the wait after each failure. It gives up early when ctx is
+// done, so a shutdown signal during startup is not held up.
+func pingWithRetry(ctx context.Context, ping handlers.Check, retries int, backoff time.Duration) error {
+	for attempt := 0; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return fmt.Errorf("ping failed after %d attempts: %w", attempt+1, err)
+		}
+
+		log.Printf("Database ping failed (attempt %d of %d), retrying in %s: %v", attempt+1, retries+1, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func databaseConnection(cfg config.Mongo) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	return mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI).
+		SetServerSelectionTimeout(cfg.ServerSelectionTimeout).
+		SetMinPoolSize(cfg.MinPoolSize).
+		SetMaxPoolSize(cfg.MaxPoolSize))
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"testingfiber/pkg/config"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return ln
+}
+
+func newTestApp() *fiber.App {
+	return fiber.New(fiber.Config{DisableStartupMessage: true})
+}
+
+// waitFor polls url until the server answers.
+func waitFor(t *testing.T, url string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRunShutsDownOnCancel(t *testing.T) {
+	for _, storage := range []string{"memory", "bolt"} {
+		t.Run(storage, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Storage = storage
+			cfg.Bolt.Path = filepath.Join(t.TempDir(), "cars.db")
+			cfg.Server.ReadTimeout = 200 * time.Millisecond
+			cfg.Server.IdleTimeout = 200 * time.Millisecond
+			ln := listen(t)
+			url := "http://" + ln.Addr().String()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- run(ctx, cfg, ln) }()
+
+			waitFor(t, url+"/")
+			for _, path := range []string{"/api/cars", "/healthz", "/readyz"} {
+				resp, err := http.Get(url + path)
+				require.NoError(t, err)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+			}
+			// A keep-alive connection that never sends a request must not
+			// hold up the shutdown.
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			cancel()
+
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("run did not return after cancel")
+			}
+
+			_, err = net.Dial("tcp", ln.Addr().String())
+			assert.Error(t, err, "listener should be closed after shutdown")
+
+			if storage == "bolt" {
+				// The data file lock is released on shutdown.
+				db, err := bolt.Open(cfg.Bolt.Path, 0o600, &bolt.Options{Timeout: 100 * time.Millisecond})
+				require.NoError(t, err)
+				db.Close()
+			}
+		})
+	}
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	app := newTestApp()
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return c.SendString("done")
+	})
+	ln := listen(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, app, ln, 5*time.Second) }()
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-started
+	cancel()
+
+	assert.Equal(t, http.StatusOK, <-status)
+	assert.NoError(t, <-done)
+}
+
+func TestServeShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	app := newTestApp()
+	app.Get("/stuck", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return nil
+	})
+	ln := listen(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, app, ln, 50*time.Millisecond) }()
+
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not give up after the shutdown timeout")
+	}
+}
+
+func TestServeCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, newTestApp(), listen(t), time.Second) }()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return for an already cancelled context")
+	}
+}
+
+func TestPingWithRetry(t *testing.T) {
+	unreachable := errors.New("unreachable")
+
+	t.Run("recovers", func(t *testing.T) {
+		calls := 0
+		ping := func(context.Context) error {
+			if calls++; calls < 3 {
+				return unreachable
+			}
+			return nil
+		}
+
+		assert.NoError(t, pingWithRetry(context.Background(), ping, 5, time.Millisecond))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("givesUp", func(t *testing.T) {
+		calls := 0
+		ping := func(context.Context) error {
+			calls++
+			return unreachable
+		}
+
+		err := pingWithRetry(context.Background(), ping, 2, time.Millisecond)
+		assert.ErrorIs(t, err, unreachable)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ping := func(context.Context) error {
+			cancel()
+			return unreachable
+		}
+
+		err := pingWithRetry(ctx, ping, 5, time.Hour)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/config"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/migrate"
+	"testingfiber/pkg/orders"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrateCommand runs "migrate [up|down|status] [flags]". The action
+// defaults to up.
+func migrateCommand(ctx context.Context, c *cli, args []string) error {
+	cfg, rest, err := c.load(newFlagSet("migrate"), "migrate [up|down|status] [flags]", args, 0, 1)
+
+	if err != nil {
+		return err
+	}
+
+	action := "up"
+	if len(rest) > 0 {
+		action = rest[0]
+	}
+
+	return runMigration(ctx, cfg, action, c.stdout)
+}
+
+// runMigration runs one migrate action against the configured storage backend
+// and reports the outcome on out.
+func runMigration(ctx context.Context, cfg *config.Config, action string, out io.Writer) error {
+	if action != "up" && action != "down" && action != "status" {
+		return fmt.Errorf("unknown migrate action %q, want up, down or status", action)
+	}
+
+	migrator, closeMigrator, err := storageMigrator(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+	defer closeMigrator()
+
+	switch action {
+	case "up":
+		return migrateUp(ctx, migrator, out)
+	case "down":
+		version, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			fmt.Fprintln(out, "No migrations to roll back")
+		} else {
+			fmt.Fprintln(out, "Rolled back", version)
+		}
+		return nil
+	}
+
+	statuses, err := migrator.Status(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied() {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", status.Version, applied)
+	}
+	return w.Flush()
+}
+
+// migrateUp applies the pending migrations and lists them on out.
+func migrateUp(ctx context.Context, migrator migrate.Migrator, out io.Writer) error {
+	versions, err := migrator.Up(ctx)
+	for _, version := range versions {
+		fmt.Fprintln(out, "Applied", version)
+	}
+
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		fmt.Fprintln(out, "Schema is up to date")
+	}
+	return nil
+}
+
+// storageMigrator connects to the configured backend and returns its
+// migrator, along with the function that disconnects.
+func storageMigrator(ctx context.Context, cfg *config.Config) (migrate.Migrator, func(), error) {
+	switch cfg.Storage {
+	case "mongo":
+		client, err := mongoClient(ctx, cfg.Mongo)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mongoMigrator(client.Database(cfg.Mongo.Database), cfg), func() { client.Disconnect(context.Background()) }, nil
+	case "postgres":
+		pool, err := postgresPool(ctx, cfg.Postgres)
+		if err != nil {
+			return nil, nil, err
+		}
+		return postgresMigrator(pool), pool.Close, nil
+	}
+	return nil, nil, fmt.Errorf("storage %q has no migrations", cfg.Storage)
+}
+
+// mongoMigrator runs the migrations of every collection the server uses.
+func mongoMigrator(db *mongo.Database, cfg *config.Config) migrate.Migrator {
+	return migrate.Chain(
+		companies.NewMongoMigrator(db.Collection(cfg.Mongo.CompaniesCollection)),
+		cars.NewMongoMigrator(db.Collection(cfg.Mongo.Collection)),
+		customers.NewMongoMigrator(db.Collection(cfg.Mongo.CustomersCollection)),
+		orders.NewMongoMigrator(db.Collection(cfg.Mongo.OrdersCollection)),
+	)
+}
+
+// postgresMigrator runs the migrations of every table the server uses, in
+// the order their references require.
+func postgresMigrator(pool *pgxpool.Pool) migrate.Migrator {
+	return migrate.Chain(
+		companies.NewPostgresMigrator(pool),
+		cars.NewPostgresMigrator(pool),
+		customers.NewPostgresMigrator(pool),
+		orders.NewPostgresMigrator(pool),
+	)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"testingfiber/pkg/config"
+	"testingfiber/pkg/migrate"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMigrator struct {
+	applied []string
+	err     error
+}
+
+func (m *fakeMigrator) Up(context.Context) ([]string, error) {
+	return m.applied, m.err
+}
+
+func (m *fakeMigrator) Down(context.Context) (string, error) {
+	return "", migrate.ErrIrreversible
+}
+
+func (m *fakeMigrator) Status(context.Context) ([]migrate.Status, error) {
+	return nil, nil
+}
+
+func TestMigrateUp(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, migrateUp(context.Background(), &fakeMigrator{applied: []string{"0001_a", "0002_b"}}, &out))
+	assert.Equal(t, "Applied 0001_a\nApplied 0002_b\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, migrateUp(context.Background(), &fakeMigrator{}, &out))
+	assert.Equal(t, "Schema is up to date\n", out.String())
+
+	// Migrations applied before a failure are still reported.
+	out.Reset()
+	broken := errors.New("broken")
+	assert.ErrorIs(t, migrateUp(context.Background(), &fakeMigrator{applied: []string{"0001_a"}, err: broken}, &out), broken)
+	assert.Equal(t, "Applied 0001_a\n", out.String())
+}
+
+func TestMigrateErrors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage = "memory"
+
+	err := runMigration(context.Background(), cfg, "sideways", &bytes.Buffer{})
+	assert.ErrorContains(t, err, `unknown migrate action "sideways"`)
+
+	err = runMigration(context.Background(), cfg, "up", &bytes.Buffer{})
+	assert.ErrorContains(t, err, `storage "memory" has no migrations`)
+}
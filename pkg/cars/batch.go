@@ -0,0 +1,50 @@
+package cars
+
+import (
+	"errors"
+	"testingfiber/pkg/entities"
+)
+
+// BatchResult is the outcome of one item of a batch write. Car is the
+// stored car after a create or an update; it is nil for deletes and for
+// items that failed.
+type BatchResult struct {
+	Car *entities.Car
+	Err error
+}
+
+// errBatchFailed rolls back the transaction of an atomic batch once an
+// item has failed.
+var errBatchFailed = errors.New("batch item failed")
+
+// batchFailed reports whether any item of a batch failed.
+func batchFailed(results []BatchResult) bool {
+	for _, result := range results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// abortBatch records that an atomic batch was rolled back: items that did
+// not fail themselves are reported as aborted, and no item keeps a car.
+func abortBatch(results []BatchResult) {
+	for i := range results {
+		results[i].Car = nil
+		if results[i].Err == nil {
+			results[i].Err = ErrBatchAborted
+		}
+	}
+}
+
+// isItemError tells the errors of a single item, which a batch reports
+// and moves past, from failures of the storage itself.
+func isItemError(err error) bool {
+	for _, target := range []error{ErrCarNotFound, ErrInvalidID, ErrVersionMismatch, ErrConflict, ErrValidation} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,601 @@
+package cars
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltCarsBucket     = []byte("cars")
+	boltCompanyIndex   = []byte("cars_by_company")
+	boltCarNameIndex   = []byte("cars_by_carName")
+	boltVINIndex       = []byte("cars_by_vin")
+	boltIndexSeparator = []byte{0}
+)
+
+// boltRepository stores cars in a single bbolt file, for deployments that
+// cannot run a database server. Cars are JSON documents keyed by ID. The
+// company and carName indexes hold "value\x00ID" keys, so a listing
+// filtered on either reads only the matching cars; what remains is
+// filtered, sorted and paged in process like the memory repository does.
+// The VIN index maps each VIN to its car, keeping VINs unique.
+type boltRepository struct {
+	db     *bolt.DB
+	ids    entities.IDGenerator
+	search *searchIndex
+}
+
+// BoltRepository is the Repository of NewBoltRepo, which can also update a
+// car together with other writes to the same file.
+type BoltRepository interface {
+	Repository
+	UpdateCarWith(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch, also func(tx *bolt.Tx, car *entities.Car) error) (*entities.Car, error)
+}
+
+// NewBoltRepo returns a repository backed by db, creating its buckets when
+// the file is new. The caller owns db and closes it.
+func NewBoltRepo(db *bolt.DB, opts ...RepositoryOption) (BoltRepository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltCarsBucket, boltCompanyIndex, boltCarNameIndex, boltVINIndex} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	o := store.NewOptions(opts)
+	r := &boltRepository{db: db, ids: o.IDs}
+	r.search = newSearchIndex(func(ctx context.Context, visit func(*entities.Car) error) error {
+		return r.ScanCars(ctx, &entities.CarQuery{}, visit)
+	}, 0)
+	return r, nil
+}
+
+func (r *boltRepository) InsertCar(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	car.ID = entities.CarID(r.ids.NewID())
+	car.MadeAt = madeAtOrNow(car.MadeAt, time.Now())
+	car.Version = 1
+
+	err := r.update(func(tx *bolt.Tx) error {
+		if err := insertCar(tx, car); err != nil {
+			return err
+		}
+		r.search.put(car)
+		return nil
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	return car, nil
+}
+
+func insertCar(tx *bolt.Tx, car *entities.Car) error {
+	if tx.Bucket(boltCarsBucket).Get([]byte(car.ID)) != nil {
+		return fmt.Errorf("%w: car %s already exists", ErrConflict, car.ID)
+	}
+	if err := checkVIN(tx, car); err != nil {
+		return err
+	}
+	return putCar(tx, car)
+}
+
+// checkVIN rejects a car whose VIN another car already has. It runs before
+// anything is written, so a failed item leaves the transaction untouched.
+func checkVIN(tx *bolt.Tx, car *entities.Car) error {
+	if car.VIN == "" {
+		return nil
+	}
+	if owner := tx.Bucket(boltVINIndex).Get([]byte(car.VIN)); owner != nil && string(owner) != string(car.ID) {
+		return fmt.Errorf("%w: VIN %s is already taken", ErrConflict, car.VIN)
+	}
+	return nil
+}
+
+func (r *boltRepository) CheckCar(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	matched, err := r.match(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return sortAndPage(matched, query), nil
+}
+
+// boltScanBatch is how many cars ScanCars reads per read transaction when
+// it walks them in ID order.
+const boltScanBatch = 100
+
+// ScanCars visits the matching cars outside any transaction, so visit may
+// write to the store. In ID order, the default, it walks the keys a batch
+// of cars at a time, picking up after the last key of the previous batch,
+// so memory use does not grow with the number of matches and the cars are
+// read as they are reached. Any other order needs every match at hand to
+// sort them, so those are collected in one read transaction first.
+func (r *boltRepository) ScanCars(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	if lookupSortField(query.SortBy) != &sortFields[0] {
+		matched, err := r.match(ctx, query)
+		if err != nil {
+			return err
+		}
+		sortCars(matched, query)
+		return visitCars(ctx, matched, visit)
+	}
+
+	var after []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch, last, err := r.scanBatch(ctx, query, after)
+		if err != nil {
+			return err
+		}
+		if err := visitCars(ctx, batch, visit); err != nil {
+			return err
+		}
+		if last == nil {
+			return nil
+		}
+		after = last
+	}
+}
+
+// scanBatch reads up to boltScanBatch matching cars in ID order, starting
+// after the key after, or from the first key when it is nil. It walks the
+// company index for a company filter, whose keys for one company end in
+// the IDs in order, and the cars otherwise. It returns the last key it
+// read, or nil once there are no more.
+func (r *boltRepository) scanBatch(ctx context.Context, query *entities.CarQuery, after []byte) (batch []entities.Car, last []byte, err error) {
+	err = r.db.View(func(tx *bolt.Tx) error {
+		cars := tx.Bucket(boltCarsBucket)
+		keys, prefix := cars, []byte(nil)
+		if query.Company != "" {
+			keys, prefix = tx.Bucket(boltCompanyIndex), indexPrefix(query.Company)
+		}
+
+		cursor := keys.Cursor()
+		for key := seekAfter(cursor, prefix, after, query.Descending); key != nil && bytes.HasPrefix(key, prefix); key = step(cursor, query.Descending) {
+			car, err := decodeCar(cars.Get(key[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			if matchesQuery(car, query) {
+				batch = append(batch, *car)
+			}
+			if len(batch) == boltScanBatch {
+				// Keys are only valid within the transaction.
+				last = append([]byte{}, key...)
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, boltError(err)
+	}
+	return batch, last, nil
+}
+
+// seekAfter moves cursor to the first key with prefix that comes after the
+// key after in the walk's direction, or to the first one in that
+// direction when after is nil.
+func seekAfter(cursor *bolt.Cursor, prefix, after []byte, descending bool) []byte {
+	if !descending {
+		if after == nil {
+			key, _ := cursor.Seek(prefix)
+			return key
+		}
+		key, _ := cursor.Seek(after)
+		if bytes.Equal(key, after) {
+			key, _ = cursor.Next()
+		}
+		return key
+	}
+
+	// Going down, the walk starts below the first key at or past the
+	// bound: after, or the end of the prefix.
+	bound := after
+	if bound == nil {
+		bound = prefixEnd(prefix)
+	}
+	if bound == nil {
+		key, _ := cursor.Last()
+		return key
+	}
+	if key, _ := cursor.Seek(bound); key == nil {
+		key, _ = cursor.Last()
+		return key
+	}
+	key, _ := cursor.Prev()
+	return key
+}
+
+// step moves cursor on to the next key in the walk's direction.
+func step(cursor *bolt.Cursor, descending bool) []byte {
+	var key []byte
+	if descending {
+		key, _ = cursor.Prev()
+	} else {
+		key, _ = cursor.Next()
+	}
+	return key
+}
+
+// prefixEnd returns the smallest key above every key with prefix, or nil
+// when there is none, as for an empty prefix.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// match reads the cars matching the filters of query, using an index when
+// one applies.
+func (r *boltRepository) match(ctx context.Context, query *entities.CarQuery) ([]entities.Car, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matched []entities.Car
+	err := r.db.View(func(tx *bolt.Tx) error {
+		cars := tx.Bucket(boltCarsBucket)
+		visit := func(carId []byte) error {
+			car, err := decodeCar(cars.Get(carId))
+			if err != nil {
+				return err
+			}
+			if matchesQuery(car, query) {
+				matched = append(matched, *car)
+			}
+			return ctx.Err()
+		}
+
+		switch {
+		case query.Company != "":
+			return scanIndex(tx.Bucket(boltCompanyIndex), indexPrefix(query.Company), visit)
+		case query.CarNamePrefix != "":
+			return scanIndex(tx.Bucket(boltCarNameIndex), []byte(query.CarNamePrefix), visit)
+		}
+		return cars.ForEach(func(carId, _ []byte) error {
+			return visit(carId)
+		})
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return matched, nil
+}
+
+// scanIndex calls visit with the ID of every index entry whose key starts
+// with prefix.
+func scanIndex(index *bolt.Bucket, prefix []byte, visit func(carId []byte) error) error {
+	cursor := index.Cursor()
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		separator := bytes.LastIndexByte(key, 0)
+		if err := visit(key[separator+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *boltRepository) GetCarByID(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var car *entities.Car
+	err = r.db.View(func(tx *bolt.Tx) error {
+		car, err = getCar(tx, carId)
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	return car, nil
+}
+
+func (r *boltRepository) UpdateCar(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	return r.UpdateCarWith(ctx, ID, version, patch, func(*bolt.Tx, *entities.Car) error { return nil })
+}
+
+// UpdateCarWith is UpdateCar running also in the same transaction once
+// the car is updated, for writes that must commit together with it, such
+// as an order selling the car.
+func (r *boltRepository) UpdateCarWith(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch, also func(tx *bolt.Tx, car *entities.Car) error) (*entities.Car, error) {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var stored *entities.Car
+	err = r.update(func(tx *bolt.Tx) error {
+		if stored, err = updateCar(tx, carId, version, patch); err != nil {
+			return err
+		}
+		if err := also(tx, stored); err != nil {
+			return err
+		}
+		r.search.put(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	return stored, nil
+}
+
+func updateCar(tx *bolt.Tx, carId entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	stored, err := getCar(tx, carId)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && stored.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	if patch.IsEmpty() {
+		return stored, nil
+	}
+
+	updated := *stored
+	patch.Apply(&updated)
+	if err := checkVIN(tx, &updated); err != nil {
+		return nil, err
+	}
+	if err := deleteIndexes(tx, stored); err != nil {
+		return nil, err
+	}
+	updated.Version++
+	return &updated, putCar(tx, &updated)
+}
+
+func (r *boltRepository) DeleteCar(ctx context.Context, ID entities.CarID, version int64) error {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = r.update(func(tx *bolt.Tx) error {
+		if err := deleteCar(tx, carId, version); err != nil {
+			return err
+		}
+		r.search.remove(carId)
+		return nil
+	})
+
+	return boltError(err)
+}
+
+func deleteCar(tx *bolt.Tx, carId entities.CarID, version int64) error {
+	stored, err := getCar(tx, carId)
+	if err != nil {
+		return err
+	}
+	if version != 0 && stored.Version != version {
+		return ErrVersionMismatch
+	}
+
+	if err := deleteIndexes(tx, stored); err != nil {
+		return err
+	}
+	return tx.Bucket(boltCarsBucket).Delete([]byte(carId))
+}
+
+func (r *boltRepository) InsertCars(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(cars), atomic, func(tx *bolt.Tx, i int) (*entities.Car, error) {
+		car := cars[i]
+		car.ID = entities.CarID(r.ids.NewID())
+		car.MadeAt = madeAtOrNow(car.MadeAt, time.Now())
+		car.Version = 1
+		return car, insertCar(tx, car)
+	}, r.search.putResults)
+}
+
+func (r *boltRepository) UpdateCars(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(updates), atomic, func(tx *bolt.Tx, i int) (*entities.Car, error) {
+		carId, err := parseID(updates[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return updateCar(tx, carId, updates[i].Version, &updates[i].CarPatch)
+	}, r.search.putResults)
+}
+
+func (r *boltRepository) DeleteCars(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(deletes), atomic, func(tx *bolt.Tx, i int) (*entities.Car, error) {
+		carId, err := parseID(deletes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, deleteCar(tx, carId, deletes[i].Version)
+	}, func(results []BatchResult) {
+		r.search.removeResults(deletes, results)
+	})
+}
+
+// batch applies n items in one transaction, which an atomic batch rolls
+// back when an item fails. Items fail before they write anything, so the
+// others can still be committed; a storage error fails the whole batch.
+// index brings the search index up to date with the results that are
+// about to be committed.
+func (r *boltRepository) batch(ctx context.Context, n int, atomic bool, apply func(tx *bolt.Tx, i int) (*entities.Car, error), index func([]BatchResult)) ([]BatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, n)
+	err := r.update(func(tx *bolt.Tx) error {
+		for i := range results {
+			car, err := apply(tx, i)
+			if err != nil && !isItemError(err) {
+				return err
+			}
+			results[i] = BatchResult{Err: err}
+			if err == nil {
+				results[i].Car = car
+			}
+		}
+		if atomic && batchFailed(results) {
+			return errBatchFailed
+		}
+		index(results)
+		return nil
+	})
+
+	if errors.Is(err, errBatchFailed) {
+		abortBatch(results)
+		return results, nil
+	}
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return results, nil
+}
+
+// Ping fails once the database file has been closed.
+// update runs fn in a write transaction. Writes bring the search index up
+// to date inside the transaction, so that the index sees them in commit
+// order; if the commit then fails, the index is loaded afresh.
+func (r *boltRepository) update(fn func(tx *bolt.Tx) error) error {
+	applied, committed := false, false
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		applied = true
+		tx.OnCommit(func() { committed = true })
+		return nil
+	})
+	if applied && !committed {
+		r.search.invalidate()
+	}
+	return err
+}
+
+func (r *boltRepository) SearchCars(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	return r.search.search(ctx, search)
+}
+
+func (r *boltRepository) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return boltError(r.db.View(func(*bolt.Tx) error { return nil }))
+}
+
+func getCar(tx *bolt.Tx, carId entities.CarID) (*entities.Car, error) {
+	data := tx.Bucket(boltCarsBucket).Get([]byte(carId))
+	if data == nil {
+		return nil, ErrCarNotFound
+	}
+	return decodeCar(data)
+}
+
+// putCar writes car and its index entries.
+func putCar(tx *bolt.Tx, car *entities.Car) error {
+	data, err := json.Marshal(car)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(boltCarsBucket).Put([]byte(car.ID), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltCompanyIndex).Put(indexKey(car.Company, car.ID), nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltCarNameIndex).Put(indexKey(car.CarName, car.ID), nil); err != nil {
+		return err
+	}
+	if car.VIN == "" {
+		return nil
+	}
+	return tx.Bucket(boltVINIndex).Put([]byte(car.VIN), []byte(car.ID))
+}
+
+func deleteIndexes(tx *bolt.Tx, car *entities.Car) error {
+	if err := tx.Bucket(boltCompanyIndex).Delete(indexKey(car.Company, car.ID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltCarNameIndex).Delete(indexKey(car.CarName, car.ID)); err != nil {
+		return err
+	}
+	if car.VIN == "" {
+		return nil
+	}
+	return tx.Bucket(boltVINIndex).Delete([]byte(car.VIN))
+}
+
+// decodeCar reads a stored car. Cars stored before they had an inventory
+// status are in stock, as the migrations of the database backends record,
+// and were never sold: their SoldAt was stamped when they were inserted.
+func decodeCar(data []byte) (*entities.Car, error) {
+	var car entities.Car
+	if err := json.Unmarshal(data, &car); err != nil {
+		return nil, fmt.Errorf("decoding stored car: %w", err)
+	}
+	if car.Status == "" {
+		car.Status = entities.InStock
+		car.SoldAt = time.Time{}
+	}
+	return &car, nil
+}
+
+// indexPrefix is the start of every index key for value.
+func indexPrefix(value string) []byte {
+	return append([]byte(value), boltIndexSeparator...)
+}
+
+func indexKey(value string, carId entities.CarID) []byte {
+	return append(indexPrefix(value), carId...)
+}
+
+// boltError reports a closed database as ErrUnavailable. The package's own
+// errors and context errors pass through untouched.
+func boltError(err error) error {
+	if errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package cars
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openBolt(t *testing.T, path string) (*bolt.DB, Repository) {
+	t.Helper()
+
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+
+	repo, err := NewBoltRepo(db)
+	require.NoError(t, err)
+	return db, repo
+}
+
+func listedNames(t *testing.T, repo Repository, query *entities.CarQuery) []string {
+	t.Helper()
+
+	page, err := repo.CheckCar(context.Background(), query)
+	require.NoError(t, err)
+
+	var names []string
+	for _, car := range page.Cars {
+		names = append(names, car.CarName)
+	}
+	return names
+}
+
+func TestBoltIndexesFollowUpdates(t *testing.T) {
+	ctx := context.Background()
+	db, repo := openBolt(t, filepath.Join(t.TempDir(), "cars.db"))
+	defer db.Close()
+
+	car, err := repo.InsertCar(ctx, &entities.Car{CarName: "CX-5", Company: "Mazda"})
+	require.NoError(t, err)
+
+	name, company := "Corolla", "Toyota"
+	_, err = repo.UpdateCar(ctx, car.ID, 0, &entities.CarPatch{CarName: &name, Company: &company})
+	require.NoError(t, err)
+
+	assert.Empty(t, listedNames(t, repo, &entities.CarQuery{Company: "Mazda"}))
+	assert.Empty(t, listedNames(t, repo, &entities.CarQuery{CarNamePrefix: "CX"}))
+	assert.Equal(t, []string{"Corolla"}, listedNames(t, repo, &entities.CarQuery{Company: "Toyota"}))
+	assert.Equal(t, []string{"Corolla"}, listedNames(t, repo, &entities.CarQuery{CarNamePrefix: "Coro"}))
+
+	require.NoError(t, repo.DeleteCar(ctx, car.ID, 0))
+
+	assert.Empty(t, listedNames(t, repo, &entities.CarQuery{Company: "Toyota"}))
+	assert.Empty(t, listedNames(t, repo, &entities.CarQuery{CarNamePrefix: "Coro"}))
+}
+
+func TestBoltCompanyIndexIsExact(t *testing.T) {
+	ctx := context.Background()
+	db, repo := openBolt(t, filepath.Join(t.TempDir(), "cars.db"))
+	defer db.Close()
+
+	for _, company := range []string{"Ford", "Fordson"} {
+		_, err := repo.InsertCar(ctx, &entities.Car{CarName: "Model", Company: company})
+		require.NoError(t, err)
+	}
+
+	page, err := repo.CheckCar(ctx, &entities.CarQuery{Company: "Ford"})
+	require.NoError(t, err)
+	require.Len(t, page.Cars, 1)
+	assert.Equal(t, "Ford", page.Cars[0].Company)
+}
+
+func TestBoltPersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cars.db")
+
+	db, repo := openBolt(t, path)
+	car, err := repo.InsertCar(ctx, &entities.Car{CarName: "CX-5", Company: "Mazda"})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	assert.ErrorIs(t, repo.Ping(ctx), ErrUnavailable)
+
+	db, repo = openBolt(t, path)
+	defer db.Close()
+
+	stored, err := repo.GetCarByID(ctx, car.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "CX-5", stored.CarName)
+	assert.Equal(t, int64(1), stored.Version)
+	assert.NoError(t, repo.Ping(ctx))
+}
+
+// checkScanStreams scans more than n cars of a fresh repository from
+// newRepo, in every direction and with and without a company filter, and
+// deletes the car the scan would reach last on the first visit. A scan
+// holding every match before its first visit would still visit it.
+func checkScanStreams(t *testing.T, newRepo func() Repository, n int) {
+	ctx := context.Background()
+	for _, query := range []entities.CarQuery{
+		{},
+		{Descending: true},
+		{Company: "Mazda"},
+		{Company: "Mazda", Descending: true},
+	} {
+		repo := newRepo()
+		var ids []entities.CarID
+		for i := 0; i <= n; i++ {
+			car, err := repo.InsertCar(ctx, &entities.Car{CarName: fmt.Sprintf("CX-%d", i), Company: "Mazda", Status: entities.InStock})
+			require.NoError(t, err)
+			ids = append(ids, car.ID)
+		}
+		_, err := repo.InsertCar(ctx, &entities.Car{CarName: "Corolla", Company: "Toyota", Status: entities.InStock})
+		require.NoError(t, err)
+
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if query.Descending {
+			sort.Slice(ids, func(i, j int) bool { return ids[j] < ids[i] })
+		}
+		final := ids[len(ids)-1]
+
+		var visited []entities.CarID
+		deleted := false
+		err = repo.ScanCars(ctx, &query, func(car *entities.Car) error {
+			if !deleted {
+				require.NoError(t, repo.DeleteCar(ctx, final, 0))
+				deleted = true
+			}
+			if car.Company == "Mazda" {
+				visited = append(visited, car.ID)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, ids[:len(ids)-1], visited, "%+v", query)
+	}
+}
+
+func TestBoltScanReadsCarsAsItGoes(t *testing.T) {
+	checkScanStreams(t, func() Repository {
+		db, repo := openBolt(t, filepath.Join(t.TempDir(), "cars.db"))
+		t.Cleanup(func() { _ = db.Close() })
+		return repo
+	}, boltScanBatch)
+}
@@ -0,0 +1,359 @@
+// Package carsio reads and writes cars as JSON arrays, newline-delimited
+// JSON and CSV, one car at a time so that large files are never held in
+// memory. The JSON forms use the same field names as the HTTP API; CSV
+// files start with a header row naming the columns, in any order.
+package carsio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testingfiber/pkg/entities"
+	"time"
+)
+
+// Format names an encoding of a list of cars.
+type Format string
+
+const (
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+)
+
+// ParseFormat validates a format name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case JSON, NDJSON, CSV:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, want json, ndjson or csv", name)
+}
+
+// FormatFromPath guesses the format of a file from its extension.
+func FormatFromPath(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, true
+	case ".ndjson", ".jsonl":
+		return NDJSON, true
+	case ".csv":
+		return CSV, true
+	}
+	return "", false
+}
+
+// csvColumns is the header written to CSV files.
+var csvColumns = []string{
+	"id", "vin", "carName", "companyId", "company", "modelYear", "trim", "color", "mileage",
+	"listPrice", "currency", "condition", "status", "madeAt", "reservedAt", "soldAt",
+	"version",
+}
+
+// Writer encodes cars one at a time. Close finishes the encoding, such as
+// the closing bracket of a JSON array; it does not close the underlying
+// writer.
+type Writer interface {
+	Write(car *entities.Car) error
+	Close() error
+}
+
+// NewWriter returns a Writer encoding cars to w in format.
+func NewWriter(w io.Writer, format Format) Writer {
+	switch format {
+	case NDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}
+	}
+	return &jsonWriter{w: bufio.NewWriter(w)}
+}
+
+type jsonWriter struct {
+	w       *bufio.Writer
+	written int
+}
+
+func (j *jsonWriter) Write(car *entities.Car) error {
+	data, err := json.Marshal(car)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if j.written == 0 {
+		separator = "[\n"
+	}
+	j.written++
+
+	if _, err := j.w.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if j.written == 0 {
+		closing = "[]\n"
+	}
+	if _, err := j.w.WriteString(closing); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(car *entities.Car) error {
+	return n.encoder.Encode(car)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(car *entities.Car) error {
+	if !c.headerWritten {
+		c.headerWritten = true
+		if err := c.w.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+
+	return c.w.Write([]string{
+		car.ID.String(),
+		car.VIN,
+		car.CarName,
+		car.CompanyID.String(),
+		car.Company,
+		formatInt(int64(car.ModelYear)),
+		car.Trim,
+		car.Color,
+		strconv.FormatInt(car.Mileage, 10),
+		strconv.FormatInt(car.ListPrice, 10),
+		car.Currency,
+		string(car.Condition),
+		string(car.Status),
+		formatTime(car.MadeAt),
+		formatTime(car.ReservedAt),
+		formatTime(car.SoldAt),
+		strconv.FormatInt(car.Version, 10),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	if !c.headerWritten {
+		c.headerWritten = true
+		if err := c.w.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// formatInt leaves an unset number empty.
+func formatInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// formatTime leaves the zero time empty rather than writing year one.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Reader decodes cars one at a time. Read returns io.EOF after the last
+// car; other errors name the record at fault, counting from 1.
+type Reader interface {
+	Read() (*entities.Car, error)
+}
+
+// NewReader returns a Reader decoding cars in format from r. For CSV the
+// header row is read straight away.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case NDJSON:
+		return &ndjsonReader{decoder: json.NewDecoder(r)}, nil
+	case CSV:
+		return newCSVReader(r)
+	}
+	return &jsonReader{decoder: json.NewDecoder(r)}, nil
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	started bool
+	record  int
+}
+
+func (j *jsonReader) Read() (*entities.Car, error) {
+	if !j.started {
+		j.started = true
+		token, err := j.decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("reading JSON array: %w", unexpectedEOF(err))
+		}
+		if token != json.Delim('[') {
+			return nil, errors.New("reading JSON array: input is not an array")
+		}
+	}
+
+	if !j.decoder.More() {
+		if _, err := j.decoder.Token(); err != nil {
+			return nil, fmt.Errorf("reading JSON array: %w", unexpectedEOF(err))
+		}
+		return nil, io.EOF
+	}
+
+	j.record++
+	var car entities.Car
+	if err := j.decoder.Decode(&car); err != nil {
+		return nil, fmt.Errorf("record %d: %w", j.record, unexpectedEOF(err))
+	}
+	return &car, nil
+}
+
+type ndjsonReader struct {
+	decoder *json.Decoder
+	record  int
+}
+
+func (n *ndjsonReader) Read() (*entities.Car, error) {
+	var car entities.Car
+	err := n.decoder.Decode(&car)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+
+	n.record++
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", n.record, unexpectedEOF(err))
+	}
+	return &car, nil
+}
+
+// unexpectedEOF tells a truncated document from a complete one.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+	record  int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("reading CSV header: input is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	known := map[string]bool{}
+	for _, column := range csvColumns {
+		known[column] = true
+	}
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if !known[header[i]] {
+			return nil, fmt.Errorf("reading CSV header: unknown column %q", column)
+		}
+	}
+
+	return &csvReader{r: reader, columns: header}, nil
+}
+
+func (c *csvReader) Read() (*entities.Car, error) {
+	row, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+
+	c.record++
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", c.record, err)
+	}
+
+	var car entities.Car
+	for i, column := range c.columns {
+		if err := setColumn(&car, column, strings.TrimSpace(row[i])); err != nil {
+			return nil, fmt.Errorf("record %d: %s: %w", c.record, column, err)
+		}
+	}
+	return &car, nil
+}
+
+func setColumn(car *entities.Car, column, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch column {
+	case "id":
+		car.ID, err = entities.ParseCarID(value)
+	case "vin":
+		car.VIN = value
+	case "carName":
+		car.CarName = value
+	case "companyId":
+		car.CompanyID, err = entities.ParseCompanyID(value)
+	case "company":
+		car.Company = value
+	case "modelYear":
+		car.ModelYear, err = strconv.Atoi(value)
+	case "trim":
+		car.Trim = value
+	case "color":
+		car.Color = value
+	case "mileage":
+		car.Mileage, err = strconv.ParseInt(value, 10, 64)
+	case "listPrice":
+		car.ListPrice, err = strconv.ParseInt(value, 10, 64)
+	case "currency":
+		car.Currency = value
+	case "condition":
+		car.Condition = entities.Condition(value)
+	case "status":
+		car.Status = entities.InventoryStatus(value)
+	case "madeAt":
+		car.MadeAt, err = time.Parse(time.RFC3339Nano, value)
+	case "reservedAt":
+		car.ReservedAt, err = time.Parse(time.RFC3339Nano, value)
+	case "soldAt":
+		car.SoldAt, err = time.Parse(time.RFC3339Nano, value)
+	case "version":
+		car.Version, err = strconv.ParseInt(value, 10, 64)
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package carsio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testingfiber/pkg/entities"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, r io.Reader, format Format) ([]entities.Car, error) {
+	t.Helper()
+
+	reader, err := NewReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var cars []entities.Car
+	for {
+		car, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return cars, nil
+		}
+		if err != nil {
+			return cars, err
+		}
+		cars = append(cars, *car)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	made := time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)
+	cars := []entities.Car{
+		{ID: "64a4c6181955b6923fff02b5", CarName: "CX-5", CompanyID: "64a4c6181955b6923fff0001", Company: "Mazda", MadeAt: made, Version: 2},
+		{ID: "01H5ZJ4XQ0V5C9G7W3K8YTRM2N", CarName: `Name, with "quotes"`, Company: "Audi", Version: 1},
+	}
+
+	for _, format := range []Format{JSON, NDJSON, CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := NewWriter(&buf, format)
+			for i := range cars {
+				require.NoError(t, writer.Write(&cars[i]))
+			}
+			require.NoError(t, writer.Close())
+
+			read, err := readAll(t, &buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, cars, read)
+		})
+
+		t.Run(string(format)+"Empty", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, NewWriter(&buf, format).Close())
+
+			read, err := readAll(t, &buf, format)
+			require.NoError(t, err)
+			assert.Empty(t, read)
+		})
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	read, err := readAll(t, strings.NewReader("company, carName\nMazda, CX-5\n"), CSV)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.Car{{CarName: "CX-5", Company: "Mazda"}}, read)
+
+	_, err = readAll(t, strings.NewReader("carName,colour\nCX-5,red\n"), CSV)
+	assert.ErrorContains(t, err, `unknown column "colour"`)
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		format      Format
+		input       string
+		contains    string
+	}{
+		{"notAnArray", JSON, `{"carName": "CX-5"}`, "not an array"},
+		{"truncatedArray", JSON, `[{"carName": "CX-5"}`, "unexpected end"},
+		{"badRecord", JSON, `[{"carName": "CX-5"}, {"madeAt": "yesterday"}]`, "record 2"},
+		{"badLine", NDJSON, "{\"carName\": \"CX-5\"}\n{\"id\": \"nope\"}\n", "record 2"},
+		{"badTime", CSV, "carName,madeAt\nCX-5,yesterday\n", "record 1: madeAt"},
+		{"ragged", CSV, "carName,company\nCX-5\n", "record 1"},
+		{"emptyCSV", CSV, "", "input is empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := readAll(t, strings.NewReader(test.input), test.format)
+			assert.ErrorContains(t, err, test.contains)
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	format, err := ParseFormat("NDJSON")
+	require.NoError(t, err)
+	assert.Equal(t, NDJSON, format)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+
+	format, ok := FormatFromPath("export/cars.jsonl")
+	assert.True(t, ok)
+	assert.Equal(t, NDJSON, format)
+
+	_, ok = FormatFromPath("cars.txt")
+	assert.False(t, ok)
+}
@@ -0,0 +1,886 @@
+// Package carstest provides a conformance suite that every cars.Repository
+// backend is expected to pass.
+package carstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/storetest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timestampTolerance absorbs the precision lost by backends that store
+// timestamps at millisecond resolution.
+const timestampTolerance = time.Millisecond
+
+// RepositoryFactory returns an empty repository for a single subtest.
+type RepositoryFactory = storetest.Factory[cars.Repository]
+
+// RepositoryConformance runs the shared behaviour checks against the
+// repositories produced by newRepo.
+func RepositoryConformance(t *testing.T, newRepo RepositoryFactory) {
+	storetest.Conformance(t, newRepo, []storetest.Case[cars.Repository]{
+		{Name: "InsertAssignsIDAndTimestamps", Run: testInsertAssignsIDAndTimestamps},
+		{Name: "InsertKeepsMadeAt", Run: testInsertKeepsMadeAt},
+		{Name: "CheckEmpty", Run: testCheckEmpty},
+		{Name: "CheckKeepsInsertionOrder", Run: testCheckKeepsInsertionOrder},
+		{Name: "GetCarByID", Run: testGetCarByID},
+		{Name: "GetMissingCar", Run: testGetMissingCar},
+		{Name: "InventoryFields", Run: testInventoryFields},
+		{Name: "UniqueVIN", Run: testUniqueVIN},
+		{Name: "BatchUniqueVIN", Run: testBatchUniqueVIN},
+		{Name: "UpdateOnlySuppliedFields", Run: testUpdateOnlySuppliedFields},
+		{Name: "UpdateSoldAt", Run: testUpdateSoldAt},
+		{Name: "UpdateLifecycle", Run: testUpdateLifecycle},
+		{Name: "UpdateClearsReservation", Run: testUpdateClearsReservation},
+		{Name: "UpdateEmptyPatch", Run: testUpdateEmptyPatch},
+		{Name: "UpdateMissingCar", Run: testUpdateMissingCar},
+		{Name: "VersionIncrements", Run: testVersionIncrements},
+		{Name: "UpdateStaleVersion", Run: testUpdateStaleVersion},
+		{Name: "DeleteStaleVersion", Run: testDeleteStaleVersion},
+		{Name: "DeleteCar", Run: testDeleteCar},
+		{Name: "DeleteInvalidID", Run: testDeleteInvalidID},
+		{Name: "DeleteMissingCar", Run: testDeleteMissingCar},
+		{Name: "FilterByCompany", Run: testFilterByCompany},
+		{Name: "FilterByCarNamePrefix", Run: testFilterByCarNamePrefix},
+		{Name: "FilterByStatus", Run: testFilterByStatus},
+		{Name: "FilterByMadeAtRange", Run: testFilterByMadeAtRange},
+		{Name: "SortByField", Run: testSortByField},
+		{Name: "SortByInventoryField", Run: testSortByInventoryField},
+		{Name: "Paging", Run: testPaging},
+		{Name: "Scan", Run: testScan},
+		{Name: "ScanStopsOnError", Run: testScanStopsOnError},
+		{Name: "Search", Run: testSearch},
+		{Name: "SearchRanksExactMatchesFirst", Run: testSearchRanksExactMatchesFirst},
+		{Name: "SearchFollowsWrites", Run: testSearchFollowsWrites},
+		{Name: "BatchInsert", Run: testBatchInsert},
+		{Name: "BatchUpdate", Run: testBatchUpdate},
+		{Name: "BatchUpdateAtomic", Run: testBatchUpdateAtomic},
+		{Name: "BatchDelete", Run: testBatchDelete},
+		{Name: "BatchDeleteAtomic", Run: testBatchDeleteAtomic},
+		{Name: "CancelledContext", Run: testCancelledContext},
+		{Name: "Ping", Run: testPing},
+	})
+}
+
+func insert(t *testing.T, repo cars.Repository, name, company string) *entities.Car {
+	t.Helper()
+
+	car, err := repo.InsertCar(context.Background(), &entities.Car{CarName: name, Company: company, Status: entities.InStock})
+	require.NoError(t, err)
+	require.NotNil(t, car)
+
+	return car
+}
+
+// missingID returns an ID in the backend's format that no stored car has,
+// by inserting a car and deleting it again.
+func missingID(t *testing.T, repo cars.Repository) entities.CarID {
+	t.Helper()
+
+	car := insert(t, repo, "Ghost", "Nobody")
+	require.NoError(t, repo.DeleteCar(context.Background(), car.ID, 0))
+
+	return car.ID
+}
+
+func list(t *testing.T, repo cars.Repository) []entities.Car {
+	t.Helper()
+
+	return query(t, repo, &entities.CarQuery{}).Cars
+}
+
+func query(t *testing.T, repo cars.Repository, q *entities.CarQuery) *entities.CarPage {
+	t.Helper()
+
+	page, err := repo.CheckCar(context.Background(), q)
+	require.NoError(t, err)
+	require.NotNil(t, page)
+
+	return page
+}
+
+func names(page *entities.CarPage) []string {
+	return storetest.Names(page.Cars, func(car entities.Car) string { return car.CarName })
+}
+
+func testInsertAssignsIDAndTimestamps(t *testing.T, repo cars.Repository) {
+	before := time.Now()
+	first := insert(t, repo, "CX-5", "Mazda")
+	second := insert(t, repo, "Corolla", "Toyota")
+
+	assert.NotEmpty(t, first.ID)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.WithinDuration(t, before, first.MadeAt, time.Second)
+	assert.True(t, first.ReservedAt.IsZero())
+	assert.True(t, first.SoldAt.IsZero())
+
+	stored := list(t, repo)
+	require.Len(t, stored, 2)
+	assert.Equal(t, first.ID, stored[0].ID)
+	assert.Equal(t, "CX-5", stored[0].CarName)
+	assert.Equal(t, "Mazda", stored[0].Company)
+	assert.WithinDuration(t, first.MadeAt, stored[0].MadeAt, timestampTolerance)
+	assert.True(t, stored[0].ReservedAt.IsZero())
+	assert.True(t, stored[0].SoldAt.IsZero())
+}
+
+// Inserts keep a madeAt the car comes with, as an import does.
+func testInsertKeepsMadeAt(t *testing.T, repo cars.Repository) {
+	madeAt := time.Date(2020, 5, 17, 9, 0, 0, 0, time.UTC)
+
+	car, err := repo.InsertCar(context.Background(), &entities.Car{CarName: "CX-5", Company: "Mazda", MadeAt: madeAt})
+	require.NoError(t, err)
+	assert.True(t, car.MadeAt.Equal(madeAt))
+
+	results, err := repo.InsertCars(context.Background(), []*entities.Car{
+		{CarName: "Corolla", Company: "Toyota", MadeAt: madeAt},
+		{CarName: "Civic", Company: "Honda"},
+	}, false)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	assert.True(t, results[0].Car.MadeAt.Equal(madeAt))
+	assert.WithinDuration(t, time.Now(), results[1].Car.MadeAt, time.Second)
+
+	stored := list(t, repo)
+	require.Len(t, stored, 3)
+	assert.True(t, stored[0].MadeAt.Equal(madeAt))
+	assert.True(t, stored[1].MadeAt.Equal(madeAt))
+}
+
+func testCheckEmpty(t *testing.T, repo cars.Repository) {
+	assert.Empty(t, list(t, repo))
+}
+
+func testCheckKeepsInsertionOrder(t *testing.T, repo cars.Repository) {
+	names := []string{"CX-5", "Corolla", "Civic", "Model 3"}
+	for _, name := range names {
+		insert(t, repo, name, "Any")
+	}
+
+	stored := list(t, repo)
+	require.Len(t, stored, len(names))
+	for i, name := range names {
+		assert.Equal(t, name, stored[i].CarName)
+	}
+}
+
+func testGetCarByID(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "Corolla", "Toyota")
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	car, err := repo.GetCarByID(context.Background(), inserted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, inserted.ID, car.ID)
+	assert.Equal(t, "CX-5", car.CarName)
+	assert.Equal(t, "Mazda", car.Company)
+	assert.WithinDuration(t, inserted.MadeAt, car.MadeAt, timestampTolerance)
+}
+
+func testGetMissingCar(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+
+	_, err := repo.GetCarByID(context.Background(), missingID(t, repo))
+	assert.ErrorIs(t, err, cars.ErrCarNotFound)
+
+	_, err = repo.GetCarByID(context.Background(), "not-an-id")
+	assert.ErrorIs(t, err, cars.ErrInvalidID)
+}
+
+// insertVIN inserts a car with the given VIN.
+func insertVIN(t *testing.T, repo cars.Repository, name, vin string) (*entities.Car, error) {
+	t.Helper()
+
+	return repo.InsertCar(context.Background(), &entities.Car{
+		VIN: vin, CarName: name, Company: "Mazda", Status: entities.InStock,
+	})
+}
+
+func testInventoryFields(t *testing.T, repo cars.Repository) {
+	inserted, err := repo.InsertCar(context.Background(), &entities.Car{
+		VIN: "JM3KFBCM0N0000001", CarName: "CX-5", Company: "Mazda", ModelYear: 2022, Trim: "Touring",
+		Color: "Soul Red", Mileage: 1200, ListPrice: 2599900, Currency: "USD",
+		Condition: entities.ConditionUsed, Status: entities.InStock,
+	})
+	require.NoError(t, err)
+
+	stored, err := repo.GetCarByID(context.Background(), inserted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "JM3KFBCM0N0000001", stored.VIN)
+	assert.Equal(t, 2022, stored.ModelYear)
+	assert.Equal(t, "Touring", stored.Trim)
+	assert.Equal(t, "Soul Red", stored.Color)
+	assert.Equal(t, int64(1200), stored.Mileage)
+	assert.Equal(t, int64(2599900), stored.ListPrice)
+	assert.Equal(t, "USD", stored.Currency)
+	assert.Equal(t, entities.ConditionUsed, stored.Condition)
+	assert.Equal(t, entities.InStock, stored.Status)
+
+	mileage, status := int64(1500), entities.Reserved
+	updated, err := repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{
+		Mileage: &mileage, Status: &status,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), updated.Mileage)
+	assert.Equal(t, entities.Reserved, updated.Status)
+	assert.Equal(t, "JM3KFBCM0N0000001", updated.VIN)
+}
+
+func testUniqueVIN(t *testing.T, repo cars.Repository) {
+	first, err := insertVIN(t, repo, "CX-5", "JM3KFBCM0N0000001")
+	require.NoError(t, err)
+
+	_, err = insertVIN(t, repo, "CX-30", "JM3KFBCM0N0000001")
+	assert.ErrorIs(t, err, cars.ErrConflict)
+
+	// Any number of cars can go without a VIN.
+	_, err = insertVIN(t, repo, "MX-5", "")
+	require.NoError(t, err)
+	second, err := insertVIN(t, repo, "CX-9", "")
+	require.NoError(t, err)
+
+	vin := "JM3KFBCM0N0000001"
+	_, err = repo.UpdateCar(context.Background(), second.ID, 0, &entities.CarPatch{VIN: &vin})
+	assert.ErrorIs(t, err, cars.ErrConflict)
+
+	// A car keeps its own VIN through updates, and gives it up once
+	// cleared.
+	name := "CX-5 Turbo"
+	_, err = repo.UpdateCar(context.Background(), first.ID, 0, &entities.CarPatch{CarName: &name, VIN: &vin})
+	require.NoError(t, err)
+	cleared := ""
+	updated, err := repo.UpdateCar(context.Background(), first.ID, 0, &entities.CarPatch{VIN: &cleared})
+	require.NoError(t, err)
+	assert.Empty(t, updated.VIN)
+
+	updated, err = repo.UpdateCar(context.Background(), second.ID, 0, &entities.CarPatch{VIN: &vin})
+	require.NoError(t, err)
+	assert.Equal(t, vin, updated.VIN)
+	assert.Len(t, list(t, repo), 3)
+}
+
+func testBatchUniqueVIN(t *testing.T, repo cars.Repository) {
+	_, err := insertVIN(t, repo, "CX-5", "JM3KFBCM0N0000001")
+	require.NoError(t, err)
+
+	results, err := repo.InsertCars(context.Background(), []*entities.Car{
+		{VIN: "JM3KFBCM4N0000003", CarName: "CX-30", Company: "Mazda", Status: entities.InStock},
+		{VIN: "JM3KFBCM0N0000001", CarName: "CX-9", Company: "Mazda", Status: entities.InStock},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, cars.ErrConflict)
+	assert.Nil(t, results[1].Car)
+	assert.Len(t, list(t, repo), 2)
+
+	results, err = repo.InsertCars(context.Background(), []*entities.Car{
+		{VIN: "JTDBR32E2N0000002", CarName: "Corolla", Company: "Toyota", Status: entities.InStock},
+		{VIN: "JM3KFBCM4N0000003", CarName: "MX-5", Company: "Mazda", Status: entities.InStock},
+	}, true)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, cars.ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, cars.ErrConflict)
+	assert.Len(t, list(t, repo), 2)
+}
+
+func testUpdateOnlySuppliedFields(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	name := "CX-9"
+	updated, err := repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{
+		CarName: &name,
+	})
+	require.NoError(t, err)
+
+	// The returned car is the stored document, not an echo of the patch.
+	assert.Equal(t, inserted.ID, updated.ID)
+	assert.Equal(t, "CX-9", updated.CarName)
+	assert.Equal(t, "Mazda", updated.Company)
+	assert.WithinDuration(t, inserted.MadeAt, updated.MadeAt, timestampTolerance)
+	assert.WithinDuration(t, inserted.SoldAt, updated.SoldAt, timestampTolerance)
+
+	stored := list(t, repo)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "CX-9", stored[0].CarName)
+	assert.Equal(t, "Mazda", stored[0].Company)
+	assert.WithinDuration(t, inserted.MadeAt, stored[0].MadeAt, timestampTolerance)
+	assert.WithinDuration(t, inserted.SoldAt, stored[0].SoldAt, timestampTolerance)
+}
+
+func testUpdateSoldAt(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	soldAt := inserted.MadeAt.Add(24 * time.Hour)
+	updated, err := repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{
+		SoldAt: &soldAt,
+	})
+	require.NoError(t, err)
+	assert.WithinDuration(t, soldAt, updated.SoldAt, timestampTolerance)
+	assert.Equal(t, "CX-5", updated.CarName)
+}
+
+// testUpdateLifecycle applies the patches of a reservation and a sale and
+// checks the stored car keeps the time of each.
+func testUpdateLifecycle(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	reserved, reservedAt := entities.Reserved, inserted.MadeAt.Add(time.Hour)
+	_, err := repo.UpdateCar(context.Background(), inserted.ID, inserted.Version, &entities.CarPatch{
+		Status:     &reserved,
+		ReservedAt: &reservedAt,
+	})
+	require.NoError(t, err)
+
+	sold, soldAt := entities.Sold, inserted.MadeAt.Add(2*time.Hour)
+	_, err = repo.UpdateCar(context.Background(), inserted.ID, inserted.Version+1, &entities.CarPatch{
+		Status: &sold,
+		SoldAt: &soldAt,
+	})
+	require.NoError(t, err)
+
+	stored, err := repo.GetCarByID(context.Background(), inserted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.Sold, stored.Status)
+	assert.WithinDuration(t, reservedAt, stored.ReservedAt, timestampTolerance)
+	assert.WithinDuration(t, soldAt, stored.SoldAt, timestampTolerance)
+	assert.Equal(t, inserted.Version+2, stored.Version)
+}
+
+func testUpdateClearsReservation(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	reserved, reservedAt := entities.Reserved, inserted.MadeAt.Add(time.Hour)
+	_, err := repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{
+		Status:     &reserved,
+		ReservedAt: &reservedAt,
+	})
+	require.NoError(t, err)
+
+	// A release puts the car back in stock with no reservation time.
+	inStock := entities.InStock
+	updated, err := repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{
+		Status:     &inStock,
+		ReservedAt: &time.Time{},
+	})
+	require.NoError(t, err)
+	assert.True(t, updated.ReservedAt.IsZero())
+
+	stored, err := repo.GetCarByID(context.Background(), inserted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.InStock, stored.Status)
+	assert.True(t, stored.ReservedAt.IsZero())
+}
+
+func testUpdateEmptyPatch(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	updated, err := repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted.ID, updated.ID)
+	assert.Equal(t, "CX-5", updated.CarName)
+}
+
+func testUpdateMissingCar(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	name := "Ghost"
+	_, err := repo.UpdateCar(context.Background(), missingID(t, repo), 0, &entities.CarPatch{
+		CarName: &name,
+	})
+	assert.ErrorIs(t, err, cars.ErrCarNotFound)
+
+	_, err = repo.UpdateCar(context.Background(), "not-an-id", 0, &entities.CarPatch{
+		CarName: &name,
+	})
+	assert.ErrorIs(t, err, cars.ErrInvalidID)
+
+	stored := list(t, repo)
+	require.Len(t, stored, 1)
+	assert.Equal(t, inserted.ID, stored[0].ID)
+	assert.Equal(t, "CX-5", stored[0].CarName)
+}
+
+func testVersionIncrements(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+	assert.Equal(t, int64(1), inserted.Version)
+
+	name := "CX-9"
+	updated, err := repo.UpdateCar(context.Background(), inserted.ID, 1, &entities.CarPatch{CarName: &name})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// Unconditional writes bump the version too.
+	name = "CX-30"
+	updated, err = repo.UpdateCar(context.Background(), inserted.ID, 0, &entities.CarPatch{CarName: &name})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updated.Version)
+
+	// A patch that changes nothing is not a new version.
+	updated, err = repo.UpdateCar(context.Background(), inserted.ID, 3, &entities.CarPatch{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updated.Version)
+
+	stored, err := repo.GetCarByID(context.Background(), inserted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stored.Version)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	name := "CX-9"
+	_, err := repo.UpdateCar(context.Background(), inserted.ID, 1, &entities.CarPatch{CarName: &name})
+	require.NoError(t, err)
+
+	name = "CX-30"
+	_, err = repo.UpdateCar(context.Background(), inserted.ID, 1, &entities.CarPatch{CarName: &name})
+	assert.ErrorIs(t, err, cars.ErrVersionMismatch)
+
+	_, err = repo.UpdateCar(context.Background(), inserted.ID, 1, &entities.CarPatch{})
+	assert.ErrorIs(t, err, cars.ErrVersionMismatch)
+
+	_, err = repo.UpdateCar(context.Background(), missingID(t, repo), 1, &entities.CarPatch{CarName: &name})
+	assert.ErrorIs(t, err, cars.ErrCarNotFound)
+
+	stored := list(t, repo)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "CX-9", stored[0].CarName)
+	assert.Equal(t, int64(2), stored[0].Version)
+}
+
+func testDeleteStaleVersion(t *testing.T, repo cars.Repository) {
+	inserted := insert(t, repo, "CX-5", "Mazda")
+
+	err := repo.DeleteCar(context.Background(), inserted.ID, 7)
+	assert.ErrorIs(t, err, cars.ErrVersionMismatch)
+	assert.Len(t, list(t, repo), 1)
+
+	err = repo.DeleteCar(context.Background(), missingID(t, repo), 7)
+	assert.ErrorIs(t, err, cars.ErrCarNotFound)
+
+	require.NoError(t, repo.DeleteCar(context.Background(), inserted.ID, 1))
+	assert.Empty(t, list(t, repo))
+}
+
+func testDeleteCar(t *testing.T, repo cars.Repository) {
+	first := insert(t, repo, "CX-5", "Mazda")
+	second := insert(t, repo, "Corolla", "Toyota")
+
+	require.NoError(t, repo.DeleteCar(context.Background(), first.ID, 0))
+
+	stored := list(t, repo)
+	require.Len(t, stored, 1)
+	assert.Equal(t, second.ID, stored[0].ID)
+}
+
+func testDeleteInvalidID(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+
+	err := repo.DeleteCar(context.Background(), "not-an-id", 0)
+	assert.ErrorIs(t, err, cars.ErrInvalidID)
+	assert.Len(t, list(t, repo), 1)
+}
+
+func testDeleteMissingCar(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+
+	err := repo.DeleteCar(context.Background(), missingID(t, repo), 0)
+	assert.ErrorIs(t, err, cars.ErrCarNotFound)
+	assert.Len(t, list(t, repo), 1)
+}
+
+func testFilterByCompany(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+	insert(t, repo, "Corolla", "Toyota")
+	insert(t, repo, "MX-5", "Mazda")
+
+	page := query(t, repo, &entities.CarQuery{Company: "Mazda"})
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, []string{"CX-5", "MX-5"}, names(page))
+}
+
+func testFilterByCarNamePrefix(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+	insert(t, repo, "CX-9", "Mazda")
+	insert(t, repo, "MX-5", "Mazda")
+	insert(t, repo, "C.X", "Mazda")
+
+	page := query(t, repo, &entities.CarQuery{CarNamePrefix: "CX"})
+	assert.Equal(t, []string{"CX-5", "CX-9"}, names(page))
+
+	// The prefix is matched literally, not as a pattern.
+	page = query(t, repo, &entities.CarQuery{CarNamePrefix: "C."})
+	assert.Equal(t, []string{"C.X"}, names(page))
+}
+
+func testFilterByStatus(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+	sold := insert(t, repo, "MX-5", "Mazda")
+	insert(t, repo, "CX-9", "Mazda")
+
+	status := entities.Sold
+	_, err := repo.UpdateCar(context.Background(), sold.ID, 0, &entities.CarPatch{Status: &status})
+	require.NoError(t, err)
+
+	page := query(t, repo, &entities.CarQuery{Status: entities.Sold})
+	assert.Equal(t, []string{"MX-5"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{Status: entities.InStock})
+	assert.Equal(t, []string{"CX-5", "CX-9"}, names(page))
+}
+
+func testFilterByMadeAtRange(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+	between := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	insert(t, repo, "MX-5", "Mazda")
+
+	page := query(t, repo, &entities.CarQuery{MadeFrom: between})
+	assert.Equal(t, []string{"MX-5"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{MadeTo: between})
+	assert.Equal(t, []string{"CX-5"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{MadeFrom: between.Add(time.Hour)})
+	assert.Empty(t, page.Cars)
+	assert.Equal(t, int64(0), page.Total)
+}
+
+func testSortByField(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "Civic", "Honda")
+	insert(t, repo, "Atenza", "Mazda")
+	insert(t, repo, "Corolla", "Toyota")
+	insert(t, repo, "Beetle", "Volkswagen")
+
+	page := query(t, repo, &entities.CarQuery{SortBy: "carName"})
+	assert.Equal(t, []string{"Atenza", "Beetle", "Civic", "Corolla"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{SortBy: "company", Descending: true})
+	assert.Equal(t, []string{"Beetle", "Corolla", "Atenza", "Civic"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{SortBy: "id", Descending: true})
+	assert.Equal(t, []string{"Beetle", "Corolla", "Atenza", "Civic"}, names(page))
+}
+
+func testSortByInventoryField(t *testing.T, repo cars.Repository) {
+	for _, car := range []*entities.Car{
+		{VIN: "JM3KFBCM0N0000002", CarName: "CX-5", Company: "Mazda", ModelYear: 2022, Trim: "Touring",
+			Color: "Soul Red", Mileage: 1200, ListPrice: 2599900, Currency: "USD", Condition: entities.ConditionUsed},
+		{VIN: "1HGCM82633A000001", CarName: "Civic", Company: "Honda", ModelYear: 2019, Trim: "EX",
+			Color: "Black", Mileage: 45000, ListPrice: 1799900, Currency: "EUR", Condition: entities.ConditionUsed},
+		{VIN: "5YJ3E1EA7KF000001", CarName: "Model 3", Company: "Tesla", ModelYear: 2024, Trim: "Long Range",
+			Color: "White", Mileage: 10, ListPrice: 4299900, Currency: "USD", Condition: entities.ConditionNew},
+		// Empty fields sort first.
+		{CarName: "Beetle", Company: "Volkswagen"},
+	} {
+		car.Status = entities.InStock
+		_, err := repo.InsertCar(context.Background(), car)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{"vin", []string{"Beetle", "Civic", "Model 3", "CX-5"}},
+		{"modelYear", []string{"Beetle", "Civic", "CX-5", "Model 3"}},
+		{"trim", []string{"Beetle", "Civic", "Model 3", "CX-5"}},
+		{"color", []string{"Beetle", "Civic", "CX-5", "Model 3"}},
+		{"mileage", []string{"Beetle", "Model 3", "CX-5", "Civic"}},
+		{"listPrice", []string{"Beetle", "Civic", "CX-5", "Model 3"}},
+		{"currency", []string{"Beetle", "Civic", "CX-5", "Model 3"}},
+		{"condition", []string{"Beetle", "Model 3", "CX-5", "Civic"}},
+	}
+	for _, test := range tests {
+		page := query(t, repo, &entities.CarQuery{SortBy: test.sortBy})
+		assert.Equal(t, test.want, names(page), test.sortBy)
+	}
+
+	page := query(t, repo, &entities.CarQuery{SortBy: "listPrice", Descending: true})
+	assert.Equal(t, []string{"Model 3", "CX-5", "Civic", "Beetle"}, names(page))
+}
+
+func testPaging(t *testing.T, repo cars.Repository) {
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		insert(t, repo, name, "Any")
+	}
+
+	page := query(t, repo, &entities.CarQuery{Limit: 2})
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, []string{"A", "B"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{Limit: 2, Offset: 4})
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, []string{"E"}, names(page))
+
+	page = query(t, repo, &entities.CarQuery{Limit: 2, Offset: 10})
+	assert.Equal(t, int64(5), page.Total)
+	assert.Empty(t, page.Cars)
+}
+
+func scan(t *testing.T, repo cars.Repository, q *entities.CarQuery) []string {
+	t.Helper()
+
+	var names []string
+	err := repo.ScanCars(context.Background(), q, func(car *entities.Car) error {
+		names = append(names, car.CarName)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return names
+}
+
+func testScan(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+	insert(t, repo, "Corolla", "Toyota")
+	insert(t, repo, "Civic", "Honda")
+	insert(t, repo, "CX-30", "Mazda")
+
+	// Limit and offset are ignored: a scan sees every match.
+	assert.Equal(t, []string{"CX-5", "Corolla", "Civic", "CX-30"},
+		scan(t, repo, &entities.CarQuery{Limit: 1, Offset: 1}))
+	assert.Equal(t, []string{"CX-5", "CX-30"}, scan(t, repo, &entities.CarQuery{Company: "Mazda"}))
+	assert.Equal(t, []string{"Corolla", "Civic", "CX-5", "CX-30"},
+		scan(t, repo, &entities.CarQuery{SortBy: "carName", Descending: true}))
+	assert.Empty(t, scan(t, repo, &entities.CarQuery{Company: "Ford"}))
+}
+
+func testScanStopsOnError(t *testing.T, repo cars.Repository) {
+	for _, name := range []string{"A", "B", "C"} {
+		insert(t, repo, name, "Any")
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	err := repo.ScanCars(context.Background(), &entities.CarQuery{}, func(*entities.Car) error {
+		visited++
+		if visited == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 2, visited)
+}
+
+func search(t *testing.T, repo cars.Repository, q string) []string {
+	t.Helper()
+
+	page, err := repo.SearchCars(context.Background(), &entities.CarSearch{Query: q, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(len(page.Hits)), page.Total)
+
+	var names []string
+	for _, hit := range page.Hits {
+		names = append(names, hit.Car.CarName)
+	}
+	return names
+}
+
+func testSearch(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "CX-5", "Mazda")
+	insert(t, repo, "Corolla", "Toyota")
+	insert(t, repo, "Civic", "Honda")
+	insert(t, repo, "MX-5", "Mazda")
+
+	assert.Equal(t, []string{"CX-5", "MX-5"}, search(t, repo, "mazda"))
+	assert.Equal(t, []string{"Corolla"}, search(t, repo, "COROLLA"))
+	// Typos and prefixes.
+	assert.Equal(t, []string{"Corolla"}, search(t, repo, "corola"))
+	assert.Equal(t, []string{"Civic"}, search(t, repo, "hodna"))
+	assert.Equal(t, []string{"Corolla"}, search(t, repo, "toy"))
+	// A name match outranks a company match.
+	assert.Equal(t, "CX-5", search(t, repo, "cx mazda")[0])
+	assert.Empty(t, search(t, repo, "ferrari"))
+
+	page, err := repo.SearchCars(context.Background(), &entities.CarSearch{Query: "mazda", Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	require.Len(t, page.Hits, 1)
+	assert.Equal(t, "MX-5", page.Hits[0].Car.CarName)
+	assert.Positive(t, page.Hits[0].Score)
+}
+
+// testSearchRanksExactMatchesFirst inserts the worst match first, so that
+// the order cannot come from the IDs.
+func testSearchRanksExactMatchesFirst(t *testing.T, repo cars.Repository) {
+	insert(t, repo, "Corola", "Toyota")
+	insert(t, repo, "Corollas", "Toyota")
+	insert(t, repo, "Corolla", "Toyota")
+	insert(t, repo, "Civic", "Honda")
+
+	assert.Equal(t, []string{"Corolla", "Corollas", "Corola"}, search(t, repo, "corolla"))
+}
+
+func testSearchFollowsWrites(t *testing.T, repo cars.Repository) {
+	car := insert(t, repo, "CX-5", "Mazda")
+	other := insert(t, repo, "Civic", "Honda")
+	assert.Equal(t, []string{"CX-5"}, search(t, repo, "mazda"))
+
+	name := "Miata"
+	_, err := repo.UpdateCar(context.Background(), car.ID, 0, &entities.CarPatch{CarName: &name})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Miata"}, search(t, repo, "miata"))
+	assert.Empty(t, search(t, repo, "cx"))
+
+	require.NoError(t, repo.DeleteCar(context.Background(), car.ID, 0))
+	assert.Empty(t, search(t, repo, "mazda"))
+
+	_, err = repo.InsertCars(context.Background(), []*entities.Car{{CarName: "Accord", Company: "Honda"}}, false)
+	require.NoError(t, err)
+	_, err = repo.DeleteCars(context.Background(), []entities.CarDelete{{ID: other.ID}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Accord"}, search(t, repo, "honda"))
+}
+
+func testBatchInsert(t *testing.T, repo cars.Repository) {
+	results, err := repo.InsertCars(context.Background(), []*entities.Car{
+		{CarName: "CX-5", Company: "Mazda"},
+		{CarName: "Corolla", Company: "Toyota"},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	for _, result := range results {
+		require.NoError(t, result.Err)
+		assert.NotEmpty(t, result.Car.ID)
+		assert.Equal(t, int64(1), result.Car.Version)
+	}
+	assert.NotEqual(t, results[0].Car.ID, results[1].Car.ID)
+
+	stored := list(t, repo)
+	require.Len(t, stored, 2)
+	assert.Equal(t, results[0].Car.ID, stored[0].ID)
+	assert.Equal(t, "Corolla", stored[1].CarName)
+}
+
+// batchUpdates names a car that can be updated, a missing car, a car at
+// another version and an invalid ID, in that order.
+func batchUpdates(t *testing.T, repo cars.Repository) (*entities.Car, []entities.CarUpdate) {
+	t.Helper()
+
+	current := insert(t, repo, "CX-5", "Mazda")
+	stale := insert(t, repo, "Corolla", "Toyota")
+	name := "CX-30"
+
+	return current, []entities.CarUpdate{
+		{ID: current.ID, Version: 1, CarPatch: entities.CarPatch{CarName: &name}},
+		{ID: missingID(t, repo), CarPatch: entities.CarPatch{CarName: &name}},
+		{ID: stale.ID, Version: 7, CarPatch: entities.CarPatch{CarName: &name}},
+		{ID: "not-an-id", CarPatch: entities.CarPatch{CarName: &name}},
+	}
+}
+
+func testBatchUpdate(t *testing.T, repo cars.Repository) {
+	current, updates := batchUpdates(t, repo)
+
+	results, err := repo.UpdateCars(context.Background(), updates, false)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, current.ID, results[0].Car.ID)
+	assert.Equal(t, "CX-30", results[0].Car.CarName)
+	assert.Equal(t, int64(2), results[0].Car.Version)
+	assert.ErrorIs(t, results[1].Err, cars.ErrCarNotFound)
+	assert.ErrorIs(t, results[2].Err, cars.ErrVersionMismatch)
+	assert.ErrorIs(t, results[3].Err, cars.ErrInvalidID)
+
+	assert.Equal(t, []string{"CX-30", "Corolla"}, names(query(t, repo, &entities.CarQuery{})))
+}
+
+func testBatchUpdateAtomic(t *testing.T, repo cars.Repository) {
+	_, updates := batchUpdates(t, repo)
+
+	results, err := repo.UpdateCars(context.Background(), updates, true)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.ErrorIs(t, results[0].Err, cars.ErrBatchAborted)
+	assert.Nil(t, results[0].Car)
+	assert.ErrorIs(t, results[1].Err, cars.ErrCarNotFound)
+	assert.ErrorIs(t, results[2].Err, cars.ErrVersionMismatch)
+	assert.ErrorIs(t, results[3].Err, cars.ErrInvalidID)
+
+	stored := list(t, repo)
+	assert.Equal(t, []string{"CX-5", "Corolla"}, names(&entities.CarPage{Cars: stored}))
+	assert.Equal(t, int64(1), stored[0].Version)
+
+	// Without the failing items the batch goes through.
+	results, err = repo.UpdateCars(context.Background(), updates[:1], true)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "CX-30", results[0].Car.CarName)
+}
+
+func batchDeletes(t *testing.T, repo cars.Repository) []entities.CarDelete {
+	t.Helper()
+
+	first := insert(t, repo, "CX-5", "Mazda")
+	second := insert(t, repo, "Corolla", "Toyota")
+	stale := insert(t, repo, "Civic", "Honda")
+
+	return []entities.CarDelete{
+		{ID: first.ID},
+		{ID: second.ID, Version: 1},
+		{ID: missingID(t, repo)},
+		{ID: stale.ID, Version: 7},
+	}
+}
+
+func testBatchDelete(t *testing.T, repo cars.Repository) {
+	deletes := batchDeletes(t, repo)
+
+	results, err := repo.DeleteCars(context.Background(), deletes, false)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.NoError(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.ErrorIs(t, results[2].Err, cars.ErrCarNotFound)
+	assert.ErrorIs(t, results[3].Err, cars.ErrVersionMismatch)
+
+	assert.Equal(t, []string{"Civic"}, names(query(t, repo, &entities.CarQuery{})))
+}
+
+func testBatchDeleteAtomic(t *testing.T, repo cars.Repository) {
+	deletes := batchDeletes(t, repo)
+
+	results, err := repo.DeleteCars(context.Background(), deletes, true)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.ErrorIs(t, results[0].Err, cars.ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, cars.ErrBatchAborted)
+	assert.ErrorIs(t, results[2].Err, cars.ErrCarNotFound)
+	assert.ErrorIs(t, results[3].Err, cars.ErrVersionMismatch)
+
+	assert.Len(t, list(t, repo), 3)
+}
+
+func testCancelledContext(t *testing.T, repo cars.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.CheckCar(ctx, &entities.CarQuery{})
+	assert.Error(t, err)
+
+	_, err = repo.InsertCar(ctx, &entities.Car{CarName: "CX-5", Company: "Mazda"})
+	assert.Error(t, err)
+
+	assert.Error(t, repo.Ping(ctx))
+}
+
+func testPing(t *testing.T, repo cars.Repository) {
+	assert.NoError(t, repo.Ping(context.Background()))
+}
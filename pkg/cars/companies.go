@@ -0,0 +1,101 @@
+package cars
+
+import (
+	"context"
+	"errors"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+)
+
+// CompanyDirectory looks up the companies cars reference. Names match
+// regardless of case. Lookups of a company that does not exist fail with
+// ErrCompanyNotFound.
+type CompanyDirectory interface {
+	GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error)
+	FindCompanyByName(ctx context.Context, name string) (*entities.Company, error)
+}
+
+// WithCompanies makes the service check that every car it writes
+// references a company of the directory, and lets listings embed them.
+// Without it, company references are stored as given.
+func WithCompanies(directory CompanyDirectory) ServiceOption {
+	return func(s *service) {
+		s.companies = directory
+	}
+}
+
+// resolveCompany points car at the company it names: by ID when it has
+// one, otherwise by name. The car takes the company's name as the company
+// writes it, so "toyota" is stored as "Toyota". A car naming no company is
+// left for the validator to reject.
+func (s *service) resolveCompany(ctx context.Context, car *entities.Car) error {
+	if s.companies == nil {
+		return nil
+	}
+
+	var company *entities.Company
+	var err error
+	field := "companyId"
+	switch {
+	case !car.CompanyID.IsZero():
+		company, err = s.companies.GetCompanyByID(ctx, car.CompanyID)
+	case car.Company != "":
+		field = "company"
+		company, err = s.companies.FindCompanyByName(ctx, car.Company)
+	default:
+		return nil
+	}
+
+	if errors.Is(err, ErrCompanyNotFound) {
+		return &ValidationError{Fields: []validation.FieldError{{Field: field, Message: "names no known company"}}}
+	}
+	if err != nil {
+		return err
+	}
+	car.CompanyID, car.Company = company.ID, company.Name
+	return nil
+}
+
+// resolvePatchCompany resolves the company a patch moves a car to, like
+// resolveCompany, and makes the patch set both its ID and its name.
+func (s *service) resolvePatchCompany(ctx context.Context, patch *entities.CarPatch) error {
+	if s.companies == nil || patch.CompanyID == nil && patch.Company == nil {
+		return nil
+	}
+
+	var car entities.Car
+	if patch.CompanyID != nil {
+		car.CompanyID = *patch.CompanyID
+	} else {
+		car.Company = *patch.Company
+	}
+	if err := s.resolveCompany(ctx, &car); err != nil {
+		return err
+	}
+	patch.CompanyID, patch.Company = &car.CompanyID, &car.Company
+	return nil
+}
+
+// embedCompanies looks up the companies of the cars of page. Companies
+// that have gone missing are left out.
+func (s *service) embedCompanies(ctx context.Context, page *entities.CarPage) error {
+	page.Companies = map[entities.CompanyID]entities.Company{}
+	if s.companies == nil {
+		return nil
+	}
+
+	for _, car := range page.Cars {
+		if _, ok := page.Companies[car.CompanyID]; ok || car.CompanyID.IsZero() {
+			continue
+		}
+		company, err := s.companies.GetCompanyByID(ctx, car.CompanyID)
+		if errors.Is(err, ErrCompanyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		page.Companies[car.CompanyID] = *company
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cars
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDirectory is a CompanyDirectory over a fixed list of companies.
+type fakeDirectory []entities.Company
+
+func (d fakeDirectory) GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	for i := range d {
+		if d[i].ID == ID {
+			return &d[i], nil
+		}
+	}
+	return nil, ErrCompanyNotFound
+}
+
+func (d fakeDirectory) FindCompanyByName(ctx context.Context, name string) (*entities.Company, error) {
+	for i := range d {
+		if strings.EqualFold(d[i].Name, name) {
+			return &d[i], nil
+		}
+	}
+	return nil, ErrCompanyNotFound
+}
+
+var testCompanies = fakeDirectory{
+	{ID: "64a4c6181955b6923fff0001", Name: "Toyota", Version: 1},
+	{ID: "64a4c6181955b6923fff0002", Name: "Mazda", Version: 1},
+}
+
+func TestInsertCarServiceResolvesCompany(t *testing.T) {
+	service := NewService(NewMemoryRepo(), WithCompanies(testCompanies))
+
+	car, err := service.InsertCarService(context.Background(), &entities.Car{CarName: "Corolla", Company: "TOYOTA"})
+	require.NoError(t, err)
+	assert.Equal(t, testCompanies[0].ID, car.CompanyID)
+	assert.Equal(t, "Toyota", car.Company)
+
+	car, err = service.InsertCarService(context.Background(), &entities.Car{CarName: "CX-5", CompanyID: testCompanies[1].ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Mazda", car.Company)
+}
+
+func TestInsertCarServiceUnknownCompany(t *testing.T) {
+	service := NewService(NewMemoryRepo(), WithCompanies(testCompanies))
+
+	_, err := service.InsertCarService(context.Background(), &entities.Car{CarName: "Civic", Company: "Honda"})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "company", validationErr.Fields[0].Field)
+
+	_, err = service.InsertCarService(context.Background(), &entities.Car{CarName: "Civic", CompanyID: "64a4c6181955b6923fff0003"})
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "companyId", validationErr.Fields[0].Field)
+}
+
+func TestUpdateCarServiceResolvesCompany(t *testing.T) {
+	repo := NewMemoryRepo()
+	service := NewService(repo, WithCompanies(testCompanies))
+
+	car, err := service.InsertCarService(context.Background(), &entities.Car{CarName: "MX-5", Company: "Toyota"})
+	require.NoError(t, err)
+
+	company := "mazda"
+	updated, err := service.UpdateCarService(context.Background(), car.ID, 0, &entities.CarPatch{Company: &company})
+	require.NoError(t, err)
+	assert.Equal(t, testCompanies[1].ID, updated.CompanyID)
+	assert.Equal(t, "Mazda", updated.Company)
+}
+
+func TestCheckCarServiceEmbedsCompanies(t *testing.T) {
+	repo := NewMemoryRepo()
+	service := NewService(repo, WithCompanies(testCompanies))
+
+	for _, name := range []string{"Corolla", "Yaris"} {
+		_, err := service.InsertCarService(context.Background(), &entities.Car{CarName: name, Company: "Toyota"})
+		require.NoError(t, err)
+	}
+	// A car stored before companies were checked.
+	_, err := repo.InsertCar(context.Background(), &entities.Car{CarName: "Civic", Company: "Honda"})
+	require.NoError(t, err)
+
+	page, err := service.CheckCarService(context.Background(), &entities.CarQuery{EmbedCompany: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Cars, 3)
+	assert.Equal(t, map[entities.CompanyID]entities.Company{testCompanies[0].ID: testCompanies[0]}, page.Companies)
+
+	page, err = service.CheckCarService(context.Background(), &entities.CarQuery{})
+	require.NoError(t, err)
+	assert.Nil(t, page.Companies)
+}
@@ -0,0 +1,59 @@
+package cars
+
+import (
+	"errors"
+	"strings"
+	"testingfiber/pkg/validation"
+)
+
+var (
+	// ErrCarNotFound is returned when no car has the requested ID.
+	ErrCarNotFound = errors.New("car not found")
+	// ErrCompanyNotFound is returned when no company has the requested ID
+	// or name.
+	ErrCompanyNotFound = errors.New("company not found")
+	// ErrInvalidID is returned for IDs that cannot name any car.
+	ErrInvalidID = errors.New("invalid car id")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("car validation failed")
+	// ErrVersionMismatch is returned when a write names a version of the
+	// car that is no longer current.
+	ErrVersionMismatch = errors.New("car version mismatch")
+	// ErrInvalidTransition is returned when a car's status does not allow
+	// the requested step of the sale lifecycle.
+	ErrInvalidTransition = errors.New("invalid car status transition")
+	// ErrConflict is returned when a write clashes with the stored state,
+	// such as a duplicate key.
+	ErrConflict = errors.New("car conflict")
+	// ErrUnavailable is returned when the storage backend cannot be
+	// reached.
+	ErrUnavailable = errors.New("car storage unavailable")
+	// ErrBatchTooLarge is returned for batches over the configured limit.
+	ErrBatchTooLarge = errors.New("car batch too large")
+	// ErrBatchAborted is reported for the items of an atomic batch that
+	// were not applied because another item failed.
+	ErrBatchAborted = errors.New("car batch aborted")
+)
+
+// ValidationError carries every field error found in a car payload.
+type ValidationError struct {
+	Fields []validation.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// FieldErrors returns the fields that failed, for callers handling the
+// validation errors of every package alike.
+func (e *ValidationError) FieldErrors() []validation.FieldError {
+	return e.Fields
+}
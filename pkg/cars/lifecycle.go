@@ -0,0 +1,107 @@
+package cars
+
+import (
+	"fmt"
+	"strings"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+)
+
+// Transition is a step of the sale lifecycle of a car.
+type Transition string
+
+const (
+	Reserve Transition = "reserve"
+	Release Transition = "release"
+	Sell    Transition = "sell"
+	Return  Transition = "return"
+	Archive Transition = "archive"
+)
+
+// transitions lists the statuses each transition may start from and the
+// status it leads to. A released car goes back in stock; a returned car
+// goes back on sale as it is.
+var transitions = map[Transition]struct {
+	from []entities.InventoryStatus
+	to   entities.InventoryStatus
+}{
+	Reserve: {from: []entities.InventoryStatus{entities.InStock, entities.Returned}, to: entities.Reserved},
+	Release: {from: []entities.InventoryStatus{entities.Reserved}, to: entities.InStock},
+	Sell:    {from: []entities.InventoryStatus{entities.InStock, entities.Reserved, entities.Returned}, to: entities.Sold},
+	Return:  {from: []entities.InventoryStatus{entities.Sold}, to: entities.Returned},
+	Archive: {from: []entities.InventoryStatus{entities.InStock, entities.Returned}, to: entities.Archived},
+}
+
+// lifecycleMessage explains to clients how the lifecycle fields change.
+const lifecycleMessage = "is changed only by the reserve, release, sell, return and archive actions"
+
+// statusOf is the status of car. Cars stored before they had a status are
+// in stock.
+func statusOf(car *entities.Car) entities.InventoryStatus {
+	if car.Status == "" {
+		return entities.InStock
+	}
+	return car.Status
+}
+
+// TransitionPatch returns the patch that applies transition to car at
+// now: the new status, and the time of a reservation or a sale, which a
+// release clears. It fails
+// with ErrInvalidTransition when the car's status does not allow the
+// step.
+func TransitionPatch(car *entities.Car, transition Transition, now time.Time) (*entities.CarPatch, error) {
+	step, ok := transitions[transition]
+	if !ok {
+		return nil, fmt.Errorf("unknown car transition %q", transition)
+	}
+
+	status := statusOf(car)
+	allowed := false
+	for _, from := range step.from {
+		allowed = allowed || status == from
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: cannot %s a car that is %s", ErrInvalidTransition, transition, strings.ReplaceAll(string(status), "_", " "))
+	}
+
+	patch := &entities.CarPatch{Status: &step.to}
+	switch transition {
+	case Reserve:
+		patch.ReservedAt = &now
+	case Release:
+		patch.ReservedAt = &time.Time{}
+	case Sell:
+		patch.SoldAt = &now
+	}
+	return patch, nil
+}
+
+// resetLifecycle starts a new car's lifecycle: it comes in stock, neither
+// reserved nor sold, whatever the payload said.
+func resetLifecycle(car *entities.Car) {
+	car.Status = entities.InStock
+	car.ReservedAt = time.Time{}
+	car.SoldAt = time.Time{}
+}
+
+// checkLifecycle rejects an update whose candidate car changes the fields
+// owned by the lifecycle transitions. Restating their current values is
+// fine, so that a client can send back a car it has read.
+func checkLifecycle(current, candidate *entities.Car) error {
+	var fields []validation.FieldError
+	if statusOf(candidate) != statusOf(current) {
+		fields = append(fields, validation.FieldError{Field: "status", Message: lifecycleMessage})
+	}
+	if !candidate.ReservedAt.Equal(current.ReservedAt) {
+		fields = append(fields, validation.FieldError{Field: "reservedAt", Message: lifecycleMessage})
+	}
+	if !candidate.SoldAt.Equal(current.SoldAt) {
+		fields = append(fields, validation.FieldError{Field: "soldAt", Message: lifecycleMessage})
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
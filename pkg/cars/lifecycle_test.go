@@ -0,0 +1,78 @@
+package cars
+
+import (
+	"testing"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionPatch(t *testing.T) {
+	now := time.Date(2023, 7, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from       entities.InventoryStatus
+		transition Transition
+		to         entities.InventoryStatus
+	}{
+		{entities.InStock, Reserve, entities.Reserved},
+		{"", Reserve, entities.Reserved},
+		{entities.Returned, Reserve, entities.Reserved},
+		{entities.Reserved, Reserve, ""},
+		{entities.Reserved, Release, entities.InStock},
+		{entities.InStock, Release, ""},
+		{entities.Sold, Release, ""},
+		{entities.InStock, Sell, entities.Sold},
+		{entities.Reserved, Sell, entities.Sold},
+		{entities.Returned, Sell, entities.Sold},
+		{entities.Sold, Sell, ""},
+		{entities.Archived, Sell, ""},
+		{entities.Sold, Return, entities.Returned},
+		{entities.InStock, Return, ""},
+		{entities.Reserved, Return, ""},
+		{entities.InStock, Archive, entities.Archived},
+		{entities.Returned, Archive, entities.Archived},
+		{entities.Reserved, Archive, ""},
+		{entities.Sold, Archive, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.transition)+"/"+string(test.from), func(t *testing.T) {
+			patch, err := TransitionPatch(&entities.Car{Status: test.from}, test.transition, now)
+			if test.to == "" {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.to, *patch.Status)
+			assert.Equal(t, test.transition == Reserve || test.transition == Release, patch.ReservedAt != nil)
+			if test.transition == Release {
+				assert.True(t, patch.ReservedAt.IsZero(), "a release clears the reservation")
+			}
+			assert.Equal(t, test.transition == Sell, patch.SoldAt != nil)
+		})
+	}
+}
+
+func TestTransitionPatchUnknown(t *testing.T) {
+	_, err := TransitionPatch(&entities.Car{Status: entities.InStock}, "steal", time.Now())
+	assert.EqualError(t, err, `unknown car transition "steal"`)
+	assert.NotErrorIs(t, err, ErrInvalidTransition)
+}
+
+func TestCheckLifecycle(t *testing.T) {
+	soldAt := time.Date(2023, 7, 5, 10, 0, 0, 0, time.UTC)
+	current := &entities.Car{Status: entities.Sold, SoldAt: soldAt}
+
+	// Restating the lifecycle fields, even in another time zone, is fine.
+	assert.NoError(t, checkLifecycle(current, &entities.Car{Status: entities.Sold, SoldAt: soldAt.In(time.FixedZone("CEST", 2*60*60))}))
+	assert.NoError(t, checkLifecycle(&entities.Car{}, &entities.Car{Status: entities.InStock}))
+
+	err := checkLifecycle(current, &entities.Car{Status: entities.Sold, ReservedAt: soldAt, SoldAt: soldAt})
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []validation.FieldError{{Field: "reservedAt", Message: lifecycleMessage}}, validationErr.Fields)
+}
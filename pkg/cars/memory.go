@@ -0,0 +1,404 @@
+package cars
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"time"
+)
+
+// memoryRepository keeps cars in process memory. It mirrors the behaviour
+// of the Mongo repository so the application can run without a database.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	ids    entities.IDGenerator
+	cars   map[entities.CarID]entities.Car
+	order  []entities.CarID
+	search *searchIndex
+}
+
+func NewMemoryRepo(opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &memoryRepository{
+		ids:    o.IDs,
+		cars:   make(map[entities.CarID]entities.Car),
+		search: newSearchIndex(nil, 0),
+	}
+}
+
+func (r *memoryRepository) InsertCar(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.insertLocked(car); err != nil {
+		return nil, err
+	}
+	r.search.put(car)
+	return car, nil
+}
+
+func (r *memoryRepository) insertLocked(car *entities.Car) error {
+	car.ID = entities.CarID(r.ids.NewID())
+	if err := r.checkVINLocked(car); err != nil {
+		return err
+	}
+	car.MadeAt = madeAtOrNow(car.MadeAt, time.Now())
+	car.Version = 1
+
+	r.cars[car.ID] = *car
+	r.order = append(r.order, car.ID)
+	return nil
+}
+
+// checkVINLocked rejects a car whose VIN another car already has, like the
+// unique indexes of the other backends. The store is scanned, since the
+// memory backend is only meant for small data sets.
+func (r *memoryRepository) checkVINLocked(car *entities.Car) error {
+	if car.VIN == "" {
+		return nil
+	}
+	for _, stored := range r.cars {
+		if stored.VIN == car.VIN && stored.ID != car.ID {
+			return fmt.Errorf("%w: VIN %s is already taken", ErrConflict, car.VIN)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) CheckCar(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	var matched []entities.Car
+	for _, id := range r.order {
+		if car := r.cars[id]; matchesQuery(&car, query) {
+			matched = append(matched, car)
+		}
+	}
+	r.mu.RUnlock()
+
+	return sortAndPage(matched, query), nil
+}
+
+// ScanCars visits the matching cars without holding the lock, so visit may
+// call back into the repository. In ID order, the default, it only sorts
+// the IDs of the matches and reads each car as it is reached, leaving out
+// those deleted or changed not to match since. Any other order sorts a
+// snapshot of every match, taken before the first visit.
+func (r *memoryRepository) ScanCars(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	if lookupSortField(query.SortBy) != &sortFields[0] {
+		all := *query
+		all.Limit, all.Offset = 0, 0
+
+		page, err := r.CheckCar(ctx, &all)
+		if err != nil {
+			return err
+		}
+		return visitCars(ctx, page.Cars, visit)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	var ids []entities.CarID
+	for id, car := range r.cars {
+		if matchesQuery(&car, query) {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		if query.Descending {
+			return ids[j] < ids[i]
+		}
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.mu.RLock()
+		car, ok := r.cars[id]
+		r.mu.RUnlock()
+		if !ok || !matchesQuery(&car, query) {
+			continue
+		}
+		if err := visit(&car); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitCars calls visit with each car in turn, for backends that collect
+// the matching cars before scanning them.
+func visitCars(ctx context.Context, list []entities.Car, visit func(*entities.Car) error) error {
+	for i := range list {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := visit(&list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortCars puts the cars matching a query in the query's order.
+func sortCars(matched []entities.Car, query *entities.CarQuery) {
+	sort.SliceStable(matched, func(i, j int) bool {
+		if query.Descending {
+			return lessCar(&matched[j], &matched[i], query.SortBy)
+		}
+		return lessCar(&matched[i], &matched[j], query.SortBy)
+	})
+}
+
+// sortAndPage orders the cars matching a query and cuts out the requested
+// page, for backends that filter in process.
+func sortAndPage(matched []entities.Car, query *entities.CarQuery) *entities.CarPage {
+	sortCars(matched, query)
+
+	total := int64(len(matched))
+	start := query.Offset
+	if start > total {
+		start = total
+	}
+	end := start + query.Limit
+	if query.Limit <= 0 || end > total {
+		end = total
+	}
+
+	var cars []entities.Car
+	cars = append(cars, matched[start:end]...)
+
+	return &entities.CarPage{Cars: cars, Total: total}
+}
+
+func matchesQuery(car *entities.Car, query *entities.CarQuery) bool {
+	if query.CompanyID != "" && car.CompanyID != query.CompanyID {
+		return false
+	}
+	if query.Company != "" && car.Company != query.Company {
+		return false
+	}
+	if query.Status != "" && car.Status != query.Status {
+		return false
+	}
+	if !strings.HasPrefix(car.CarName, query.CarNamePrefix) {
+		return false
+	}
+	return inRange(car.MadeAt, query.MadeFrom, query.MadeTo) &&
+		inRange(car.SoldAt, query.SoldFrom, query.SoldTo)
+}
+
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && t.After(to) {
+		return false
+	}
+	return true
+}
+
+// lessCar orders two cars by the given sort field, falling back to the ID
+// like the Mongo repository does.
+func lessCar(a, b *entities.Car, field string) bool {
+	if c := lookupSortField(field).compare(a, b); c != 0 {
+		return c < 0
+	}
+	return a.ID < b.ID
+}
+
+func (r *memoryRepository) GetCarByID(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	car, ok := r.cars[carId]
+	if !ok {
+		return nil, ErrCarNotFound
+	}
+
+	return &car, nil
+}
+
+func (r *memoryRepository) UpdateCar(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, err := r.updateLocked(carId, version, patch)
+	if err != nil {
+		return nil, err
+	}
+	r.search.put(car)
+	return car, nil
+}
+
+func (r *memoryRepository) updateLocked(carId entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	stored, ok := r.cars[carId]
+	if !ok {
+		return nil, ErrCarNotFound
+	}
+	if version != 0 && stored.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	if !patch.IsEmpty() {
+		patch.Apply(&stored)
+		if err := r.checkVINLocked(&stored); err != nil {
+			return nil, err
+		}
+		stored.Version++
+		r.cars[carId] = stored
+	}
+
+	return &stored, nil
+}
+
+func (r *memoryRepository) DeleteCar(ctx context.Context, ID entities.CarID, version int64) error {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.deleteLocked(carId, version); err != nil {
+		return err
+	}
+	r.search.remove(carId)
+	return nil
+}
+
+func (r *memoryRepository) deleteLocked(carId entities.CarID, version int64) error {
+	stored, ok := r.cars[carId]
+	if !ok {
+		return ErrCarNotFound
+	}
+	if version != 0 && stored.Version != version {
+		return ErrVersionMismatch
+	}
+
+	delete(r.cars, carId)
+	for i, id := range r.order {
+		if id == carId {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) InsertCars(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(cars), atomic, func(i int) (*entities.Car, error) {
+		if err := r.insertLocked(cars[i]); err != nil {
+			return nil, err
+		}
+		return cars[i], nil
+	}, r.search.putResults)
+}
+
+func (r *memoryRepository) UpdateCars(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(updates), atomic, func(i int) (*entities.Car, error) {
+		carId, err := parseID(updates[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return r.updateLocked(carId, updates[i].Version, &updates[i].CarPatch)
+	}, r.search.putResults)
+}
+
+func (r *memoryRepository) DeleteCars(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(deletes), atomic, func(i int) (*entities.Car, error) {
+		carId, err := parseID(deletes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, r.deleteLocked(carId, deletes[i].Version)
+	}, func(results []BatchResult) {
+		r.search.removeResults(deletes, results)
+	})
+}
+
+// batch applies n items under the write lock. An atomic batch works on a
+// copy of the store that only replaces it when every item succeeded.
+// index is handed the final results, to bring the search index up to date.
+func (r *memoryRepository) batch(ctx context.Context, n int, atomic bool, apply func(i int) (*entities.Car, error), index func([]BatchResult)) ([]BatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cars, order := r.cars, r.order
+	if atomic {
+		r.cars = make(map[entities.CarID]entities.Car, len(cars))
+		for id, car := range cars {
+			r.cars[id] = car
+		}
+		r.order = append([]entities.CarID(nil), order...)
+	}
+
+	results := make([]BatchResult, n)
+	for i := range results {
+		results[i].Car, results[i].Err = apply(i)
+	}
+
+	if atomic && batchFailed(results) {
+		r.cars, r.order = cars, order
+		abortBatch(results)
+	}
+	index(results)
+	return results, nil
+}
+
+func (r *memoryRepository) SearchCars(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	return r.search.search(ctx, search)
+}
+
+func (r *memoryRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
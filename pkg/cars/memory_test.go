@@ -0,0 +1,35 @@
+package cars
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepoConcurrentInserts(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepo()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.InsertCar(ctx, &entities.Car{CarName: "CX-5", Company: "Mazda"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	cars, err := repo.CheckCar(ctx, &entities.CarQuery{})
+	require.NoError(t, err)
+	assert.Len(t, cars.Cars, 50)
+}
+
+func TestMemoryScanReadsCarsAsItGoes(t *testing.T) {
+	checkScanStreams(t, func() Repository { return NewMemoryRepo() }, int(MaxListLimit))
+}
@@ -0,0 +1,11 @@
+CREATE TABLE cars (
+    id       bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    car_name text        NOT NULL,
+    company  text        NOT NULL,
+    made_at  timestamptz,
+    sold_at  timestamptz,
+    version  bigint      NOT NULL DEFAULT 1
+);
+
+CREATE INDEX cars_company_idx ON cars (company);
+CREATE INDEX cars_car_name_idx ON cars (car_name text_pattern_ops);
@@ -0,0 +1,6 @@
+-- Car IDs are generated by the application in a configurable format and
+-- stored as text. Existing numeric IDs are rewritten as zero-padded
+-- 24 digit hex, which is a valid ObjectID-format ID, so those cars stay
+-- reachable (under a new ID).
+ALTER TABLE cars ALTER COLUMN id DROP IDENTITY;
+ALTER TABLE cars ALTER COLUMN id TYPE text USING lpad(to_hex(id), 24, '0');
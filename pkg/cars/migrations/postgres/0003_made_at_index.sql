@@ -0,0 +1,2 @@
+-- Listings filter and sort on the manufacturing date.
+CREATE INDEX cars_made_at_idx ON cars (made_at);
@@ -0,0 +1,20 @@
+-- Inventory details of a car. The defaults backfill existing cars, which
+-- were all for sale: in stock, with no VIN, mileage or price recorded.
+-- An empty VIN is stored as NULL, so any number of cars can go without
+-- one.
+ALTER TABLE cars
+    ADD COLUMN vin        text,
+    ADD COLUMN model_year integer NOT NULL DEFAULT 0,
+    ADD COLUMN trim       text    NOT NULL DEFAULT '',
+    ADD COLUMN color      text    NOT NULL DEFAULT '',
+    ADD COLUMN mileage    bigint  NOT NULL DEFAULT 0 CHECK (mileage >= 0),
+    ADD COLUMN list_price bigint  NOT NULL DEFAULT 0 CHECK (list_price >= 0),
+    ADD COLUMN currency   text    NOT NULL DEFAULT '',
+    ADD COLUMN condition  text    NOT NULL DEFAULT ''
+        CHECK (condition IN ('', 'new', 'used', 'certified')),
+    ADD COLUMN status     text    NOT NULL DEFAULT 'in_stock'
+        CHECK (status IN ('in_stock', 'reserved', 'sold', 'returned', 'archived'));
+
+CREATE UNIQUE INDEX cars_vin_key ON cars (vin);
+-- Listings filter on the status.
+CREATE INDEX cars_status_idx ON cars (status);
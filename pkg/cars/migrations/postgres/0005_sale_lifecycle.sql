@@ -0,0 +1,5 @@
+-- Reservations are timestamped like sales. Until now sold_at was set when
+-- a car was inserted, so it is cleared for cars that were never sold.
+ALTER TABLE cars ADD COLUMN reserved_at timestamptz;
+
+UPDATE cars SET sold_at = NULL WHERE status NOT IN ('sold', 'returned');
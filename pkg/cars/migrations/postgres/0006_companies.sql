@@ -0,0 +1,6 @@
+-- Cars stored so far name their company only by name, and keep a NULL
+-- company_id. A referenced company cannot be deleted. The companies table
+-- comes from the companies migrations.
+ALTER TABLE cars ADD COLUMN company_id text REFERENCES companies (id) ON DELETE RESTRICT;
+
+CREATE INDEX cars_company_id_idx ON cars (company_id);
@@ -0,0 +1,240 @@
+package cars
+
+import (
+	"context"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoMigrator returns the migrator for the cars collection.
+func NewMongoMigrator(cars *mongo.Collection) migrate.Migrator {
+	return migrate.NewMongo(cars, mongoMigrations)
+}
+
+// mongoMigrations are applied in this order, which is also version order.
+var mongoMigrations = []migrate.MongoMigration{
+	{
+		Version: "0001_listing_indexes",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "company", Value: 1}}, Options: options.Index().SetName("company_1")},
+				{Keys: bson.D{{Key: "carName", Value: 1}}, Options: options.Index().SetName("carName_1")},
+				{Keys: bson.D{{Key: "madeAt", Value: 1}}, Options: options.Index().SetName("madeAt_1")},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, cars, "company_1", "carName_1", "madeAt_1")
+		},
+	},
+	{
+		// Only cars that have a VIN take part, so any number of cars can
+		// go without one.
+		Version: "0002_unique_vin",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "vin", Value: 1}},
+				Options: options.Index().SetName("vin_1").SetUnique(true).
+					SetPartialFilterExpression(bson.M{"vin": bson.M{"$type": "string"}}),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, cars, "vin_1")
+		},
+	},
+	{
+		// Cars written before optimistic concurrency have no version, and
+		// versioned writes could never match them.
+		Version: "0003_backfill_version",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.UpdateMany(ctx,
+				bson.M{"version": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"version": int64(1)}})
+			return err
+		},
+		// The backfilled versions are indistinguishable from real ones
+		// and harmless, so they stay.
+		Down: func(context.Context, *mongo.Collection) error { return nil },
+	},
+	{
+		// Moderate validation checks inserts and updates of valid
+		// documents, leaving any invalid legacy document writable.
+		Version: "0004_car_validator",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			return migrate.SetValidator(ctx, cars, bson.M{"$jsonSchema": carSchema}, "moderate")
+		},
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			return migrate.SetValidator(ctx, cars, bson.M{}, "off")
+		},
+	},
+	{
+		// Car and company names are not prose, so words are indexed as
+		// they are written rather than stemmed.
+		Version: "0005_text_index",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "carName", Value: "text"}, {Key: "company", Value: "text"}},
+				Options: options.Index().SetName("car_text").
+					SetWeights(bson.M{"carName": 2, "company": 1}).
+					SetDefaultLanguage("none"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, cars, "car_text")
+		},
+	},
+	{
+		// Cars stored so far were all for sale, so they are put in stock.
+		// Listings filter on the status, hence the index.
+		Version: "0006_inventory_fields",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.UpdateMany(ctx,
+				bson.M{"status": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"status": string(entities.InStock)}})
+			if err != nil {
+				return err
+			}
+			_, err = cars.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "status", Value: 1}},
+				Options: options.Index().SetName("status_1"),
+			})
+			if err != nil {
+				return err
+			}
+			return migrate.SetValidator(ctx, cars, bson.M{"$jsonSchema": inventoryCarSchema}, "moderate")
+		},
+		// The backfilled statuses are harmless and stay, like the
+		// versions of 0003.
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			if err := migrate.SetValidator(ctx, cars, bson.M{"$jsonSchema": carSchema}, "moderate"); err != nil {
+				return err
+			}
+			return migrate.DropIndexes(ctx, cars, "status_1")
+		},
+	},
+	{
+		// soldAt used to be set when a car was inserted; it now records
+		// the sale, so cars that were never sold lose it.
+		Version: "0007_sale_lifecycle",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.UpdateMany(ctx,
+				bson.M{"status": bson.M{"$nin": bson.A{string(entities.Sold), string(entities.Returned)}}},
+				bson.M{"$unset": bson.M{"soldAt": ""}})
+			return err
+		},
+		// The insert times cannot be told apart any more, and were
+		// meaningless anyway.
+		Down: func(context.Context, *mongo.Collection) error { return nil },
+	},
+	{
+		// A company cannot be deleted while cars reference it, which is
+		// checked by looking them up.
+		Version: "0008_company_ids",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			_, err := cars.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "companyId", Value: 1}},
+				Options: options.Index().SetName("companyId_1").SetSparse(true),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, cars, "companyId_1")
+		},
+	},
+	{
+		// Search widens its terms to the stored words through a
+		// collection of their own, see wordDocument; it is filled from
+		// the cars stored so far.
+		Version: "0009_search_words",
+		Up: func(ctx context.Context, cars *mongo.Collection) error {
+			words := wordsCollection(cars)
+			_, err := words.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "variants", Value: 1}},
+				Options: options.Index().SetName("variants_1"),
+			})
+			if err != nil {
+				return err
+			}
+
+			projection := bson.M{"carName": 1, "company": 1}
+			cursor, err := cars.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			counts := map[string]int{}
+			for cursor.Next(ctx) {
+				var doc carDocument
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				for _, word := range carWords(doc.car()) {
+					counts[word]++
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				return err
+			}
+
+			var models []mongo.WriteModel
+			for word, count := range counts {
+				models = append(models, mongo.NewReplaceOneModel().
+					SetFilter(bson.M{"_id": word}).
+					SetReplacement(wordDocument{Word: word, Cars: count, Variants: wordVariants(word)}).
+					SetUpsert(true))
+			}
+			if len(models) == 0 {
+				return nil
+			}
+			_, err = words.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			return err
+		},
+		Down: func(ctx context.Context, cars *mongo.Collection) error {
+			return wordsCollection(cars).Drop(ctx)
+		},
+	},
+}
+
+// carSchema is the JSON schema stored cars must satisfy.
+var carSchema = bson.M{
+	"bsonType": "object",
+	"required": bson.A{"carName", "company", "version"},
+	"properties": bson.M{
+		"carName": bson.M{"bsonType": "string", "minLength": 1},
+		"company": bson.M{"bsonType": "string", "minLength": 1},
+		"madeAt":  bson.M{"bsonType": "date"},
+		"soldAt":  bson.M{"bsonType": "date"},
+		"version": bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 1},
+		"vin":     bson.M{"bsonType": "string"},
+	},
+}
+
+// inventoryCarSchema extends carSchema with the inventory fields that
+// 0006_inventory_fields introduced.
+var inventoryCarSchema = bson.M{
+	"bsonType": "object",
+	"required": bson.A{"carName", "company", "status", "version"},
+	"properties": bson.M{
+		"carName":   bson.M{"bsonType": "string", "minLength": 1},
+		"company":   bson.M{"bsonType": "string", "minLength": 1},
+		"madeAt":    bson.M{"bsonType": "date"},
+		"soldAt":    bson.M{"bsonType": "date"},
+		"version":   bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 1},
+		"vin":       bson.M{"bsonType": "string", "pattern": "^[A-HJ-NPR-Z0-9]{17}$"},
+		"modelYear": bson.M{"bsonType": bson.A{"int", "long"}, "minimum": firstModelYear},
+		"trim":      bson.M{"bsonType": "string"},
+		"color":     bson.M{"bsonType": "string"},
+		"mileage":   bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0},
+		"listPrice": bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0},
+		"currency":  bson.M{"bsonType": "string", "pattern": "^[A-Z]{3}$"},
+		"condition": bson.M{"enum": bson.A{"new", "used", "certified"}},
+		"status":    bson.M{"enum": bson.A{"in_stock", "reserved", "sold", "returned", "archived"}},
+	},
+}
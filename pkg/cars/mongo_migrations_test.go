@@ -0,0 +1,111 @@
+package cars
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMongoMigrationsAreOrdered(t *testing.T) {
+	versions := make([]string, len(mongoMigrations))
+	for i, migration := range mongoMigrations {
+		versions[i] = migration.Version
+		assert.NotNil(t, migration.Up, migration.Version)
+		assert.NotNil(t, migration.Down, migration.Version)
+	}
+	assert.True(t, sort.StringsAreSorted(versions), "versions out of order: %v", versions)
+}
+
+// TestMongoMigrator runs against a real server when MONGO_TEST_URI is set.
+func TestMongoMigrator(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+	db := client.Database("cars_migrations_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() { _ = db.Drop(context.Background()) })
+	collection := db.Collection("cars")
+
+	// A car from before optimistic concurrency, stamped as sold when it
+	// was inserted.
+	_, err = collection.InsertOne(ctx, bson.M{"carName": "CX-5", "company": "Mazda", "soldAt": time.Now()})
+	require.NoError(t, err)
+
+	migrator := NewMongoMigrator(collection)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(mongoMigrations))
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied(), status.Version)
+	}
+
+	var legacy bson.M
+	require.NoError(t, collection.FindOne(ctx, bson.M{"carName": "CX-5"}).Decode(&legacy))
+	assert.EqualValues(t, 1, legacy["version"])
+	assert.Equal(t, "in_stock", legacy["status"])
+	assert.NotContains(t, legacy, "soldAt")
+
+	_, err = collection.InsertOne(ctx, bson.M{"carName": "A4", "company": "Audi", "version": 1, "status": "in_stock", "vin": "WAUZZZ8K9BA000001"})
+	require.NoError(t, err)
+	_, err = collection.InsertOne(ctx, bson.M{"carName": "A6", "company": "Audi", "version": 1, "status": "in_stock", "vin": "WAUZZZ8K9BA000001"})
+	assert.True(t, mongo.IsDuplicateKeyError(err), "duplicate VIN: %v", err)
+	_, err = collection.InsertOne(ctx, bson.M{"carName": "", "company": "Audi", "version": 1, "status": "in_stock"})
+	assert.Error(t, err, "validator should reject an empty carName")
+	_, err = collection.InsertOne(ctx, bson.M{"carName": "A5", "company": "Audi", "version": 1, "status": "stolen"})
+	assert.Error(t, err, "validator should reject an unknown status")
+
+	indexes, err := collection.Indexes().ListSpecifications(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, index := range indexes {
+		names = append(names, index.Name)
+	}
+	assert.Contains(t, names, "companyId_1")
+
+	// The words of the cars stored before search had a vocabulary.
+	var word wordDocument
+	require.NoError(t, wordsCollection(collection).FindOne(ctx, bson.M{"_id": "cx"}).Decode(&word))
+	assert.Equal(t, 1, word.Cars)
+	assert.Contains(t, word.Variants, "cx")
+
+	// Roll back down to and including 0006_inventory_fields.
+	for i := len(mongoMigrations) - 1; i >= len(mongoMigrations)-4; i-- {
+		rolledBack, err := migrator.Down(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, mongoMigrations[i].Version, rolledBack)
+	}
+
+	_, err = collection.InsertOne(ctx, bson.M{"carName": "A5", "company": "Audi", "version": 1})
+	assert.NoError(t, err, "status should no longer be required")
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses[len(statuses)-4:] {
+		assert.False(t, status.Applied(), status.Version)
+	}
+}
@@ -0,0 +1,292 @@
+package cars
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testingfiber/pkg/entities"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// wordDocument is the stored form of a word of the searchable fields.
+//
+// A text index only matches whole words, so the Mongo repository keeps the
+// words of the searchable fields in a collection of their own, next to the
+// cars, to widen search terms to the stored words they match by prefix or
+// within a typo or two. Each word document counts the cars that have the
+// word and lists the strings left by deleting up to two of its letters:
+// a word is within a few typos of a term only if the two share such a
+// string, so candidates are found through the index on those variants
+// rather than by comparing the term with every word.
+//
+// The counts are updated after the cars are written, outside any
+// transaction. A count a failed update leaves behind only affects fuzzy
+// matches, since the terms themselves are always searched as they are;
+// migrating down and up again rebuilds the collection.
+type wordDocument struct {
+	Word     string   `bson:"_id"`
+	Cars     int      `bson:"cars"`
+	Variants []string `bson:"variants"`
+}
+
+// wordsCollection is the collection of the words of the cars in cars.
+func wordsCollection(cars *mongo.Collection) *mongo.Collection {
+	return cars.Database().Collection(cars.Name() + "_words")
+}
+
+// carWords returns the distinct words of the searchable fields of car.
+func carWords(car *entities.Car) []string {
+	var all []string
+	seen := map[string]bool{}
+	for _, field := range searchFields {
+		for _, word := range searchTerms(field.value(car)) {
+			if !seen[word] {
+				seen[word] = true
+				all = append(all, word)
+			}
+		}
+	}
+	return all
+}
+
+// wordVariants lists the strings a stored word is found by: the word and
+// what deleting letters leaves, as many as the longest term within reach
+// of the word may have typos.
+func wordVariants(word string) []string {
+	deletions := 0
+	switch n := utf8.RuneCountInString(word); {
+	case n >= 6:
+		deletions = 2
+	case n >= 3:
+		deletions = 1
+	}
+	return deletionVariants(word, deletions)
+}
+
+// deletionVariants returns word and the distinct strings left by deleting
+// up to max of its letters.
+func deletionVariants(word string, max int) []string {
+	variants := []string{word}
+	seen := map[string]bool{word: true}
+	level := []string{word}
+	for d := 0; d < max; d++ {
+		var next []string
+		for _, variant := range level {
+			runes := []rune(variant)
+			for i := range runes {
+				shorter := string(runes[:i]) + string(runes[i+1:])
+				if shorter != "" && !seen[shorter] {
+					seen[shorter] = true
+					next = append(next, shorter)
+				}
+			}
+		}
+		variants = append(variants, next...)
+		level = next
+	}
+	return variants
+}
+
+// wordCounts returns by how much the car counts of the words change when
+// the cars in removed are replaced by those in added.
+func wordCounts(removed, added []*entities.Car) map[string]int {
+	counts := map[string]int{}
+	for _, car := range removed {
+		for _, word := range carWords(car) {
+			counts[word]--
+		}
+	}
+	for _, car := range added {
+		for _, word := range carWords(car) {
+			counts[word]++
+		}
+	}
+	return counts
+}
+
+// countWords brings the word collection in line with the cars in removed
+// being replaced by those in added; either may be empty. Words no car has
+// any more are dropped.
+func (r *repository) countWords(ctx context.Context, removed, added []*entities.Car) error {
+	var models []mongo.WriteModel
+	var gone bson.A
+	for word, delta := range wordCounts(removed, added) {
+		switch {
+		case delta > 0:
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": word}).
+				SetUpdate(bson.M{
+					"$inc":         bson.M{"cars": delta},
+					"$setOnInsert": bson.M{"variants": wordVariants(word)},
+				}).
+				SetUpsert(true))
+		case delta < 0:
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": word}).
+				SetUpdate(bson.M{"$inc": bson.M{"cars": delta}}))
+			gone = append(gone, word)
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	if _, err := r.words.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return mongoError(err)
+	}
+	if len(gone) > 0 {
+		_, err := r.words.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": gone}, "cars": bson.M{"$lte": 0}})
+		if err != nil {
+			return mongoError(err)
+		}
+	}
+	return nil
+}
+
+// recountWords is countWords for writes that have already been made: the
+// cars are stored whether or not the words are, so a failure is not the
+// caller's. It only costs fuzzy matches on the words concerned.
+func (r *repository) recountWords(ctx context.Context, removed, added []*entities.Car) {
+	_ = r.countWords(ctx, removed, added)
+}
+
+// SearchCars widens every term to the stored words it matches, finds the
+// cars with any of them through the car_text index, and ranks those the
+// way the in-process index of the other backends does, so that an exact
+// match comes before a corrected one.
+func (r *repository) SearchCars(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	terms := searchTerms(search.Query)
+	if len(terms) == 0 {
+		return &entities.SearchPage{}, nil
+	}
+
+	words, err := r.matchingWords(ctx, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": strings.Join(words, " ")}}
+	projection := bson.M{"carName": 1, "company": 1}
+	cursor, err := r.Collection.Find(ctx, filter, options.Find().SetProjection(projection))
+
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	// Every car that has one of the words is among the matches, so they
+	// tell how many cars have each word.
+	var matched []*entities.Car
+	counts := map[string]int{}
+	for cursor.Next(ctx) {
+		var doc carDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		car := doc.car()
+		matched = append(matched, car)
+		for _, word := range carWords(car) {
+			counts[word]++
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, mongoError(err)
+	}
+
+	total, err := r.Collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var hits []entities.SearchHit
+	for _, car := range matched {
+		if score := carScore(terms, car, counts, int(total)); score > 0 {
+			hits = append(hits, entities.SearchHit{Car: *car, Score: score})
+		}
+	}
+	page := rankHits(hits, search)
+
+	ids := make([]entities.CarID, len(page.Hits))
+	for i, hit := range page.Hits {
+		ids[i] = hit.Car.ID
+	}
+	found, err := r.findCars(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	hits = page.Hits[:0]
+	for _, hit := range page.Hits {
+		// A car deleted since it matched is left out of the page.
+		if car, ok := found[hit.Car.ID]; ok {
+			hits = append(hits, entities.SearchHit{Car: *car, Score: hit.Score})
+		}
+	}
+	page.Hits = hits
+	return page, nil
+}
+
+// matchingWords returns the terms and the stored words that match any of
+// them by prefix or within the typos the term tolerates.
+func (r *repository) matchingWords(ctx context.Context, terms []string) ([]string, error) {
+	var variants []string
+	var or bson.A
+	for _, term := range terms {
+		variants = append(variants, deletionVariants(term, typoBudget(term))...)
+		if utf8.RuneCountInString(term) >= 2 {
+			or = append(or, bson.M{"_id": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(term)}})
+		}
+	}
+	or = append(or, bson.M{"variants": bson.M{"$in": variants}})
+
+	cursor, err := r.words.Find(ctx, bson.M{"$or": or}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var docs []wordDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+
+	words := append([]string{}, terms...)
+	for _, doc := range docs {
+		for _, term := range terms {
+			if doc.Word != term && matchTerm(term, doc.Word) > 0 {
+				words = append(words, doc.Word)
+				break
+			}
+		}
+	}
+	return words, nil
+}
+
+// carScore scores car against terms like searchIndex.search: for each
+// term, the best match among the words of car, weighted by the field the
+// word is in and by its rarity among total cars, where counts tells how
+// many cars have each word.
+func carScore(terms []string, car *entities.Car, counts map[string]int, total int) float64 {
+	var score float64
+	for _, term := range terms {
+		var best float64
+		for _, field := range searchFields {
+			for _, word := range searchTerms(field.value(car)) {
+				match := matchTerm(term, word)
+				if match == 0 {
+					continue
+				}
+				if s := match * field.weight * rarity(counts[word], total); s > best {
+					best = s
+				}
+			}
+		}
+		score += best
+	}
+	return score
+}
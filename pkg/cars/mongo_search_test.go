@@ -0,0 +1,84 @@
+package cars
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWordVariantsFindTypos checks that a stored word and every term
+// within its typos share a deletion variant, which is how the Mongo
+// repository finds the word.
+func TestWordVariantsFindTypos(t *testing.T) {
+	tests := []struct{ term, word string }{
+		{"corola", "corolla"},
+		{"toyta", "toyota"},
+		{"tyoota", "toyota"},
+		{"hodna", "honda"},
+		{"lamborgini", "lamborghini"},
+		{"lambrgini", "lamborghini"},
+		{"lamborghinii", "lamborghini"},
+		{"civc", "civic"},
+		{"ciivc", "civic"},
+		{"kiaa", "kia"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.term+"/"+test.word, func(t *testing.T) {
+			if matchTerm(test.term, test.word) == 0 {
+				t.Fatalf("%q does not match %q", test.term, test.word)
+			}
+			stored := map[string]bool{}
+			for _, variant := range wordVariants(test.word) {
+				stored[variant] = true
+			}
+			shared := false
+			for _, variant := range deletionVariants(test.term, typoBudget(test.term)) {
+				shared = shared || stored[variant]
+			}
+			assert.True(t, shared)
+		})
+	}
+}
+
+func TestDeletionVariants(t *testing.T) {
+	assert.Equal(t, []string{"kia"}, deletionVariants("kia", 0))
+	assert.ElementsMatch(t, []string{"civic", "ivic", "cvic", "ciic", "civc", "civi"}, deletionVariants("civic", 1))
+	assert.Len(t, wordVariants("ab"), 1)
+}
+
+func TestWordCounts(t *testing.T) {
+	before := &entities.Car{CarName: "CX-5", Company: "Mazda"}
+	after := &entities.Car{CarName: "CX-30", Company: "Mazda"}
+
+	assert.Equal(t, map[string]int{"cx": 0, "5": -1, "30": 1, "mazda": 0},
+		wordCounts([]*entities.Car{before}, []*entities.Car{after}))
+	assert.Equal(t, map[string]int{"mazda": 2, "cx": 2, "5": 1, "30": 1},
+		wordCounts(nil, []*entities.Car{before, after}))
+}
+
+func TestCarScoreRanksLikeTheIndex(t *testing.T) {
+	stored := []entities.Car{
+		{ID: "1", CarName: "Corola", Company: "Toyota", Version: 1},
+		{ID: "2", CarName: "Corollas", Company: "Toyota", Version: 1},
+		{ID: "3", CarName: "Corolla", Company: "Toyota", Version: 1},
+	}
+	index := newSearchIndex(nil, 0)
+	counts := map[string]int{}
+	for i := range stored {
+		index.put(&stored[i])
+		for _, word := range carWords(&stored[i]) {
+			counts[word]++
+		}
+	}
+
+	terms := searchTerms("corolla toyota")
+	page, err := index.search(context.Background(), &entities.CarSearch{Query: "corolla toyota"})
+	assert.NoError(t, err)
+	for _, hit := range page.Hits {
+		assert.InDelta(t, hit.Score, carScore(terms, &hit.Car, counts, len(stored)), 1e-9, hit.Car.CarName)
+	}
+	assert.Equal(t, entities.CarID("3"), page.Hits[0].Car.ID)
+}
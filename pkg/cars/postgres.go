@@ -0,0 +1,542 @@
+package cars
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"testingfiber/pkg/migrate"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresColumns lists the columns of a car in the order scanCar reads
+// them.
+const postgresColumns = "id, vin, car_name, company_id, company, model_year, trim, color, mileage, list_price, currency, condition, status, made_at, reserved_at, sold_at, version"
+
+// postgresSearchIndexMaxAge bounds how long writes made by other server
+// instances can go unnoticed by search.
+const postgresSearchIndexMaxAge = time.Minute
+
+// postgresRepository stores cars in PostgreSQL, keyed by their ID as text.
+// Searches run against an in-process index, loaded from the table on the
+// first search and again once it is postgresSearchIndexMaxAge old.
+type postgresRepository struct {
+	pool   *pgxpool.Pool
+	ids    entities.IDGenerator
+	search *searchIndex
+}
+
+// PostgresRepository is the Repository of NewPostgresRepo, which can also
+// update a car together with other writes to the same database.
+type PostgresRepository interface {
+	Repository
+	UpdateCarWith(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch, also func(tx pgx.Tx, car *entities.Car) error) (*entities.Car, error)
+}
+
+// NewPostgresRepo returns a repository backed by pool. The schema must be
+// up to date, see NewPostgresMigrator.
+func NewPostgresRepo(pool *pgxpool.Pool, opts ...RepositoryOption) PostgresRepository {
+	o := store.NewOptions(opts)
+	r := &postgresRepository{pool: pool, ids: o.IDs}
+	r.search = newSearchIndex(func(ctx context.Context, visit func(*entities.Car) error) error {
+		return r.ScanCars(ctx, &entities.CarQuery{}, visit)
+	}, postgresSearchIndexMaxAge)
+	return r
+}
+
+// NewPostgresMigrator returns the migrator for the cars table of the
+// database behind pool. The cars reference companies, so the companies
+// migrations must be applied first.
+func NewPostgresMigrator(pool *pgxpool.Pool) migrate.Migrator {
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		panic(err)
+	}
+	return migrate.NewPostgres(pool, migrations)
+}
+
+// postgresQuerier runs the car statements on the pool, or on a
+// transaction for batches.
+type postgresQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (r *postgresRepository) InsertCar(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	car, err := r.insert(ctx, r.pool, car)
+	if err != nil {
+		return nil, err
+	}
+	r.search.put(car)
+	return car, nil
+}
+
+func (r *postgresRepository) insert(ctx context.Context, q postgresQuerier, car *entities.Car) (*entities.Car, error) {
+	row := q.QueryRow(ctx,
+		"INSERT INTO cars (id, vin, car_name, company_id, company, model_year, trim, color, mileage, list_price, currency, condition, status, made_at, reserved_at, sold_at)"+
+			" VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING "+postgresColumns,
+		r.ids.NewID(), nullString(car.VIN), car.CarName, nullString(string(car.CompanyID)), car.Company, car.ModelYear, car.Trim, car.Color,
+		car.Mileage, car.ListPrice, car.Currency, car.Condition, car.Status, madeAtOrNow(car.MadeAt, time.Now()), nullTime(car.ReservedAt), nullTime(car.SoldAt))
+
+	return scanCar(row)
+}
+
+func (r *postgresRepository) CheckCar(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	where, args := postgresFilter(query)
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT count(*) FROM cars"+where, args...).Scan(&total); err != nil {
+		return nil, postgresError(err)
+	}
+
+	order := postgresOrder(query)
+
+	// A NULL limit means no limit, matching a zero limit elsewhere.
+	var limit *int64
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	args = append(args, limit, query.Offset)
+	page := fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.pool.Query(ctx, "SELECT "+postgresColumns+" FROM cars"+where+order+page, args...)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	var cars []entities.Car
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return nil, err
+		}
+		cars = append(cars, *car)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+
+	return &entities.CarPage{Cars: cars, Total: total}, nil
+}
+
+// ScanCars reads the matching rows as they arrive, so memory use does not
+// grow with the number of cars. The connection is held until the scan
+// ends.
+func (r *postgresRepository) ScanCars(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	where, args := postgresFilter(query)
+
+	rows, err := r.pool.Query(ctx, "SELECT "+postgresColumns+" FROM cars"+where+postgresOrder(query), args...)
+	if err != nil {
+		return postgresError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return err
+		}
+		if err := visit(car); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return postgresError(err)
+	}
+	return nil
+}
+
+// postgresOrder builds the ORDER BY clause of a listing query.
+func postgresOrder(query *entities.CarQuery) string {
+	field := lookupSortField(query.SortBy)
+	// NULLs sort like missing fields in Mongo: first when ascending.
+	direction := "ASC NULLS FIRST"
+	if query.Descending {
+		direction = "DESC NULLS LAST"
+	}
+	order := " ORDER BY " + field.postgres + " " + direction
+	if id := &sortFields[0]; field != id {
+		// Break ties on id so that pages do not overlap.
+		order += ", " + id.postgres + " " + direction
+	}
+	return order
+}
+
+// postgresFilter translates the filters of a listing query into a WHERE
+// clause and its arguments.
+func postgresFilter(query *entities.CarQuery) (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.CompanyID != "" {
+		add("company_id = $%d", query.CompanyID)
+	}
+	if query.Company != "" {
+		add("company = $%d", query.Company)
+	}
+	if query.Status != "" {
+		add("status = $%d", query.Status)
+	}
+	if query.CarNamePrefix != "" {
+		add("car_name LIKE $%d", likePrefix(query.CarNamePrefix))
+	}
+	if !query.MadeFrom.IsZero() {
+		add("made_at >= $%d", query.MadeFrom)
+	}
+	if !query.MadeTo.IsZero() {
+		add("made_at <= $%d", query.MadeTo)
+	}
+	if !query.SoldFrom.IsZero() {
+		add("sold_at >= $%d", query.SoldFrom)
+	}
+	if !query.SoldTo.IsZero() {
+		add("sold_at <= $%d", query.SoldTo)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// likePrefix builds a LIKE pattern matching strings that start with prefix
+// taken literally.
+func likePrefix(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(prefix) + "%"
+}
+
+func (r *postgresRepository) GetCarByID(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	row := r.pool.QueryRow(ctx, "SELECT "+postgresColumns+" FROM cars WHERE id = $1", carId)
+	return scanCar(row)
+}
+
+func (r *postgresRepository) UpdateCar(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := postgresUpdate(ctx, r.pool, carId, version, patch)
+	if err != nil {
+		return nil, err
+	}
+	r.search.put(car)
+	return car, nil
+}
+
+// UpdateCarWith is UpdateCar running also in the same transaction once
+// the car is updated, for writes that must commit together with it, such
+// as an order selling the car. The search index sees the car once both
+// have committed.
+func (r *postgresRepository) UpdateCarWith(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch, also func(tx pgx.Tx, car *entities.Car) error) (*entities.Car, error) {
+	carId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var car *entities.Car
+	err = pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if car, err = postgresUpdate(ctx, tx, carId, version, patch); err != nil {
+			return err
+		}
+		return also(tx, car)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.search.put(car)
+	return car, nil
+}
+
+func postgresUpdate(ctx context.Context, q postgresQuerier, carId entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	if patch.IsEmpty() {
+		car, err := scanCar(q.QueryRow(ctx, "SELECT "+postgresColumns+" FROM cars WHERE id = $1", carId))
+		if err == nil && version != 0 && car.Version != version {
+			return nil, ErrVersionMismatch
+		}
+		return car, err
+	}
+
+	args := []any{carId, version}
+	var set []string
+	assign := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.VIN != nil {
+		assign("vin", nullString(*patch.VIN))
+	}
+	if patch.CarName != nil {
+		assign("car_name", *patch.CarName)
+	}
+	if patch.CompanyID != nil {
+		assign("company_id", nullString(string(*patch.CompanyID)))
+	}
+	if patch.Company != nil {
+		assign("company", *patch.Company)
+	}
+	if patch.ModelYear != nil {
+		assign("model_year", *patch.ModelYear)
+	}
+	if patch.Trim != nil {
+		assign("trim", *patch.Trim)
+	}
+	if patch.Color != nil {
+		assign("color", *patch.Color)
+	}
+	if patch.Mileage != nil {
+		assign("mileage", *patch.Mileage)
+	}
+	if patch.ListPrice != nil {
+		assign("list_price", *patch.ListPrice)
+	}
+	if patch.Currency != nil {
+		assign("currency", *patch.Currency)
+	}
+	if patch.Condition != nil {
+		assign("condition", *patch.Condition)
+	}
+	if patch.Status != nil {
+		assign("status", *patch.Status)
+	}
+	if patch.MadeAt != nil {
+		assign("made_at", nullTime(*patch.MadeAt))
+	}
+	if patch.ReservedAt != nil {
+		assign("reserved_at", nullTime(*patch.ReservedAt))
+	}
+	if patch.SoldAt != nil {
+		assign("sold_at", nullTime(*patch.SoldAt))
+	}
+
+	row := q.QueryRow(ctx,
+		"UPDATE cars SET "+strings.Join(set, ", ")+", version = version + 1"+
+			" WHERE id = $1 AND ($2::bigint = 0 OR version = $2) RETURNING "+postgresColumns,
+		args...)
+
+	car, err := scanCar(row)
+	if errors.Is(err, ErrCarNotFound) {
+		return nil, postgresMissError(ctx, q, carId)
+	}
+	return car, err
+}
+
+func (r *postgresRepository) DeleteCar(ctx context.Context, ID entities.CarID, version int64) error {
+	carId, err := parseID(ID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := postgresDelete(ctx, r.pool, carId, version); err != nil {
+		return err
+	}
+	r.search.remove(carId)
+	return nil
+}
+
+func postgresDelete(ctx context.Context, q postgresQuerier, carId entities.CarID, version int64) error {
+	tag, err := q.Exec(ctx, "DELETE FROM cars WHERE id = $1 AND ($2::bigint = 0 OR version = $2)", carId, version)
+
+	if err != nil {
+		return postgresError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return postgresMissError(ctx, q, carId)
+	}
+
+	return nil
+}
+
+func (r *postgresRepository) InsertCars(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(cars), atomic, func(q postgresQuerier, i int) (*entities.Car, error) {
+		return r.insert(ctx, q, cars[i])
+	}, r.search.putResults)
+}
+
+func (r *postgresRepository) UpdateCars(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(updates), atomic, func(q postgresQuerier, i int) (*entities.Car, error) {
+		carId, err := parseID(updates[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return postgresUpdate(ctx, q, carId, updates[i].Version, &updates[i].CarPatch)
+	}, r.search.putResults)
+}
+
+func (r *postgresRepository) DeleteCars(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error) {
+	return r.batch(ctx, len(deletes), atomic, func(q postgresQuerier, i int) (*entities.Car, error) {
+		carId, err := parseID(deletes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, postgresDelete(ctx, q, carId, deletes[i].Version)
+	}, func(results []BatchResult) {
+		r.search.removeResults(deletes, results)
+	})
+}
+
+// batch applies n items in one transaction, each under a savepoint so that
+// a failed statement only undoes its own item. An atomic batch still runs
+// every item, to report each failure, and then rolls back. index is handed
+// the results once they are committed.
+func (r *postgresRepository) batch(ctx context.Context, n int, atomic bool, apply func(q postgresQuerier, i int) (*entities.Car, error), index func([]BatchResult)) ([]BatchResult, error) {
+	results := make([]BatchResult, n)
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		for i := range results {
+			err := pgx.BeginFunc(ctx, tx, func(savepoint pgx.Tx) error {
+				car, err := apply(savepoint, i)
+				results[i] = BatchResult{Car: car, Err: err}
+				return err
+			})
+			if err != nil && !isItemError(err) {
+				return err
+			}
+			if err != nil {
+				results[i].Car = nil
+			}
+		}
+		if atomic && batchFailed(results) {
+			return errBatchFailed
+		}
+		return nil
+	})
+
+	if errors.Is(err, errBatchFailed) {
+		abortBatch(results)
+		return results, nil
+	}
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	index(results)
+	return results, nil
+}
+
+func (r *postgresRepository) SearchCars(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	return r.search.search(ctx, search)
+}
+
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return postgresError(r.pool.Ping(ctx))
+}
+
+// postgresMissError explains why a versioned write matched nothing:
+// either the car is gone or it has moved on to another version.
+func postgresMissError(ctx context.Context, q postgresQuerier, carId entities.CarID) error {
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM cars WHERE id = $1)", carId).Scan(&exists)
+
+	if err != nil {
+		return postgresError(err)
+	}
+
+	if !exists {
+		return ErrCarNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// scanCar reads a row of postgresColumns. A missing row is reported as
+// ErrCarNotFound.
+func scanCar(row pgx.Row) (*entities.Car, error) {
+	var car entities.Car
+	var vin, companyId *string
+	var madeAt, reservedAt, soldAt *time.Time
+
+	err := row.Scan(&car.ID, &vin, &car.CarName, &companyId, &car.Company, &car.ModelYear, &car.Trim, &car.Color,
+		&car.Mileage, &car.ListPrice, &car.Currency, &car.Condition, &car.Status, &madeAt, &reservedAt, &soldAt, &car.Version)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCarNotFound
+	}
+	if err != nil {
+		return nil, postgresError(err)
+	}
+
+	if vin != nil {
+		car.VIN = *vin
+	}
+	if companyId != nil {
+		car.CompanyID = entities.CompanyID(*companyId)
+	}
+	if madeAt != nil {
+		car.MadeAt = *madeAt
+	}
+	if reservedAt != nil {
+		car.ReservedAt = *reservedAt
+	}
+	if soldAt != nil {
+		car.SoldAt = *soldAt
+	}
+	return &car, nil
+}
+
+// nullString stores the empty string as NULL, for optional columns that
+// are unique or reference another table.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// nullTime stores the zero time as NULL, as Mongo omits it.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// postgresError classifies pgx errors into the package's error values, like
+// mongoError does for the driver. A nil error stays nil.
+func postgresError(err error) error {
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return err
+	case errors.As(err, &pgErr):
+		switch {
+		// Unique and foreign key violations.
+		case pgErr.Code == "23505", pgErr.Code == "23503":
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		// Connection exceptions, too many connections and shutdowns.
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "53300", strings.HasPrefix(pgErr.Code, "57P"):
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	case errors.As(err, &netErr), pgconn.SafeToRetry(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package cars
+
+import (
+	"testing"
+	"testingfiber/pkg/entities"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresFilter(t *testing.T) {
+	made := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where, args := postgresFilter(&entities.CarQuery{})
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	where, args = postgresFilter(&entities.CarQuery{
+		Company:       "Mazda",
+		CarNamePrefix: "CX_5%",
+		MadeFrom:      made,
+	})
+	assert.Equal(t, " WHERE company = $1 AND car_name LIKE $2 AND made_at >= $3", where)
+	assert.Equal(t, []any{"Mazda", `CX\_5\%%`, made}, args)
+}
+
+func TestPostgresOrder(t *testing.T) {
+	assert.Equal(t, ` ORDER BY id COLLATE "C" ASC NULLS FIRST`, postgresOrder(&entities.CarQuery{SortBy: "id"}))
+	assert.Equal(t, ` ORDER BY list_price DESC NULLS LAST, id COLLATE "C" DESC NULLS LAST`,
+		postgresOrder(&entities.CarQuery{SortBy: "listPrice", Descending: true}))
+
+	for _, field := range SortFields() {
+		assert.True(t, IsSortField(field), field)
+		assert.Equal(t, field, lookupSortField(field).name)
+	}
+}
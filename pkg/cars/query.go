@@ -0,0 +1,156 @@
+package cars
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testingfiber/pkg/entities"
+	"time"
+)
+
+const (
+	DefaultListLimit int64 = 20
+	MaxListLimit     int64 = 100
+)
+
+// sortField is a field cars can be listed in the order of: its JSON name,
+// the key it is stored under in Mongo, the column expression Postgres
+// sorts it by, and how the backends that sort in process compare it.
+// Empty values come first when ascending, as missing fields do in Mongo
+// and NULLs do in Postgres. Text sorts bytewise everywhere.
+type sortField struct {
+	name     string
+	mongo    string
+	postgres string
+	compare  func(a, b *entities.Car) int
+}
+
+// sortFields lists every stored field of a car; listings and the CLI take
+// the sortable fields from it.
+var sortFields = []sortField{
+	{"id", "_id", `id COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(string(a.ID), string(b.ID)) }},
+	{"vin", "vin", `vin COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(a.VIN, b.VIN) }},
+	{"carName", "carName", `car_name COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(a.CarName, b.CarName) }},
+	{"companyId", "companyId", `company_id COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(string(a.CompanyID), string(b.CompanyID)) }},
+	{"company", "company", `company COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(a.Company, b.Company) }},
+	{"modelYear", "modelYear", "model_year", func(a, b *entities.Car) int { return compareInts(int64(a.ModelYear), int64(b.ModelYear)) }},
+	{"trim", "trim", `trim COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(a.Trim, b.Trim) }},
+	{"color", "color", `color COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(a.Color, b.Color) }},
+	{"mileage", "mileage", "mileage", func(a, b *entities.Car) int { return compareInts(a.Mileage, b.Mileage) }},
+	{"listPrice", "listPrice", "list_price", func(a, b *entities.Car) int { return compareInts(a.ListPrice, b.ListPrice) }},
+	{"currency", "currency", `currency COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(a.Currency, b.Currency) }},
+	{"condition", "condition", `condition COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(string(a.Condition), string(b.Condition)) }},
+	{"status", "status", `status COLLATE "C"`, func(a, b *entities.Car) int { return strings.Compare(string(a.Status), string(b.Status)) }},
+	{"madeAt", "madeAt", "made_at", func(a, b *entities.Car) int { return compareTimes(a.MadeAt, b.MadeAt) }},
+	{"reservedAt", "reservedAt", "reserved_at", func(a, b *entities.Car) int { return compareTimes(a.ReservedAt, b.ReservedAt) }},
+	{"soldAt", "soldAt", "sold_at", func(a, b *entities.Car) int { return compareTimes(a.SoldAt, b.SoldAt) }},
+	{"version", "version", "version", func(a, b *entities.Car) int { return compareInts(a.Version, b.Version) }},
+}
+
+// lookupSortField returns the sort field named field, or the ID when there
+// is none.
+func lookupSortField(field string) *sortField {
+	for i := range sortFields {
+		if sortFields[i].name == field {
+			return &sortFields[i]
+		}
+	}
+	return &sortFields[0]
+}
+
+// SortFields returns the JSON names of the fields cars can be ordered by.
+func SortFields() []string {
+	names := make([]string, len(sortFields))
+	for i, field := range sortFields {
+		names[i] = field.name
+	}
+	return names
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+const cursorPrefix = "o:"
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// IsSortField reports whether cars can be ordered by the given JSON field.
+func IsSortField(field string) bool {
+	for _, sortable := range sortFields {
+		if sortable.name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeCursor turns a listing offset into an opaque page cursor.
+func EncodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(offset, 10)))
+}
+
+// NextCursor is the cursor of the page after one that starts at offset
+// and holds listed of total items, or "" on the last page.
+func NextCursor(offset int64, listed int, total int64) string {
+	if next := offset + int64(listed); listed > 0 && next < total {
+		return EncodeCursor(next)
+	}
+	return ""
+}
+
+// DecodeCursor reverses EncodeCursor.
+func DecodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, errInvalidCursor
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+
+	return offset, nil
+}
+
+// normalizeQuery fills in defaults and clamps the page size so that no
+// listing is unbounded.
+func normalizeQuery(query *entities.CarQuery) {
+	ClampPage(&query.Limit, &query.Offset)
+	if !IsSortField(query.SortBy) {
+		query.SortBy = "id"
+	}
+}
+
+// ClampPage applies the listing limits to any paged request: a missing
+// limit becomes DefaultListLimit, no limit exceeds MaxListLimit and a
+// negative offset starts from the first item.
+func ClampPage(limit, offset *int64) {
+	if *limit <= 0 {
+		*limit = DefaultListLimit
+	}
+	if *limit > MaxListLimit {
+		*limit = MaxListLimit
+	}
+	if *offset < 0 {
+		*offset = 0
+	}
+}
@@ -2,85 +2,787 @@ package cars
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
 	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// Repository stores cars. Every write bumps the car's version; UpdateCar
+// and DeleteCar only apply when the stored version equals the version
+// given, or unconditionally when that version is 0. Inserts keep the
+// madeAt of a car and stamp the current time on cars without one. Ping
+// reports whether the backing store can serve requests.
+//
+// ScanCars calls visit with every car matching the filters of query, in
+// the query's order; Limit and Offset are ignored. It stops at the first
+// error returned by visit and returns it. Mongo and PostgreSQL stream
+// every order; the bbolt and memory backends stream the default ID order
+// and hold every match of any other order before the first visit.
+//
+// SearchCars finds the cars whose name or company contain words matching
+// the search terms exactly, by prefix or within a typo or two, best match
+// first and then by ID; a word matching exactly ranks above one matching
+// by prefix or with typos in the same field. Hits come without
+// highlights.
+//
+// The batch methods return one result per item, in order, and an error
+// only when the batch as a whole could not run. Items of a batch name
+// distinct cars. An atomic batch is applied entirely or not at all: if any
+// item fails, the others report ErrBatchAborted.
 type Repository interface {
-	InsertCar(car *entities.Car) (*entities.Car, error)
-	CheckCar() (*[]entities.Car, error)
-	UpdateCar(book *entities.Car) (*entities.Car, error)
-	DeleteCar(ID string) error
+	InsertCar(ctx context.Context, car *entities.Car) (*entities.Car, error)
+	CheckCar(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error)
+	GetCarByID(ctx context.Context, ID entities.CarID) (*entities.Car, error)
+	UpdateCar(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error)
+	DeleteCar(ctx context.Context, ID entities.CarID, version int64) error
+	InsertCars(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error)
+	UpdateCars(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error)
+	DeleteCars(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error)
+	ScanCars(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error
+	SearchCars(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error)
+	Ping(ctx context.Context) error
+}
+
+// RepositoryOption customises the repositories built by NewRepo,
+// NewMemoryRepo, NewPostgresRepo and NewBoltRepo.
+type RepositoryOption = store.Option
+
+// WithIDGenerator sets how new cars are identified. By default they get
+// ObjectID-format IDs.
+func WithIDGenerator(ids entities.IDGenerator) RepositoryOption {
+	return store.WithIDGenerator(ids)
+}
+
+// madeAtOrNow returns madeAt, or now for a new car that has none.
+func madeAtOrNow(madeAt, now time.Time) time.Time {
+	if madeAt.IsZero() {
+		return now
+	}
+	return madeAt
 }
 
 type repository struct {
 	Collection *mongo.Collection
+	words      *mongo.Collection
+	ids        entities.IDGenerator
 }
 
-func NewRepo(collection *mongo.Collection) Repository {
+// carDocument is the stored form of a car. IDs in ObjectID format are
+// stored as native ObjectIDs, so documents written before car IDs became
+// pluggable keep their keys; other formats are stored as strings. A car
+// without a VIN has no vin field, which keeps it out of the unique index.
+type carDocument struct {
+	ID         interface{}              `bson:"_id"`
+	VIN        string                   `bson:"vin,omitempty"`
+	CarName    string                   `bson:"carName"`
+	CompanyID  entities.CompanyID       `bson:"companyId,omitempty"`
+	Company    string                   `bson:"company"`
+	ModelYear  int                      `bson:"modelYear,omitempty"`
+	Trim       string                   `bson:"trim,omitempty"`
+	Color      string                   `bson:"color,omitempty"`
+	Mileage    int64                    `bson:"mileage"`
+	ListPrice  int64                    `bson:"listPrice"`
+	Currency   string                   `bson:"currency,omitempty"`
+	Condition  entities.Condition       `bson:"condition,omitempty"`
+	Status     entities.InventoryStatus `bson:"status"`
+	MadeAt     time.Time                `bson:"madeAt,omitempty"`
+	ReservedAt time.Time                `bson:"reservedAt,omitempty"`
+	SoldAt     time.Time                `bson:"soldAt,omitempty"`
+	Version    int64                    `bson:"version"`
+}
+
+func newCarDocument(car *entities.Car) *carDocument {
+	return &carDocument{
+		ID:         mongoID(car.ID),
+		VIN:        car.VIN,
+		CarName:    car.CarName,
+		CompanyID:  car.CompanyID,
+		Company:    car.Company,
+		ModelYear:  car.ModelYear,
+		Trim:       car.Trim,
+		Color:      car.Color,
+		Mileage:    car.Mileage,
+		ListPrice:  car.ListPrice,
+		Currency:   car.Currency,
+		Condition:  car.Condition,
+		Status:     car.Status,
+		MadeAt:     car.MadeAt,
+		ReservedAt: car.ReservedAt,
+		SoldAt:     car.SoldAt,
+		Version:    car.Version,
+	}
+}
+
+func (d *carDocument) car() *entities.Car {
+	return &entities.Car{
+		ID:         carIDFromMongo(d.ID),
+		VIN:        d.VIN,
+		CarName:    d.CarName,
+		CompanyID:  d.CompanyID,
+		Company:    d.Company,
+		ModelYear:  d.ModelYear,
+		Trim:       d.Trim,
+		Color:      d.Color,
+		Mileage:    d.Mileage,
+		ListPrice:  d.ListPrice,
+		Currency:   d.Currency,
+		Condition:  d.Condition,
+		Status:     d.Status,
+		MadeAt:     d.MadeAt,
+		ReservedAt: d.ReservedAt,
+		SoldAt:     d.SoldAt,
+		Version:    d.Version,
+	}
+}
+
+// mongoID is the _id value stored for a car ID.
+func mongoID(carId entities.CarID) interface{} {
+	if raw, ok := carId.ObjectID(); ok {
+		return primitive.ObjectID(raw)
+	}
+	return string(carId)
+}
+
+func carIDFromMongo(value interface{}) entities.CarID {
+	switch id := value.(type) {
+	case primitive.ObjectID:
+		return entities.CarID(id.Hex())
+	case string:
+		return entities.CarID(id)
+	}
+	return entities.CarID(fmt.Sprint(value))
+}
+
+func NewRepo(collection *mongo.Collection, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
 	return &repository{
 		Collection: collection,
+		words:      wordsCollection(collection),
+		ids:        o.IDs,
 	}
 }
 
-func (r *repository) InsertCar(car *entities.Car) (*entities.Car, error) {
-	car.ID = primitive.NewObjectID()
-	car.MadeAt = time.Now()
-	car.SoldAt = time.Now()
-	_, err := r.Collection.InsertOne(context.Background(), car)
+func (r *repository) InsertCar(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	car.ID = entities.CarID(r.ids.NewID())
+	car.MadeAt = madeAtOrNow(car.MadeAt, time.Now())
+	car.Version = 1
+	_, err := r.Collection.InsertOne(ctx, newCarDocument(car))
 
 	if err != nil {
-		return nil, err
+		return nil, mongoError(err)
 	}
 
+	r.recountWords(ctx, nil, []*entities.Car{car})
 	return car, nil
 }
 
-func (r *repository) CheckCar() (*[]entities.Car, error) {
+func (r *repository) CheckCar(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	filter := carFilter(query)
+
+	total, err := r.Collection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	opts := options.Find().
+		SetSort(carSort(query)).
+		SetSkip(query.Offset).
+		SetLimit(query.Limit)
+
 	var cars []entities.Car
-	cursor, err := r.Collection.Find(context.Background(), bson.D{})
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc carDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+		cars = append(cars, *doc.car())
+	}
+
+	// Next returns false both at the end of the results and when ctx is
+	// cancelled mid-iteration, so the cursor error has to be checked.
+	if err := cursor.Err(); err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &entities.CarPage{Cars: cars, Total: total}, nil
+}
+
+// ScanCars reads the matching cars straight off the cursor, so memory use
+// does not grow with the number of cars.
+func (r *repository) ScanCars(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	cursor, err := r.Collection.Find(ctx, carFilter(query), options.Find().SetSort(carSort(query)))
+
+	if err != nil {
+		return mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc carDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		if err := visit(doc.car()); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return mongoError(err)
+	}
+	return nil
+}
+
+// carSort translates the order of a listing query into a Mongo sort
+// document.
+func carSort(query *entities.CarQuery) bson.D {
+	direction := 1
+	if query.Descending {
+		direction = -1
+	}
+	key := lookupSortField(query.SortBy).mongo
+	sort := bson.D{{Key: key, Value: direction}}
+	if key != "_id" {
+		// Break ties on _id so that pages do not overlap.
+		sort = append(sort, bson.E{Key: "_id", Value: direction})
+	}
+	return sort
+}
+
+// carFilter translates the filters of a listing query into a Mongo filter
+// document.
+func carFilter(query *entities.CarQuery) bson.M {
+	filter := bson.M{}
+
+	if query.CompanyID != "" {
+		filter["companyId"] = query.CompanyID
+	}
+	if query.Company != "" {
+		filter["company"] = query.Company
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	if query.CarNamePrefix != "" {
+		filter["carName"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(query.CarNamePrefix)}
+	}
+	if r := timeRange(query.MadeFrom, query.MadeTo); r != nil {
+		filter["madeAt"] = r
+	}
+	if r := timeRange(query.SoldFrom, query.SoldTo); r != nil {
+		filter["soldAt"] = r
+	}
+
+	return filter
+}
+
+func timeRange(from, to time.Time) bson.M {
+	if from.IsZero() && to.IsZero() {
+		return nil
+	}
+
+	r := bson.M{}
+	if !from.IsZero() {
+		r["$gte"] = from
+	}
+	if !to.IsZero() {
+		r["$lte"] = to
+	}
+	return r
+}
+
+func (r *repository) GetCarByID(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	carId, err := parseID(ID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	for cursor.Next(context.TODO()) {
-		var car entities.Car
-		_ = cursor.Decode(&car)
+	var doc carDocument
+	err = r.Collection.FindOne(ctx, bson.M{"_id": mongoID(carId)}).Decode(&doc)
 
-		cars = append(cars, car)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCarNotFound
+	}
+	if err != nil {
+		return nil, mongoError(err)
 	}
 
-	return &cars, nil
+	return doc.car(), nil
 }
 
-func (r *repository) UpdateCar(car *entities.Car) (*entities.Car, error) {
-	car.SoldAt = time.Now()
-	_, err := r.Collection.UpdateOne(context.Background(), bson.M{"_id": car.ID}, bson.M{"$set": car})
+func (r *repository) UpdateCar(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	carId, err := parseID(ID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return car, nil
+	// An empty $set is rejected by the server, and there is nothing to
+	// write anyway.
+	if patch.IsEmpty() {
+		car, err := r.GetCarByID(ctx, ID)
+		if err == nil && version != 0 && car.Version != version {
+			return nil, ErrVersionMismatch
+		}
+		return car, err
+	}
+
+	// The car as it was tells which words it no longer has.
+	var doc carDocument
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err = r.Collection.FindOneAndUpdate(ctx, versionFilter(carId, version), patchUpdate(patch), opts).Decode(&doc)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, r.missError(ctx, carId)
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	before := doc.car()
+	car := *before
+	patch.Apply(&car)
+	car.Version++
+	storedTimes(&car)
+	r.recountWords(ctx, []*entities.Car{before}, []*entities.Car{&car})
+	return &car, nil
+}
+
+// storedTimes rounds the times of car the way they are stored, to the
+// millisecond in UTC, so that a car worked out from a write reads like
+// the stored one.
+func storedTimes(car *entities.Car) {
+	for _, t := range []*time.Time{&car.MadeAt, &car.ReservedAt, &car.SoldAt} {
+		if !t.IsZero() {
+			*t = t.Truncate(time.Millisecond).UTC()
+		}
+	}
 }
 
-func (r *repository) DeleteCar(ID string) error {
-	carId, err := primitive.ObjectIDFromHex(ID)
+func (r *repository) DeleteCar(ctx context.Context, ID entities.CarID, version int64) error {
+	carId, err := parseID(ID)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = r.Collection.DeleteOne(context.Background(), bson.M{"_id": carId})
+	var doc carDocument
+	err = r.Collection.FindOneAndDelete(ctx, versionFilter(carId, version)).Decode(&doc)
 
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return r.missError(ctx, carId)
+	}
 	if err != nil {
-		return err
+		return mongoError(err)
 	}
 
+	r.recountWords(ctx, []*entities.Car{doc.car()}, nil)
 	return nil
 }
+
+func (r *repository) InsertCars(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error) {
+	now := time.Now()
+	docs := make([]interface{}, len(cars))
+	for i, car := range cars {
+		car.ID = entities.CarID(r.ids.NewID())
+		car.MadeAt = madeAtOrNow(car.MadeAt, now)
+		car.Version = 1
+		docs[i] = newCarDocument(car)
+	}
+
+	results, err := r.batch(ctx, len(cars), atomic, func(ctx context.Context, results []BatchResult) error {
+		for i, car := range cars {
+			results[i].Car = car
+		}
+		_, err := r.Collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		return writeErrors(err, results, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var inserted []*entities.Car
+	for _, result := range results {
+		if result.Err == nil {
+			inserted = append(inserted, result.Car)
+		}
+	}
+	r.recountWords(ctx, nil, inserted)
+	return results, nil
+}
+
+// UpdateCars reads the cars first to check each item, then writes every
+// change in one BulkWrite. The writes are conditional on the versions
+// read, so a car changed by someone else in between is reported as a
+// version mismatch rather than overwritten.
+func (r *repository) UpdateCars(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error) {
+	ids := make([]entities.CarID, len(updates))
+	var stored map[entities.CarID]*entities.Car
+	results, err := r.batch(ctx, len(updates), atomic, func(ctx context.Context, results []BatchResult) error {
+		for i, update := range updates {
+			ids[i], results[i].Err = parseID(update.ID)
+		}
+
+		var err error
+		stored, err = r.findCars(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		var models []mongo.WriteModel
+		var written []int
+		for i := range updates {
+			update := &updates[i]
+			car, ok := stored[ids[i]]
+			switch {
+			case results[i].Err != nil:
+			case !ok:
+				results[i].Err = ErrCarNotFound
+			case update.Version != 0 && car.Version != update.Version:
+				results[i].Err = ErrVersionMismatch
+			case update.IsEmpty():
+				results[i].Car = car
+			default:
+				models = append(models, mongo.NewUpdateOneModel().
+					SetFilter(versionFilter(ids[i], car.Version)).
+					SetUpdate(patchUpdate(&update.CarPatch)))
+				written = append(written, i)
+			}
+		}
+
+		if len(models) == 0 {
+			return nil
+		}
+
+		_, err = r.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err := writeErrors(err, results, written); err != nil {
+			return err
+		}
+
+		updated, err := r.findCars(ctx, pickIDs(ids, written))
+		if err != nil {
+			return err
+		}
+
+		for _, i := range written {
+			car, ok := updated[ids[i]]
+			switch {
+			case results[i].Err != nil:
+			case !ok:
+				results[i].Err = ErrCarNotFound
+			case car.Version != stored[ids[i]].Version+1:
+				results[i].Err = ErrVersionMismatch
+			default:
+				results[i].Car = car
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var before, after []*entities.Car
+	for i, result := range results {
+		if result.Err == nil {
+			before = append(before, stored[ids[i]])
+			after = append(after, result.Car)
+		}
+	}
+	r.recountWords(ctx, before, after)
+	return results, nil
+}
+
+// DeleteCars checks and writes like UpdateCars.
+func (r *repository) DeleteCars(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error) {
+	ids := make([]entities.CarID, len(deletes))
+	var stored map[entities.CarID]*entities.Car
+	results, err := r.batch(ctx, len(deletes), atomic, func(ctx context.Context, results []BatchResult) error {
+		for i, del := range deletes {
+			ids[i], results[i].Err = parseID(del.ID)
+		}
+
+		var err error
+		stored, err = r.findCars(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		var models []mongo.WriteModel
+		var written []int
+		for i, del := range deletes {
+			car, ok := stored[ids[i]]
+			switch {
+			case results[i].Err != nil:
+			case !ok:
+				results[i].Err = ErrCarNotFound
+			case del.Version != 0 && car.Version != del.Version:
+				results[i].Err = ErrVersionMismatch
+			default:
+				models = append(models, mongo.NewDeleteOneModel().SetFilter(versionFilter(ids[i], car.Version)))
+				written = append(written, i)
+			}
+		}
+
+		if len(models) == 0 {
+			return nil
+		}
+
+		result, err := r.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err := writeErrors(err, results, written); err != nil {
+			return err
+		}
+		if result != nil && result.DeletedCount == int64(len(models)) {
+			return nil
+		}
+
+		// Cars that are still there changed before they could be deleted.
+		remaining, err := r.findCars(ctx, pickIDs(ids, written))
+		if err != nil {
+			return err
+		}
+		for _, i := range written {
+			if _, ok := remaining[ids[i]]; ok && results[i].Err == nil {
+				results[i].Err = ErrVersionMismatch
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []*entities.Car
+	for i, result := range results {
+		if result.Err == nil {
+			deleted = append(deleted, stored[ids[i]])
+		}
+	}
+	r.recountWords(ctx, deleted, nil)
+	return results, nil
+}
+
+// batch runs write, inside a transaction when the batch is atomic. write
+// records the failures of single items in results and returns an error
+// only when the batch as a whole failed. Transactions need a replica set
+// or a sharded cluster.
+func (r *repository) batch(ctx context.Context, n int, atomic bool, write func(ctx context.Context, results []BatchResult) error) ([]BatchResult, error) {
+	results := make([]BatchResult, n)
+	if !atomic {
+		if err := write(ctx, results); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
+	session, err := r.Collection.Database().Client().StartSession()
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// The callback is retried on transient errors, so it starts afresh.
+		for i := range results {
+			results[i] = BatchResult{}
+		}
+		if err := write(sc, results); err != nil {
+			return nil, err
+		}
+		if batchFailed(results) {
+			return nil, errBatchFailed
+		}
+		return nil, nil
+	})
+
+	if errors.Is(err, errBatchFailed) {
+		abortBatch(results)
+		return results, nil
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return results, nil
+}
+
+// findCars loads the cars with the given IDs, skipping empty ones.
+func (r *repository) findCars(ctx context.Context, ids []entities.CarID) (map[entities.CarID]*entities.Car, error) {
+	var keys bson.A
+	for _, carId := range ids {
+		if !carId.IsZero() {
+			keys = append(keys, mongoID(carId))
+		}
+	}
+
+	found := map[entities.CarID]*entities.Car{}
+	if len(keys) == 0 {
+		return found, nil
+	}
+
+	cursor, err := r.Collection.Find(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var docs []carDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+	for i := range docs {
+		car := docs[i].car()
+		found[car.ID] = car
+	}
+	return found, nil
+}
+
+func pickIDs(ids []entities.CarID, indexes []int) []entities.CarID {
+	picked := make([]entities.CarID, len(indexes))
+	for j, i := range indexes {
+		picked[j] = ids[i]
+	}
+	return picked
+}
+
+// writeErrors moves the per-write errors of a BulkWrite or InsertMany onto
+// the items they belong to. The writes were made for the items listed in
+// items, or for every item when items is nil. Any other error fails the
+// whole batch.
+func writeErrors(err error, results []BatchResult, items []int) error {
+	if err == nil {
+		return nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return mongoError(err)
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		i := writeErr.Index
+		if items != nil {
+			i = items[i]
+		}
+		results[i].Car = nil
+		results[i].Err = writeErr.WriteError
+		if writeErr.Code == 11000 {
+			results[i].Err = fmt.Errorf("%w: %w", ErrConflict, writeErr.WriteError)
+		}
+	}
+	return nil
+}
+
+// patchUpdate is the update document applying a non-empty patch and
+// bumping the version. Optional fields set to their empty value are
+// removed rather than stored, as newCarDocument leaves them out: the
+// schema constrains them when present, and the unique index would take
+// an empty VIN for a VIN.
+func patchUpdate(patch *entities.CarPatch) bson.M {
+	set := *patch
+	unset := bson.M{}
+	if set.VIN != nil && *set.VIN == "" {
+		set.VIN = nil
+		unset["vin"] = ""
+	}
+	if set.CompanyID != nil && *set.CompanyID == "" {
+		set.CompanyID = nil
+		unset["companyId"] = ""
+	}
+	if set.ModelYear != nil && *set.ModelYear == 0 {
+		set.ModelYear = nil
+		unset["modelYear"] = ""
+	}
+	if set.Trim != nil && *set.Trim == "" {
+		set.Trim = nil
+		unset["trim"] = ""
+	}
+	if set.Color != nil && *set.Color == "" {
+		set.Color = nil
+		unset["color"] = ""
+	}
+	if set.Currency != nil && *set.Currency == "" {
+		set.Currency = nil
+		unset["currency"] = ""
+	}
+	if set.Condition != nil && *set.Condition == "" {
+		set.Condition = nil
+		unset["condition"] = ""
+	}
+	if set.ReservedAt != nil && set.ReservedAt.IsZero() {
+		set.ReservedAt = nil
+		unset["reservedAt"] = ""
+	}
+	if set.SoldAt != nil && set.SoldAt.IsZero() {
+		set.SoldAt = nil
+		unset["soldAt"] = ""
+	}
+
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if !set.IsEmpty() {
+		update["$set"] = &set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// versionFilter matches the car with the given ID, and with the given
+// version unless it is 0.
+func versionFilter(carId entities.CarID, version int64) bson.M {
+	filter := bson.M{"_id": mongoID(carId)}
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missError explains why a versioned write matched nothing: either the car
+// is gone or it has moved on to another version.
+func (r *repository) missError(ctx context.Context, carId entities.CarID) error {
+	count, err := r.Collection.CountDocuments(ctx, bson.M{"_id": mongoID(carId)})
+
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if count == 0 {
+		return ErrCarNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// parseID validates an ID handed to a repository and puts it in canonical
+// form.
+func parseID(ID entities.CarID) (entities.CarID, error) {
+	return store.ParseID(ID, entities.ParseCarID, ErrInvalidID)
+}
+
+// Ping checks that the primary is reachable, since every write goes to it.
+func (r *repository) Ping(ctx context.Context) error {
+	if err := r.Collection.Database().Client().Ping(ctx, readpref.Primary()); err != nil {
+		return mongoError(err)
+	}
+	return nil
+}
+
+// mongoError classifies driver errors into the package's error values.
+var mongoError = store.MongoErrors{Conflict: ErrConflict, Unavailable: ErrUnavailable}.Classify
@@ -0,0 +1,428 @@
+package cars
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"testingfiber/pkg/entities"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchFields are the searchable fields of a car, with the weight a match
+// in each carries. A hit on the name counts for more than one on the
+// company, since a company matches many cars.
+var searchFields = []struct {
+	name   string
+	weight float64
+	value  func(*entities.Car) string
+}{
+	{"carName", 2, func(car *entities.Car) string { return car.CarName }},
+	{"company", 1, func(car *entities.Car) string { return car.Company }},
+}
+
+// Relevance of a word against a search term, by how closely it matches.
+const (
+	exactMatch  = 1
+	prefixMatch = 0.75
+	typoMatch   = 0.5
+)
+
+// rarity is the factor by which a word found in count of total cars
+// counts: 1 for a word only one car has, falling towards 3/4 for a word
+// every car has. It stays within the gap between prefixMatch and
+// exactMatch, so that rarity orders the cars matching a term equally well
+// but never puts a fuzzy match above an exact one in the same field.
+func rarity(count, total int) float64 {
+	if total < count {
+		total = count
+	}
+	return 1 - 0.25*float64(count-1)/float64(total)
+}
+
+// searchTerms splits a search into lower-case words, dropping repeats.
+func searchTerms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, word := range words(text) {
+		term := strings.ToLower(text[word[0]:word[1]])
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// words returns the start and end offsets of the runs of letters and
+// digits in text.
+func words(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// matchTerm reports how well a lower-case word matches a search term:
+// exactly, as a prefix of at least two letters, or within a few typos.
+// Longer terms tolerate more typos; terms under four letters need to be
+// spelt right. It returns 0 when the word does not match.
+func matchTerm(term, word string) float64 {
+	if word == term {
+		return exactMatch
+	}
+	if utf8.RuneCountInString(term) >= 2 && strings.HasPrefix(word, term) {
+		return prefixMatch
+	}
+
+	allowed := typoBudget(term)
+	if allowed == 0 {
+		return 0
+	}
+	if d := editDistance(term, word, allowed); d <= allowed {
+		return typoMatch / float64(d)
+	}
+	return 0
+}
+
+// typoBudget is the number of typos a search term tolerates.
+func typoBudget(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps
+// of adjacent letters that turn a into b. It gives up and returns max+1
+// once the distance is known to exceed max.
+func editDistance(a, b string, max int) int {
+	s, t := []rune(a), []rune(b)
+	if diff := len(s) - len(t); diff > max || -diff > max {
+		return max + 1
+	}
+
+	// Three rows of the optimal string alignment matrix.
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		best := curr[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				curr[j] = minInt(curr[j], prev2[j-2]+1)
+			}
+			best = minInt(best, curr[j])
+		}
+		if best > max {
+			return max + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(t)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, v := range rest {
+		if v < first {
+			first = v
+		}
+	}
+	return first
+}
+
+// highlight escapes text for HTML and wraps the words matching any of the
+// search terms in <em> tags. It reports whether any word matched.
+func highlight(text string, terms []string) (string, bool) {
+	var b strings.Builder
+	matched := false
+	last := 0
+	for _, word := range words(text) {
+		lower := strings.ToLower(text[word[0]:word[1]])
+		for _, term := range terms {
+			if matchTerm(term, lower) > 0 {
+				b.WriteString(html.EscapeString(text[last:word[0]]))
+				b.WriteString("<em>")
+				b.WriteString(html.EscapeString(text[word[0]:word[1]]))
+				b.WriteString("</em>")
+				last = word[1]
+				matched = true
+				break
+			}
+		}
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), matched
+}
+
+// highlights marks the matching words of every searchable field of car.
+func highlights(car *entities.Car, terms []string) map[string]string {
+	marked := map[string]string{}
+	for _, field := range searchFields {
+		if text, ok := highlight(field.value(car), terms); ok {
+			marked[field.name] = text
+		}
+	}
+	return marked
+}
+
+// searchIndex is an in-process inverted index over the searchable fields
+// of cars, for the backends that have no full-text search of their own.
+// It maps every word to the cars containing it and keeps a copy of each
+// car, so hits need no trip to storage.
+//
+// Repositories put cars into the index and remove them as they write.
+// When the index starts out empty rather than mirroring an empty store,
+// load fills it on the first search, and again whenever it is older than
+// maxAge, for stores that other processes write to as well.
+type searchIndex struct {
+	mu       sync.RWMutex
+	cars     map[entities.CarID]entities.Car
+	postings map[string]map[entities.CarID]float64
+	load     func(ctx context.Context, visit func(*entities.Car) error) error
+	maxAge   time.Duration
+	loaded   bool
+	loadedAt time.Time
+}
+
+// newSearchIndex returns an index filled by load on first use. A nil load
+// means the index starts out complete.
+func newSearchIndex(load func(ctx context.Context, visit func(*entities.Car) error) error, maxAge time.Duration) *searchIndex {
+	return &searchIndex{
+		cars:     map[entities.CarID]entities.Car{},
+		postings: map[string]map[entities.CarID]float64{},
+		load:     load,
+		maxAge:   maxAge,
+		loaded:   load == nil,
+	}
+}
+
+// put indexes car, replacing any earlier version of it; a car older than
+// the one indexed, from a write that lost a race, is ignored. Before the
+// index is loaded, writes are left for the load to pick up from storage.
+func (x *searchIndex) put(car *entities.Car) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if indexed, ok := x.cars[car.ID]; !x.loaded || ok && indexed.Version > car.Version {
+		return
+	}
+	x.removeLocked(car.ID)
+	x.addLocked(car)
+}
+
+func (x *searchIndex) remove(carId entities.CarID) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.loaded {
+		x.removeLocked(carId)
+	}
+}
+
+// invalidate has the index loaded again before the next search, after a
+// write whose outcome is unknown.
+func (x *searchIndex) invalidate() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.load != nil {
+		x.loaded = false
+	}
+}
+
+// replace makes the index hold exactly list.
+func (x *searchIndex) replace(list []entities.Car) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.resetLocked()
+	for i := range list {
+		x.addLocked(&list[i])
+	}
+	x.loaded, x.loadedAt = true, time.Now()
+}
+
+// putResults indexes the cars written by a batch.
+func (x *searchIndex) putResults(results []BatchResult) {
+	for _, result := range results {
+		if result.Err == nil && result.Car != nil {
+			x.put(result.Car)
+		}
+	}
+}
+
+// removeResults drops the cars deleted by a batch.
+func (x *searchIndex) removeResults(deletes []entities.CarDelete, results []BatchResult) {
+	for i, result := range results {
+		if carId, err := entities.ParseCarID(string(deletes[i].ID)); err == nil && result.Err == nil {
+			x.remove(carId)
+		}
+	}
+}
+
+func (x *searchIndex) resetLocked() {
+	x.cars = map[entities.CarID]entities.Car{}
+	x.postings = map[string]map[entities.CarID]float64{}
+}
+
+// addLocked records, for every word of car, the weight of the best field
+// it appears in.
+func (x *searchIndex) addLocked(car *entities.Car) {
+	x.cars[car.ID] = *car
+	for _, field := range searchFields {
+		for _, term := range searchTerms(field.value(car)) {
+			cars := x.postings[term]
+			if cars == nil {
+				cars = map[entities.CarID]float64{}
+				x.postings[term] = cars
+			}
+			if field.weight > cars[car.ID] {
+				cars[car.ID] = field.weight
+			}
+		}
+	}
+}
+
+func (x *searchIndex) removeLocked(carId entities.CarID) {
+	car, ok := x.cars[carId]
+	if !ok {
+		return
+	}
+	delete(x.cars, carId)
+	for _, field := range searchFields {
+		for _, term := range searchTerms(field.value(&car)) {
+			delete(x.postings[term], carId)
+			if len(x.postings[term]) == 0 {
+				delete(x.postings, term)
+			}
+		}
+	}
+}
+
+// ensureLoaded loads the index if it has never been, or has grown stale.
+// Writes wait for the load, so none is lost between the read of storage
+// and the swap.
+func (x *searchIndex) ensureLoaded(ctx context.Context) error {
+	x.mu.RLock()
+	fresh := x.freshLocked()
+	x.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.freshLocked() {
+		return nil
+	}
+
+	x.resetLocked()
+	x.loaded = false
+	err := x.load(ctx, func(car *entities.Car) error {
+		x.addLocked(car)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	x.loaded, x.loadedAt = true, time.Now()
+	return nil
+}
+
+func (x *searchIndex) freshLocked() bool {
+	return x.loaded && (x.load == nil || x.maxAge <= 0 || time.Since(x.loadedAt) < x.maxAge)
+}
+
+// search ranks the indexed cars against the terms of search. A car scores,
+// for each term, the best match among its words, weighted by the field
+// the word is in and by the rarity of the word; the scores of the terms
+// add up. Ties are broken by ID so that pages do not overlap.
+func (x *searchIndex) search(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := x.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	scores := map[entities.CarID]float64{}
+	for _, term := range searchTerms(search.Query) {
+		best := map[entities.CarID]float64{}
+		for word, cars := range x.postings {
+			match := matchTerm(term, word)
+			if match == 0 {
+				continue
+			}
+			factor := rarity(len(cars), len(x.cars))
+			for carId, weight := range cars {
+				if score := match * weight * factor; score > best[carId] {
+					best[carId] = score
+				}
+			}
+		}
+		for carId, score := range best {
+			scores[carId] += score
+		}
+	}
+
+	hits := make([]entities.SearchHit, 0, len(scores))
+	for carId, score := range scores {
+		hits = append(hits, entities.SearchHit{Car: x.cars[carId], Score: score})
+	}
+	return rankHits(hits, search), nil
+}
+
+// rankHits orders hits best first, breaking ties by ID so that pages do
+// not overlap, and cuts out the page search asks for.
+func rankHits(hits []entities.SearchHit, search *entities.CarSearch) *entities.SearchPage {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Car.ID < hits[j].Car.ID
+	})
+
+	page := &entities.SearchPage{Total: int64(len(hits))}
+	start := search.Offset
+	if start > page.Total {
+		start = page.Total
+	}
+	end := start + search.Limit
+	if search.Limit <= 0 || end > page.Total {
+		end = page.Total
+	}
+	page.Hits = hits[start:end]
+	return page
+}
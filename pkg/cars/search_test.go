@@ -0,0 +1,134 @@
+package cars
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"cx", "5", "mazda"}, searchTerms("CX-5  mazda, Mazda"))
+	assert.Empty(t, searchTerms(" -- "))
+	assert.Equal(t, []string{"citroën", "c4"}, searchTerms("Citroën C4"))
+}
+
+func TestMatchTerm(t *testing.T) {
+	tests := []struct {
+		term, word string
+		expected   float64
+	}{
+		{"corolla", "corolla", exactMatch},
+		{"cor", "corolla", prefixMatch},
+		{"c", "corolla", 0},
+		{"corola", "corolla", typoMatch},
+		{"toyta", "toyota", typoMatch},
+		{"tyoota", "toyota", typoMatch},
+		{"hodna", "honda", typoMatch},
+		{"lamborgini", "lamborghini", typoMatch},
+		{"lambrgini", "lamborghini", typoMatch / 2},
+		{"kia", "kla", 0},
+		{"mazda", "honda", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.term+"/"+test.word, func(t *testing.T) {
+			assert.Equal(t, test.expected, matchTerm(test.term, test.word))
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	text, ok := highlight("CX-5 <Sport>", []string{"cx", "sprot"})
+	assert.True(t, ok)
+	assert.Equal(t, "<em>CX</em>-5 &lt;<em>Sport</em>&gt;", text)
+
+	text, ok = highlight("Corolla", []string{"civic"})
+	assert.False(t, ok)
+	assert.Equal(t, "Corolla", text)
+}
+
+func TestSearchIndexRanking(t *testing.T) {
+	index := newSearchIndex(nil, 0)
+	for _, car := range []entities.Car{
+		{ID: "1", CarName: "CX-5", Company: "Mazda", Version: 1},
+		{ID: "2", CarName: "Mazda3", Company: "Mazda", Version: 1},
+		{ID: "3", CarName: "Corolla", Company: "Toyota", Version: 1},
+		{ID: "4", CarName: "MX-5", Company: "Mazda", Version: 1},
+	} {
+		index.put(&car)
+	}
+
+	page, err := index.search(context.Background(), &entities.CarSearch{Query: "mazda cx"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	// The car matching both terms comes first.
+	assert.Equal(t, entities.CarID("1"), page.Hits[0].Car.ID)
+
+	page, err = index.search(context.Background(), &entities.CarSearch{Query: "corola", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Hits, 1)
+	assert.Equal(t, "Corolla", page.Hits[0].Car.CarName)
+
+	// A stale write does not replace a newer version.
+	index.put(&entities.Car{ID: "3", CarName: "Yaris", Company: "Toyota", Version: 3})
+	index.put(&entities.Car{ID: "3", CarName: "Corolla", Company: "Toyota", Version: 2})
+	page, err = index.search(context.Background(), &entities.CarSearch{Query: "yaris"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+
+	index.remove("3")
+	page, err = index.search(context.Background(), &entities.CarSearch{Query: "toyota"})
+	require.NoError(t, err)
+	assert.Zero(t, page.Total)
+}
+
+func TestSearchIndexRanksExactMatchesFirst(t *testing.T) {
+	index := newSearchIndex(nil, 0)
+	// Every car but one is named Corolla, so the typo is the rarer word.
+	index.put(&entities.Car{ID: "1", CarName: "Corola", Company: "Toyota", Version: 1})
+	for _, carId := range []entities.CarID{"2", "3", "4", "5"} {
+		index.put(&entities.Car{ID: carId, CarName: "Corolla", Company: "Toyota", Version: 1})
+	}
+
+	page, err := index.search(context.Background(), &entities.CarSearch{Query: "corolla"})
+	require.NoError(t, err)
+	require.Len(t, page.Hits, 5)
+	assert.Equal(t, entities.CarID("1"), page.Hits[4].Car.ID)
+	assert.Less(t, page.Hits[4].Score, page.Hits[3].Score)
+}
+
+func TestSearchIndexLoad(t *testing.T) {
+	stored := []entities.Car{{ID: "1", CarName: "Civic", Company: "Honda", Version: 1}}
+	loads := 0
+	index := newSearchIndex(func(ctx context.Context, visit func(*entities.Car) error) error {
+		loads++
+		if loads == 1 {
+			return errors.New("unavailable")
+		}
+		for i := range stored {
+			if err := visit(&stored[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, 0)
+
+	// Writes before the first load are left to it.
+	index.put(&entities.Car{ID: "2", CarName: "Accord", Company: "Honda", Version: 1})
+
+	_, err := index.search(context.Background(), &entities.CarSearch{Query: "honda"})
+	assert.Error(t, err)
+
+	page, err := index.search(context.Background(), &entities.CarSearch{Query: "honda"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+
+	index.invalidate()
+	_, err = index.search(context.Background(), &entities.CarSearch{Query: "honda"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, loads)
+}
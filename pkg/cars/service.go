@@ -1,36 +1,388 @@
 package cars
 
-import "testingfiber/pkg/entities"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+)
+
+// DefaultBatchLimit is the largest batch a service accepts unless
+// configured otherwise.
+const DefaultBatchLimit = 100
 
 type Service interface {
-	InsertCarService(car *entities.Car) (*entities.Car, error)
-	CheckCarService() (*[]entities.Car, error)
-	UpdateCarService(car *entities.Car) (*entities.Car, error)
-	RemoveCarService(ID string) error
+	InsertCarService(ctx context.Context, car *entities.Car) (*entities.Car, error)
+	CheckCarService(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error)
+	ScanCarsService(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error
+	SearchCarsService(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error)
+	GetCarByIDService(ctx context.Context, ID entities.CarID) (*entities.Car, error)
+	UpdateCarService(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error)
+	RemoveCarService(ctx context.Context, ID entities.CarID, version int64) error
+	TransitionCarService(ctx context.Context, ID entities.CarID, version int64, transition Transition) (*entities.Car, error)
+	InsertCarsService(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error)
+	UpdateCarsService(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error)
+	RemoveCarsService(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error)
 }
 
 type service struct {
 	repository Repository
+	validator  *Validator
+	batchLimit int
+	companies  CompanyDirectory
+	now        func() time.Time
+}
+
+// ServiceOption customises the Service built by NewService.
+type ServiceOption func(*service)
+
+// WithValidator replaces the default validator, which accepts any company.
+func WithValidator(v *Validator) ServiceOption {
+	return func(s *service) {
+		s.validator = v
+	}
 }
 
-func NewService(r Repository) Service {
-	return &service{
+// WithBatchLimit caps the number of items in a batch.
+func WithBatchLimit(limit int) ServiceOption {
+	return func(s *service) {
+		s.batchLimit = limit
+	}
+}
+
+func NewService(r Repository, opts ...ServiceOption) Service {
+	s := &service{
 		repository: r,
+		validator:  NewValidator(),
+		batchLimit: DefaultBatchLimit,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// InsertCarService stores a new car, in stock.
+func (s *service) InsertCarService(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	resetLifecycle(car)
+	if err := s.resolveCompany(ctx, car); err != nil {
+		return nil, err
+	}
+	if err := s.validator.Validate(car); err != nil {
+		return nil, err
+	}
+
+	return s.repository.InsertCar(ctx, car)
+}
+
+func (s *service) CheckCarService(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	normalizeQuery(query)
+
+	page, err := s.repository.CheckCar(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Limit = query.Limit
+	page.Offset = query.Offset
+	page.NextCursor = NextCursor(query.Offset, len(page.Cars), page.Total)
+	if query.EmbedCompany {
+		if err := s.embedCompanies(ctx, page); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// ScanCarsService visits every car matching the filters of query, in its
+// order, without holding them all in memory where the storage allows.
+func (s *service) ScanCarsService(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	if !IsSortField(query.SortBy) {
+		query.SortBy = "id"
+	}
+	return s.repository.ScanCars(ctx, query, visit)
+}
+
+// SearchCarsService finds the cars matching a free-text search and marks
+// the words that matched in each hit. A search without any words finds
+// nothing.
+func (s *service) SearchCarsService(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	ClampPage(&search.Limit, &search.Offset)
+
+	terms := searchTerms(search.Query)
+	page := &entities.SearchPage{}
+	if len(terms) > 0 {
+		var err error
+		if page, err = s.repository.SearchCars(ctx, search); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range page.Hits {
+		page.Hits[i].Highlights = highlights(&page.Hits[i].Car, terms)
+	}
+	page.Limit = search.Limit
+	page.Offset = search.Offset
+	page.NextCursor = NextCursor(search.Offset, len(page.Hits), page.Total)
+
+	return page, nil
+}
+
+func (s *service) GetCarByIDService(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	return s.repository.GetCarByID(ctx, ID)
+}
+
+// UpdateCarService changes the supplied fields of a car. The patch is
+// validated against the car it will produce, so rules spanning several
+// fields still hold, and may not move the car along its lifecycle. The
+// write is conditional on the version that was checked, so that a car
+// changed in between, say by a sale, is checked again rather than
+// overwritten; a non-zero version must also match.
+func (s *service) UpdateCarService(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	if err := s.resolvePatchCompany(ctx, patch); err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		current, err := s.checkUpdate(ctx, ID, version, patch)
+		if err != nil {
+			return nil, err
+		}
+
+		car, err := s.repository.UpdateCar(ctx, ID, current.Version, patch)
+		if errors.Is(err, ErrVersionMismatch) && version == 0 && attempt < writeAttempts {
+			continue
+		}
+		return car, err
 	}
 }
 
-func (s *service) InsertCarService(car *entities.Car) (*entities.Car, error) {
-	return s.repository.InsertCar(car)
+// checkUpdate reads the car ID names and checks that patch may be applied
+// to it. It returns the car checked against.
+func (s *service) checkUpdate(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	current, err := s.repository.GetCarByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	candidate := *current
+	patch.Apply(&candidate)
+	if err := checkLifecycle(current, &candidate); err != nil {
+		return nil, err
+	}
+	if err := s.validator.Validate(&candidate); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// writeAttempts bounds how often a write without a version is retried
+// when the car changes between the read it was checked against and the
+// write.
+const writeAttempts = 3
+
+// TransitionCarService moves a car one step along its sale lifecycle,
+// stamping the time of a reservation or a sale. It fails with
+// ErrInvalidTransition when the car's status does not allow the step. The
+// write is conditional on the version read, so two clients cannot both
+// sell the same car; a non-zero version must also match.
+func (s *service) TransitionCarService(ctx context.Context, ID entities.CarID, version int64, transition Transition) (*entities.Car, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := s.repository.GetCarByID(ctx, ID)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && current.Version != version {
+			return nil, ErrVersionMismatch
+		}
+
+		patch, err := TransitionPatch(current, transition, s.now())
+		if err != nil {
+			return nil, err
+		}
+
+		car, err := s.repository.UpdateCar(ctx, ID, current.Version, patch)
+		if errors.Is(err, ErrVersionMismatch) && version == 0 && attempt < writeAttempts {
+			continue
+		}
+		return car, err
+	}
+}
+
+func (s *service) RemoveCarService(ctx context.Context, ID entities.CarID, version int64) error {
+	return s.repository.DeleteCar(ctx, ID, version)
 }
 
-func (s *service) CheckCarService() (*[]entities.Car, error) {
-	return s.repository.CheckCar()
+// InsertCarsService validates every car and stores the valid ones.
+func (s *service) InsertCarsService(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error) {
+	return s.runBatch(len(cars), atomic,
+		func(i int) error {
+			resetLifecycle(cars[i])
+			if err := s.resolveCompany(ctx, cars[i]); err != nil {
+				return err
+			}
+			return s.validator.Validate(cars[i])
+		},
+		func(items []int) ([]BatchResult, error) {
+			valid := make([]*entities.Car, len(items))
+			for j, i := range items {
+				valid[j] = cars[i]
+			}
+			return s.repository.InsertCars(ctx, valid, atomic)
+		})
 }
 
-func (s *service) UpdateCarService(car *entities.Car) (*entities.Car, error) {
-	return s.repository.UpdateCar(car)
+// UpdateCarsService checks every update like UpdateCarService does, one
+// car at a time, then writes the ones that passed together, each on the
+// condition that its car is still at the version checked. Updates without
+// a version whose car changed in between are checked and written again.
+func (s *service) UpdateCarsService(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error) {
+	seen := map[entities.CarID]bool{}
+	checked := make([]int64, len(updates))
+	check := func(i int) error {
+		update := &updates[i]
+		if err := s.resolvePatchCompany(ctx, &update.CarPatch); err != nil {
+			return err
+		}
+		current, err := s.checkUpdate(ctx, update.ID, update.Version, &update.CarPatch)
+		if err != nil {
+			return err
+		}
+		checked[i] = current.Version
+		return nil
+	}
+	write := func(items []int) ([]BatchResult, error) {
+		valid := make([]entities.CarUpdate, len(items))
+		for j, i := range items {
+			valid[j] = updates[i]
+			valid[j].Version = checked[i]
+		}
+		return s.repository.UpdateCars(ctx, valid, atomic)
+	}
+
+	results, err := s.runBatch(len(updates), atomic,
+		func(i int) error {
+			if err := checkDuplicate(seen, updates[i].ID); err != nil {
+				return err
+			}
+			return check(i)
+		}, write)
+
+	for attempt := 2; err == nil && attempt <= writeAttempts; attempt++ {
+		stale := staleUpdates(updates, results, atomic)
+		if len(stale) == 0 {
+			break
+		}
+
+		var retried []BatchResult
+		retried, err = s.runBatch(len(stale), atomic,
+			func(j int) error { return check(stale[j]) },
+			func(items []int) ([]BatchResult, error) {
+				mapped := make([]int, len(items))
+				for k, j := range items {
+					mapped[k] = stale[j]
+				}
+				return write(mapped)
+			})
+		if err == nil {
+			for j, i := range stale {
+				results[i] = retried[j]
+			}
+		}
+	}
+	return results, err
 }
 
-func (s *service) RemoveCarService(ID string) error {
-	return s.repository.DeleteCar(ID)
+// staleUpdates lists the items of a batch worth trying again: the updates
+// without a version that lost a race with another write. An atomic batch
+// wrote nothing, so it is tried again whole, unless an item failed for
+// another reason.
+func staleUpdates(updates []entities.CarUpdate, results []BatchResult, atomic bool) []int {
+	var stale []int
+	for i, result := range results {
+		switch {
+		case errors.Is(result.Err, ErrVersionMismatch) && updates[i].Version == 0:
+			stale = append(stale, i)
+		case atomic && result.Err != nil && !errors.Is(result.Err, ErrBatchAborted):
+			return nil
+		}
+	}
+
+	if atomic && len(stale) > 0 {
+		stale = stale[:0]
+		for i := range results {
+			stale = append(stale, i)
+		}
+	}
+	return stale
+}
+
+func (s *service) RemoveCarsService(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error) {
+	seen := map[entities.CarID]bool{}
+	return s.runBatch(len(deletes), atomic,
+		func(i int) error {
+			return checkDuplicate(seen, deletes[i].ID)
+		},
+		func(items []int) ([]BatchResult, error) {
+			valid := make([]entities.CarDelete, len(items))
+			for j, i := range items {
+				valid[j] = deletes[i]
+			}
+			return s.repository.DeleteCars(ctx, valid, atomic)
+		})
+}
+
+// runBatch checks every item and hands the indexes of those that passed to
+// write, which returns their results in the same order. Items that fail
+// the check are not written; in an atomic batch, nothing is.
+func (s *service) runBatch(n int, atomic bool, check func(i int) error, write func(items []int) ([]BatchResult, error)) ([]BatchResult, error) {
+	if n > s.batchLimit {
+		return nil, fmt.Errorf("%w: %d items, at most %d allowed", ErrBatchTooLarge, n, s.batchLimit)
+	}
+
+	results := make([]BatchResult, n)
+	var passed []int
+	for i := range results {
+		if results[i].Err = check(i); results[i].Err == nil {
+			passed = append(passed, i)
+		}
+	}
+
+	if atomic && len(passed) < n {
+		abortBatch(results)
+		return results, nil
+	}
+	if len(passed) == 0 {
+		return results, nil
+	}
+
+	written, err := write(passed)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range passed {
+		results[i] = written[j]
+	}
+	return results, nil
+}
+
+// checkDuplicate rejects a car that an earlier item of the batch names.
+// Malformed IDs are left for the repository to report.
+func checkDuplicate(seen map[entities.CarID]bool, ID entities.CarID) error {
+	carId, err := entities.ParseCarID(string(ID))
+	if err != nil {
+		return nil
+	}
+	if seen[carId] {
+		return &ValidationError{Fields: []validation.FieldError{{Field: "id", Message: "appears more than once in the batch"}}}
+	}
+	seen[carId] = true
+	return nil
 }
@@ -1,8 +1,11 @@
 package cars
 
 import (
+	"context"
 	"testing"
 	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -12,8 +15,8 @@ type mockRepository struct {
 	mock.Mock
 }
 
-func (m *mockRepository) InsertCar(car *entities.Car) (*entities.Car, error) {
-	args := m.Called(car)
+func (m *mockRepository) InsertCar(ctx context.Context, car *entities.Car) (*entities.Car, error) {
+	args := m.Called(ctx, car)
 	result := args.Get(0)
 	err := args.Error(1)
 
@@ -24,18 +27,50 @@ func (m *mockRepository) InsertCar(car *entities.Car) (*entities.Car, error) {
 	return nil, err
 }
 
-func (m *mockRepository) CheckCar() (*[]entities.Car, error) {
-	args := m.Called()
+func (m *mockRepository) CheckCar(ctx context.Context, query *entities.CarQuery) (*entities.CarPage, error) {
+	args := m.Called(ctx, query)
 	result := args.Get(0)
 	err := args.Error(1)
 	if result != nil {
-		return result.(*[]entities.Car), err
+		return result.(*entities.CarPage), err
 	}
 	return nil, err
 }
 
-func (m *mockRepository) UpdateCar(car *entities.Car) (*entities.Car, error) {
-	args := m.Called(car)
+// ScanCars visits the cars given to Return, then returns its error.
+func (m *mockRepository) ScanCars(ctx context.Context, query *entities.CarQuery, visit func(*entities.Car) error) error {
+	args := m.Called(ctx, query)
+	list, _ := args.Get(0).([]entities.Car)
+	for i := range list {
+		if err := visit(&list[i]); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *mockRepository) SearchCars(ctx context.Context, search *entities.CarSearch) (*entities.SearchPage, error) {
+	args := m.Called(ctx, search)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.SearchPage), err
+	}
+	return nil, err
+}
+
+func (m *mockRepository) GetCarByID(ctx context.Context, ID entities.CarID) (*entities.Car, error) {
+	args := m.Called(ctx, ID)
+	result := args.Get(0)
+	err := args.Error(1)
+	if result != nil {
+		return result.(*entities.Car), err
+	}
+	return nil, err
+}
+
+func (m *mockRepository) UpdateCar(ctx context.Context, ID entities.CarID, version int64, patch *entities.CarPatch) (*entities.Car, error) {
+	args := m.Called(ctx, ID, version, patch)
 	result := args.Get(0)
 	err := args.Error(1)
 	if result != nil {
@@ -44,38 +79,121 @@ func (m *mockRepository) UpdateCar(car *entities.Car) (*entities.Car, error) {
 	return nil, err
 }
 
-func (m *mockRepository) DeleteCar(ID string) error {
-	args := m.Called(ID)
+func (m *mockRepository) DeleteCar(ctx context.Context, ID entities.CarID, version int64) error {
+	args := m.Called(ctx, ID, version)
+	return args.Error(0)
+}
+
+func (m *mockRepository) InsertCars(ctx context.Context, cars []*entities.Car, atomic bool) ([]BatchResult, error) {
+	args := m.Called(ctx, cars, atomic)
+	results, _ := args.Get(0).([]BatchResult)
+	return results, args.Error(1)
+}
+
+func (m *mockRepository) UpdateCars(ctx context.Context, updates []entities.CarUpdate, atomic bool) ([]BatchResult, error) {
+	args := m.Called(ctx, updates, atomic)
+	results, _ := args.Get(0).([]BatchResult)
+	return results, args.Error(1)
+}
+
+func (m *mockRepository) DeleteCars(ctx context.Context, deletes []entities.CarDelete, atomic bool) ([]BatchResult, error) {
+	args := m.Called(ctx, deletes, atomic)
+	results, _ := args.Get(0).([]BatchResult)
+	return results, args.Error(1)
+}
+
+func (m *mockRepository) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
 	return args.Error(0)
 }
 
 func TestInsertCarService(t *testing.T) {
+	ctx := context.Background()
 	repo := new(mockRepository)
 	service := NewService(repo)
 
 	car := &entities.Car{
-		CarName: "Mazda",
+		CarName: "CX-5",
+		Company: "Mazda",
+		Status:  entities.Sold,
+		SoldAt:  time.Now(),
 	}
 
 	expectedCar := &entities.Car{
-		CarName: "Mazda",
+		CarName: "CX-5",
+		Company: "Mazda",
 	}
 
-	repo.On("InsertCar", car).Return(expectedCar, nil)
+	repo.On("InsertCar", ctx, car).Return(expectedCar, nil)
 
-	result, err := service.InsertCarService(car)
+	result, err := service.InsertCarService(ctx, car)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedCar, result)
+	assert.Equal(t, entities.InStock, car.Status, "a new car comes in stock")
+	assert.True(t, car.SoldAt.IsZero())
 
 	repo.AssertExpectations(t)
 }
 
+func TestScanCarsService(t *testing.T) {
+	mockRepo := new(mockRepository)
+	mockRepo.On("ScanCars", mock.Anything, &entities.CarQuery{Company: "Mazda", SortBy: "id"}).
+		Return([]entities.Car{{CarName: "CX-5"}, {CarName: "CX-30"}}, nil)
+
+	var visited []string
+	err := NewService(mockRepo).ScanCarsService(context.Background(),
+		&entities.CarQuery{Company: "Mazda", SortBy: "horsepower"},
+		func(car *entities.Car) error {
+			visited = append(visited, car.CarName)
+			return nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CX-5", "CX-30"}, visited)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchCarsService(t *testing.T) {
+	mockRepo := new(mockRepository)
+	mockRepo.On("SearchCars", mock.Anything, &entities.CarSearch{Query: "mazda cx", Limit: 1, Offset: 0}).
+		Return(&entities.SearchPage{
+			Hits: []entities.SearchHit{
+				{Car: entities.Car{CarName: "CX-5", Company: "Mazda"}, Score: 3},
+			},
+			Total: 2,
+		}, nil)
+
+	page, err := NewService(mockRepo).SearchCarsService(context.Background(),
+		&entities.CarSearch{Query: "mazda cx", Limit: 1, Offset: -4})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Hits, 1)
+	assert.Equal(t, map[string]string{
+		"carName": "<em>CX</em>-5",
+		"company": "<em>Mazda</em>",
+	}, page.Hits[0].Highlights)
+	assert.Equal(t, EncodeCursor(1), page.NextCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchCarsServiceWithoutWords(t *testing.T) {
+	mockRepo := new(mockRepository)
+
+	page, err := NewService(mockRepo).SearchCarsService(context.Background(), &entities.CarSearch{Query: " -- "})
+
+	assert.NoError(t, err)
+	assert.Empty(t, page.Hits)
+	assert.Equal(t, DefaultListLimit, page.Limit)
+	mockRepo.AssertNotCalled(t, "SearchCars", mock.Anything, mock.Anything)
+}
+
 func TestCheckCarService(t *testing.T) {
+	ctx := context.Background()
 	repo := new(mockRepository)
 	service := NewService(repo)
 
-	cars := &[]entities.Car{
+	cars := []entities.Car{
 		{
 			CarName: "Mazda",
 		},
@@ -84,11 +202,78 @@ func TestCheckCarService(t *testing.T) {
 		},
 	}
 
-	expectedCar := &cars
+	query := &entities.CarQuery{}
+	expectedQuery := &entities.CarQuery{Limit: DefaultListLimit, SortBy: "id"}
+
+	repo.On("CheckCar", ctx, expectedQuery).Return(&entities.CarPage{Cars: cars, Total: 2}, nil)
+
+	result, err := service.CheckCarService(ctx, query)
+
+	assert.NoError(t, err)
+	assert.Equal(t, cars, result.Cars)
+	assert.Equal(t, int64(2), result.Total)
+	assert.Equal(t, DefaultListLimit, result.Limit)
+	assert.Empty(t, result.NextCursor)
+
+	repo.AssertExpectations(t)
+}
+
+func TestCheckCarServiceNextCursor(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	cars := []entities.Car{{CarName: "Mazda"}, {CarName: "Toyota"}}
+	query := &entities.CarQuery{Limit: 2, Offset: 4, SortBy: "carName"}
+
+	repo.On("CheckCar", ctx, query).Return(&entities.CarPage{Cars: cars, Total: 10}, nil)
+
+	result, err := service.CheckCarService(ctx, query)
+
+	assert.NoError(t, err)
+	offset, err := DecodeCursor(result.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), offset)
+
+	repo.AssertExpectations(t)
+}
+
+func TestCheckCarServiceClampsLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	query := &entities.CarQuery{Limit: MaxListLimit + 1}
+
+	repo.On("CheckCar", ctx, mock.MatchedBy(func(q *entities.CarQuery) bool {
+		return q.Limit == MaxListLimit
+	})).Return(&entities.CarPage{}, nil)
+
+	_, err := service.CheckCarService(ctx, query)
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	_, err := DecodeCursor("not a cursor")
+	assert.Error(t, err)
+
+	_, err = DecodeCursor(EncodeCursor(7))
+	assert.NoError(t, err)
+}
+
+func TestGetCarByIDService(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	expectedCar := &entities.Car{CarName: "Mazda"}
 
-	repo.On("CheckCar").Return(expectedCar, nil)
+	repo.On("GetCarByID", ctx, ID).Return(expectedCar, nil)
 
-	result, err := service.CheckCarService()
+	result, err := service.GetCarByIDService(ctx, ID)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedCar, result)
@@ -97,20 +282,32 @@ func TestCheckCarService(t *testing.T) {
 }
 
 func TestUpdateCarService(t *testing.T) {
+	ctx := context.Background()
 	repo := new(mockRepository)
 	service := NewService(repo)
 
-	car := &entities.Car{
-		CarName: "Mazda",
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	name := "CX-9"
+	patch := &entities.CarPatch{CarName: &name}
+
+	current := &entities.Car{
+		CarName: "CX-5",
+		Company: "Mazda",
+		Version: 2,
 	}
 
 	expectedCar := &entities.Car{
-		CarName: "Mazda",
+		CarName: "CX-9",
+		Company: "Mazda",
+		Version: 3,
 	}
 
-	repo.On("UpdateCar", car).Return(expectedCar, nil)
+	// The write is conditional on the version checked, even without one
+	// from the client.
+	repo.On("GetCarByID", ctx, ID).Return(current, nil)
+	repo.On("UpdateCar", ctx, ID, int64(2), patch).Return(expectedCar, nil)
 
-	result, err := service.UpdateCarService(car)
+	result, err := service.UpdateCarService(ctx, ID, 0, patch)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedCar, result)
@@ -119,16 +316,315 @@ func TestUpdateCarService(t *testing.T) {
 }
 
 func TestRemoveCarService(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("123")
+
+	repo.On("DeleteCar", ctx, ID, int64(3)).Return(nil)
+
+	err := service.RemoveCarService(ctx, ID, 3)
+
+	assert.NoError(t, err)
+
+	repo.AssertExpectations(t)
+}
+
+func TestInsertCarServiceValidation(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	_, err := service.InsertCarService(ctx, &entities.Car{})
+
+	var validationErr *ValidationError
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 2)
+
+	repo.AssertNotCalled(t, "InsertCar", mock.Anything, mock.Anything)
+}
+
+func TestInsertCarServiceRejectsFutureMadeAt(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	_, err := service.InsertCarService(ctx, &entities.Car{CarName: "CX-5", Company: "Mazda", MadeAt: time.Now().Add(time.Hour)})
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []validation.FieldError{{Field: "madeAt", Message: "must not be in the future"}}, validationErr.Fields)
+	repo.AssertNotCalled(t, "InsertCar", mock.Anything, mock.Anything)
+}
+
+func TestUpdateCarServiceValidation(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	blank := ""
+	soldAt := time.Now().Add(-time.Hour)
+	madeAt := soldAt.Add(30 * time.Minute)
+
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{
+		CarName: "CX-5", Company: "Mazda", Status: entities.Sold, MadeAt: soldAt.Add(-time.Hour), SoldAt: soldAt,
+	}, nil)
+
+	_, err := service.UpdateCarService(ctx, ID, 0, &entities.CarPatch{Company: &blank, MadeAt: &madeAt})
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []validation.FieldError{
+		{Field: "company", Message: "is required"},
+		{Field: "soldAt", Message: "must not be before madeAt"},
+	}, validationErr.Fields)
+	repo.AssertNotCalled(t, "UpdateCar", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateCarServiceMissingCar(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	repo.On("GetCarByID", ctx, ID).Return(nil, ErrCarNotFound)
+
+	_, err := service.UpdateCarService(ctx, ID, 0, &entities.CarPatch{})
+
+	assert.ErrorIs(t, err, ErrCarNotFound)
+	repo.AssertNotCalled(t, "UpdateCar", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateCarServiceStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	name := "CX-9"
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Version: 4}, nil)
+
+	_, err := service.UpdateCarService(ctx, ID, 3, &entities.CarPatch{CarName: &name})
+
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	repo.AssertNotCalled(t, "UpdateCar", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateCarServiceRejectsLifecycleFields(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	sold := entities.Sold
+	soldAt := time.Now()
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.InStock}, nil)
+
+	_, err := service.UpdateCarService(ctx, ID, 0, &entities.CarPatch{Status: &sold, SoldAt: &soldAt})
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []validation.FieldError{
+		{Field: "status", Message: lifecycleMessage},
+		{Field: "soldAt", Message: lifecycleMessage},
+	}, validationErr.Fields)
+	repo.AssertNotCalled(t, "UpdateCar", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransitionCarService(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	sold := &entities.Car{ID: ID, CarName: "CX-5", Company: "Mazda", Status: entities.Sold, Version: 5}
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{ID: ID, CarName: "CX-5", Company: "Mazda", Status: entities.Reserved, Version: 4}, nil)
+
+	var patch *entities.CarPatch
+	repo.On("UpdateCar", ctx, ID, int64(4), mock.Anything).
+		Run(func(args mock.Arguments) { patch = args.Get(3).(*entities.CarPatch) }).
+		Return(sold, nil)
+
+	before := time.Now()
+	result, err := service.TransitionCarService(ctx, ID, 4, Sell)
+
+	assert.NoError(t, err)
+	assert.Equal(t, sold, result)
+	assert.Equal(t, entities.Sold, *patch.Status)
+	assert.WithinDuration(t, before, *patch.SoldAt, time.Second)
+	assert.Nil(t, patch.ReservedAt)
+	repo.AssertExpectations(t)
+}
+
+func TestTransitionCarServiceInvalid(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.Archived, Version: 2}, nil)
+
+	_, err := service.TransitionCarService(ctx, ID, 0, Reserve)
+
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.EqualError(t, err, "invalid car status transition: cannot reserve a car that is archived")
+	repo.AssertNotCalled(t, "UpdateCar", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransitionCarServiceStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.InStock, Version: 4}, nil)
+
+	_, err := service.TransitionCarService(ctx, ID, 3, Sell)
+
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	repo.AssertNotCalled(t, "UpdateCar", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// A transition without a version reads the car again when another write
+// gets in between, and is checked against the car as it now is.
+func TestTransitionCarServiceRetriesLostRace(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.InStock, Version: 1}, nil).Once()
+	repo.On("UpdateCar", ctx, ID, int64(1), mock.Anything).Return(nil, ErrVersionMismatch).Once()
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.Sold, Version: 2}, nil).Once()
+
+	_, err := service.TransitionCarService(ctx, ID, 0, Sell)
+
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	repo.AssertExpectations(t)
+}
+
+// An update without a version that loses a race with a sale is checked
+// again, so restating the old status cannot undo the sale.
+func TestUpdateCarServiceRetriesLostRace(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	status := entities.InStock
+	patch := &entities.CarPatch{Status: &status}
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.InStock, Version: 1}, nil).Once()
+	repo.On("UpdateCar", ctx, ID, int64(1), patch).Return(nil, ErrVersionMismatch).Once()
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{CarName: "CX-5", Company: "Mazda", Status: entities.Sold, Version: 2}, nil).Once()
+
+	_, err := service.UpdateCarService(ctx, ID, 0, patch)
+
+	assert.ErrorIs(t, err, ErrValidation)
+	repo.AssertExpectations(t)
+}
+
+func TestInsertCarsServiceSkipsInvalidCars(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	valid := &entities.Car{CarName: "CX-5", Company: "Mazda"}
+	invalid := &entities.Car{CarName: "", Company: "Mazda"}
+	stored := &entities.Car{ID: "64a4c6181955b6923fff02b5", CarName: "CX-5", Company: "Mazda", Version: 1}
+
+	repo.On("InsertCars", ctx, []*entities.Car{valid}, false).Return([]BatchResult{{Car: stored}}, nil)
+
+	results, err := service.InsertCarsService(ctx, []*entities.Car{invalid, valid}, false)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.ErrorIs(t, results[0].Err, ErrValidation)
+	assert.Equal(t, stored, results[1].Car)
+	assert.NoError(t, results[1].Err)
+
+	repo.AssertExpectations(t)
+}
+
+func TestInsertCarsServiceAtomicValidation(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	results, err := service.InsertCarsService(ctx, []*entities.Car{
+		{CarName: "CX-5", Company: "Mazda"},
+		{CarName: "", Company: "Mazda"},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, ErrValidation)
+
+	repo.AssertNotCalled(t, "InsertCars", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBatchLimit(t *testing.T) {
+	service := NewService(new(mockRepository), WithBatchLimit(1))
+
+	_, err := service.RemoveCarsService(context.Background(), make([]entities.CarDelete, 2), false)
+
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+}
+
+func TestUpdateCarsServiceChecksEachCar(t *testing.T) {
+	ctx := context.Background()
 	repo := new(mockRepository)
 	service := NewService(repo)
 
-	ID := "123"
+	current := &entities.Car{ID: "64a4c6181955b6923fff02b5", CarName: "CX-5", Company: "Mazda", Version: 3}
+	missing := entities.CarID("64a4c6181955b6923fff02b6")
+	name := "CX-30"
+	updates := []entities.CarUpdate{
+		{ID: current.ID, Version: 3, CarPatch: entities.CarPatch{CarName: &name}},
+		{ID: current.ID, CarPatch: entities.CarPatch{CarName: &name}},
+		{ID: missing, CarPatch: entities.CarPatch{CarName: &name}},
+	}
+	updated := &entities.Car{ID: current.ID, CarName: name, Company: "Mazda", Version: 4}
 
-	repo.On("DeleteCar", ID).Return(nil)
+	repo.On("GetCarByID", ctx, current.ID).Return(current, nil).Once()
+	repo.On("GetCarByID", ctx, missing).Return(nil, ErrCarNotFound)
+	repo.On("UpdateCars", ctx, updates[:1], false).Return([]BatchResult{{Car: updated}}, nil)
 
-	err := service.RemoveCarService(ID)
+	results, err := service.UpdateCarsService(ctx, updates, false)
 
 	assert.NoError(t, err)
+	assert.Equal(t, updated, results[0].Car)
+	assert.ErrorIs(t, results[1].Err, ErrValidation, "the same car twice")
+	assert.ErrorIs(t, results[2].Err, ErrCarNotFound)
 
 	repo.AssertExpectations(t)
 }
+
+// Batch updates without a version are written at the version checked,
+// and checked again when the car changes in between.
+func TestUpdateCarsServiceRetriesLostRace(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepository)
+	service := NewService(repo)
+
+	ID := entities.CarID("64a4c6181955b6923fff02b5")
+	name := "CX-30"
+	updates := []entities.CarUpdate{{ID: ID, CarPatch: entities.CarPatch{CarName: &name}}}
+	updated := &entities.Car{ID: ID, CarName: name, Company: "Mazda", Version: 3}
+
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{ID: ID, CarName: "CX-5", Company: "Mazda", Version: 1}, nil).Once()
+	repo.On("UpdateCars", ctx, []entities.CarUpdate{{ID: ID, Version: 1, CarPatch: updates[0].CarPatch}}, true).
+		Return([]BatchResult{{Err: ErrVersionMismatch}}, nil).Once()
+	repo.On("GetCarByID", ctx, ID).Return(&entities.Car{ID: ID, CarName: "CX-5", Company: "Mazda", Version: 2}, nil).Once()
+	repo.On("UpdateCars", ctx, []entities.CarUpdate{{ID: ID, Version: 2, CarPatch: updates[0].CarPatch}}, true).
+		Return([]BatchResult{{Car: updated}}, nil).Once()
+
+	results, err := service.UpdateCarsService(ctx, updates, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, updated, results[0].Car)
+	assert.NoError(t, results[0].Err)
+	repo.AssertExpectations(t)
+}
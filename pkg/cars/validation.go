@@ -0,0 +1,120 @@
+package cars
+
+import (
+	"fmt"
+	"reflect"
+	"testingfiber/pkg/validation"
+	"time"
+)
+
+// Validator checks car payloads against the rules declared in their
+// `validate` struct tags; see validation.Validator for the rules every
+// payload can use. Cars can also use:
+//
+//	company            the string must be one of the configured companies
+//	vin                the string must be a vehicle identification number
+//	                   with a valid check digit
+//	modelyear          the year must lie between the first car and next year
+//	listprice          the price must not exceed MaxListPrice
+type Validator struct {
+	companies map[string]bool
+	now       func() time.Time
+	rules     *validation.Validator
+}
+
+// firstModelYear is the model year of the first production car.
+const firstModelYear = 1886
+
+// MaxListPrice is the highest list price a car can have, in minor units:
+// a trillion in major units. Orders work out shares of the price in basis
+// points, and the cap keeps those products within an int64.
+const MaxListPrice int64 = 100_000_000_000_000
+
+// NewValidator returns a Validator that accepts the given companies. With
+// no companies, any company name is accepted.
+func NewValidator(companies ...string) *Validator {
+	v := &Validator{
+		companies: make(map[string]bool, len(companies)),
+		now:       time.Now,
+	}
+	for _, company := range companies {
+		v.companies[company] = true
+	}
+	v.rules = validation.New(func() time.Time { return v.now() }, map[string]validation.Rule{
+		"company":   v.checkCompany,
+		"vin":       func(field reflect.Value, _ string) string { return validateVIN(field.String()) },
+		"modelyear": v.checkModelYear,
+		"listprice": checkListPrice,
+	})
+	return v
+}
+
+// Validate checks every tagged field of the struct that value points to
+// and returns a *ValidationError listing the ones that fail.
+func (v *Validator) Validate(value interface{}) error {
+	if fields := v.rules.Validate(value); len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func (v *Validator) checkCompany(field reflect.Value, _ string) string {
+	if len(v.companies) > 0 && !v.companies[field.String()] {
+		return "is not a supported company"
+	}
+	return ""
+}
+
+func (v *Validator) checkModelYear(field reflect.Value, _ string) string {
+	if year := field.Int(); year < firstModelYear || year > int64(v.now().Year()+1) {
+		return fmt.Sprintf("must be between %d and next year", firstModelYear)
+	}
+	return ""
+}
+
+func checkListPrice(field reflect.Value, _ string) string {
+	if field.Int() > MaxListPrice {
+		return fmt.Sprintf("must be at most %d", MaxListPrice)
+	}
+	return ""
+}
+
+// vinTransliteration gives the value of each character of a VIN in the
+// check digit computation. I, O and Q are not used, to avoid confusion
+// with 1 and 0.
+var vinTransliteration = map[rune]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+// vinWeights weigh each position of a VIN; the ninth is the check digit.
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// validateVIN checks a vehicle identification number as defined by ISO
+// 3779, with the check digit of 49 CFR 565: the weighted sum of its
+// characters, modulo 11, with X standing for 10. It returns a message when
+// the VIN fails.
+func validateVIN(vin string) string {
+	if len(vin) != len(vinWeights) {
+		return "must be 17 characters long"
+	}
+
+	sum := 0
+	for i, r := range vin {
+		value, ok := vinTransliteration[r]
+		if r >= '0' && r <= '9' {
+			value, ok = int(r-'0'), true
+		}
+		if !ok {
+			return "must contain only digits and capital letters other than I, O and Q"
+		}
+		sum += value * vinWeights[i]
+	}
+
+	check := "0123456789X"[sum%11]
+	if vin[8] != check {
+		return "has an invalid check digit"
+	}
+	return ""
+}
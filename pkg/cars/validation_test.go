@@ -0,0 +1,144 @@
+package cars
+
+import (
+	"strings"
+	"testing"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorCar(t *testing.T) {
+	now := time.Date(2023, 7, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description    string
+		car            entities.Car
+		expectedFields []validation.FieldError
+	}{
+		{
+			description: "valid",
+			car:         entities.Car{CarName: "CX-5 (2023)", Company: "Mazda", MadeAt: now.Add(-time.Hour), SoldAt: now},
+		},
+		{
+			description: "validInventory",
+			car: entities.Car{
+				VIN: "1M8GDM9AXKP042788", CarName: "CX-5", Company: "Mazda", ModelYear: 2024, Trim: "Grand Touring",
+				Color: "Soul Red", Mileage: 1200, ListPrice: 2599900, Currency: "USD",
+				Condition: entities.ConditionCertified, Status: entities.Reserved,
+			},
+		},
+		{
+			description: "missingFields",
+			car:         entities.Car{CarName: "  "},
+			expectedFields: []validation.FieldError{
+				{Field: "carName", Message: "is required"},
+				{Field: "company", Message: "is required"},
+			},
+		},
+		{
+			description: "tooLong",
+			car:         entities.Car{CarName: strings.Repeat("x", 65), Company: "Mazda"},
+			expectedFields: []validation.FieldError{
+				{Field: "carName", Message: "must be at most 64 characters"},
+			},
+		},
+		{
+			description: "badCharacters",
+			car:         entities.Car{CarName: "<script>", Company: "Mazda"},
+			expectedFields: []validation.FieldError{
+				{Field: "carName", Message: "contains characters that are not allowed"},
+			},
+		},
+		{
+			description: "unknownCompany",
+			car:         entities.Car{CarName: "Model 3", Company: "Tesla"},
+			expectedFields: []validation.FieldError{
+				{Field: "company", Message: "is not a supported company"},
+			},
+		},
+		{
+			description: "madeInFuture",
+			car:         entities.Car{CarName: "CX-5", Company: "Mazda", MadeAt: now.Add(time.Hour)},
+			expectedFields: []validation.FieldError{
+				{Field: "madeAt", Message: "must not be in the future"},
+			},
+		},
+		{
+			description: "soldBeforeMade",
+			car:         entities.Car{CarName: "CX-5", Company: "Mazda", MadeAt: now, SoldAt: now.Add(-time.Hour)},
+			expectedFields: []validation.FieldError{
+				{Field: "soldAt", Message: "must not be before madeAt"},
+			},
+		},
+		{
+			description: "badVINCheckDigit",
+			car:         entities.Car{VIN: "1M8GDM9A1KP042788", CarName: "CX-5", Company: "Mazda"},
+			expectedFields: []validation.FieldError{
+				{Field: "vin", Message: "has an invalid check digit"},
+			},
+		},
+		{
+			description: "badVINCharacters",
+			car:         entities.Car{VIN: "1M8GDM9AXKPO42788", CarName: "CX-5", Company: "Mazda"},
+			expectedFields: []validation.FieldError{
+				{Field: "vin", Message: "must contain only digits and capital letters other than I, O and Q"},
+			},
+		},
+		{
+			description: "badInventory",
+			car: entities.Car{
+				VIN: "1M8GDM9AXKP04278", CarName: "CX-5", Company: "Mazda", ModelYear: 2025, Mileage: -1,
+				ListPrice: 100, Condition: "mint", Status: "stolen",
+			},
+			expectedFields: []validation.FieldError{
+				{Field: "vin", Message: "must be 17 characters long"},
+				{Field: "modelYear", Message: "must be between 1886 and next year"},
+				{Field: "mileage", Message: "must be at least 0"},
+				{Field: "currency", Message: "is required with listPrice"},
+				{Field: "condition", Message: "must be one of new, used, certified"},
+				{Field: "status", Message: "must be one of in_stock, reserved, sold, returned, archived"},
+			},
+		},
+		{
+			description: "listPriceTooHigh",
+			car:         entities.Car{CarName: "CX-5", Company: "Mazda", ListPrice: MaxListPrice + 1, Currency: "USD"},
+			expectedFields: []validation.FieldError{
+				{Field: "listPrice", Message: "must be at most 100000000000000"},
+			},
+		},
+		{
+			description: "badCurrency",
+			car:         entities.Car{CarName: "CX-5", Company: "Mazda", ListPrice: 100, Currency: "usd"},
+			expectedFields: []validation.FieldError{
+				{Field: "currency", Message: "contains characters that are not allowed"},
+			},
+		},
+	}
+
+	validator := NewValidator("Mazda", "Toyota")
+	validator.now = func() time.Time { return now }
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := validator.Validate(&test.car)
+
+			if test.expectedFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, test.expectedFields, validationErr.Fields)
+		})
+	}
+}
+
+func TestValidatorAnyCompany(t *testing.T) {
+	err := NewValidator().Validate(&entities.Car{CarName: "Model 3", Company: "Tesla"})
+	assert.NoError(t, err)
+}
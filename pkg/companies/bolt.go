@@ -0,0 +1,230 @@
+package companies
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltCompaniesBucket = []byte("companies")
+	boltNameIndex       = []byte("companies_by_name")
+)
+
+// boltRepository stores companies in the bbolt file of the cars, as JSON
+// documents keyed by ID. The name index maps the name key of every company
+// to its ID, which keeps names unique and lists companies in name order.
+type boltRepository struct {
+	db  *bolt.DB
+	ids entities.IDGenerator
+}
+
+// NewBoltRepo returns a repository backed by db, creating its buckets when
+// the file is new. The caller owns db and closes it.
+func NewBoltRepo(db *bolt.DB, opts ...RepositoryOption) (Repository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltCompaniesBucket, boltNameIndex} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	o := store.NewOptions(opts)
+	return &boltRepository{db: db, ids: o.IDs}, nil
+}
+
+func (r *boltRepository) InsertCompany(ctx context.Context, company *entities.Company) (*entities.Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	company.ID = newCompanyID(r.ids)
+	company.Version = 1
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return putCompany(tx, company)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return company, nil
+}
+
+func (r *boltRepository) ListCompanies(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matched []entities.Company
+	err := r.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(nameKey(query.NamePrefix))
+		cursor := tx.Bucket(boltNameIndex).Cursor()
+		for key, companyId := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, companyId = cursor.Next() {
+			company, err := getCompany(tx, companyId)
+			if err != nil {
+				return err
+			}
+			if matchesQuery(company, query) {
+				matched = append(matched, *company)
+			}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return pageOf(matched, query), nil
+}
+
+func (r *boltRepository) GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var company *entities.Company
+	err = r.db.View(func(tx *bolt.Tx) error {
+		company, err = getCompany(tx, []byte(companyId))
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return company, nil
+}
+
+func (r *boltRepository) FindCompanyByName(ctx context.Context, name string) (*entities.Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var company *entities.Company
+	err := r.db.View(func(tx *bolt.Tx) error {
+		companyId := tx.Bucket(boltNameIndex).Get([]byte(nameKey(name)))
+		if companyId == nil {
+			return ErrCompanyNotFound
+		}
+		var err error
+		company, err = getCompany(tx, companyId)
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return company, nil
+}
+
+func (r *boltRepository) UpdateCompany(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var company *entities.Company
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		if company, err = currentCompany(tx, companyId, version); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltNameIndex).Delete([]byte(nameKey(company.Name))); err != nil {
+			return err
+		}
+		patch.Apply(company)
+		company.Version++
+		return putCompany(tx, company)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return company, nil
+}
+
+func (r *boltRepository) DeleteCompany(ctx context.Context, ID entities.CompanyID, version int64) error {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		company, err := currentCompany(tx, companyId, version)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltNameIndex).Delete([]byte(nameKey(company.Name))); err != nil {
+			return err
+		}
+		return tx.Bucket(boltCompaniesBucket).Delete([]byte(companyId))
+	})
+	return boltError(err)
+}
+
+// currentCompany reads the stored company, provided it is at version or
+// version is 0.
+func currentCompany(tx *bolt.Tx, companyId entities.CompanyID, version int64) (*entities.Company, error) {
+	company, err := getCompany(tx, []byte(companyId))
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && company.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	return company, nil
+}
+
+// putCompany stores company and indexes its name, which no other company
+// may have.
+func putCompany(tx *bolt.Tx, company *entities.Company) error {
+	key := []byte(nameKey(company.Name))
+	names := tx.Bucket(boltNameIndex)
+	if owner := names.Get(key); owner != nil && string(owner) != string(company.ID) {
+		return fmt.Errorf("%w: the name %s is taken", ErrConflict, company.Name)
+	}
+
+	data, err := json.Marshal(company)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltCompaniesBucket).Put([]byte(company.ID), data); err != nil {
+		return err
+	}
+	return names.Put(key, []byte(company.ID))
+}
+
+func getCompany(tx *bolt.Tx, companyId []byte) (*entities.Company, error) {
+	data := tx.Bucket(boltCompaniesBucket).Get(companyId)
+	if data == nil {
+		return nil, ErrCompanyNotFound
+	}
+
+	var company entities.Company
+	if err := json.Unmarshal(data, &company); err != nil {
+		return nil, fmt.Errorf("decoding stored company: %w", err)
+	}
+	return &company, nil
+}
+
+func boltError(err error) error {
+	if errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+// Package companiestest provides a conformance suite that every
+// companies.Repository backend is expected to pass.
+package companiestest
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/storetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RepositoryFactory returns an empty repository for a single subtest.
+type RepositoryFactory = storetest.Factory[companies.Repository]
+
+// RepositoryConformance runs the shared behaviour checks against the
+// repositories produced by newRepo.
+func RepositoryConformance(t *testing.T, newRepo RepositoryFactory) {
+	storetest.Conformance(t, newRepo, []storetest.Case[companies.Repository]{
+		{Name: "InsertAssignsID", Run: testInsertAssignsID},
+		{Name: "UniqueName", Run: testUniqueName},
+		{Name: "GetMissingCompany", Run: testGetMissingCompany},
+		{Name: "GetInvalidID", Run: testGetInvalidID},
+		{Name: "FindByName", Run: testFindByName},
+		{Name: "ListOrderAndPaging", Run: testListOrderAndPaging},
+		{Name: "ListFilters", Run: testListFilters},
+		{Name: "Update", Run: testUpdate},
+		{Name: "UpdateTakenName", Run: testUpdateTakenName},
+		{Name: "UpdateStaleVersion", Run: testUpdateStaleVersion},
+		{Name: "UpdateMissingCompany", Run: testUpdateMissingCompany},
+		{Name: "Delete", Run: testDelete},
+		{Name: "DeleteStaleVersion", Run: testDeleteStaleVersion},
+		{Name: "DeleteMissingCompany", Run: testDeleteMissingCompany},
+	})
+}
+
+func insert(t *testing.T, repo companies.Repository, name, country string) *entities.Company {
+	t.Helper()
+
+	company, err := repo.InsertCompany(context.Background(), &entities.Company{Name: name, Country: country})
+	require.NoError(t, err)
+	return company
+}
+
+func listedNames(t *testing.T, repo companies.Repository, query *entities.CompanyQuery) []string {
+	t.Helper()
+
+	page, err := repo.ListCompanies(context.Background(), query)
+	require.NoError(t, err)
+
+	return storetest.Names(page.Companies, func(company entities.Company) string { return company.Name })
+}
+
+func testInsertAssignsID(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "JP")
+
+	assert.False(t, company.ID.IsZero())
+	assert.Equal(t, "Toyota", company.Name)
+	assert.Equal(t, "JP", company.Country)
+	assert.Equal(t, int64(1), company.Version)
+
+	got, err := repo.GetCompanyByID(context.Background(), company.ID)
+	require.NoError(t, err)
+	assert.Equal(t, company, got)
+}
+
+func testUniqueName(t *testing.T, repo companies.Repository) {
+	insert(t, repo, "Toyota", "JP")
+
+	_, err := repo.InsertCompany(context.Background(), &entities.Company{Name: "TOYOTA"})
+	assert.ErrorIs(t, err, companies.ErrConflict)
+}
+
+func testGetMissingCompany(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "")
+	require.NoError(t, repo.DeleteCompany(context.Background(), company.ID, 0))
+
+	_, err := repo.GetCompanyByID(context.Background(), company.ID)
+	assert.ErrorIs(t, err, companies.ErrCompanyNotFound)
+}
+
+func testGetInvalidID(t *testing.T, repo companies.Repository) {
+	_, err := repo.GetCompanyByID(context.Background(), "not-an-id")
+	assert.ErrorIs(t, err, companies.ErrInvalidID)
+}
+
+func testFindByName(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Mazda", "JP")
+
+	got, err := repo.FindCompanyByName(context.Background(), "mAZDA")
+	require.NoError(t, err)
+	assert.Equal(t, company.ID, got.ID)
+
+	_, err = repo.FindCompanyByName(context.Background(), "Maz")
+	assert.ErrorIs(t, err, companies.ErrCompanyNotFound)
+}
+
+func testListOrderAndPaging(t *testing.T, repo companies.Repository) {
+	for _, name := range []string{"toyota", "Honda", "mazda", "BMW"} {
+		insert(t, repo, name, "")
+	}
+
+	assert.Equal(t, []string{"BMW", "Honda", "mazda", "toyota"}, listedNames(t, repo, &entities.CompanyQuery{}))
+
+	page, err := repo.ListCompanies(context.Background(), &entities.CompanyQuery{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), page.Total)
+	require.Len(t, page.Companies, 2)
+	assert.Equal(t, "Honda", page.Companies[0].Name)
+	assert.Equal(t, "mazda", page.Companies[1].Name)
+
+	page, err = repo.ListCompanies(context.Background(), &entities.CompanyQuery{Offset: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), page.Total)
+	assert.Empty(t, page.Companies)
+}
+
+func testListFilters(t *testing.T, repo companies.Repository) {
+	insert(t, repo, "Mazda", "JP")
+	insert(t, repo, "Maserati", "IT")
+	insert(t, repo, "Honda", "JP")
+	insert(t, repo, "Ma_Motors", "")
+
+	assert.Equal(t, []string{"Maserati"}, listedNames(t, repo, &entities.CompanyQuery{NamePrefix: "mas"}))
+	assert.Equal(t, []string{"Ma_Motors", "Maserati", "Mazda"}, listedNames(t, repo, &entities.CompanyQuery{NamePrefix: "MA"}))
+	assert.Equal(t, []string{"Ma_Motors"}, listedNames(t, repo, &entities.CompanyQuery{NamePrefix: "ma_"}))
+	assert.Equal(t, []string{"Honda", "Mazda"}, listedNames(t, repo, &entities.CompanyQuery{Country: "JP"}))
+	assert.Equal(t, []string{"Mazda"}, listedNames(t, repo, &entities.CompanyQuery{NamePrefix: "ma", Country: "JP"}))
+}
+
+func testUpdate(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyta", "JP")
+	name := "Toyota"
+
+	updated, err := repo.UpdateCompany(context.Background(), company.ID, company.Version, &entities.CompanyPatch{Name: &name})
+	require.NoError(t, err)
+	assert.Equal(t, "Toyota", updated.Name)
+	assert.Equal(t, "JP", updated.Country)
+	assert.Equal(t, company.Version+1, updated.Version)
+
+	got, err := repo.FindCompanyByName(context.Background(), "toyota")
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+
+	// The old name is free again.
+	insert(t, repo, "Toyta", "")
+}
+
+func testUpdateTakenName(t *testing.T, repo companies.Repository) {
+	insert(t, repo, "Toyota", "")
+	company := insert(t, repo, "Honda", "")
+	name := "toyota"
+
+	_, err := repo.UpdateCompany(context.Background(), company.ID, 0, &entities.CompanyPatch{Name: &name})
+	assert.ErrorIs(t, err, companies.ErrConflict)
+
+	// Changing the case of a company's own name is no conflict.
+	name = "HONDA"
+	updated, err := repo.UpdateCompany(context.Background(), company.ID, 0, &entities.CompanyPatch{Name: &name})
+	require.NoError(t, err)
+	assert.Equal(t, "HONDA", updated.Name)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "")
+	country := "JP"
+
+	_, err := repo.UpdateCompany(context.Background(), company.ID, company.Version+1, &entities.CompanyPatch{Country: &country})
+	assert.ErrorIs(t, err, companies.ErrVersionMismatch)
+}
+
+func testUpdateMissingCompany(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "")
+	require.NoError(t, repo.DeleteCompany(context.Background(), company.ID, 0))
+	country := "JP"
+
+	_, err := repo.UpdateCompany(context.Background(), company.ID, 0, &entities.CompanyPatch{Country: &country})
+	assert.ErrorIs(t, err, companies.ErrCompanyNotFound)
+}
+
+func testDelete(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "")
+	require.NoError(t, repo.DeleteCompany(context.Background(), company.ID, company.Version))
+
+	assert.Empty(t, listedNames(t, repo, &entities.CompanyQuery{}))
+	_, err := repo.FindCompanyByName(context.Background(), "Toyota")
+	assert.ErrorIs(t, err, companies.ErrCompanyNotFound)
+
+	// The name is free again.
+	insert(t, repo, "Toyota", "")
+}
+
+func testDeleteStaleVersion(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "")
+
+	err := repo.DeleteCompany(context.Background(), company.ID, company.Version+1)
+	assert.ErrorIs(t, err, companies.ErrVersionMismatch)
+}
+
+func testDeleteMissingCompany(t *testing.T, repo companies.Repository) {
+	company := insert(t, repo, "Toyota", "")
+	require.NoError(t, repo.DeleteCompany(context.Background(), company.ID, 0))
+
+	err := repo.DeleteCompany(context.Background(), company.ID, 0)
+	assert.ErrorIs(t, err, companies.ErrCompanyNotFound)
+}
@@ -0,0 +1,50 @@
+package companies_test
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/companies/companiestest"
+	"testingfiber/pkg/internal/storetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepoConformance(t *testing.T) {
+	companiestest.RepositoryConformance(t, func(t *testing.T) companies.Repository {
+		return companies.NewMemoryRepo()
+	})
+}
+
+func TestBoltRepoConformance(t *testing.T) {
+	companiestest.RepositoryConformance(t, func(t *testing.T) companies.Repository {
+		repo, err := companies.NewBoltRepo(storetest.Bolt(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+// TestMongoRepoConformance runs against a real server when MONGO_TEST_URI
+// is set. Every subtest gets its own collection, dropped afterwards.
+func TestMongoRepoConformance(t *testing.T) {
+	db := storetest.MongoDatabase(t)
+	companiestest.RepositoryConformance(t, func(t *testing.T) companies.Repository {
+		collection := storetest.MongoCollection(t, db, "companies")
+		_, err := companies.NewMongoMigrator(collection).Up(context.Background())
+		require.NoError(t, err)
+		return companies.NewMongoRepo(collection)
+	})
+}
+
+// TestPostgresRepoConformance runs against a real server when
+// POSTGRES_TEST_URL is set. Every subtest gets its own schema, migrated
+// from scratch and dropped afterwards.
+func TestPostgresRepoConformance(t *testing.T) {
+	config := storetest.PostgresConfig(t)
+	companiestest.RepositoryConformance(t, func(t *testing.T) companies.Repository {
+		pool := storetest.PostgresPool(t, config, "companies")
+		_, err := companies.NewPostgresMigrator(pool).Up(context.Background())
+		require.NoError(t, err)
+		return companies.NewPostgresRepo(pool)
+	})
+}
@@ -0,0 +1,51 @@
+package companies
+
+import (
+	"errors"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/validation"
+)
+
+var (
+	// ErrCompanyNotFound is returned when no company has the requested ID
+	// or name. It is the error the cars service expects from its
+	// cars.CompanyDirectory.
+	ErrCompanyNotFound = cars.ErrCompanyNotFound
+	// ErrInvalidID is returned for IDs that cannot name any company.
+	ErrInvalidID = errors.New("invalid company id")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("company validation failed")
+	// ErrVersionMismatch is returned when a write names a version of the
+	// company that is no longer current.
+	ErrVersionMismatch = errors.New("company version mismatch")
+	// ErrConflict is returned when a company would take the name of
+	// another.
+	ErrConflict = errors.New("company conflict")
+	// ErrCompanyInUse is returned when deleting a company that cars still
+	// reference.
+	ErrCompanyInUse = errors.New("company is referenced by cars")
+)
+
+// ValidationError carries every field error found in a company payload.
+type ValidationError struct {
+	Fields []validation.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// FieldErrors returns the fields that failed, like
+// cars.ValidationError.FieldErrors.
+func (e *ValidationError) FieldErrors() []validation.FieldError {
+	return e.Fields
+}
@@ -0,0 +1,169 @@
+package companies
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+)
+
+// memoryRepository keeps companies in process memory, next to the memory
+// cars repository.
+type memoryRepository struct {
+	mu        sync.RWMutex
+	ids       entities.IDGenerator
+	companies map[entities.CompanyID]entities.Company
+}
+
+func NewMemoryRepo(opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &memoryRepository{
+		ids:       o.IDs,
+		companies: make(map[entities.CompanyID]entities.Company),
+	}
+}
+
+func (r *memoryRepository) InsertCompany(ctx context.Context, company *entities.Company) (*entities.Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	company.ID = newCompanyID(r.ids)
+	if err := r.checkNameLocked(company); err != nil {
+		return nil, err
+	}
+	company.Version = 1
+	r.companies[company.ID] = *company
+	return company, nil
+}
+
+// checkNameLocked rejects a company whose name another company already
+// has, like the unique indexes of the other backends.
+func (r *memoryRepository) checkNameLocked(company *entities.Company) error {
+	for _, stored := range r.companies {
+		if nameKey(stored.Name) == nameKey(company.Name) && stored.ID != company.ID {
+			return fmt.Errorf("%w: the name %s is taken", ErrConflict, company.Name)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) ListCompanies(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []entities.Company
+	for _, company := range r.companies {
+		if matchesQuery(&company, query) {
+			matched = append(matched, company)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if a, b := nameKey(matched[i].Name), nameKey(matched[j].Name); a != b {
+			return a < b
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	return pageOf(matched, query), nil
+}
+
+func (r *memoryRepository) GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	company, ok := r.companies[companyId]
+	if !ok {
+		return nil, ErrCompanyNotFound
+	}
+	return &company, nil
+}
+
+func (r *memoryRepository) FindCompanyByName(ctx context.Context, name string) (*entities.Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, company := range r.companies {
+		if nameKey(company.Name) == nameKey(name) {
+			return &company, nil
+		}
+	}
+	return nil, ErrCompanyNotFound
+}
+
+func (r *memoryRepository) UpdateCompany(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	company, err := r.currentLocked(companyId, version)
+	if err != nil {
+		return nil, err
+	}
+	patch.Apply(&company)
+	if err := r.checkNameLocked(&company); err != nil {
+		return nil, err
+	}
+	company.Version++
+	r.companies[companyId] = company
+	return &company, nil
+}
+
+func (r *memoryRepository) DeleteCompany(ctx context.Context, ID entities.CompanyID, version int64) error {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.currentLocked(companyId, version); err != nil {
+		return err
+	}
+	delete(r.companies, companyId)
+	return nil
+}
+
+// currentLocked returns the stored company, provided it is at version or
+// version is 0.
+func (r *memoryRepository) currentLocked(companyId entities.CompanyID, version int64) (entities.Company, error) {
+	company, ok := r.companies[companyId]
+	if !ok {
+		return company, ErrCompanyNotFound
+	}
+	if version != 0 && company.Version != version {
+		return company, ErrVersionMismatch
+	}
+	return company, nil
+}
@@ -0,0 +1,11 @@
+-- Names are unique regardless of case. Databases migrated before the
+-- companies had migrations of their own already have the table, which
+-- the cars migrations used to create.
+CREATE TABLE IF NOT EXISTS companies (
+    id      text   PRIMARY KEY,
+    name    text   NOT NULL CHECK (name <> ''),
+    country text   NOT NULL DEFAULT '',
+    version bigint NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS companies_name_key ON companies (lower(name));
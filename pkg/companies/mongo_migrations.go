@@ -0,0 +1,33 @@
+package companies
+
+import (
+	"context"
+	"testingfiber/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoMigrator returns the migrator for the companies collection.
+func NewMongoMigrator(companies *mongo.Collection) migrate.Migrator {
+	return migrate.NewMongo(companies, mongoMigrations)
+}
+
+// mongoMigrations are applied in this order, which is also version order.
+var mongoMigrations = []migrate.MongoMigration{
+	{
+		// Names are unique regardless of case, by way of their key.
+		Version: "0001_unique_name",
+		Up: func(ctx context.Context, companies *mongo.Collection) error {
+			_, err := companies.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "nameKey", Value: 1}},
+				Options: options.Index().SetName("nameKey_1").SetUnique(true),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, companies *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, companies, "nameKey_1")
+		},
+	},
+}
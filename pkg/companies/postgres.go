@@ -0,0 +1,221 @@
+package companies
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"testingfiber/pkg/migrate"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresColumns lists the columns of a company in the order
+// scanCompany reads them.
+const postgresColumns = "id, name, country, version"
+
+// postgresRepository stores companies in the companies table.
+type postgresRepository struct {
+	pool *pgxpool.Pool
+	ids  entities.IDGenerator
+}
+
+// NewPostgresRepo returns a repository backed by pool. The schema must be
+// up to date, see NewPostgresMigrator.
+func NewPostgresRepo(pool *pgxpool.Pool, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &postgresRepository{pool: pool, ids: o.IDs}
+}
+
+// NewPostgresMigrator returns the migrator for the companies table of the
+// database behind pool.
+func NewPostgresMigrator(pool *pgxpool.Pool) migrate.Migrator {
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		panic(err)
+	}
+	return migrate.NewPostgres(pool, migrations)
+}
+
+func (r *postgresRepository) InsertCompany(ctx context.Context, company *entities.Company) (*entities.Company, error) {
+	row := r.pool.QueryRow(ctx,
+		"INSERT INTO companies (id, name, country) VALUES ($1, $2, $3) RETURNING "+postgresColumns,
+		newCompanyID(r.ids), company.Name, company.Country)
+	return scanCompany(row)
+}
+
+func (r *postgresRepository) ListCompanies(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if query.NamePrefix != "" {
+		add("lower(name) LIKE $%d", likePrefix(nameKey(query.NamePrefix)))
+	}
+	if query.Country != "" {
+		add("country = $%d", query.Country)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT count(*) FROM companies"+where, args...).Scan(&total); err != nil {
+		return nil, postgresError(err)
+	}
+
+	// A NULL limit means no limit, matching a zero limit elsewhere.
+	var limit *int64
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	args = append(args, limit, query.Offset)
+	page := fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.pool.Query(ctx,
+		"SELECT "+postgresColumns+" FROM companies"+where+` ORDER BY lower(name) COLLATE "C", id COLLATE "C"`+page, args...)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	result := &entities.CompanyPage{Total: total}
+	for rows.Next() {
+		company, err := scanCompany(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Companies = append(result.Companies, *company)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+	return result, nil
+}
+
+func likePrefix(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(prefix) + "%"
+}
+
+func (r *postgresRepository) GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCompany(r.pool.QueryRow(ctx, "SELECT "+postgresColumns+" FROM companies WHERE id = $1", companyId))
+}
+
+func (r *postgresRepository) FindCompanyByName(ctx context.Context, name string) (*entities.Company, error) {
+	return scanCompany(r.pool.QueryRow(ctx, "SELECT "+postgresColumns+" FROM companies WHERE lower(name) = $1", nameKey(name)))
+}
+
+func (r *postgresRepository) UpdateCompany(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{companyId, version}
+	set := []string{"version = version + 1"}
+	assign := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.Name != nil {
+		assign("name", *patch.Name)
+	}
+	if patch.Country != nil {
+		assign("country", *patch.Country)
+	}
+
+	company, err := scanCompany(r.pool.QueryRow(ctx,
+		"UPDATE companies SET "+strings.Join(set, ", ")+
+			" WHERE id = $1 AND ($2::bigint = 0 OR version = $2) RETURNING "+postgresColumns,
+		args...))
+	if errors.Is(err, ErrCompanyNotFound) {
+		return nil, r.missError(ctx, companyId)
+	}
+	return company, err
+}
+
+func (r *postgresRepository) DeleteCompany(ctx context.Context, ID entities.CompanyID, version int64) error {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM companies WHERE id = $1 AND ($2::bigint = 0 OR version = $2)", companyId, version)
+	if err != nil {
+		return postgresError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return r.missError(ctx, companyId)
+	}
+	return nil
+}
+
+// missError explains why a versioned write matched nothing: either the
+// company is gone or it has moved on to another version.
+func (r *postgresRepository) missError(ctx context.Context, companyId entities.CompanyID) error {
+	var exists bool
+	if err := r.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM companies WHERE id = $1)", companyId).Scan(&exists); err != nil {
+		return postgresError(err)
+	}
+	if !exists {
+		return ErrCompanyNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// scanCompany reads a row of postgresColumns. A missing row is reported as
+// ErrCompanyNotFound.
+func scanCompany(row pgx.Row) (*entities.Company, error) {
+	var company entities.Company
+	err := row.Scan(&company.ID, &company.Name, &company.Country, &company.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCompanyNotFound
+	}
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	return &company, nil
+}
+
+// postgresError classifies pgx errors like the cars repository does. The
+// foreign key from cars makes deleting a referenced company fail.
+func postgresError(err error) error {
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return err
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == "23505":
+			return fmt.Errorf("%w: the name is taken: %w", ErrConflict, err)
+		case pgErr.Code == "23503":
+			return fmt.Errorf("%w: %w", ErrCompanyInUse, err)
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "53300", strings.HasPrefix(pgErr.Code, "57P"):
+			return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+		}
+	case errors.As(err, &netErr), pgconn.SafeToRetry(err):
+		return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+package companies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores companies. Names are unique regardless of case; a
+// write that would take another company's name fails with ErrConflict.
+// Every write bumps the company's version; UpdateCompany and
+// DeleteCompany only apply when the stored version equals the version
+// given, or unconditionally when that version is 0.
+//
+// ListCompanies orders companies by name, regardless of case, and then by
+// ID. The name prefix of a query also matches regardless of case.
+//
+// Every Repository is a cars.CompanyDirectory.
+type Repository interface {
+	InsertCompany(ctx context.Context, company *entities.Company) (*entities.Company, error)
+	ListCompanies(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error)
+	GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error)
+	FindCompanyByName(ctx context.Context, name string) (*entities.Company, error)
+	UpdateCompany(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error)
+	DeleteCompany(ctx context.Context, ID entities.CompanyID, version int64) error
+}
+
+// RepositoryOption customises the repositories built by NewMongoRepo,
+// NewMemoryRepo, NewPostgresRepo and NewBoltRepo.
+type RepositoryOption = store.Option
+
+// WithIDGenerator sets how new companies are identified, like
+// cars.WithIDGenerator does for cars.
+func WithIDGenerator(ids entities.IDGenerator) RepositoryOption {
+	return store.WithIDGenerator(ids)
+}
+
+// nameKey is the form of a name that uniqueness and ordering go by.
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+func newCompanyID(ids entities.IDGenerator) entities.CompanyID {
+	return entities.CompanyID(ids.NewID())
+}
+
+func parseID(ID entities.CompanyID) (entities.CompanyID, error) {
+	return store.ParseID(ID, entities.ParseCompanyID, ErrInvalidID)
+}
+
+// pageOf cuts the page that query asks for out of the matching companies.
+func pageOf(matched []entities.Company, query *entities.CompanyQuery) *entities.CompanyPage {
+	total := int64(len(matched))
+	start := query.Offset
+	if start > total {
+		start = total
+	}
+	end := start + query.Limit
+	if query.Limit <= 0 || end > total {
+		end = total
+	}
+
+	var companies []entities.Company
+	companies = append(companies, matched[start:end]...)
+	return &entities.CompanyPage{Companies: companies, Total: total}
+}
+
+func matchesQuery(company *entities.Company, query *entities.CompanyQuery) bool {
+	if query.Country != "" && company.Country != query.Country {
+		return false
+	}
+	return strings.HasPrefix(nameKey(company.Name), nameKey(query.NamePrefix))
+}
+
+type mongoRepository struct {
+	collection *mongo.Collection
+	ids        entities.IDGenerator
+}
+
+// companyDocument is the stored form of a company. NameKey backs the
+// unique index on names.
+type companyDocument struct {
+	ID      entities.CompanyID `bson:"_id"`
+	Name    string             `bson:"name"`
+	NameKey string             `bson:"nameKey"`
+	Country string             `bson:"country,omitempty"`
+	Version int64              `bson:"version"`
+}
+
+func newCompanyDocument(company *entities.Company) *companyDocument {
+	return &companyDocument{
+		ID:      company.ID,
+		Name:    company.Name,
+		NameKey: nameKey(company.Name),
+		Country: company.Country,
+		Version: company.Version,
+	}
+}
+
+func (d *companyDocument) company() *entities.Company {
+	return &entities.Company{ID: d.ID, Name: d.Name, Country: d.Country, Version: d.Version}
+}
+
+// NewMongoRepo returns a repository keeping companies in collection. Names
+// are only unique once the index of NewMongoMigrator exists.
+func NewMongoRepo(collection *mongo.Collection, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &mongoRepository{collection: collection, ids: o.IDs}
+}
+
+func (r *mongoRepository) InsertCompany(ctx context.Context, company *entities.Company) (*entities.Company, error) {
+	company.ID = newCompanyID(r.ids)
+	company.Version = 1
+	if _, err := r.collection.InsertOne(ctx, newCompanyDocument(company)); err != nil {
+		return nil, mongoError(err)
+	}
+	return company, nil
+}
+
+func (r *mongoRepository) ListCompanies(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error) {
+	filter := bson.M{}
+	if query.NamePrefix != "" {
+		filter["nameKey"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(nameKey(query.NamePrefix))}
+	}
+	if query.Country != "" {
+		filter["country"] = query.Country
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "nameKey", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(query.Offset)
+	if query.Limit > 0 {
+		findOptions.SetLimit(query.Limit)
+	}
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var documents []companyDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, mongoError(err)
+	}
+
+	page := &entities.CompanyPage{Total: total}
+	for i := range documents {
+		page.Companies = append(page.Companies, *documents[i].company())
+	}
+	return page, nil
+}
+
+func (r *mongoRepository) GetCompanyByID(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.findOne(ctx, bson.M{"_id": companyId})
+}
+
+func (r *mongoRepository) FindCompanyByName(ctx context.Context, name string) (*entities.Company, error) {
+	return r.findOne(ctx, bson.M{"nameKey": nameKey(name)})
+}
+
+func (r *mongoRepository) findOne(ctx context.Context, filter bson.M) (*entities.Company, error) {
+	var document companyDocument
+	err := r.collection.FindOne(ctx, filter).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCompanyNotFound
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return document.company(), nil
+}
+
+func (r *mongoRepository) UpdateCompany(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error) {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	if patch.Name != nil {
+		set["name"] = *patch.Name
+		set["nameKey"] = nameKey(*patch.Name)
+	}
+	if patch.Country != nil {
+		set["country"] = *patch.Country
+	}
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	var document companyDocument
+	err = r.collection.FindOneAndUpdate(ctx, versionFilter(companyId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, r.missError(ctx, companyId)
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return document.company(), nil
+}
+
+func (r *mongoRepository) DeleteCompany(ctx context.Context, ID entities.CompanyID, version int64) error {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.DeleteOne(ctx, versionFilter(companyId, version))
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return r.missError(ctx, companyId)
+	}
+	return nil
+}
+
+// versionFilter matches the company with the given ID, and with the given
+// version unless it is 0.
+func versionFilter(companyId entities.CompanyID, version int64) bson.M {
+	filter := bson.M{"_id": companyId}
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missError explains why a versioned write matched nothing: either the
+// company is gone or it has moved on to another version.
+func (r *mongoRepository) missError(ctx context.Context, companyId entities.CompanyID) error {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": companyId})
+	if err != nil {
+		return mongoError(err)
+	}
+	if count == 0 {
+		return ErrCompanyNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// mongoError classifies driver errors like the cars repository does; the
+// only unique key is the name.
+var mongoError = store.MongoErrors{
+	Conflict:    fmt.Errorf("%w: the name is taken", ErrConflict),
+	Unavailable: cars.ErrUnavailable,
+}.Classify
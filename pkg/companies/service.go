@@ -0,0 +1,145 @@
+package companies
+
+import (
+	"context"
+	"errors"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+)
+
+type Service interface {
+	InsertCompanyService(ctx context.Context, company *entities.Company) (*entities.Company, error)
+	ListCompaniesService(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error)
+	GetCompanyByIDService(ctx context.Context, ID entities.CompanyID) (*entities.Company, error)
+	UpdateCompanyService(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error)
+	RemoveCompanyService(ctx context.Context, ID entities.CompanyID, version int64) error
+}
+
+type service struct {
+	repository Repository
+	cars       cars.Repository
+	validator  *validation.Validator
+}
+
+// NewService returns the service for the companies of r. The cars of
+// carRepository are kept in step with them: renaming a company renames it
+// on its cars, and a company cannot be removed while cars reference it.
+func NewService(r Repository, carRepository cars.Repository) Service {
+	return &service{
+		repository: r,
+		cars:       carRepository,
+		validator:  validation.New(time.Now, nil),
+	}
+}
+
+func (s *service) InsertCompanyService(ctx context.Context, company *entities.Company) (*entities.Company, error) {
+	if err := s.validate(company); err != nil {
+		return nil, err
+	}
+
+	return s.repository.InsertCompany(ctx, company)
+}
+
+func (s *service) ListCompaniesService(ctx context.Context, query *entities.CompanyQuery) (*entities.CompanyPage, error) {
+	cars.ClampPage(&query.Limit, &query.Offset)
+
+	page, err := s.repository.ListCompanies(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Limit = query.Limit
+	page.Offset = query.Offset
+	return page, nil
+}
+
+func (s *service) GetCompanyByIDService(ctx context.Context, ID entities.CompanyID) (*entities.Company, error) {
+	return s.repository.GetCompanyByID(ctx, ID)
+}
+
+// UpdateCompanyService changes the supplied fields of a company. The patch
+// is validated against the company it will produce. A new name is carried
+// over to the company's cars afterwards; a car that fails to follow keeps
+// the old name until the company is next renamed, and the error is
+// returned along with the updated company.
+func (s *service) UpdateCompanyService(ctx context.Context, ID entities.CompanyID, version int64, patch *entities.CompanyPatch) (*entities.Company, error) {
+	current, err := s.repository.GetCompanyByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	candidate := *current
+	patch.Apply(&candidate)
+	if err := s.validate(&candidate); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repository.UpdateCompany(ctx, ID, version, patch)
+	if err != nil {
+		return nil, err
+	}
+	if updated.Name != current.Name {
+		if err := s.renameCars(ctx, updated); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// validate returns a *ValidationError listing the fields of company that
+// break their rules.
+func (s *service) validate(company *entities.Company) error {
+	if fields := s.validator.Validate(company); len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// renameCars gives every car of company the company's current name. The
+// cars are collected first, so that no write happens under an open scan.
+func (s *service) renameCars(ctx context.Context, company *entities.Company) error {
+	var carIds []entities.CarID
+	err := s.cars.ScanCars(ctx, &entities.CarQuery{CompanyID: company.ID, SortBy: "id"}, func(car *entities.Car) error {
+		if car.Company != company.Name {
+			carIds = append(carIds, car.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	patch := &entities.CarPatch{Company: &company.Name}
+	for _, carId := range carIds {
+		if _, err := s.cars.UpdateCar(ctx, carId, 0, patch); err != nil && !errors.Is(err, cars.ErrCarNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveCompanyService deletes a company that no car references, and
+// fails with ErrCompanyInUse otherwise. Stores with foreign keys also
+// catch a car taking the company while it is being removed.
+func (s *service) RemoveCompanyService(ctx context.Context, ID entities.CompanyID, version int64) error {
+	companyId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+
+	page, err := s.cars.CheckCar(ctx, &entities.CarQuery{CompanyID: companyId, Limit: 1, SortBy: "id"})
+	if err != nil {
+		return err
+	}
+	if page.Total > 0 {
+		return ErrCompanyInUse
+	}
+
+	return s.repository.DeleteCompany(ctx, companyId, version)
+}
@@ -0,0 +1,120 @@
+package companies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T) (Service, Repository, cars.Repository) {
+	t.Helper()
+
+	repo := NewMemoryRepo()
+	carRepo := cars.NewMemoryRepo()
+	return NewService(repo, carRepo), repo, carRepo
+}
+
+func TestInsertCompanyServiceValidation(t *testing.T) {
+	service, _, _ := newTestService(t)
+
+	_, err := service.InsertCompanyService(context.Background(), &entities.Company{Name: "Toyota", Country: "Japan"})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "country", validationErr.Fields[0].Field)
+
+	company, err := service.InsertCompanyService(context.Background(), &entities.Company{Name: "Toyota", Country: "JP"})
+	require.NoError(t, err)
+	assert.False(t, company.ID.IsZero())
+}
+
+func TestListCompaniesServiceClampsLimit(t *testing.T) {
+	service, _, _ := newTestService(t)
+
+	page, err := service.ListCompaniesService(context.Background(), &entities.CompanyQuery{Limit: 1000, Offset: -1})
+	require.NoError(t, err)
+	assert.Equal(t, cars.MaxListLimit, page.Limit)
+	assert.Zero(t, page.Offset)
+
+	page, err = service.ListCompaniesService(context.Background(), &entities.CompanyQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, cars.DefaultListLimit, page.Limit)
+}
+
+func TestUpdateCompanyServiceRenamesCars(t *testing.T) {
+	ctx := context.Background()
+	service, repo, carRepo := newTestService(t)
+
+	toyota, err := repo.InsertCompany(ctx, &entities.Company{Name: "Toyta"})
+	require.NoError(t, err)
+	honda, err := repo.InsertCompany(ctx, &entities.Company{Name: "Honda"})
+	require.NoError(t, err)
+	corolla, err := carRepo.InsertCar(ctx, &entities.Car{CarName: "Corolla", CompanyID: toyota.ID, Company: toyota.Name})
+	require.NoError(t, err)
+	civic, err := carRepo.InsertCar(ctx, &entities.Car{CarName: "Civic", CompanyID: honda.ID, Company: honda.Name})
+	require.NoError(t, err)
+
+	name := "Toyota"
+	updated, err := service.UpdateCompanyService(ctx, toyota.ID, toyota.Version, &entities.CompanyPatch{Name: &name})
+	require.NoError(t, err)
+	assert.Equal(t, "Toyota", updated.Name)
+
+	car, err := carRepo.GetCarByID(ctx, corolla.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Toyota", car.Company)
+	car, err = carRepo.GetCarByID(ctx, civic.ID)
+	require.NoError(t, err)
+	assert.Equal(t, civic.Version, car.Version)
+}
+
+func TestUpdateCompanyServiceStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	service, repo, _ := newTestService(t)
+
+	company, err := repo.InsertCompany(ctx, &entities.Company{Name: "Toyota"})
+	require.NoError(t, err)
+
+	country := "JP"
+	_, err = service.UpdateCompanyService(ctx, company.ID, company.Version+1, &entities.CompanyPatch{Country: &country})
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+}
+
+func TestUpdateCompanyServiceValidation(t *testing.T) {
+	ctx := context.Background()
+	service, repo, _ := newTestService(t)
+
+	company, err := repo.InsertCompany(ctx, &entities.Company{Name: "Toyota"})
+	require.NoError(t, err)
+
+	name := ""
+	_, err = service.UpdateCompanyService(ctx, company.ID, 0, &entities.CompanyPatch{Name: &name})
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+}
+
+func TestRemoveCompanyServiceInUse(t *testing.T) {
+	ctx := context.Background()
+	service, repo, carRepo := newTestService(t)
+
+	company, err := repo.InsertCompany(ctx, &entities.Company{Name: "Toyota"})
+	require.NoError(t, err)
+	car, err := carRepo.InsertCar(ctx, &entities.Car{CarName: "Corolla", CompanyID: company.ID, Company: company.Name})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, service.RemoveCompanyService(ctx, company.ID, 0), ErrCompanyInUse)
+
+	require.NoError(t, carRepo.DeleteCar(ctx, car.ID, 0))
+	require.NoError(t, service.RemoveCompanyService(ctx, company.ID, 0))
+	_, err = repo.GetCompanyByID(ctx, company.ID)
+	assert.ErrorIs(t, err, ErrCompanyNotFound)
+}
+
+func TestRemoveCompanyServiceInvalidID(t *testing.T) {
+	service, _, _ := newTestService(t)
+
+	assert.ErrorIs(t, service.RemoveCompanyService(context.Background(), "nope", 0), ErrInvalidID)
+}
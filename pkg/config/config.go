@@ -0,0 +1,499 @@
+// Package config loads the settings of the car shop server.
+//
+// Settings are resolved from four sources, each overriding the previous:
+//
+//  1. built-in defaults (see Default)
+//  2. a YAML file, named by the -config flag or the CAR_CONFIG variable
+//  3. environment variables
+//  4. command-line flags
+//
+// Environment variables and flags:
+//
+//	CAR_ADDR                             -addr                  listen address
+//	CAR_REQUEST_TIMEOUT                  -request-timeout       per-request deadline
+//	CAR_SHUTDOWN_TIMEOUT                 -shutdown-timeout      time allowed to drain requests on shutdown
+//	CAR_READ_TIMEOUT                     -read-timeout          time allowed to read a request
+//	CAR_IDLE_TIMEOUT                     -idle-timeout          time a keep-alive connection may wait for a request
+//	CAR_CORS_ORIGINS                     -cors-origins          allowed CORS origins, comma separated
+//	CAR_MAX_BATCH_SIZE                   -max-batch-size        most items in one batch request
+//	CAR_LOG_LEVEL                        -log-level             debug (log every request) or info
+//	CAR_STORAGE                          -storage               mongo, postgres, bolt or memory
+//	CAR_COMPANIES                        -companies             accepted companies, comma separated
+//	CAR_ID_FORMAT                        -id-format             objectid, uuidv7 or ulid
+//	CAR_FEATURES                         -features              feature toggles, e.g. "a,b=false"
+//	CAR_ORDERS_TAX_RATE                  -orders-tax-rate       tax charged on orders, in basis points
+//	CAR_MONGO_URI                        -mongo-uri             MongoDB connection string
+//	CAR_MONGO_DATABASE                   -mongo-database        database name
+//	CAR_MONGO_COLLECTION                 -mongo-collection      cars collection name
+//	CAR_MONGO_COMPANIES_COLLECTION       -mongo-companies-collection
+//	CAR_MONGO_CUSTOMERS_COLLECTION       -mongo-customers-collection
+//	CAR_MONGO_ORDERS_COLLECTION          -mongo-orders-collection
+//	CAR_MONGO_CONNECT_TIMEOUT            -mongo-connect-timeout
+//	CAR_MONGO_SERVER_SELECTION_TIMEOUT   -mongo-server-selection-timeout
+//	CAR_MONGO_MIN_POOL_SIZE              -mongo-min-pool-size
+//	CAR_MONGO_MAX_POOL_SIZE              -mongo-max-pool-size
+//	CAR_MONGO_CONNECT_RETRIES            -mongo-connect-retries  startup pings retried after the first
+//	CAR_MONGO_RETRY_BACKOFF              -mongo-retry-backoff    wait before the first retry, doubled each time
+//	CAR_MONGO_AUTO_MIGRATE               -mongo-auto-migrate    apply pending migrations on startup
+//	CAR_POSTGRES_URL                     -postgres-url          PostgreSQL connection string
+//	CAR_POSTGRES_MAX_CONNS               -postgres-max-conns    maximum connection pool size
+//	CAR_POSTGRES_CONNECT_RETRIES         -postgres-connect-retries
+//	CAR_POSTGRES_RETRY_BACKOFF           -postgres-retry-backoff
+//	CAR_BOLT_PATH                        -bolt-path             data file of the embedded store
+//	CAR_BOLT_OPEN_TIMEOUT                -bolt-open-timeout     wait for another process to release the file
+//
+// Durations use Go syntax ("5s", "1m30s"). See config.example.yaml for the
+// file layout.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server    Server          `yaml:"server"`
+	Mongo     Mongo           `yaml:"mongo"`
+	Postgres  Postgres        `yaml:"postgres"`
+	Bolt      Bolt            `yaml:"bolt"`
+	Orders    Orders          `yaml:"orders"`
+	Storage   string          `yaml:"storage"`
+	Companies []string        `yaml:"companies"`
+	IDFormat  string          `yaml:"idFormat"`
+	LogLevel  string          `yaml:"logLevel"`
+	Features  map[string]bool `yaml:"features"`
+}
+
+type Server struct {
+	Addr            string        `yaml:"addr"`
+	RequestTimeout  time.Duration `yaml:"requestTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout"`
+	CORSOrigins     []string      `yaml:"corsOrigins"`
+	MaxBatchSize    int           `yaml:"maxBatchSize"`
+}
+
+type Mongo struct {
+	URI                    string        `yaml:"uri"`
+	Database               string        `yaml:"database"`
+	Collection             string        `yaml:"collection"`
+	CompaniesCollection    string        `yaml:"companiesCollection"`
+	CustomersCollection    string        `yaml:"customersCollection"`
+	OrdersCollection       string        `yaml:"ordersCollection"`
+	ConnectTimeout         time.Duration `yaml:"connectTimeout"`
+	ServerSelectionTimeout time.Duration `yaml:"serverSelectionTimeout"`
+	MinPoolSize            uint64        `yaml:"minPoolSize"`
+	MaxPoolSize            uint64        `yaml:"maxPoolSize"`
+	ConnectRetries         int           `yaml:"connectRetries"`
+	RetryBackoff           time.Duration `yaml:"retryBackoff"`
+	AutoMigrate            bool          `yaml:"autoMigrate"`
+}
+
+type Postgres struct {
+	URL            string        `yaml:"url"`
+	MaxConns       int           `yaml:"maxConns"`
+	ConnectRetries int           `yaml:"connectRetries"`
+	RetryBackoff   time.Duration `yaml:"retryBackoff"`
+}
+
+// Bolt configures the embedded single-file store.
+type Bolt struct {
+	Path        string        `yaml:"path"`
+	OpenTimeout time.Duration `yaml:"openTimeout"`
+}
+
+// Orders configures how orders are priced.
+type Orders struct {
+	// TaxRate is charged on the discounted price of every order, in
+	// basis points: 825 is 8.25%.
+	TaxRate int `yaml:"taxRate"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			RequestTimeout:  5 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			ReadTimeout:     5 * time.Second,
+			IdleTimeout:     5 * time.Second,
+			CORSOrigins:     []string{"*"},
+			MaxBatchSize:    100,
+		},
+		Mongo: Mongo{
+			URI:                    "mongodb://localhost:27017/cars",
+			Database:               "cars",
+			Collection:             "cars",
+			CompaniesCollection:    "companies",
+			CustomersCollection:    "customers",
+			OrdersCollection:       "orders",
+			ConnectTimeout:         10 * time.Second,
+			ServerSelectionTimeout: 5 * time.Second,
+			MaxPoolSize:            100,
+			ConnectRetries:         5,
+			RetryBackoff:           500 * time.Millisecond,
+		},
+		Postgres: Postgres{
+			URL:            "postgres://localhost:5432/cars",
+			MaxConns:       10,
+			ConnectRetries: 5,
+			RetryBackoff:   500 * time.Millisecond,
+		},
+		Bolt: Bolt{
+			Path:        "cars.db",
+			OpenTimeout: time.Second,
+		},
+		Storage:  "mongo",
+		IDFormat: "objectid",
+		LogLevel: "info",
+		Features: map[string]bool{},
+	}
+}
+
+// Feature reports whether the named feature toggle is switched on.
+func (c *Config) Feature(name string) bool {
+	return c.Features[name]
+}
+
+// Load resolves the configuration from the command-line arguments (without
+// the program name) and the environment, then validates it. Arguments
+// other than flags are rejected.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet("carshop", flag.ContinueOnError)
+	cfg, rest, err := LoadFlags(fs, args, getenv)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("config: unexpected argument %q", rest[0])
+	}
+	return cfg, nil
+}
+
+// LoadFlags is Load for commands with flags and arguments of their own: it
+// adds the configuration flags to fs, parses args with it and returns the
+// arguments that are not flags. Flags may come before, between or after
+// those arguments, unless a "--" ends the flags.
+func LoadFlags(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, []string, error) {
+	cfg := Default()
+
+	flags := newFlagValues(fs)
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+
+	set := visited(fs)
+
+	path := getenv("CAR_CONFIG")
+	if set["config"] {
+		path = *flags.config
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs []error
+	for _, setting := range settings(cfg) {
+		if value := getenv(setting.env); value != "" {
+			if err := setting.apply(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", setting.env, err))
+			}
+		}
+	}
+
+	for _, setting := range settings(cfg) {
+		if set[setting.flag] {
+			if err := setting.apply(*flags.values[setting.flag]); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", setting.flag, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, rest, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	check(c.Server.RequestTimeout >= 0, "server.requestTimeout must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+	check(c.Server.ReadTimeout > 0, "server.readTimeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idleTimeout must be positive")
+	check(c.Server.MaxBatchSize > 0, "server.maxBatchSize must be positive")
+	check(c.Storage == "mongo" || c.Storage == "postgres" || c.Storage == "bolt" || c.Storage == "memory",
+		"storage must be mongo, postgres, bolt or memory, got %q", c.Storage)
+	check(c.IDFormat == "objectid" || c.IDFormat == "uuidv7" || c.IDFormat == "ulid",
+		"idFormat must be objectid, uuidv7 or ulid, got %q", c.IDFormat)
+	check(isLogLevel(c.LogLevel),
+		"logLevel must be debug or info, got %q", c.LogLevel)
+	check(c.Orders.TaxRate >= 0 && c.Orders.TaxRate <= 10000,
+		"orders.taxRate must be between 0 and 10000 basis points, got %d", c.Orders.TaxRate)
+
+	if c.Storage == "mongo" {
+		check(strings.HasPrefix(c.Mongo.URI, "mongodb://") || strings.HasPrefix(c.Mongo.URI, "mongodb+srv://"),
+			"mongo.uri must start with mongodb:// or mongodb+srv://")
+		check(c.Mongo.Database != "", "mongo.database must not be empty")
+		check(c.Mongo.Collection != "", "mongo.collection must not be empty")
+		check(c.Mongo.CompaniesCollection != "", "mongo.companiesCollection must not be empty")
+		check(c.Mongo.CustomersCollection != "", "mongo.customersCollection must not be empty")
+		check(c.Mongo.OrdersCollection != "", "mongo.ordersCollection must not be empty")
+		check(c.Mongo.ConnectTimeout > 0, "mongo.connectTimeout must be positive")
+		check(c.Mongo.ServerSelectionTimeout > 0, "mongo.serverSelectionTimeout must be positive")
+		check(c.Mongo.MaxPoolSize == 0 || c.Mongo.MinPoolSize <= c.Mongo.MaxPoolSize,
+			"mongo.minPoolSize (%d) must not exceed mongo.maxPoolSize (%d)", c.Mongo.MinPoolSize, c.Mongo.MaxPoolSize)
+		check(c.Mongo.ConnectRetries >= 0, "mongo.connectRetries must not be negative")
+		check(c.Mongo.RetryBackoff > 0, "mongo.retryBackoff must be positive")
+	}
+
+	if c.Storage == "postgres" {
+		check(strings.HasPrefix(c.Postgres.URL, "postgres://") || strings.HasPrefix(c.Postgres.URL, "postgresql://"),
+			"postgres.url must start with postgres:// or postgresql://")
+		check(c.Postgres.MaxConns > 0, "postgres.maxConns must be positive")
+		check(c.Postgres.ConnectRetries >= 0, "postgres.connectRetries must not be negative")
+		check(c.Postgres.RetryBackoff > 0, "postgres.retryBackoff must be positive")
+	}
+
+	if c.Storage == "bolt" {
+		check(c.Bolt.Path != "", "bolt.path must not be empty")
+		check(c.Bolt.OpenTimeout > 0, "bolt.openTimeout must be positive")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func isLogLevel(level string) bool {
+	switch level {
+	case "debug", "info":
+		return true
+	}
+	return false
+}
+
+// setting ties one configuration field to its environment variable and
+// flag, and knows how to parse a raw value into it.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	apply func(string) error
+}
+
+func settings(cfg *Config) []setting {
+	return []setting{
+		{"CAR_ADDR", "addr", "listen address", stringValue(&cfg.Server.Addr)},
+		{"CAR_REQUEST_TIMEOUT", "request-timeout", "per-request deadline", durationValue(&cfg.Server.RequestTimeout)},
+		{"CAR_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed to drain requests on shutdown", durationValue(&cfg.Server.ShutdownTimeout)},
+		{"CAR_READ_TIMEOUT", "read-timeout", "time allowed to read a request", durationValue(&cfg.Server.ReadTimeout)},
+		{"CAR_IDLE_TIMEOUT", "idle-timeout", "time a keep-alive connection may wait for a request", durationValue(&cfg.Server.IdleTimeout)},
+		{"CAR_CORS_ORIGINS", "cors-origins", "allowed CORS origins, comma separated", listValue(&cfg.Server.CORSOrigins)},
+		{"CAR_MAX_BATCH_SIZE", "max-batch-size", "most items in one batch request", intValue(&cfg.Server.MaxBatchSize)},
+		{"CAR_LOG_LEVEL", "log-level", "debug (log every request) or info", stringValue(&cfg.LogLevel)},
+		{"CAR_STORAGE", "storage", "mongo, postgres, bolt or memory", stringValue(&cfg.Storage)},
+		{"CAR_COMPANIES", "companies", "accepted companies, comma separated", listValue(&cfg.Companies)},
+		{"CAR_ID_FORMAT", "id-format", "objectid, uuidv7 or ulid", stringValue(&cfg.IDFormat)},
+		{"CAR_FEATURES", "features", `feature toggles, e.g. "a,b=false"`, featuresValue(cfg)},
+		{"CAR_ORDERS_TAX_RATE", "orders-tax-rate", "tax charged on orders, in basis points", intValue(&cfg.Orders.TaxRate)},
+		{"CAR_MONGO_URI", "mongo-uri", "MongoDB connection string", stringValue(&cfg.Mongo.URI)},
+		{"CAR_MONGO_DATABASE", "mongo-database", "database name", stringValue(&cfg.Mongo.Database)},
+		{"CAR_MONGO_COLLECTION", "mongo-collection", "cars collection name", stringValue(&cfg.Mongo.Collection)},
+		{"CAR_MONGO_COMPANIES_COLLECTION", "mongo-companies-collection", "companies collection name", stringValue(&cfg.Mongo.CompaniesCollection)},
+		{"CAR_MONGO_CUSTOMERS_COLLECTION", "mongo-customers-collection", "customers collection name", stringValue(&cfg.Mongo.CustomersCollection)},
+		{"CAR_MONGO_ORDERS_COLLECTION", "mongo-orders-collection", "orders collection name", stringValue(&cfg.Mongo.OrdersCollection)},
+		{"CAR_MONGO_CONNECT_TIMEOUT", "mongo-connect-timeout", "connect timeout", durationValue(&cfg.Mongo.ConnectTimeout)},
+		{"CAR_MONGO_SERVER_SELECTION_TIMEOUT", "mongo-server-selection-timeout", "server selection timeout", durationValue(&cfg.Mongo.ServerSelectionTimeout)},
+		{"CAR_MONGO_MIN_POOL_SIZE", "mongo-min-pool-size", "minimum connection pool size", uintValue(&cfg.Mongo.MinPoolSize)},
+		{"CAR_MONGO_MAX_POOL_SIZE", "mongo-max-pool-size", "maximum connection pool size", uintValue(&cfg.Mongo.MaxPoolSize)},
+		{"CAR_MONGO_CONNECT_RETRIES", "mongo-connect-retries", "startup pings retried after the first", intValue(&cfg.Mongo.ConnectRetries)},
+		{"CAR_MONGO_RETRY_BACKOFF", "mongo-retry-backoff", "wait before the first retry, doubled each time", durationValue(&cfg.Mongo.RetryBackoff)},
+		{"CAR_MONGO_AUTO_MIGRATE", "mongo-auto-migrate", "apply pending migrations on startup", boolValue(&cfg.Mongo.AutoMigrate)},
+		{"CAR_POSTGRES_URL", "postgres-url", "PostgreSQL connection string", stringValue(&cfg.Postgres.URL)},
+		{"CAR_POSTGRES_MAX_CONNS", "postgres-max-conns", "maximum connection pool size", intValue(&cfg.Postgres.MaxConns)},
+		{"CAR_POSTGRES_CONNECT_RETRIES", "postgres-connect-retries", "startup pings retried after the first", intValue(&cfg.Postgres.ConnectRetries)},
+		{"CAR_POSTGRES_RETRY_BACKOFF", "postgres-retry-backoff", "wait before the first retry, doubled each time", durationValue(&cfg.Postgres.RetryBackoff)},
+		{"CAR_BOLT_PATH", "bolt-path", "data file of the embedded store", stringValue(&cfg.Bolt.Path)},
+		{"CAR_BOLT_OPEN_TIMEOUT", "bolt-open-timeout", "wait for another process to release the file", durationValue(&cfg.Bolt.OpenTimeout)},
+	}
+}
+
+// flagValues holds the raw flag strings; they are applied after the file
+// and the environment so that flags take precedence.
+type flagValues struct {
+	config *string
+	values map[string]*string
+}
+
+func newFlagValues(fs *flag.FlagSet) *flagValues {
+	f := &flagValues{
+		config: fs.String("config", "", "path to a YAML configuration file"),
+		values: map[string]*string{},
+	}
+	for _, setting := range settings(Default()) {
+		f.values[setting.flag] = fs.String(setting.flag, "", setting.usage)
+	}
+	return f
+}
+
+// parseInterspersed parses the flags in args wherever they appear and
+// returns the other arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		remaining := fs.Args()
+		if len(remaining) == 0 {
+			return rest, nil
+		}
+		// Parse stops at the first argument that is not a flag, and
+		// swallows a "--" that ends the flags.
+		if consumed := len(args) - len(remaining); consumed > 0 && args[consumed-1] == "--" {
+			return append(rest, remaining...), nil
+		}
+		rest = append(rest, remaining[0])
+		args = remaining[1:]
+	}
+}
+
+// visited returns the names of the flags given on the command line.
+func visited(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	return set
+}
+
+func stringValue(dst *string) func(string) error {
+	return func(value string) error {
+		*dst = value
+		return nil
+	}
+}
+
+func durationValue(dst *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*dst = d
+		return nil
+	}
+}
+
+func uintValue(dst *uint64) func(string) error {
+	return func(value string) error {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func intValue(dst *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func boolValue(dst *bool) func(string) error {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*dst = b
+		return nil
+	}
+}
+
+func listValue(dst *[]string) func(string) error {
+	return func(value string) error {
+		*dst = splitList(value)
+		return nil
+	}
+}
+
+// featuresValue parses "name" and "name=bool" items and merges them into
+// the toggles loaded so far.
+func featuresValue(cfg *Config) func(string) error {
+	return func(value string) error {
+		if cfg.Features == nil {
+			cfg.Features = map[string]bool{}
+		}
+		for _, item := range splitList(value) {
+			name, raw, hasValue := strings.Cut(item, "=")
+			enabled := true
+			if hasValue {
+				var err error
+				if enabled, err = strconv.ParseBool(raw); err != nil {
+					return fmt.Errorf("feature %q: %w", name, err)
+				}
+			}
+			cfg.Features[name] = enabled
+		}
+		return nil
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
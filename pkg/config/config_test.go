@@ -0,0 +1,234 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, "mongodb://localhost:27017/cars", cfg.Mongo.URI)
+	assert.Equal(t, 10*time.Second, cfg.Mongo.ConnectTimeout)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+  requestTimeout: 2s
+mongo:
+  database: fromfile
+  collection: fromfile
+  maxPoolSize: 20
+companies: [Mazda]
+features:
+  search: true
+`)
+
+	cfg, err := Load(
+		[]string{"-config", path, "-mongo-collection", "fromflag", "-features", "export"},
+		env(map[string]string{
+			"CAR_MONGO_DATABASE":     "fromenv",
+			"CAR_MONGO_COLLECTION":   "fromenv",
+			"CAR_COMPANIES":          "Mazda, Toyota",
+			"CAR_MONGO_AUTO_MIGRATE": "true",
+		}),
+	)
+
+	require.NoError(t, err)
+	// The file overrides the defaults.
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, 2*time.Second, cfg.Server.RequestTimeout)
+	assert.Equal(t, uint64(20), cfg.Mongo.MaxPoolSize)
+	// The environment overrides the file.
+	assert.Equal(t, "fromenv", cfg.Mongo.Database)
+	assert.Equal(t, []string{"Mazda", "Toyota"}, cfg.Companies)
+	assert.True(t, cfg.Mongo.AutoMigrate)
+	// Flags override the environment.
+	assert.Equal(t, "fromflag", cfg.Mongo.Collection)
+	// Feature toggles from every source are merged.
+	assert.True(t, cfg.Feature("search"))
+	assert.True(t, cfg.Feature("export"))
+	assert.False(t, cfg.Feature("missing"))
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	path := writeFile(t, "storage: memory\n")
+
+	cfg, err := Load(nil, env(map[string]string{"CAR_CONFIG": path}))
+
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Storage)
+}
+
+func TestLoadFlags(t *testing.T) {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "json", "")
+
+	cfg, rest, err := LoadFlags(fs,
+		[]string{"first", "-storage", "memory", "second", "-format", "csv", "--", "-third"},
+		env(nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Storage)
+	assert.Equal(t, "csv", *format)
+	assert.Equal(t, []string{"first", "second", "-third"}, rest)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		args        []string
+		env         map[string]string
+		file        string
+		contains    []string
+	}{
+		{
+			description: "badDuration",
+			env:         map[string]string{"CAR_REQUEST_TIMEOUT": "soon"},
+			contains:    []string{"CAR_REQUEST_TIMEOUT"},
+		},
+		{
+			description: "badFlag",
+			args:        []string{"-mongo-max-pool-size", "lots"},
+			contains:    []string{"-mongo-max-pool-size"},
+		},
+		{
+			description: "unknownFileKey",
+			file:        "sever:\n  addr: x\n",
+			contains:    []string{"sever"},
+		},
+		{
+			description: "missingFile",
+			args:        []string{"-config", "/does/not/exist.yaml"},
+			contains:    []string{"exist.yaml"},
+		},
+		{
+			description: "invalidValues",
+			env: map[string]string{
+				"CAR_LOG_LEVEL":           "verbose",
+				"CAR_MONGO_MIN_POOL_SIZE": "50",
+				"CAR_MONGO_MAX_POOL_SIZE": "10",
+			},
+			contains: []string{"logLevel", "minPoolSize (50)"},
+		},
+		{
+			description: "ineffectiveLogLevel",
+			env:         map[string]string{"CAR_LOG_LEVEL": "warn"},
+			contains:    []string{`logLevel must be debug or info, got "warn"`},
+		},
+		{
+			description: "negativeRetries",
+			env:         map[string]string{"CAR_MONGO_CONNECT_RETRIES": "-1"},
+			contains:    []string{"mongo.connectRetries"},
+		},
+		{
+			description: "zeroShutdownTimeout",
+			args:        []string{"-shutdown-timeout", "0s"},
+			contains:    []string{"server.shutdownTimeout"},
+		},
+		{
+			description: "zeroIdleTimeout",
+			env:         map[string]string{"CAR_IDLE_TIMEOUT": "0s"},
+			contains:    []string{"server.idleTimeout"},
+		},
+		{
+			description: "zeroMaxBatchSize",
+			env:         map[string]string{"CAR_MAX_BATCH_SIZE": "0"},
+			contains:    []string{"server.maxBatchSize"},
+		},
+		{
+			description: "taxRateOverWhole",
+			env:         map[string]string{"CAR_ORDERS_TAX_RATE": "12000"},
+			contains:    []string{"orders.taxRate"},
+		},
+		{
+			description: "emptyOrdersCollection",
+			args:        []string{"-mongo-orders-collection", ""},
+			contains:    []string{"mongo.ordersCollection"},
+		},
+		{
+			description: "badStorage",
+			env:         map[string]string{"CAR_STORAGE": "cassandra"},
+			contains:    []string{"storage must be mongo, postgres, bolt or memory"},
+		},
+		{
+			description: "badIDFormat",
+			env:         map[string]string{"CAR_ID_FORMAT": "serial"},
+			contains:    []string{"idFormat"},
+		},
+		{
+			description: "emptyBoltPath",
+			args:        []string{"-storage", "bolt", "-bolt-path", ""},
+			contains:    []string{"bolt.path"},
+		},
+		{
+			description: "badPostgresURL",
+			env:         map[string]string{"CAR_STORAGE": "postgres", "CAR_POSTGRES_URL": "localhost:5432"},
+			contains:    []string{"postgres.url"},
+		},
+		{
+			description: "unexpectedArgument",
+			args:        []string{"-storage", "memory", "extra"},
+			contains:    []string{`unexpected argument "extra"`},
+		},
+		{
+			description: "badAutoMigrate",
+			env:         map[string]string{"CAR_MONGO_AUTO_MIGRATE": "sometimes"},
+			contains:    []string{"CAR_MONGO_AUTO_MIGRATE"},
+		},
+		{
+			description: "badMongoURI",
+			env:         map[string]string{"CAR_MONGO_URI": "localhost:27017"},
+			contains:    []string{"mongo.uri"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			args := test.args
+			if test.file != "" {
+				args = append(args, "-config", writeFile(t, test.file))
+			}
+
+			_, err := Load(args, env(test.env))
+
+			require.Error(t, err)
+			for _, fragment := range test.contains {
+				assert.Contains(t, err.Error(), fragment)
+			}
+		})
+	}
+}
+
+func TestValidateSkipsMongoForMemoryStorage(t *testing.T) {
+	cfg := Default()
+	cfg.Storage = "memory"
+	cfg.Mongo.URI = ""
+
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,211 @@
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltCustomersBucket = []byte("customers")
+	boltEmailIndex      = []byte("customers_by_email")
+)
+
+// boltRepository stores customers in the bbolt file of the cars, as JSON
+// documents keyed by ID. The email index maps the folded email of every
+// customer to their ID, which keeps emails unique.
+type boltRepository struct {
+	db  *bolt.DB
+	ids entities.IDGenerator
+}
+
+// NewBoltRepo returns a repository backed by db, creating its buckets when
+// the file is new. The caller owns db and closes it.
+func NewBoltRepo(db *bolt.DB, opts ...RepositoryOption) (Repository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltCustomersBucket, boltEmailIndex} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	o := store.NewOptions(opts)
+	return &boltRepository{db: db, ids: o.IDs}, nil
+}
+
+func (r *boltRepository) InsertCustomer(ctx context.Context, customer *entities.Customer) (*entities.Customer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	customer.ID = newCustomerID(r.ids)
+	customer.Version = 1
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return putCustomer(tx, customer)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return customer, nil
+}
+
+// ListCustomers reads every customer to order them by name; customer
+// lists are short next to the cars they buy.
+func (r *boltRepository) ListCustomers(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matched []entities.Customer
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCustomersBucket).ForEach(func(key, data []byte) error {
+			var customer entities.Customer
+			if err := json.Unmarshal(data, &customer); err != nil {
+				return fmt.Errorf("decoding stored customer: %w", err)
+			}
+			if matchesQuery(&customer, query) {
+				matched = append(matched, customer)
+			}
+			return ctx.Err()
+		})
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return lessByName(&matched[i], &matched[j]) })
+	return pageOf(matched, query), nil
+}
+
+func (r *boltRepository) GetCustomerByID(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var customer *entities.Customer
+	err = r.db.View(func(tx *bolt.Tx) error {
+		customer, err = getCustomer(tx, []byte(customerId))
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return customer, nil
+}
+
+func (r *boltRepository) UpdateCustomer(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var customer *entities.Customer
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		if customer, err = currentCustomer(tx, customerId, version); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltEmailIndex).Delete([]byte(foldKey(customer.Email))); err != nil {
+			return err
+		}
+		patch.Apply(customer)
+		customer.Version++
+		return putCustomer(tx, customer)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return customer, nil
+}
+
+func (r *boltRepository) DeleteCustomer(ctx context.Context, ID entities.CustomerID, version int64) error {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		customer, err := currentCustomer(tx, customerId, version)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltEmailIndex).Delete([]byte(foldKey(customer.Email))); err != nil {
+			return err
+		}
+		return tx.Bucket(boltCustomersBucket).Delete([]byte(customerId))
+	})
+	return boltError(err)
+}
+
+// currentCustomer reads the stored customer, provided they are at version
+// or version is 0.
+func currentCustomer(tx *bolt.Tx, customerId entities.CustomerID, version int64) (*entities.Customer, error) {
+	customer, err := getCustomer(tx, []byte(customerId))
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && customer.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	return customer, nil
+}
+
+// putCustomer stores customer and indexes their email, which no other
+// customer may have.
+func putCustomer(tx *bolt.Tx, customer *entities.Customer) error {
+	key := []byte(foldKey(customer.Email))
+	emails := tx.Bucket(boltEmailIndex)
+	if owner := emails.Get(key); owner != nil && string(owner) != string(customer.ID) {
+		return fmt.Errorf("%w: the email %s is taken", ErrConflict, customer.Email)
+	}
+
+	data, err := json.Marshal(customer)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltCustomersBucket).Put([]byte(customer.ID), data); err != nil {
+		return err
+	}
+	return emails.Put(key, []byte(customer.ID))
+}
+
+func getCustomer(tx *bolt.Tx, customerId []byte) (*entities.Customer, error) {
+	data := tx.Bucket(boltCustomersBucket).Get(customerId)
+	if data == nil {
+		return nil, ErrCustomerNotFound
+	}
+
+	var customer entities.Customer
+	if err := json.Unmarshal(data, &customer); err != nil {
+		return nil, fmt.Errorf("decoding stored customer: %w", err)
+	}
+	return &customer, nil
+}
+
+func boltError(err error) error {
+	if errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+	}
+	return err
+}
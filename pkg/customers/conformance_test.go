@@ -0,0 +1,50 @@
+package customers_test
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/customers/customerstest"
+	"testingfiber/pkg/internal/storetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepoConformance(t *testing.T) {
+	customerstest.RepositoryConformance(t, func(t *testing.T) customers.Repository {
+		return customers.NewMemoryRepo()
+	})
+}
+
+func TestBoltRepoConformance(t *testing.T) {
+	customerstest.RepositoryConformance(t, func(t *testing.T) customers.Repository {
+		repo, err := customers.NewBoltRepo(storetest.Bolt(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+// TestMongoRepoConformance runs against a real server when MONGO_TEST_URI
+// is set. Every subtest gets its own collection, dropped afterwards.
+func TestMongoRepoConformance(t *testing.T) {
+	db := storetest.MongoDatabase(t)
+	customerstest.RepositoryConformance(t, func(t *testing.T) customers.Repository {
+		collection := storetest.MongoCollection(t, db, "customers")
+		_, err := customers.NewMongoMigrator(collection).Up(context.Background())
+		require.NoError(t, err)
+		return customers.NewMongoRepo(collection)
+	})
+}
+
+// TestPostgresRepoConformance runs against a real server when
+// POSTGRES_TEST_URL is set. Every subtest gets its own schema, migrated
+// from scratch and dropped afterwards.
+func TestPostgresRepoConformance(t *testing.T) {
+	config := storetest.PostgresConfig(t)
+	customerstest.RepositoryConformance(t, func(t *testing.T) customers.Repository {
+		pool := storetest.PostgresPool(t, config, "customers")
+		_, err := customers.NewPostgresMigrator(pool).Up(context.Background())
+		require.NoError(t, err)
+		return customers.NewPostgresRepo(pool)
+	})
+}
@@ -0,0 +1,199 @@
+// Package customerstest provides a conformance suite that every
+// customers.Repository backend is expected to pass.
+package customerstest
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/storetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RepositoryFactory returns an empty repository for a single subtest.
+type RepositoryFactory = storetest.Factory[customers.Repository]
+
+// RepositoryConformance runs the shared behaviour checks against the
+// repositories produced by newRepo.
+func RepositoryConformance(t *testing.T, newRepo RepositoryFactory) {
+	storetest.Conformance(t, newRepo, []storetest.Case[customers.Repository]{
+		{Name: "InsertAssignsID", Run: testInsertAssignsID},
+		{Name: "UniqueEmail", Run: testUniqueEmail},
+		{Name: "GetMissingCustomer", Run: testGetMissingCustomer},
+		{Name: "GetInvalidID", Run: testGetInvalidID},
+		{Name: "ListOrderAndPaging", Run: testListOrderAndPaging},
+		{Name: "ListFilters", Run: testListFilters},
+		{Name: "Update", Run: testUpdate},
+		{Name: "UpdateTakenEmail", Run: testUpdateTakenEmail},
+		{Name: "UpdateStaleVersion", Run: testUpdateStaleVersion},
+		{Name: "UpdateMissingCustomer", Run: testUpdateMissingCustomer},
+		{Name: "Delete", Run: testDelete},
+		{Name: "DeleteStaleVersion", Run: testDeleteStaleVersion},
+		{Name: "DeleteMissingCustomer", Run: testDeleteMissingCustomer},
+	})
+}
+
+func insert(t *testing.T, repo customers.Repository, name, email string) *entities.Customer {
+	t.Helper()
+
+	customer, err := repo.InsertCustomer(context.Background(), &entities.Customer{Name: name, Email: email})
+	require.NoError(t, err)
+	return customer
+}
+
+func listedNames(t *testing.T, repo customers.Repository, query *entities.CustomerQuery) []string {
+	t.Helper()
+
+	page, err := repo.ListCustomers(context.Background(), query)
+	require.NoError(t, err)
+
+	return storetest.Names(page.Customers, func(customer entities.Customer) string { return customer.Name })
+}
+
+func testInsertAssignsID(t *testing.T, repo customers.Repository) {
+	customer, err := repo.InsertCustomer(context.Background(), &entities.Customer{
+		Name: "Ada Lovelace", Email: "ada@example.com", Phone: "+44 20 7946 0000",
+	})
+	require.NoError(t, err)
+
+	assert.False(t, customer.ID.IsZero())
+	assert.Equal(t, "Ada Lovelace", customer.Name)
+	assert.Equal(t, "ada@example.com", customer.Email)
+	assert.Equal(t, "+44 20 7946 0000", customer.Phone)
+	assert.Equal(t, int64(1), customer.Version)
+
+	got, err := repo.GetCustomerByID(context.Background(), customer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, customer, got)
+}
+
+func testUniqueEmail(t *testing.T, repo customers.Repository) {
+	insert(t, repo, "Ada", "ada@example.com")
+
+	_, err := repo.InsertCustomer(context.Background(), &entities.Customer{Name: "Other Ada", Email: "ADA@example.com"})
+	assert.ErrorIs(t, err, customers.ErrConflict)
+}
+
+func testGetMissingCustomer(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+	require.NoError(t, repo.DeleteCustomer(context.Background(), customer.ID, 0))
+
+	_, err := repo.GetCustomerByID(context.Background(), customer.ID)
+	assert.ErrorIs(t, err, customers.ErrCustomerNotFound)
+}
+
+func testGetInvalidID(t *testing.T, repo customers.Repository) {
+	_, err := repo.GetCustomerByID(context.Background(), "not-an-id")
+	assert.ErrorIs(t, err, customers.ErrInvalidID)
+}
+
+func testListOrderAndPaging(t *testing.T, repo customers.Repository) {
+	for _, name := range []string{"grace", "Ada", "linus", "Ken"} {
+		insert(t, repo, name, name+"@example.com")
+	}
+
+	assert.Equal(t, []string{"Ada", "grace", "Ken", "linus"}, listedNames(t, repo, &entities.CustomerQuery{}))
+
+	page, err := repo.ListCustomers(context.Background(), &entities.CustomerQuery{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), page.Total)
+	require.Len(t, page.Customers, 2)
+	assert.Equal(t, "grace", page.Customers[0].Name)
+	assert.Equal(t, "Ken", page.Customers[1].Name)
+
+	page, err = repo.ListCustomers(context.Background(), &entities.CustomerQuery{Offset: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), page.Total)
+	assert.Empty(t, page.Customers)
+}
+
+func testListFilters(t *testing.T, repo customers.Repository) {
+	insert(t, repo, "Ada Lovelace", "ada@example.com")
+	insert(t, repo, "Adam Smith", "adam@example.com")
+	insert(t, repo, "Grace Hopper", "grace@example.com")
+	insert(t, repo, "Ad_Agency", "agency@example.com")
+
+	assert.Equal(t, []string{"Adam Smith"}, listedNames(t, repo, &entities.CustomerQuery{NamePrefix: "adam"}))
+	assert.Equal(t, []string{"Ad_Agency", "Ada Lovelace", "Adam Smith"}, listedNames(t, repo, &entities.CustomerQuery{NamePrefix: "AD"}))
+	assert.Equal(t, []string{"Ad_Agency"}, listedNames(t, repo, &entities.CustomerQuery{NamePrefix: "ad_"}))
+	assert.Equal(t, []string{"Grace Hopper"}, listedNames(t, repo, &entities.CustomerQuery{Email: "Grace@Example.com"}))
+	assert.Empty(t, listedNames(t, repo, &entities.CustomerQuery{NamePrefix: "ada", Email: "grace@example.com"}))
+}
+
+func testUpdate(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+	email := "lovelace@example.com"
+
+	updated, err := repo.UpdateCustomer(context.Background(), customer.ID, customer.Version, &entities.CustomerPatch{Email: &email})
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", updated.Name)
+	assert.Equal(t, "lovelace@example.com", updated.Email)
+	assert.Equal(t, customer.Version+1, updated.Version)
+
+	got, err := repo.GetCustomerByID(context.Background(), customer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+
+	// The old email is free again.
+	insert(t, repo, "Other Ada", "ada@example.com")
+}
+
+func testUpdateTakenEmail(t *testing.T, repo customers.Repository) {
+	insert(t, repo, "Ada", "ada@example.com")
+	customer := insert(t, repo, "Grace", "grace@example.com")
+	email := "Ada@example.com"
+
+	_, err := repo.UpdateCustomer(context.Background(), customer.ID, 0, &entities.CustomerPatch{Email: &email})
+	assert.ErrorIs(t, err, customers.ErrConflict)
+
+	// Changing the case of a customer's own email is no conflict.
+	email = "GRACE@example.com"
+	updated, err := repo.UpdateCustomer(context.Background(), customer.ID, 0, &entities.CustomerPatch{Email: &email})
+	require.NoError(t, err)
+	assert.Equal(t, "GRACE@example.com", updated.Email)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+	phone := "555 0100"
+
+	_, err := repo.UpdateCustomer(context.Background(), customer.ID, customer.Version+1, &entities.CustomerPatch{Phone: &phone})
+	assert.ErrorIs(t, err, customers.ErrVersionMismatch)
+}
+
+func testUpdateMissingCustomer(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+	require.NoError(t, repo.DeleteCustomer(context.Background(), customer.ID, 0))
+	phone := "555 0100"
+
+	_, err := repo.UpdateCustomer(context.Background(), customer.ID, 0, &entities.CustomerPatch{Phone: &phone})
+	assert.ErrorIs(t, err, customers.ErrCustomerNotFound)
+}
+
+func testDelete(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+	require.NoError(t, repo.DeleteCustomer(context.Background(), customer.ID, customer.Version))
+
+	assert.Empty(t, listedNames(t, repo, &entities.CustomerQuery{}))
+
+	// The email is free again.
+	insert(t, repo, "Ada", "ada@example.com")
+}
+
+func testDeleteStaleVersion(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+
+	err := repo.DeleteCustomer(context.Background(), customer.ID, customer.Version+1)
+	assert.ErrorIs(t, err, customers.ErrVersionMismatch)
+}
+
+func testDeleteMissingCustomer(t *testing.T, repo customers.Repository) {
+	customer := insert(t, repo, "Ada", "ada@example.com")
+	require.NoError(t, repo.DeleteCustomer(context.Background(), customer.ID, 0))
+
+	err := repo.DeleteCustomer(context.Background(), customer.ID, 0)
+	assert.ErrorIs(t, err, customers.ErrCustomerNotFound)
+}
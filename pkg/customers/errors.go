@@ -0,0 +1,49 @@
+package customers
+
+import (
+	"errors"
+	"strings"
+	"testingfiber/pkg/validation"
+)
+
+var (
+	// ErrCustomerNotFound is returned when no customer has the requested
+	// ID.
+	ErrCustomerNotFound = errors.New("customer not found")
+	// ErrInvalidID is returned for IDs that cannot name any customer.
+	ErrInvalidID = errors.New("invalid customer id")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("customer validation failed")
+	// ErrVersionMismatch is returned when a write names a version of the
+	// customer that is no longer current.
+	ErrVersionMismatch = errors.New("customer version mismatch")
+	// ErrConflict is returned when a customer would take the email of
+	// another.
+	ErrConflict = errors.New("customer conflict")
+	// ErrCustomerInUse is returned when deleting a customer who has placed
+	// orders, which keep referencing them.
+	ErrCustomerInUse = errors.New("customer has orders")
+)
+
+// ValidationError carries every field error found in a customer payload.
+type ValidationError struct {
+	Fields []validation.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// FieldErrors returns the fields that failed, like
+// cars.ValidationError.FieldErrors.
+func (e *ValidationError) FieldErrors() []validation.FieldError {
+	return e.Fields
+}
@@ -0,0 +1,147 @@
+package customers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+)
+
+// memoryRepository keeps customers in process memory.
+type memoryRepository struct {
+	mu        sync.RWMutex
+	ids       entities.IDGenerator
+	customers map[entities.CustomerID]entities.Customer
+}
+
+func NewMemoryRepo(opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &memoryRepository{
+		ids:       o.IDs,
+		customers: make(map[entities.CustomerID]entities.Customer),
+	}
+}
+
+func (r *memoryRepository) InsertCustomer(ctx context.Context, customer *entities.Customer) (*entities.Customer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customer.ID = newCustomerID(r.ids)
+	if err := r.checkEmailLocked(customer); err != nil {
+		return nil, err
+	}
+	customer.Version = 1
+	r.customers[customer.ID] = *customer
+	return customer, nil
+}
+
+// checkEmailLocked rejects a customer whose email another customer already
+// has, like the unique indexes of the other backends.
+func (r *memoryRepository) checkEmailLocked(customer *entities.Customer) error {
+	for _, stored := range r.customers {
+		if foldKey(stored.Email) == foldKey(customer.Email) && stored.ID != customer.ID {
+			return fmt.Errorf("%w: the email %s is taken", ErrConflict, customer.Email)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) ListCustomers(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []entities.Customer
+	for _, customer := range r.customers {
+		if matchesQuery(&customer, query) {
+			matched = append(matched, customer)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return lessByName(&matched[i], &matched[j]) })
+	return pageOf(matched, query), nil
+}
+
+func (r *memoryRepository) GetCustomerByID(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	customer, ok := r.customers[customerId]
+	if !ok {
+		return nil, ErrCustomerNotFound
+	}
+	return &customer, nil
+}
+
+func (r *memoryRepository) UpdateCustomer(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customer, err := r.currentLocked(customerId, version)
+	if err != nil {
+		return nil, err
+	}
+	patch.Apply(&customer)
+	if err := r.checkEmailLocked(&customer); err != nil {
+		return nil, err
+	}
+	customer.Version++
+	r.customers[customerId] = customer
+	return &customer, nil
+}
+
+func (r *memoryRepository) DeleteCustomer(ctx context.Context, ID entities.CustomerID, version int64) error {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.currentLocked(customerId, version); err != nil {
+		return err
+	}
+	delete(r.customers, customerId)
+	return nil
+}
+
+// currentLocked returns the stored customer, provided they are at version
+// or version is 0.
+func (r *memoryRepository) currentLocked(customerId entities.CustomerID, version int64) (entities.Customer, error) {
+	customer, ok := r.customers[customerId]
+	if !ok {
+		return customer, ErrCustomerNotFound
+	}
+	if version != 0 && customer.Version != version {
+		return customer, ErrVersionMismatch
+	}
+	return customer, nil
+}
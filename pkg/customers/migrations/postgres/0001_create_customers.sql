@@ -0,0 +1,13 @@
+-- Emails are unique regardless of case. Databases migrated before the
+-- customers had migrations of their own already have the table, which the
+-- cars migrations used to create.
+CREATE TABLE IF NOT EXISTS customers (
+    id      text   PRIMARY KEY,
+    name    text   NOT NULL CHECK (name <> ''),
+    email   text   NOT NULL CHECK (email <> ''),
+    phone   text   NOT NULL DEFAULT '',
+    version bigint NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS customers_email_key ON customers (lower(email));
+CREATE INDEX IF NOT EXISTS customers_name_idx ON customers (lower(name) text_pattern_ops, id);
@@ -0,0 +1,40 @@
+package customers
+
+import (
+	"context"
+	"testingfiber/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoMigrator returns the migrator for the customers collection.
+func NewMongoMigrator(customers *mongo.Collection) migrate.Migrator {
+	return migrate.NewMongo(customers, mongoMigrations)
+}
+
+// mongoMigrations are applied in this order, which is also version order.
+var mongoMigrations = []migrate.MongoMigration{
+	{
+		// Emails are unique regardless of case, by way of their key;
+		// listings are ordered by the key of the name.
+		Version: "0001_customer_indexes",
+		Up: func(ctx context.Context, customers *mongo.Collection) error {
+			_, err := customers.Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "emailKey", Value: 1}},
+					Options: options.Index().SetName("emailKey_1").SetUnique(true),
+				},
+				{
+					Keys:    bson.D{{Key: "nameKey", Value: 1}, {Key: "_id", Value: 1}},
+					Options: options.Index().SetName("nameKey_1__id_1"),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, customers *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, customers, "emailKey_1", "nameKey_1__id_1")
+		},
+	},
+}
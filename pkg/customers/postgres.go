@@ -0,0 +1,220 @@
+package customers
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"testingfiber/pkg/migrate"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresColumns lists the columns of a customer in the order
+// scanCustomer reads them.
+const postgresColumns = "id, name, email, phone, version"
+
+// postgresRepository stores customers in the customers table.
+type postgresRepository struct {
+	pool *pgxpool.Pool
+	ids  entities.IDGenerator
+}
+
+// NewPostgresRepo returns a repository backed by pool. The schema must be
+// up to date, see NewPostgresMigrator.
+func NewPostgresRepo(pool *pgxpool.Pool, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &postgresRepository{pool: pool, ids: o.IDs}
+}
+
+// NewPostgresMigrator returns the migrator for the customers table of the
+// database behind pool.
+func NewPostgresMigrator(pool *pgxpool.Pool) migrate.Migrator {
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		panic(err)
+	}
+	return migrate.NewPostgres(pool, migrations)
+}
+
+func (r *postgresRepository) InsertCustomer(ctx context.Context, customer *entities.Customer) (*entities.Customer, error) {
+	row := r.pool.QueryRow(ctx,
+		"INSERT INTO customers (id, name, email, phone) VALUES ($1, $2, $3, $4) RETURNING "+postgresColumns,
+		newCustomerID(r.ids), customer.Name, customer.Email, customer.Phone)
+	return scanCustomer(row)
+}
+
+func (r *postgresRepository) ListCustomers(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if query.NamePrefix != "" {
+		add("lower(name) LIKE $%d", likePrefix(foldKey(query.NamePrefix)))
+	}
+	if query.Email != "" {
+		add("lower(email) = $%d", foldKey(query.Email))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT count(*) FROM customers"+where, args...).Scan(&total); err != nil {
+		return nil, postgresError(err)
+	}
+
+	// A NULL limit means no limit, matching a zero limit elsewhere.
+	var limit *int64
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	args = append(args, limit, query.Offset)
+	page := fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.pool.Query(ctx,
+		"SELECT "+postgresColumns+" FROM customers"+where+` ORDER BY lower(name) COLLATE "C", id COLLATE "C"`+page, args...)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	result := &entities.CustomerPage{Total: total}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Customers = append(result.Customers, *customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+	return result, nil
+}
+
+func likePrefix(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(prefix) + "%"
+}
+
+func (r *postgresRepository) GetCustomerByID(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCustomer(r.pool.QueryRow(ctx, "SELECT "+postgresColumns+" FROM customers WHERE id = $1", customerId))
+}
+
+func (r *postgresRepository) UpdateCustomer(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{customerId, version}
+	set := []string{"version = version + 1"}
+	assign := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.Name != nil {
+		assign("name", *patch.Name)
+	}
+	if patch.Email != nil {
+		assign("email", *patch.Email)
+	}
+	if patch.Phone != nil {
+		assign("phone", *patch.Phone)
+	}
+
+	customer, err := scanCustomer(r.pool.QueryRow(ctx,
+		"UPDATE customers SET "+strings.Join(set, ", ")+
+			" WHERE id = $1 AND ($2::bigint = 0 OR version = $2) RETURNING "+postgresColumns,
+		args...))
+	if errors.Is(err, ErrCustomerNotFound) {
+		return nil, r.missError(ctx, customerId)
+	}
+	return customer, err
+}
+
+func (r *postgresRepository) DeleteCustomer(ctx context.Context, ID entities.CustomerID, version int64) error {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM customers WHERE id = $1 AND ($2::bigint = 0 OR version = $2)", customerId, version)
+	if err != nil {
+		return postgresError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return r.missError(ctx, customerId)
+	}
+	return nil
+}
+
+// missError explains why a versioned write matched nothing: either the
+// customer is gone or they have moved on to another version.
+func (r *postgresRepository) missError(ctx context.Context, customerId entities.CustomerID) error {
+	var exists bool
+	if err := r.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)", customerId).Scan(&exists); err != nil {
+		return postgresError(err)
+	}
+	if !exists {
+		return ErrCustomerNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// scanCustomer reads a row of postgresColumns. A missing row is reported
+// as ErrCustomerNotFound.
+func scanCustomer(row pgx.Row) (*entities.Customer, error) {
+	var customer entities.Customer
+	err := row.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone, &customer.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	return &customer, nil
+}
+
+// postgresError classifies pgx errors like the cars repository does. The
+// foreign key from orders makes deleting a customer with orders fail.
+func postgresError(err error) error {
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return err
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == "23505":
+			return fmt.Errorf("%w: the email is taken: %w", ErrConflict, err)
+		case pgErr.Code == "23503":
+			return fmt.Errorf("%w: %w", ErrCustomerInUse, err)
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "53300", strings.HasPrefix(pgErr.Code, "57P"):
+			return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+		}
+	case errors.As(err, &netErr), pgconn.SafeToRetry(err):
+		return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,274 @@
+package customers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores customers. Emails are unique regardless of case; a
+// write that would take another customer's email fails with ErrConflict.
+// Every write bumps the customer's version; UpdateCustomer and
+// DeleteCustomer only apply when the stored version equals the version
+// given, or unconditionally when that version is 0.
+//
+// ListCustomers orders customers by name, regardless of case, and then by
+// ID. The name prefix and the email of a query also match regardless of
+// case.
+type Repository interface {
+	InsertCustomer(ctx context.Context, customer *entities.Customer) (*entities.Customer, error)
+	ListCustomers(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error)
+	GetCustomerByID(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error)
+	UpdateCustomer(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error)
+	DeleteCustomer(ctx context.Context, ID entities.CustomerID, version int64) error
+}
+
+// RepositoryOption customises the repositories built by NewMongoRepo,
+// NewMemoryRepo, NewPostgresRepo and NewBoltRepo.
+type RepositoryOption = store.Option
+
+// WithIDGenerator sets how new customers are identified, like
+// cars.WithIDGenerator does for cars.
+func WithIDGenerator(ids entities.IDGenerator) RepositoryOption {
+	return store.WithIDGenerator(ids)
+}
+
+// foldKey is the form of a name or an email that matching and ordering go
+// by.
+func foldKey(s string) string {
+	return strings.ToLower(s)
+}
+
+func newCustomerID(ids entities.IDGenerator) entities.CustomerID {
+	return entities.CustomerID(ids.NewID())
+}
+
+func parseID(ID entities.CustomerID) (entities.CustomerID, error) {
+	return store.ParseID(ID, entities.ParseCustomerID, ErrInvalidID)
+}
+
+// pageOf cuts the page that query asks for out of the matching customers.
+func pageOf(matched []entities.Customer, query *entities.CustomerQuery) *entities.CustomerPage {
+	total := int64(len(matched))
+	start := query.Offset
+	if start > total {
+		start = total
+	}
+	end := start + query.Limit
+	if query.Limit <= 0 || end > total {
+		end = total
+	}
+
+	var customers []entities.Customer
+	customers = append(customers, matched[start:end]...)
+	return &entities.CustomerPage{Customers: customers, Total: total}
+}
+
+func matchesQuery(customer *entities.Customer, query *entities.CustomerQuery) bool {
+	if query.Email != "" && foldKey(customer.Email) != foldKey(query.Email) {
+		return false
+	}
+	return strings.HasPrefix(foldKey(customer.Name), foldKey(query.NamePrefix))
+}
+
+// lessByName orders customers by name, regardless of case, and then by ID.
+func lessByName(a, b *entities.Customer) bool {
+	if ka, kb := foldKey(a.Name), foldKey(b.Name); ka != kb {
+		return ka < kb
+	}
+	return a.ID < b.ID
+}
+
+type mongoRepository struct {
+	collection *mongo.Collection
+	ids        entities.IDGenerator
+}
+
+// customerDocument is the stored form of a customer. NameKey orders
+// listings and EmailKey backs the unique index on emails.
+type customerDocument struct {
+	ID       entities.CustomerID `bson:"_id"`
+	Name     string              `bson:"name"`
+	NameKey  string              `bson:"nameKey"`
+	Email    string              `bson:"email"`
+	EmailKey string              `bson:"emailKey"`
+	Phone    string              `bson:"phone,omitempty"`
+	Version  int64               `bson:"version"`
+}
+
+func newCustomerDocument(customer *entities.Customer) *customerDocument {
+	return &customerDocument{
+		ID:       customer.ID,
+		Name:     customer.Name,
+		NameKey:  foldKey(customer.Name),
+		Email:    customer.Email,
+		EmailKey: foldKey(customer.Email),
+		Phone:    customer.Phone,
+		Version:  customer.Version,
+	}
+}
+
+func (d *customerDocument) customer() *entities.Customer {
+	return &entities.Customer{ID: d.ID, Name: d.Name, Email: d.Email, Phone: d.Phone, Version: d.Version}
+}
+
+// NewMongoRepo returns a repository keeping customers in collection.
+// Emails are only unique once the indexes of NewMongoMigrator exist.
+func NewMongoRepo(collection *mongo.Collection, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &mongoRepository{collection: collection, ids: o.IDs}
+}
+
+func (r *mongoRepository) InsertCustomer(ctx context.Context, customer *entities.Customer) (*entities.Customer, error) {
+	customer.ID = newCustomerID(r.ids)
+	customer.Version = 1
+	if _, err := r.collection.InsertOne(ctx, newCustomerDocument(customer)); err != nil {
+		return nil, mongoError(err)
+	}
+	return customer, nil
+}
+
+func (r *mongoRepository) ListCustomers(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error) {
+	filter := bson.M{}
+	if query.NamePrefix != "" {
+		filter["nameKey"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(foldKey(query.NamePrefix))}
+	}
+	if query.Email != "" {
+		filter["emailKey"] = foldKey(query.Email)
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "nameKey", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(query.Offset)
+	if query.Limit > 0 {
+		findOptions.SetLimit(query.Limit)
+	}
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var documents []customerDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, mongoError(err)
+	}
+
+	page := &entities.CustomerPage{Total: total}
+	for i := range documents {
+		page.Customers = append(page.Customers, *documents[i].customer())
+	}
+	return page, nil
+}
+
+func (r *mongoRepository) GetCustomerByID(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var document customerDocument
+	err = r.collection.FindOne(ctx, bson.M{"_id": customerId}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return document.customer(), nil
+}
+
+func (r *mongoRepository) UpdateCustomer(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error) {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	if patch.Name != nil {
+		set["name"] = *patch.Name
+		set["nameKey"] = foldKey(*patch.Name)
+	}
+	if patch.Email != nil {
+		set["email"] = *patch.Email
+		set["emailKey"] = foldKey(*patch.Email)
+	}
+	if patch.Phone != nil {
+		set["phone"] = *patch.Phone
+	}
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	var document customerDocument
+	err = r.collection.FindOneAndUpdate(ctx, versionFilter(customerId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, r.missError(ctx, customerId)
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return document.customer(), nil
+}
+
+func (r *mongoRepository) DeleteCustomer(ctx context.Context, ID entities.CustomerID, version int64) error {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.DeleteOne(ctx, versionFilter(customerId, version))
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return r.missError(ctx, customerId)
+	}
+	return nil
+}
+
+// versionFilter matches the customer with the given ID, and with the
+// given version unless it is 0.
+func versionFilter(customerId entities.CustomerID, version int64) bson.M {
+	filter := bson.M{"_id": customerId}
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missError explains why a versioned write matched nothing: either the
+// customer is gone or they have moved on to another version.
+func (r *mongoRepository) missError(ctx context.Context, customerId entities.CustomerID) error {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": customerId})
+	if err != nil {
+		return mongoError(err)
+	}
+	if count == 0 {
+		return ErrCustomerNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// mongoError classifies driver errors like the cars repository does; the
+// only unique key is the email.
+var mongoError = store.MongoErrors{
+	Conflict:    fmt.Errorf("%w: the email is taken", ErrConflict),
+	Unavailable: cars.ErrUnavailable,
+}.Classify
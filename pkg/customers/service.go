@@ -0,0 +1,116 @@
+package customers
+
+import (
+	"context"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+)
+
+// OrderHistory lists the orders customers have placed. The orders
+// repository provides it; it is declared here so that customers does not
+// depend on orders.
+type OrderHistory interface {
+	ListOrders(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error)
+}
+
+type Service interface {
+	InsertCustomerService(ctx context.Context, customer *entities.Customer) (*entities.Customer, error)
+	ListCustomersService(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error)
+	GetCustomerByIDService(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error)
+	UpdateCustomerService(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error)
+	RemoveCustomerService(ctx context.Context, ID entities.CustomerID, version int64) error
+}
+
+type service struct {
+	repository Repository
+	orders     OrderHistory
+	validator  *validation.Validator
+}
+
+// NewService returns the service for the customers of r. A customer who
+// has orders in orders cannot be removed, so that no order loses its
+// buyer.
+func NewService(r Repository, orders OrderHistory) Service {
+	return &service{
+		repository: r,
+		orders:     orders,
+		validator:  validation.New(time.Now, nil),
+	}
+}
+
+func (s *service) InsertCustomerService(ctx context.Context, customer *entities.Customer) (*entities.Customer, error) {
+	if err := s.validate(customer); err != nil {
+		return nil, err
+	}
+
+	return s.repository.InsertCustomer(ctx, customer)
+}
+
+func (s *service) ListCustomersService(ctx context.Context, query *entities.CustomerQuery) (*entities.CustomerPage, error) {
+	cars.ClampPage(&query.Limit, &query.Offset)
+
+	page, err := s.repository.ListCustomers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Limit = query.Limit
+	page.Offset = query.Offset
+	return page, nil
+}
+
+func (s *service) GetCustomerByIDService(ctx context.Context, ID entities.CustomerID) (*entities.Customer, error) {
+	return s.repository.GetCustomerByID(ctx, ID)
+}
+
+// UpdateCustomerService changes the supplied fields of a customer. The
+// patch is validated against the customer it will produce.
+func (s *service) UpdateCustomerService(ctx context.Context, ID entities.CustomerID, version int64, patch *entities.CustomerPatch) (*entities.Customer, error) {
+	current, err := s.repository.GetCustomerByID(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	candidate := *current
+	patch.Apply(&candidate)
+	if err := s.validate(&candidate); err != nil {
+		return nil, err
+	}
+
+	return s.repository.UpdateCustomer(ctx, ID, version, patch)
+}
+
+// validate returns a *ValidationError listing the fields of customer that
+// break their rules.
+func (s *service) validate(customer *entities.Customer) error {
+	if fields := s.validator.Validate(customer); len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// RemoveCustomerService deletes a customer who has placed no orders, and
+// fails with ErrCustomerInUse otherwise. Stores with foreign keys also
+// catch an order being placed while the customer is being removed.
+func (s *service) RemoveCustomerService(ctx context.Context, ID entities.CustomerID, version int64) error {
+	customerId, err := parseID(ID)
+	if err != nil {
+		return err
+	}
+
+	page, err := s.orders.ListOrders(ctx, &entities.OrderQuery{CustomerID: customerId, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if page.Total > 0 {
+		return ErrCustomerInUse
+	}
+
+	return s.repository.DeleteCustomer(ctx, customerId, version)
+}
@@ -0,0 +1,93 @@
+package customers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderHistory is an OrderHistory holding fixed orders.
+type orderHistory []entities.Order
+
+func (h orderHistory) ListOrders(_ context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	page := &entities.OrderPage{}
+	for _, order := range h {
+		if query.CustomerID.IsZero() || order.CustomerID == query.CustomerID {
+			page.Orders = append(page.Orders, order)
+			page.Total++
+		}
+	}
+	return page, nil
+}
+
+func TestInsertCustomerServiceValidation(t *testing.T) {
+	service := NewService(NewMemoryRepo(), orderHistory(nil))
+
+	_, err := service.InsertCustomerService(context.Background(), &entities.Customer{Name: "Ada", Email: "ada.example.com"})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "email", validationErr.Fields[0].Field)
+
+	customer, err := service.InsertCustomerService(context.Background(), &entities.Customer{Name: "Ada", Email: "ada@example.com"})
+	require.NoError(t, err)
+	assert.False(t, customer.ID.IsZero())
+}
+
+func TestListCustomersServiceClampsLimit(t *testing.T) {
+	service := NewService(NewMemoryRepo(), orderHistory(nil))
+
+	page, err := service.ListCustomersService(context.Background(), &entities.CustomerQuery{Limit: 1000, Offset: -1})
+	require.NoError(t, err)
+	assert.Equal(t, cars.MaxListLimit, page.Limit)
+	assert.Zero(t, page.Offset)
+
+	page, err = service.ListCustomersService(context.Background(), &entities.CustomerQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, cars.DefaultListLimit, page.Limit)
+}
+
+func TestUpdateCustomerServiceValidatesResult(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepo()
+	service := NewService(repo, orderHistory(nil))
+
+	customer, err := repo.InsertCustomer(ctx, &entities.Customer{Name: "Ada", Email: "ada@example.com"})
+	require.NoError(t, err)
+
+	phone := "call me"
+	_, err = service.UpdateCustomerService(ctx, customer.ID, 0, &entities.CustomerPatch{Phone: &phone})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "phone", validationErr.Fields[0].Field)
+
+	phone = "+1 (555) 010-0000"
+	_, err = service.UpdateCustomerService(ctx, customer.ID, customer.Version+1, &entities.CustomerPatch{Phone: &phone})
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+
+	updated, err := service.UpdateCustomerService(ctx, customer.ID, customer.Version, &entities.CustomerPatch{Phone: &phone})
+	require.NoError(t, err)
+	assert.Equal(t, phone, updated.Phone)
+}
+
+func TestRemoveCustomerServiceInUse(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepo()
+
+	ada, err := repo.InsertCustomer(ctx, &entities.Customer{Name: "Ada", Email: "ada@example.com"})
+	require.NoError(t, err)
+	grace, err := repo.InsertCustomer(ctx, &entities.Customer{Name: "Grace", Email: "grace@example.com"})
+	require.NoError(t, err)
+	service := NewService(repo, orderHistory{{ID: "o1", CustomerID: ada.ID}})
+
+	assert.ErrorIs(t, service.RemoveCustomerService(ctx, ada.ID, 0), ErrCustomerInUse)
+	require.NoError(t, service.RemoveCustomerService(ctx, grace.ID, grace.Version))
+
+	_, err = repo.GetCustomerByID(ctx, grace.ID)
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
+	assert.ErrorIs(t, service.RemoveCustomerService(ctx, "not-an-id", 0), ErrInvalidID)
+}
@@ -0,0 +1,290 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CarID identifies a car. Valid IDs are in one of the formats an
+// IDGenerator produces: a 24 digit hex ObjectID, a canonical UUIDv7 or a
+// 26 character ULID. The formats are told apart by their shape, so an ID
+// can be validated without knowing which generator made it. All of them
+// sort by creation time.
+//
+// CarID marshals to JSON as a plain string and rejects malformed IDs when
+// unmarshalled. Being a string, it is stored as one by BSON and SQL
+// drivers; repositories that prefer a native type convert it themselves.
+type CarID string
+
+var errInvalidID = errors.New("not an ObjectID, UUIDv7 or ULID")
+
+// ParseCarID validates s and returns it in canonical form: lowercase hex
+// for ObjectIDs and UUIDs, uppercase for ULIDs.
+func ParseCarID(s string) (CarID, error) {
+	id, ok := canonicalID(s)
+	if !ok {
+		return "", fmt.Errorf("car ID %q: %w", s, errInvalidID)
+	}
+	return CarID(id), nil
+}
+
+// canonicalID reports whether s is an ID in one of the generated formats
+// and returns it in canonical form.
+func canonicalID(s string) (string, bool) {
+	switch len(s) {
+	case 24:
+		if _, err := hex.DecodeString(s); err == nil {
+			return strings.ToLower(s), true
+		}
+	case 36:
+		if isUUIDv7(s) {
+			return strings.ToLower(s), true
+		}
+	case 26:
+		if isULID(s) {
+			return strings.ToUpper(s), true
+		}
+	}
+	return "", false
+}
+
+func (id CarID) String() string {
+	return string(id)
+}
+
+func (id CarID) IsZero() bool {
+	return id == ""
+}
+
+// ObjectID returns the 12 bytes of an ObjectID-format ID, for storage as a
+// native ObjectID. It reports false for the other formats.
+func (id CarID) ObjectID() ([12]byte, bool) {
+	var raw [12]byte
+	if len(id) != 24 {
+		return raw, false
+	}
+	if _, err := hex.Decode(raw[:], []byte(id)); err != nil {
+		return raw, false
+	}
+	return raw, true
+}
+
+func (id CarID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+// UnmarshalText accepts a valid ID, or an empty one for bodies that do not
+// name a car.
+func (id *CarID) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*id = ""
+		return nil
+	}
+	parsed, err := ParseCarID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+func isUUIDv7(s string) bool {
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(r) {
+				return false
+			}
+		}
+	}
+	// The version nibble is 7 and the variant bits are 10.
+	return s[14] == '7' && strings.ContainsRune("89abAB", rune(s[19]))
+}
+
+func isHexDigit(r rune) bool {
+	return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func isULID(s string) bool {
+	upper := strings.ToUpper(s)
+	// 26 characters hold 130 bits; the top two must be clear.
+	if upper[0] > '7' {
+		return false
+	}
+	for _, r := range upper {
+		if !strings.ContainsRune(crockford, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// IDGenerator creates IDs for new records of the shop, which all take the
+// formats of a CarID; each repository converts them to its own ID type.
+// Implementations are safe for concurrent use and produce increasing IDs
+// within a process, so listing by ID keeps insertion order.
+type IDGenerator interface {
+	NewID() string
+}
+
+// ID formats accepted by NewIDGenerator.
+const (
+	IDFormatObjectID = "objectid"
+	IDFormatUUIDv7   = "uuidv7"
+	IDFormatULID     = "ulid"
+)
+
+// NewIDGenerator returns the generator for the named format.
+func NewIDGenerator(format string) (IDGenerator, error) {
+	switch format {
+	case IDFormatObjectID:
+		return NewObjectIDGenerator(), nil
+	case IDFormatUUIDv7:
+		return &uuidV7Generator{}, nil
+	case IDFormatULID:
+		return &ulidGenerator{}, nil
+	}
+	return nil, fmt.Errorf("unknown ID format %q", format)
+}
+
+// objectIDGenerator lays out IDs like MongoDB ObjectIDs: a 4 byte
+// timestamp in seconds, 5 random bytes fixed for the process and a 3 byte
+// counter.
+type objectIDGenerator struct {
+	process [5]byte
+	counter uint32
+}
+
+func NewObjectIDGenerator() IDGenerator {
+	g := &objectIDGenerator{}
+	random(g.process[:])
+	var seed [4]byte
+	random(seed[:])
+	g.counter = binary.BigEndian.Uint32(seed[:]) & 0xffffff
+	return g
+}
+
+func (g *objectIDGenerator) NewID() string {
+	var raw [12]byte
+	binary.BigEndian.PutUint32(raw[0:4], uint32(time.Now().Unix()))
+	copy(raw[4:9], g.process[:])
+	count := atomic.AddUint32(&g.counter, 1)
+	raw[9], raw[10], raw[11] = byte(count>>16), byte(count>>8), byte(count)
+	return hex.EncodeToString(raw[:])
+}
+
+// uuidV7Generator makes RFC 9562 version 7 UUIDs. The 12 bits after the
+// millisecond timestamp are a counter, so IDs made in the same millisecond
+// still increase.
+type uuidV7Generator struct {
+	mu     sync.Mutex
+	lastMs int64
+	seq    uint16
+}
+
+func (g *uuidV7Generator) NewID() string {
+	var raw [16]byte
+	random(raw[8:])
+
+	g.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms > g.lastMs {
+		g.lastMs = ms
+		// Start low in the counter space to leave room for increments.
+		var seed [2]byte
+		random(seed[:])
+		g.seq = binary.BigEndian.Uint16(seed[:]) & 0x7ff
+	} else if g.seq++; g.seq > 0xfff {
+		// The counter overflowed or the clock went back: borrow the
+		// next millisecond.
+		g.lastMs++
+		g.seq = 0
+	}
+	ms, seq := g.lastMs, g.seq
+	g.mu.Unlock()
+
+	putUint48(raw[0:6], ms)
+	binary.BigEndian.PutUint16(raw[6:8], 0x7000|seq)
+	raw[8] = raw[8]&0x3f | 0x80
+
+	s := hex.EncodeToString(raw[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// ulidGenerator makes monotonic ULIDs: within a millisecond the 80 random
+// bits of the previous ID are incremented instead of drawn again.
+type ulidGenerator struct {
+	mu      sync.Mutex
+	lastMs  int64
+	entropy [10]byte
+}
+
+func (g *ulidGenerator) NewID() string {
+	var raw [16]byte
+
+	g.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms > g.lastMs {
+		g.lastMs = ms
+		random(g.entropy[:])
+	} else if !increment(g.entropy[:]) {
+		g.lastMs++
+		random(g.entropy[:])
+	}
+	putUint48(raw[0:6], g.lastMs)
+	copy(raw[6:], g.entropy[:])
+	g.mu.Unlock()
+
+	return encodeCrockford(raw)
+}
+
+// increment adds one to the big-endian number in b and reports false when
+// it wraps around.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i]++; b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCrockford writes the 128 bits of raw as 26 base32 characters, the
+// first of which carries only three bits.
+func encodeCrockford(raw [16]byte) string {
+	hi := binary.BigEndian.Uint64(raw[0:8])
+	lo := binary.BigEndian.Uint64(raw[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+func putUint48(b []byte, v int64) {
+	b[0], b[1], b[2] = byte(v>>40), byte(v>>32), byte(v>>24)
+	b[3], b[4], b[5] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+func random(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("entities: reading random bytes: %v", err))
+	}
+}
@@ -0,0 +1,92 @@
+package entities
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCarID(t *testing.T) {
+	valid := map[string]CarID{
+		"64A4C6181955B6923FFF02B5":             "64a4c6181955b6923fff02b5",
+		"01890A5D-AC96-774B-BCCE-B302099A8057": "01890a5d-ac96-774b-bcce-b302099a8057",
+		"01h455vb4pex5vsknk084sn02q":           "01H455VB4PEX5VSKNK084SN02Q",
+	}
+	for input, expected := range valid {
+		id, err := ParseCarID(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, id)
+	}
+
+	invalid := []string{
+		"",
+		"42",
+		"64a4c6181955b6923fff02bz",
+		// A version 4 UUID.
+		"f47ac10b-58cc-4372-a567-0e02b2c3d479",
+		// Overflows 128 bits.
+		"81H455VB4PEX5VSKNK084SN02Q",
+		// U is not in the Crockford alphabet.
+		"01H455VB4PEX5VSKNK084SN02U",
+	}
+	for _, input := range invalid {
+		_, err := ParseCarID(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestCarIDObjectID(t *testing.T) {
+	raw, ok := CarID("64a4c6181955b6923fff02b5").ObjectID()
+	assert.True(t, ok)
+	assert.Equal(t, byte(0x64), raw[0])
+	assert.Equal(t, byte(0xb5), raw[11])
+
+	_, ok = CarID("01H455VB4PEX5VSKNK084SN02Q").ObjectID()
+	assert.False(t, ok)
+}
+
+func TestCarIDJSON(t *testing.T) {
+	var body struct {
+		ID CarID `json:"id"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"64A4C6181955B6923FFF02B5"}`), &body))
+	assert.Equal(t, CarID("64a4c6181955b6923fff02b5"), body.ID)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"id":""}`), &body))
+	assert.True(t, body.ID.IsZero())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"nope"}`), &body))
+
+	encoded, err := json.Marshal(Car{ID: "64a4c6181955b6923fff02b5"})
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"id":"64a4c6181955b6923fff02b5"`)
+}
+
+func TestIDGenerators(t *testing.T) {
+	for _, format := range []string{IDFormatObjectID, IDFormatUUIDv7, IDFormatULID} {
+		t.Run(format, func(t *testing.T) {
+			generator, err := NewIDGenerator(format)
+			require.NoError(t, err)
+
+			ids := make([]string, 1000)
+			seen := map[string]bool{}
+			for i := range ids {
+				id := generator.NewID()
+				parsed, err := ParseCarID(id)
+				require.NoError(t, err)
+				require.Equal(t, CarID(id), parsed, "generated IDs are canonical")
+				require.False(t, seen[id], "duplicate ID %s", id)
+				seen[id] = true
+				ids[i] = id
+			}
+			assert.True(t, sort.StringsAreSorted(ids), "IDs increase")
+		})
+	}
+
+	_, err := NewIDGenerator("serial")
+	assert.Error(t, err)
+}
@@ -1,19 +1,229 @@
 package entities
 
-import (
-	"time"
+import "time"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
+// Car is a car of the shop. The ID is assigned by the repository that
+// stores the car.
+//
+// CompanyID references the company that made the car. Company repeats its
+// name, so that cars can be filtered and searched by it.
+//
+// The VIN is optional but, when given, unique across the shop. ListPrice
+// is in the minor unit of Currency, such as cents for USD. Status,
+// ReservedAt and SoldAt follow the sale lifecycle and only change through
+// its transitions.
+type Car struct {
+	ID         CarID           `json:"id"`
+	VIN        string          `json:"vin" validate:"vin"`
+	CarName    string          `json:"carName" validate:"required,max=64,pattern=name"`
+	CompanyID  CompanyID       `json:"companyId"`
+	Company    string          `json:"company" validate:"required,max=64,pattern=name,company"`
+	ModelYear  int             `json:"modelYear" validate:"modelyear"`
+	Trim       string          `json:"trim" validate:"max=64,pattern=name"`
+	Color      string          `json:"color" validate:"max=32,pattern=name"`
+	Mileage    int64           `json:"mileage" validate:"gte=0"`
+	ListPrice  int64           `json:"listPrice" validate:"gte=0,listprice"`
+	Currency   string          `json:"currency" validate:"requiredwith=listPrice,pattern=currency"`
+	Condition  Condition       `json:"condition" validate:"oneof=new used certified"`
+	Status     InventoryStatus `json:"status" validate:"oneof=in_stock reserved sold returned archived"`
+	MadeAt     time.Time       `json:"madeAt" validate:"past"`
+	ReservedAt time.Time       `json:"reservedAt" validate:"after=madeAt"`
+	SoldAt     time.Time       `json:"soldAt" validate:"after=madeAt"`
+	Version    int64           `json:"version"`
+}
+
+// Condition is the state a car is offered in.
+type Condition string
+
+const (
+	ConditionNew       Condition = "new"
+	ConditionUsed      Condition = "used"
+	ConditionCertified Condition = "certified"
 )
 
-type Car struct {
-	ID      primitive.ObjectID `json:"id"  bson:"_id,omitempty"`
-	CarName string             `json:"carName" bson:"carName"`
-	Company string             `json:"company" bson:"company"`
-	MadeAt  time.Time          `json:"madeAt" bson:"madeAt,omitempty"`
-	SoldAt  time.Time          `json:"soldAt" bson:"soldAt,omitempty"`
+// InventoryStatus tells where a car stands in its sale lifecycle: cars
+// come in stock, may be reserved, are sold and may be returned, and are
+// archived once the shop no longer offers them.
+type InventoryStatus string
+
+const (
+	InStock  InventoryStatus = "in_stock"
+	Reserved InventoryStatus = "reserved"
+	Sold     InventoryStatus = "sold"
+	Returned InventoryStatus = "returned"
+	Archived InventoryStatus = "archived"
+)
+
+// CarPatch is a partial update of a car: only the non-nil fields are
+// changed.
+type CarPatch struct {
+	VIN        *string          `json:"vin,omitempty" bson:"vin,omitempty"`
+	CarName    *string          `json:"carName,omitempty" bson:"carName,omitempty"`
+	CompanyID  *CompanyID       `json:"companyId,omitempty" bson:"companyId,omitempty"`
+	Company    *string          `json:"company,omitempty" bson:"company,omitempty"`
+	ModelYear  *int             `json:"modelYear,omitempty" bson:"modelYear,omitempty"`
+	Trim       *string          `json:"trim,omitempty" bson:"trim,omitempty"`
+	Color      *string          `json:"color,omitempty" bson:"color,omitempty"`
+	Mileage    *int64           `json:"mileage,omitempty" bson:"mileage,omitempty"`
+	ListPrice  *int64           `json:"listPrice,omitempty" bson:"listPrice,omitempty"`
+	Currency   *string          `json:"currency,omitempty" bson:"currency,omitempty"`
+	Condition  *Condition       `json:"condition,omitempty" bson:"condition,omitempty"`
+	Status     *InventoryStatus `json:"status,omitempty" bson:"status,omitempty"`
+	MadeAt     *time.Time       `json:"madeAt,omitempty" bson:"madeAt,omitempty"`
+	ReservedAt *time.Time       `json:"reservedAt,omitempty" bson:"reservedAt,omitempty"`
+	SoldAt     *time.Time       `json:"soldAt,omitempty" bson:"soldAt,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p *CarPatch) IsEmpty() bool {
+	return *p == CarPatch{}
+}
+
+// Apply copies the supplied fields of the patch onto car.
+func (p *CarPatch) Apply(car *Car) {
+	if p.VIN != nil {
+		car.VIN = *p.VIN
+	}
+	if p.CarName != nil {
+		car.CarName = *p.CarName
+	}
+	if p.CompanyID != nil {
+		car.CompanyID = *p.CompanyID
+	}
+	if p.Company != nil {
+		car.Company = *p.Company
+	}
+	if p.ModelYear != nil {
+		car.ModelYear = *p.ModelYear
+	}
+	if p.Trim != nil {
+		car.Trim = *p.Trim
+	}
+	if p.Color != nil {
+		car.Color = *p.Color
+	}
+	if p.Mileage != nil {
+		car.Mileage = *p.Mileage
+	}
+	if p.ListPrice != nil {
+		car.ListPrice = *p.ListPrice
+	}
+	if p.Currency != nil {
+		car.Currency = *p.Currency
+	}
+	if p.Condition != nil {
+		car.Condition = *p.Condition
+	}
+	if p.Status != nil {
+		car.Status = *p.Status
+	}
+	if p.MadeAt != nil {
+		car.MadeAt = *p.MadeAt
+	}
+	if p.ReservedAt != nil {
+		car.ReservedAt = *p.ReservedAt
+	}
+	if p.SoldAt != nil {
+		car.SoldAt = *p.SoldAt
+	}
+}
+
+// UpdateRequest is the body of PUT /cars: the car to change and the
+// fields to change on it.
+type UpdateRequest struct {
+	ID CarID `json:"id"`
+	CarPatch
 }
 
 type DeleteRequest struct {
-	ID string `json:"id"`
+	ID CarID `json:"id"`
+}
+
+// CarUpdate is one item of a batch update: the car to change, the version
+// it must be at (0 for any) and the fields to change.
+type CarUpdate struct {
+	ID      CarID `json:"id"`
+	Version int64 `json:"version"`
+	CarPatch
+}
+
+// CarDelete is one item of a batch delete.
+type CarDelete struct {
+	ID      CarID `json:"id"`
+	Version int64 `json:"version"`
+}
+
+// InsertBatchRequest is the body of POST /cars:batchCreate.
+type InsertBatchRequest struct {
+	Atomic bool  `json:"atomic"`
+	Items  []Car `json:"items"`
+}
+
+// UpdateBatchRequest is the body of POST /cars:batchUpdate.
+type UpdateBatchRequest struct {
+	Atomic bool        `json:"atomic"`
+	Items  []CarUpdate `json:"items"`
+}
+
+// DeleteBatchRequest is the body of POST /cars:batchDelete.
+type DeleteBatchRequest struct {
+	Atomic bool        `json:"atomic"`
+	Items  []CarDelete `json:"items"`
+}
+
+// CarQuery narrows, orders and pages a car listing. Zero values mean "no
+// constraint" for the filters. EmbedCompany asks for the companies of the
+// listed cars along with them.
+type CarQuery struct {
+	Limit         int64
+	Offset        int64
+	CompanyID     CompanyID
+	Company       string
+	CarNamePrefix string
+	Status        InventoryStatus
+	MadeFrom      time.Time
+	MadeTo        time.Time
+	SoldFrom      time.Time
+	SoldTo        time.Time
+	SortBy        string
+	Descending    bool
+	EmbedCompany  bool
+}
+
+// CarPage is one page of a car listing. Total counts every car matching
+// the query, not just the ones on this page. Companies holds the companies
+// of the listed cars when the query embeds them.
+type CarPage struct {
+	Cars       []Car
+	Total      int64
+	Limit      int64
+	Offset     int64
+	NextCursor string
+	Companies  map[CompanyID]Company
+}
+
+// CarSearch is a free-text search over the names and companies of cars.
+type CarSearch struct {
+	Query  string
+	Limit  int64
+	Offset int64
+}
+
+// SearchHit is a car found by a search. Score ranks it against the other
+// hits; Highlights maps each field that matched to its text with the
+// matching words marked.
+type SearchHit struct {
+	Car        Car
+	Score      float64
+	Highlights map[string]string
+}
+
+// SearchPage is one page of search hits, best first. Total counts every
+// hit, not just the ones on this page.
+type SearchPage struct {
+	Hits       []SearchHit
+	Total      int64
+	Limit      int64
+	Offset     int64
+	NextCursor string
 }
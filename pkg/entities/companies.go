@@ -0,0 +1,94 @@
+package entities
+
+import "fmt"
+
+// CompanyID identifies a company. It takes the same formats as a CarID,
+// from the same IDGenerator.
+type CompanyID string
+
+// ParseCompanyID validates s and returns it in canonical form, like
+// ParseCarID.
+func ParseCompanyID(s string) (CompanyID, error) {
+	id, ok := canonicalID(s)
+	if !ok {
+		return "", fmt.Errorf("company ID %q: %w", s, errInvalidID)
+	}
+	return CompanyID(id), nil
+}
+
+func (id CompanyID) String() string {
+	return string(id)
+}
+
+func (id CompanyID) IsZero() bool {
+	return id == ""
+}
+
+func (id CompanyID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+// UnmarshalText accepts a valid ID, or an empty one for bodies that do not
+// name a company.
+func (id *CompanyID) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*id = ""
+		return nil
+	}
+	parsed, err := ParseCompanyID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Company is a maker of cars. Names are unique regardless of case, so
+// "Toyota" and "TOYOTA" cannot both exist; Country is an ISO 3166-1
+// alpha-2 code.
+type Company struct {
+	ID      CompanyID `json:"id"`
+	Name    string    `json:"name" validate:"required,max=64,pattern=name"`
+	Country string    `json:"country" validate:"pattern=country"`
+	Version int64     `json:"version"`
+}
+
+// CompanyPatch is a partial update of a company: only the non-nil fields
+// are changed.
+type CompanyPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Country *string `json:"country,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p *CompanyPatch) IsEmpty() bool {
+	return *p == CompanyPatch{}
+}
+
+// Apply copies the supplied fields of the patch onto company.
+func (p *CompanyPatch) Apply(company *Company) {
+	if p.Name != nil {
+		company.Name = *p.Name
+	}
+	if p.Country != nil {
+		company.Country = *p.Country
+	}
+}
+
+// CompanyQuery narrows and pages a company listing, which is ordered by
+// name. Zero values mean "no constraint" for the filters.
+type CompanyQuery struct {
+	Limit      int64
+	Offset     int64
+	NamePrefix string
+	Country    string
+}
+
+// CompanyPage is one page of a company listing. Total counts every
+// company matching the query, not just the ones on this page.
+type CompanyPage struct {
+	Companies []Company
+	Total     int64
+	Limit     int64
+	Offset    int64
+}
@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompanyID(t *testing.T) {
+	id, err := ParseCompanyID("64A4C6181955B6923FFF0001")
+	require.NoError(t, err)
+	assert.Equal(t, CompanyID("64a4c6181955b6923fff0001"), id)
+
+	_, err = ParseCompanyID("Toyota")
+	assert.ErrorContains(t, err, "company ID")
+}
+
+func TestCompanyIDJSON(t *testing.T) {
+	var car Car
+	require.NoError(t, json.Unmarshal([]byte(`{"companyId":"01H455VB4PEX5VSKNK084SN02Q"}`), &car))
+	assert.Equal(t, CompanyID("01H455VB4PEX5VSKNK084SN02Q"), car.CompanyID)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"companyId":"toyota"}`), &car))
+
+	// Cars without a company reference leave it empty.
+	require.NoError(t, json.Unmarshal([]byte(`{"companyId":""}`), &car))
+	assert.True(t, car.CompanyID.IsZero())
+}
+
+func TestCompanyPatchApply(t *testing.T) {
+	company := Company{Name: "Toyta", Country: "JP"}
+	name := "Toyota"
+	patch := CompanyPatch{Name: &name}
+
+	assert.False(t, patch.IsEmpty())
+	patch.Apply(&company)
+	assert.Equal(t, Company{Name: "Toyota", Country: "JP"}, company)
+	assert.True(t, (&CompanyPatch{}).IsEmpty())
+}
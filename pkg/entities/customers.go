@@ -0,0 +1,99 @@
+package entities
+
+import "fmt"
+
+// CustomerID identifies a customer. It takes the same formats as a CarID,
+// from the same IDGenerator.
+type CustomerID string
+
+// ParseCustomerID validates s and returns it in canonical form, like
+// ParseCarID.
+func ParseCustomerID(s string) (CustomerID, error) {
+	id, ok := canonicalID(s)
+	if !ok {
+		return "", fmt.Errorf("customer ID %q: %w", s, errInvalidID)
+	}
+	return CustomerID(id), nil
+}
+
+func (id CustomerID) String() string {
+	return string(id)
+}
+
+func (id CustomerID) IsZero() bool {
+	return id == ""
+}
+
+func (id CustomerID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+// UnmarshalText accepts a valid ID, or an empty one for bodies that do not
+// name a customer.
+func (id *CustomerID) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*id = ""
+		return nil
+	}
+	parsed, err := ParseCustomerID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Customer is someone who buys cars from the shop. Emails are unique
+// regardless of case, so a customer is known by their email.
+type Customer struct {
+	ID      CustomerID `json:"id"`
+	Name    string     `json:"name" validate:"required,max=128,pattern=name"`
+	Email   string     `json:"email" validate:"required,max=254,pattern=email"`
+	Phone   string     `json:"phone" validate:"max=32,pattern=phone"`
+	Version int64      `json:"version"`
+}
+
+// CustomerPatch is a partial update of a customer: only the non-nil fields
+// are changed.
+type CustomerPatch struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Phone *string `json:"phone,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p *CustomerPatch) IsEmpty() bool {
+	return *p == CustomerPatch{}
+}
+
+// Apply copies the supplied fields of the patch onto customer.
+func (p *CustomerPatch) Apply(customer *Customer) {
+	if p.Name != nil {
+		customer.Name = *p.Name
+	}
+	if p.Email != nil {
+		customer.Email = *p.Email
+	}
+	if p.Phone != nil {
+		customer.Phone = *p.Phone
+	}
+}
+
+// CustomerQuery narrows and pages a customer listing, which is ordered by
+// name. Zero values mean "no constraint" for the filters; the email
+// matches regardless of case.
+type CustomerQuery struct {
+	Limit      int64
+	Offset     int64
+	NamePrefix string
+	Email      string
+}
+
+// CustomerPage is one page of a customer listing. Total counts every
+// customer matching the query, not just the ones on this page.
+type CustomerPage struct {
+	Customers []Customer
+	Total     int64
+	Limit     int64
+	Offset    int64
+}
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCustomerID(t *testing.T) {
+	id, err := ParseCustomerID("64A4C6181955B6923FFF0101")
+	require.NoError(t, err)
+	assert.Equal(t, CustomerID("64a4c6181955b6923fff0101"), id)
+
+	_, err = ParseCustomerID("ada")
+	assert.ErrorContains(t, err, "customer ID")
+}
+
+func TestOrderRequestJSON(t *testing.T) {
+	var request OrderRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"customerId":"64a4c6181955b6923fff0101","carId":"64a4c6181955b6923fff0301","carVersion":2}`), &request))
+	assert.Equal(t, CustomerID("64a4c6181955b6923fff0101"), request.CustomerID)
+	assert.Equal(t, int64(2), request.CarVersion)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"customerId":"ada"}`), &request))
+	_, err := ParseOrderID("latest")
+	assert.ErrorContains(t, err, "order ID")
+}
+
+func TestCustomerPatchApply(t *testing.T) {
+	customer := Customer{Name: "Ada", Email: "ada@example.com"}
+	phone := "555 0100"
+	patch := CustomerPatch{Phone: &phone}
+
+	assert.False(t, patch.IsEmpty())
+	patch.Apply(&customer)
+	assert.Equal(t, Customer{Name: "Ada", Email: "ada@example.com", Phone: "555 0100"}, customer)
+	assert.True(t, (&CustomerPatch{}).IsEmpty())
+}
@@ -0,0 +1,106 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderID identifies a sales order. It takes the same formats as a CarID,
+// from the same IDGenerator.
+type OrderID string
+
+// ParseOrderID validates s and returns it in canonical form, like
+// ParseCarID.
+func ParseOrderID(s string) (OrderID, error) {
+	id, ok := canonicalID(s)
+	if !ok {
+		return "", fmt.Errorf("order ID %q: %w", s, errInvalidID)
+	}
+	return OrderID(id), nil
+}
+
+func (id OrderID) String() string {
+	return string(id)
+}
+
+func (id OrderID) IsZero() bool {
+	return id == ""
+}
+
+func (id OrderID) MarshalText() ([]byte, error) {
+	return []byte(id), nil
+}
+
+func (id *OrderID) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*id = ""
+		return nil
+	}
+	parsed, err := ParseOrderID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Order is the sale of a car to a customer. It records the car as it was
+// sold and what the customer paid for it, so the order reads the same
+// whatever becomes of the car.
+//
+// Amounts are in the minor unit of Currency. Subtotal is the list price
+// of the car; the discounts come off it in turn, and tax is charged on
+// what remains at TaxRate, in basis points (825 is 8.25%). Total is what
+// the customer pays.
+type Order struct {
+	ID         OrderID    `json:"id"`
+	CustomerID CustomerID `json:"customerId"`
+	CarID      CarID      `json:"carId"`
+	CarName    string     `json:"carName"`
+	Company    string     `json:"company"`
+	VIN        string     `json:"vin"`
+	Currency   string     `json:"currency"`
+	Subtotal   int64      `json:"subtotal"`
+	Discounts  []Discount `json:"discounts"`
+	Discount   int64      `json:"discount"`
+	TaxRate    int64      `json:"taxRate"`
+	Tax        int64      `json:"tax"`
+	Total      int64      `json:"total"`
+	PlacedAt   time.Time  `json:"placedAt"`
+}
+
+// Discount is a reduction of the price of an order, either a share of
+// the price in basis points or a fixed amount. Amount is what it took off
+// the order once applied.
+type Discount struct {
+	Code    string `json:"code" validate:"max=32,pattern=name"`
+	Percent int64  `json:"percent" validate:"gte=0,lte=10000"`
+	Amount  int64  `json:"amount" validate:"gte=0"`
+}
+
+// OrderRequest is the body of POST /orders. A non-zero CarVersion makes
+// the order conditional on the car still being at that version.
+type OrderRequest struct {
+	CustomerID CustomerID `json:"customerId"`
+	CarID      CarID      `json:"carId"`
+	CarVersion int64      `json:"carVersion"`
+	Discounts  []Discount `json:"discounts"`
+}
+
+// OrderQuery pages an order listing, newest first. A CustomerID limits it
+// to the order history of that customer.
+type OrderQuery struct {
+	Limit      int64
+	Offset     int64
+	CustomerID CustomerID
+}
+
+// OrderPage is one page of an order listing. Total counts every order
+// matching the query, not just the ones on this page.
+type OrderPage struct {
+	Orders     []Order
+	Total      int64
+	Limit      int64
+	Offset     int64
+	NextCursor string
+}
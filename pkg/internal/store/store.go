@@ -0,0 +1,75 @@
+// Package store holds what the repositories of cars, companies, customers
+// and orders have in common: the options they are built with, how they
+// check the IDs handed to them and how they classify Mongo driver errors.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testingfiber/pkg/entities"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Option customises a repository.
+type Option func(*Options)
+
+// Options are what a repository is built with.
+type Options struct {
+	// IDs identifies new entities.
+	IDs entities.IDGenerator
+}
+
+// WithIDGenerator sets how new entities are identified.
+func WithIDGenerator(ids entities.IDGenerator) Option {
+	return func(o *Options) {
+		o.IDs = ids
+	}
+}
+
+// NewOptions applies opts to the defaults: ObjectID-format IDs.
+func NewOptions(opts []Option) Options {
+	o := Options{IDs: entities.NewObjectIDGenerator()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ParseID validates an ID handed to a repository with parse and puts it
+// in canonical form. A malformed ID fails with invalid, wrapping the
+// reason.
+func ParseID[ID ~string](id ID, parse func(string) (ID, error), invalid error) (ID, error) {
+	parsed, err := parse(string(id))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", invalid, err)
+	}
+	return parsed, nil
+}
+
+// MongoErrors are the errors a repository reports for the Mongo driver
+// errors it classifies.
+type MongoErrors struct {
+	// Conflict wraps duplicate key errors. When nil, they are passed
+	// through.
+	Conflict error
+	// Unavailable wraps the errors of a server that cannot be reached.
+	Unavailable error
+}
+
+// Classify wraps a driver error in the matching repository error, keeping
+// the original error in the chain. Context errors are passed through
+// untouched so callers can still tell a deadline from an outage.
+func (e MongoErrors) Classify(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return err
+	case e.Conflict != nil && mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %w", e.Conflict, err)
+	case mongo.IsNetworkError(err), errors.Is(err, topology.ErrServerSelectionTimeout):
+		return fmt.Errorf("%w: %w", e.Unavailable, err)
+	}
+	return err
+}
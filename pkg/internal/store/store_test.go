@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+var (
+	errConflict    = errors.New("conflict")
+	errUnavailable = errors.New("unavailable")
+	errInvalid     = errors.New("invalid id")
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions(nil)
+	_, err := entities.ParseCarID(o.IDs.NewID())
+	assert.NoError(t, err, "ObjectID-format IDs by default")
+
+	ids := entities.NewObjectIDGenerator()
+	o = NewOptions([]Option{WithIDGenerator(ids)})
+	assert.Equal(t, ids, o.IDs)
+}
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID(entities.CarID("64A4C6181955B6923FFF02B5"), entities.ParseCarID, errInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, entities.CarID("64a4c6181955b6923fff02b5"), id)
+
+	_, err = ParseID(entities.CarID("not-an-id"), entities.ParseCarID, errInvalid)
+	assert.ErrorIs(t, err, errInvalid)
+}
+
+func TestMongoErrorsClassify(t *testing.T) {
+	duplicate := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key"}}}
+	unreachable := fmt.Errorf("selecting a server: %w", topology.ErrServerSelectionTimeout)
+	other := errors.New("boom")
+
+	errs := MongoErrors{Conflict: errConflict, Unavailable: errUnavailable}
+	assert.ErrorIs(t, errs.Classify(duplicate), errConflict)
+	assert.ErrorAs(t, errs.Classify(duplicate), new(mongo.WriteException))
+	assert.ErrorIs(t, errs.Classify(unreachable), errUnavailable)
+	assert.Equal(t, context.DeadlineExceeded, errs.Classify(context.DeadlineExceeded))
+	assert.Equal(t, other, errs.Classify(other))
+
+	// Without a conflict error, duplicate keys are the caller's to handle.
+	errs = MongoErrors{Unavailable: errUnavailable}
+	assert.Equal(t, error(duplicate), errs.Classify(duplicate))
+}
@@ -0,0 +1,83 @@
+// Package migrate runs the versioned schema migrations of the storage
+// backends. Every package that stores records owns its migrations and
+// hands them to NewPostgres or NewMongo; Chain runs the migrators of
+// several packages as one.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrIrreversible is returned by Migrator.Down for backends whose
+// migrations only go forward.
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Migrator brings the schema of a storage backend up to date. Migrations
+// are versioned and applied in version order; each backend records the
+// ones it has applied next to the records themselves.
+type Migrator interface {
+	// Up applies every pending migration and returns their versions.
+	Up(ctx context.Context) ([]string, error)
+	// Down rolls back the most recently applied migration and returns
+	// its version, or "" when none is applied.
+	Down(ctx context.Context) (string, error)
+	// Status lists every known migration in version order.
+	Status(ctx context.Context) ([]Status, error)
+}
+
+// Status tells whether a migration has been applied.
+type Status struct {
+	Version string
+	// AppliedAt is zero while the migration is pending.
+	AppliedAt time.Time
+}
+
+func (s Status) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// chain runs migrators in order, for schemas where later ones depend on
+// earlier ones, such as a table referencing another package's table.
+type chain []Migrator
+
+// Chain returns a Migrator that applies the migrations of every migrator
+// in the order given and rolls them back in reverse.
+func Chain(migrators ...Migrator) Migrator {
+	return chain(migrators)
+}
+
+func (c chain) Up(ctx context.Context) ([]string, error) {
+	var versions []string
+	for _, migrator := range c {
+		applied, err := migrator.Up(ctx)
+		versions = append(versions, applied...)
+		if err != nil {
+			return versions, err
+		}
+	}
+	return versions, nil
+}
+
+func (c chain) Down(ctx context.Context) (string, error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		version, err := c[i].Down(ctx)
+		if err != nil || version != "" {
+			return version, err
+		}
+	}
+	return "", nil
+}
+
+func (c chain) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	for _, migrator := range c {
+		more, err := migrator.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, more...)
+	}
+	return statuses, nil
+}
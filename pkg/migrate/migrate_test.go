@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMigrator applies its pending versions on Up and rolls back the last
+// applied one on Down.
+type fakeMigrator struct {
+	applied []string
+	pending []string
+	err     error
+}
+
+func (m *fakeMigrator) Up(context.Context) ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	applied := m.pending
+	m.applied, m.pending = append(m.applied, m.pending...), nil
+	return applied, nil
+}
+
+func (m *fakeMigrator) Down(context.Context) (string, error) {
+	if len(m.applied) == 0 {
+		return "", nil
+	}
+	last := m.applied[len(m.applied)-1]
+	m.applied, m.pending = m.applied[:len(m.applied)-1], append([]string{last}, m.pending...)
+	return last, nil
+}
+
+func (m *fakeMigrator) Status(context.Context) ([]Status, error) {
+	var statuses []Status
+	for _, version := range m.applied {
+		statuses = append(statuses, Status{Version: version, AppliedAt: time.Now()})
+	}
+	for _, version := range m.pending {
+		statuses = append(statuses, Status{Version: version})
+	}
+	return statuses, nil
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	first := &fakeMigrator{applied: []string{"0001_a"}, pending: []string{"0002_a"}}
+	second := &fakeMigrator{pending: []string{"0001_b"}}
+	chain := Chain(first, second)
+
+	applied, err := chain.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0002_a", "0001_b"}, applied)
+
+	statuses, err := chain.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "0001_b", statuses[2].Version)
+	assert.True(t, statuses[2].Applied())
+
+	// Down rolls back the later migrator first, then moves on to the
+	// earlier one once the later has nothing left.
+	for _, want := range []string{"0001_b", "0002_a", "0001_a", ""} {
+		version, err := chain.Down(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, version)
+	}
+}
+
+func TestChainStopsAtFailure(t *testing.T) {
+	broken := errors.New("broken")
+	last := &fakeMigrator{pending: []string{"0001_c"}}
+	chain := Chain(&fakeMigrator{pending: []string{"0001_a"}}, &fakeMigrator{err: broken}, last)
+
+	applied, err := chain.Up(context.Background())
+	assert.ErrorIs(t, err, broken)
+	assert.Equal(t, []string{"0001_a"}, applied, "migrations applied before the failure are reported")
+	assert.Equal(t, []string{"0001_c"}, last.pending)
+}
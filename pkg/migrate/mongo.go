@@ -0,0 +1,172 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoMigration is one versioned change to a collection. Up and Down must
+// be safe to run twice: instances starting side by side may both apply a
+// migration before either has recorded it.
+type MongoMigration struct {
+	Version string
+	Up      func(ctx context.Context, collection *mongo.Collection) error
+	Down    func(ctx context.Context, collection *mongo.Collection) error
+}
+
+// mongoMigrator records applied migrations in the schema_migrations
+// collection of the database, keyed by collection and version so that
+// several collections can share a database.
+type mongoMigrator struct {
+	collection *mongo.Collection
+	migrations []MongoMigration
+	applied    *mongo.Collection
+}
+
+type migrationKey struct {
+	Collection string `bson:"collection"`
+	Version    string `bson:"version"`
+}
+
+type migrationRecord struct {
+	Key       migrationKey `bson:"_id"`
+	AppliedAt time.Time    `bson:"appliedAt"`
+}
+
+// NewMongo returns the migrator applying migrations, which must be in
+// version order, to collection.
+func NewMongo(collection *mongo.Collection, migrations []MongoMigration) Migrator {
+	return &mongoMigrator{
+		collection: collection,
+		migrations: migrations,
+		applied:    collection.Database().Collection("schema_migrations"),
+	}
+}
+
+func (m *mongoMigrator) Up(ctx context.Context) ([]string, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for i, status := range statuses {
+		if status.Applied() {
+			continue
+		}
+
+		migration := m.migrations[i]
+		if err := migration.Up(ctx, m.collection); err != nil {
+			return versions, fmt.Errorf("migration %s: %w", migration.Version, err)
+		}
+
+		record := migrationRecord{Key: m.key(migration.Version), AppliedAt: time.Now()}
+		// Another instance may have recorded it in the meantime.
+		if _, err := m.applied.InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return versions, fmt.Errorf("migration %s: %w", migration.Version, err)
+		}
+		versions = append(versions, migration.Version)
+	}
+	return versions, nil
+}
+
+func (m *mongoMigrator) Down(ctx context.Context) (string, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied() {
+			continue
+		}
+
+		migration := m.migrations[i]
+		if err := migration.Down(ctx, m.collection); err != nil {
+			return "", fmt.Errorf("migration %s: %w", migration.Version, err)
+		}
+		if _, err := m.applied.DeleteOne(ctx, bson.M{"_id": m.key(migration.Version)}); err != nil {
+			return "", fmt.Errorf("migration %s: %w", migration.Version, err)
+		}
+		return migration.Version, nil
+	}
+	return "", nil
+}
+
+func (m *mongoMigrator) Status(ctx context.Context) ([]Status, error) {
+	cursor, err := m.applied.Find(ctx, bson.M{"_id.collection": m.collection.Name()})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []migrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	appliedAt := map[string]time.Time{}
+	for _, record := range records {
+		appliedAt[record.Key.Version] = record.AppliedAt
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Version: migration.Version, AppliedAt: appliedAt[migration.Version]}
+	}
+	return statuses, nil
+}
+
+func (m *mongoMigrator) key(version string) migrationKey {
+	return migrationKey{Collection: m.collection.Name(), Version: version}
+}
+
+// Server error codes the migrations tolerate.
+const (
+	mongoNamespaceNotFound = 26
+	mongoIndexNotFound     = 27
+	mongoNamespaceExists   = 48
+)
+
+// DropIndexes drops the named indexes of collection, skipping any that do
+// not exist, for Down functions undoing index creation.
+func DropIndexes(ctx context.Context, collection *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		_, err := collection.Indexes().DropOne(ctx, name)
+		if err != nil && !hasErrorCode(err, mongoIndexNotFound, mongoNamespaceNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetValidator replaces the validator of collection, creating the
+// collection first since collMod needs it to exist.
+func SetValidator(ctx context.Context, collection *mongo.Collection, validator bson.M, level string) error {
+	db := collection.Database()
+	if err := db.CreateCollection(ctx, collection.Name()); err != nil && !hasErrorCode(err, mongoNamespaceExists) {
+		return err
+	}
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: level},
+	}).Err()
+}
+
+func hasErrorCode(err error, codes ...int) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	for _, code := range codes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresMigrator applies the SQL files of one package in file name
+// order, recording each in schema_migrations. Every package records its
+// migrations in that one table, so file names must be unique across
+// packages; each names what it changes. Every migration runs in its own
+// transaction under an advisory lock, so instances starting side by side
+// do not apply one twice. Migrations only go forward.
+type postgresMigrator struct {
+	pool       *pgxpool.Pool
+	migrations fs.FS
+}
+
+// NewPostgres returns the migrator applying the *.sql files at the root of
+// migrations to the database behind pool.
+func NewPostgres(pool *pgxpool.Pool, migrations fs.FS) Migrator {
+	return &postgresMigrator{pool: pool, migrations: migrations}
+}
+
+func (m *postgresMigrator) Up(ctx context.Context) ([]string, error) {
+	names, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, name := range names {
+		version := strings.TrimSuffix(name, ".sql")
+		applied, err := m.apply(ctx, name, version)
+		if err != nil {
+			return versions, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if applied {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (m *postgresMigrator) Down(context.Context) (string, error) {
+	return "", fmt.Errorf("postgres: %w", ErrIrreversible)
+}
+
+func (m *postgresMigrator) Status(ctx context.Context) ([]Status, error) {
+	names, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.pool.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := map[string]time.Time{}
+	var version string
+	var at time.Time
+	_, err = pgx.ForEachRow(rows, []any{&version, &at}, func() error {
+		appliedAt[version] = at
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(names))
+	for i, name := range names {
+		version := strings.TrimSuffix(name, ".sql")
+		statuses[i] = Status{Version: version, AppliedAt: appliedAt[version]}
+	}
+	return statuses, nil
+}
+
+// prepare creates schema_migrations if needed and returns the migration
+// files in order.
+func (m *postgresMigrator) prepare(ctx context.Context) ([]string, error) {
+	names, err := fs.Glob(m.migrations, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	_, err = m.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    text        PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// migrationLock is the advisory lock key held while a migration runs.
+const migrationLock = 7_236_617_311
+
+// apply runs the named migration unless it has been applied, and reports
+// whether it ran.
+func (m *postgresMigrator) apply(ctx context.Context, name, version string) (bool, error) {
+	script, err := fs.ReadFile(m.migrations, name)
+	if err != nil {
+		return false, err
+	}
+
+	var ran bool
+	err = pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+			return err
+		}
+
+		var applied bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+		if err != nil || applied {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, string(script)); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+		ran = err == nil
+		return err
+	})
+	return ran, err
+}
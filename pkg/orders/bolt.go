@@ -0,0 +1,124 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltOrdersBucket = []byte("orders")
+
+// boltRepository stores orders in the bbolt file of the cars, as JSON
+// documents keyed by ID. An order and the sale of its car commit in one
+// transaction.
+type boltRepository struct {
+	db   *bolt.DB
+	cars cars.BoltRepository
+	ids  entities.IDGenerator
+}
+
+// NewBoltRepo returns a repository backed by db, selling the cars of
+// carRepository, which must use the same file. It creates its bucket when
+// the file is new. The caller owns db and closes it.
+func NewBoltRepo(db *bolt.DB, carRepository cars.BoltRepository, opts ...RepositoryOption) (Repository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltOrdersBucket)
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	o := store.NewOptions(opts)
+	return &boltRepository{db: db, cars: carRepository, ids: o.IDs}, nil
+}
+
+func (r *boltRepository) PlaceOrder(ctx context.Context, order *entities.Order, carVersion int64, sale *entities.CarPatch) (*entities.Order, error) {
+	order.ID = newOrderID(r.ids)
+	_, err := r.cars.UpdateCarWith(ctx, order.CarID, carVersion, sale, func(tx *bolt.Tx, _ *entities.Car) error {
+		data, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltOrdersBucket).Put([]byte(order.ID), data)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return order, nil
+}
+
+func (r *boltRepository) GetOrderByID(ctx context.Context, ID entities.OrderID) (*entities.Order, error) {
+	orderId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var order *entities.Order
+	err = r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltOrdersBucket).Get([]byte(orderId))
+		if data == nil {
+			return ErrOrderNotFound
+		}
+		order, err = decodeOrder(data)
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return order, nil
+}
+
+// ListOrders reads every order to sort them by the time they were placed.
+func (r *boltRepository) ListOrders(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matched []entities.Order
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltOrdersBucket).ForEach(func(key, data []byte) error {
+			order, err := decodeOrder(data)
+			if err != nil {
+				return err
+			}
+			if matchesQuery(order, query) {
+				matched = append(matched, *order)
+			}
+			return ctx.Err()
+		})
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return newerFirst(&matched[i], &matched[j]) })
+	return pageOf(matched, query), nil
+}
+
+func decodeOrder(data []byte) (*entities.Order, error) {
+	var order entities.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("decoding stored order: %w", err)
+	}
+	return &order, nil
+}
+
+// boltError reports a closed database as cars.ErrUnavailable, like the
+// cars repository does.
+func boltError(err error) error {
+	if errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package orders_test
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/companies"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/internal/storetest"
+	"testingfiber/pkg/migrate"
+	"testingfiber/pkg/orders"
+	"testingfiber/pkg/orders/orderstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepoConformance(t *testing.T) {
+	orderstest.RepositoryConformance(t, func(t *testing.T) orderstest.Stores {
+		carRepo := cars.NewMemoryRepo()
+		return orderstest.Stores{
+			Orders:    orders.NewMemoryRepo(carRepo),
+			Cars:      carRepo,
+			Customers: customers.NewMemoryRepo(),
+		}
+	})
+}
+
+func TestBoltRepoConformance(t *testing.T) {
+	orderstest.RepositoryConformance(t, func(t *testing.T) orderstest.Stores {
+		db := storetest.Bolt(t)
+		carRepo, err := cars.NewBoltRepo(db)
+		require.NoError(t, err)
+		customerRepo, err := customers.NewBoltRepo(db)
+		require.NoError(t, err)
+		orderRepo, err := orders.NewBoltRepo(db, carRepo)
+		require.NoError(t, err)
+		return orderstest.Stores{Orders: orderRepo, Cars: carRepo, Customers: customerRepo}
+	})
+}
+
+// TestMongoRepoConformance runs against a real server when MONGO_TEST_URI
+// is set; orders need transactions, so the server must be a replica set.
+// Every subtest gets its own collections, dropped afterwards.
+func TestMongoRepoConformance(t *testing.T) {
+	db := storetest.MongoDatabase(t)
+	orderstest.RepositoryConformance(t, func(t *testing.T) orderstest.Stores {
+		carCollection := storetest.MongoCollection(t, db, "cars")
+		customerCollection := storetest.MongoCollection(t, db, "customers")
+		orderCollection := storetest.MongoCollection(t, db, "orders")
+		_, err := migrate.Chain(
+			cars.NewMongoMigrator(carCollection),
+			customers.NewMongoMigrator(customerCollection),
+			orders.NewMongoMigrator(orderCollection),
+		).Up(context.Background())
+		require.NoError(t, err)
+
+		carRepo := cars.NewRepo(carCollection)
+		return orderstest.Stores{
+			Orders:    orders.NewMongoRepo(orderCollection, carRepo),
+			Cars:      carRepo,
+			Customers: customers.NewMongoRepo(customerCollection),
+		}
+	})
+}
+
+// TestPostgresRepoConformance runs against a real server when
+// POSTGRES_TEST_URL is set. Every subtest gets its own schema, migrated
+// from scratch and dropped afterwards.
+func TestPostgresRepoConformance(t *testing.T) {
+	config := storetest.PostgresConfig(t)
+	orderstest.RepositoryConformance(t, func(t *testing.T) orderstest.Stores {
+		pool := storetest.PostgresPool(t, config, "orders")
+		_, err := migrate.Chain(
+			companies.NewPostgresMigrator(pool),
+			cars.NewPostgresMigrator(pool),
+			customers.NewPostgresMigrator(pool),
+			orders.NewPostgresMigrator(pool),
+		).Up(context.Background())
+		require.NoError(t, err)
+
+		carRepo := cars.NewPostgresRepo(pool)
+		return orderstest.Stores{
+			Orders:    orders.NewPostgresRepo(pool, carRepo),
+			Cars:      carRepo,
+			Customers: customers.NewPostgresRepo(pool),
+		}
+	})
+}
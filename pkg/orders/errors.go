@@ -0,0 +1,39 @@
+package orders
+
+import (
+	"errors"
+	"strings"
+	"testingfiber/pkg/validation"
+)
+
+var (
+	// ErrOrderNotFound is returned when no order has the requested ID.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidID is returned for IDs that cannot name any order.
+	ErrInvalidID = errors.New("invalid order id")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("order validation failed")
+)
+
+// ValidationError carries every field error found in a order payload.
+type ValidationError struct {
+	Fields []validation.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// FieldErrors returns the fields that failed, like
+// cars.ValidationError.FieldErrors.
+func (e *ValidationError) FieldErrors() []validation.FieldError {
+	return e.Fields
+}
@@ -0,0 +1,86 @@
+package orders
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+)
+
+// memoryRepository keeps orders in process memory. The car is updated
+// first and the order, which cannot fail to be kept, is added under the
+// same lock, so that no listing sees a sold car without its order.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	cars   cars.Repository
+	ids    entities.IDGenerator
+	orders map[entities.OrderID]entities.Order
+}
+
+// NewMemoryRepo returns a repository selling the cars of carRepository.
+func NewMemoryRepo(carRepository cars.Repository, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &memoryRepository{
+		cars:   carRepository,
+		ids:    o.IDs,
+		orders: make(map[entities.OrderID]entities.Order),
+	}
+}
+
+func (r *memoryRepository) PlaceOrder(ctx context.Context, order *entities.Order, carVersion int64, sale *entities.CarPatch) (*entities.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.cars.UpdateCar(ctx, order.CarID, carVersion, sale); err != nil {
+		return nil, err
+	}
+	order.ID = newOrderID(r.ids)
+	r.orders[order.ID] = *order
+	return order, nil
+}
+
+func (r *memoryRepository) GetOrderByID(ctx context.Context, ID entities.OrderID) (*entities.Order, error) {
+	orderId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[orderId]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	return &order, nil
+}
+
+func (r *memoryRepository) ListOrders(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []entities.Order
+	for _, order := range r.orders {
+		if matchesQuery(&order, query) {
+			matched = append(matched, order)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return newerFirst(&matched[i], &matched[j])
+	})
+	return pageOf(matched, query), nil
+}
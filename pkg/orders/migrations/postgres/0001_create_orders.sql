@@ -0,0 +1,24 @@
+-- An order records the car as it was sold, so it does not reference the
+-- car: a car may be sold again after a return, or deleted, and its orders
+-- stay as they were. A customer with orders cannot be deleted. Databases
+-- migrated before the orders had migrations of their own already have the
+-- table, which the cars migrations used to create.
+CREATE TABLE IF NOT EXISTS orders (
+    id          text        PRIMARY KEY,
+    customer_id text        NOT NULL REFERENCES customers (id) ON DELETE RESTRICT,
+    car_id      text        NOT NULL,
+    car_name    text        NOT NULL,
+    company     text        NOT NULL,
+    vin         text        NOT NULL DEFAULT '',
+    currency    text        NOT NULL,
+    subtotal    bigint      NOT NULL CHECK (subtotal >= 0),
+    discounts   jsonb       NOT NULL DEFAULT '[]',
+    discount    bigint      NOT NULL CHECK (discount >= 0),
+    tax_rate    bigint      NOT NULL CHECK (tax_rate >= 0),
+    tax         bigint      NOT NULL CHECK (tax >= 0),
+    total       bigint      NOT NULL CHECK (total >= 0),
+    placed_at   timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS orders_placed_at_idx ON orders (placed_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS orders_customer_idx ON orders (customer_id, placed_at DESC, id DESC);
@@ -0,0 +1,33 @@
+package orders
+
+import (
+	"context"
+	"testingfiber/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoMigrator returns the migrator for the orders collection.
+func NewMongoMigrator(orders *mongo.Collection) migrate.Migrator {
+	return migrate.NewMongo(orders, mongoMigrations)
+}
+
+// mongoMigrations are applied in this order, which is also version order.
+var mongoMigrations = []migrate.MongoMigration{
+	{
+		// Order histories are read newest first.
+		Version: "0001_customer_history_index",
+		Up: func(ctx context.Context, orders *mongo.Collection) error {
+			_, err := orders.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "customerId", Value: 1}, {Key: "placedAt", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("customerId_1_placedAt_-1__id_-1"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, orders *mongo.Collection) error {
+			return migrate.DropIndexes(ctx, orders, "customerId_1_placedAt_-1__id_-1")
+		},
+	},
+}
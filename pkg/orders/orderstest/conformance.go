@@ -0,0 +1,211 @@
+// Package orderstest provides a conformance suite that every
+// orders.Repository backend is expected to pass.
+package orderstest
+
+import (
+	"context"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/storetest"
+	"testingfiber/pkg/orders"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Stores are the repositories of a single subtest: the orders under test,
+// and the cars they sell and the customers they sell them to, from the
+// same backend.
+type Stores struct {
+	Orders    orders.Repository
+	Cars      cars.Repository
+	Customers customers.Repository
+}
+
+// RepositoryFactory returns empty stores for a single subtest.
+type RepositoryFactory = storetest.Factory[Stores]
+
+// RepositoryConformance runs the shared behaviour checks against the
+// repositories produced by newStores.
+func RepositoryConformance(t *testing.T, newStores RepositoryFactory) {
+	storetest.Conformance(t, newStores, []storetest.Case[Stores]{
+		{Name: "PlaceOrderSellsCar", Run: testPlaceOrderSellsCar},
+		{Name: "PlaceOrderStaleCar", Run: testPlaceOrderStaleCar},
+		{Name: "PlaceOrderMissingCar", Run: testPlaceOrderMissingCar},
+		{Name: "GetMissingOrder", Run: testGetMissingOrder},
+		{Name: "GetInvalidID", Run: testGetInvalidID},
+		{Name: "ListNewestFirst", Run: testListNewestFirst},
+		{Name: "ListCustomerHistory", Run: testListCustomerHistory},
+	})
+}
+
+// placedAt is when the orders of the suite are placed, to the millisecond
+// every backend keeps.
+var placedAt = time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+
+func insertCustomer(t *testing.T, stores Stores, email string) *entities.Customer {
+	t.Helper()
+
+	customer, err := stores.Customers.InsertCustomer(context.Background(), &entities.Customer{Name: "Ada", Email: email})
+	require.NoError(t, err)
+	return customer
+}
+
+func insertCar(t *testing.T, stores Stores) *entities.Car {
+	t.Helper()
+
+	car, err := stores.Cars.InsertCar(context.Background(), &entities.Car{
+		CarName: "CX-5", Company: "Mazda", ListPrice: 3000000, Currency: "USD", Status: entities.InStock,
+	})
+	require.NoError(t, err)
+	return car
+}
+
+func newOrder(customer *entities.Customer, car *entities.Car, at time.Time) *entities.Order {
+	return &entities.Order{
+		CustomerID: customer.ID,
+		CarID:      car.ID,
+		CarName:    car.CarName,
+		Company:    car.Company,
+		Currency:   car.Currency,
+		Subtotal:   car.ListPrice,
+		Discounts:  []entities.Discount{{Code: "SPRING", Percent: 500, Amount: 150000}},
+		Discount:   150000,
+		TaxRate:    825,
+		Tax:        235125,
+		Total:      3085125,
+		PlacedAt:   at,
+	}
+}
+
+func salePatch(at time.Time) *entities.CarPatch {
+	sold := entities.Sold
+	return &entities.CarPatch{Status: &sold, SoldAt: &at}
+}
+
+// place sells a new car to customer at the given time.
+func place(t *testing.T, stores Stores, customer *entities.Customer, at time.Time) *entities.Order {
+	t.Helper()
+
+	car := insertCar(t, stores)
+	order, err := stores.Orders.PlaceOrder(context.Background(), newOrder(customer, car, at), car.Version, salePatch(at))
+	require.NoError(t, err)
+	return order
+}
+
+func listedIDs(t *testing.T, stores Stores, query *entities.OrderQuery) []entities.OrderID {
+	t.Helper()
+
+	page, err := stores.Orders.ListOrders(context.Background(), query)
+	require.NoError(t, err)
+
+	var ids []entities.OrderID
+	for _, order := range page.Orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+func testPlaceOrderSellsCar(t *testing.T, stores Stores) {
+	customer := insertCustomer(t, stores, "ada@example.com")
+	car := insertCar(t, stores)
+	// Searching first loads any in-process search index, which the sale
+	// must then reach.
+	_, err := stores.Cars.SearchCars(context.Background(), &entities.CarSearch{Query: car.CarName})
+	require.NoError(t, err)
+
+	order, err := stores.Orders.PlaceOrder(context.Background(), newOrder(customer, car, placedAt), car.Version, salePatch(placedAt))
+	require.NoError(t, err)
+	assert.False(t, order.ID.IsZero())
+
+	got, err := stores.Orders.GetOrderByID(context.Background(), order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, order, got)
+
+	sold, err := stores.Cars.GetCarByID(context.Background(), car.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.Sold, sold.Status)
+	assert.True(t, sold.SoldAt.Equal(placedAt))
+	assert.Equal(t, car.Version+1, sold.Version)
+
+	found, err := stores.Cars.SearchCars(context.Background(), &entities.CarSearch{Query: car.CarName})
+	require.NoError(t, err)
+	require.Len(t, found.Hits, 1)
+	assert.Equal(t, entities.Sold, found.Hits[0].Car.Status)
+	assert.Equal(t, sold.Version, found.Hits[0].Car.Version)
+}
+
+func testPlaceOrderStaleCar(t *testing.T, stores Stores) {
+	customer := insertCustomer(t, stores, "ada@example.com")
+	car := insertCar(t, stores)
+
+	_, err := stores.Orders.PlaceOrder(context.Background(), newOrder(customer, car, placedAt), car.Version+1, salePatch(placedAt))
+	assert.ErrorIs(t, err, cars.ErrVersionMismatch)
+
+	// Neither write happened.
+	assert.Empty(t, listedIDs(t, stores, &entities.OrderQuery{}))
+	got, err := stores.Cars.GetCarByID(context.Background(), car.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.InStock, got.Status)
+	assert.Equal(t, car.Version, got.Version)
+}
+
+func testPlaceOrderMissingCar(t *testing.T, stores Stores) {
+	customer := insertCustomer(t, stores, "ada@example.com")
+	car := insertCar(t, stores)
+	require.NoError(t, stores.Cars.DeleteCar(context.Background(), car.ID, 0))
+
+	_, err := stores.Orders.PlaceOrder(context.Background(), newOrder(customer, car, placedAt), car.Version, salePatch(placedAt))
+	assert.ErrorIs(t, err, cars.ErrCarNotFound)
+	assert.Empty(t, listedIDs(t, stores, &entities.OrderQuery{}))
+}
+
+func testGetMissingOrder(t *testing.T, stores Stores) {
+	missing := entities.OrderID(entities.NewObjectIDGenerator().NewID())
+
+	_, err := stores.Orders.GetOrderByID(context.Background(), missing)
+	assert.ErrorIs(t, err, orders.ErrOrderNotFound)
+}
+
+func testGetInvalidID(t *testing.T, stores Stores) {
+	_, err := stores.Orders.GetOrderByID(context.Background(), "not-an-id")
+	assert.ErrorIs(t, err, orders.ErrInvalidID)
+}
+
+func testListNewestFirst(t *testing.T, stores Stores) {
+	customer := insertCustomer(t, stores, "ada@example.com")
+	first := place(t, stores, customer, placedAt)
+	third := place(t, stores, customer, placedAt.Add(2*time.Hour))
+	second := place(t, stores, customer, placedAt.Add(time.Hour))
+
+	assert.Equal(t, []entities.OrderID{third.ID, second.ID, first.ID}, listedIDs(t, stores, &entities.OrderQuery{}))
+
+	page, err := stores.Orders.ListOrders(context.Background(), &entities.OrderQuery{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, second.ID, page.Orders[0].ID)
+
+	page, err = stores.Orders.ListOrders(context.Background(), &entities.OrderQuery{Offset: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Empty(t, page.Orders)
+}
+
+func testListCustomerHistory(t *testing.T, stores Stores) {
+	ada := insertCustomer(t, stores, "ada@example.com")
+	grace := insertCustomer(t, stores, "grace@example.com")
+	adaFirst := place(t, stores, ada, placedAt)
+	graceOnly := place(t, stores, grace, placedAt.Add(time.Hour))
+	adaSecond := place(t, stores, ada, placedAt.Add(2*time.Hour))
+
+	assert.Equal(t, []entities.OrderID{adaSecond.ID, adaFirst.ID}, listedIDs(t, stores, &entities.OrderQuery{CustomerID: ada.ID}))
+	assert.Equal(t, []entities.OrderID{graceOnly.ID}, listedIDs(t, stores, &entities.OrderQuery{CustomerID: grace.ID}))
+
+	page, err := stores.Orders.ListOrders(context.Background(), &entities.OrderQuery{CustomerID: ada.ID, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+}
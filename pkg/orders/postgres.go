@@ -0,0 +1,169 @@
+package orders
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"testingfiber/pkg/migrate"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresColumns lists the columns of an order in the order scanOrder
+// reads them.
+const postgresColumns = "id, customer_id, car_id, car_name, company, vin, currency, subtotal, discounts, discount, tax_rate, tax, total, placed_at"
+
+// postgresRepository stores orders in the orders table. An order and the
+// sale of its car commit in one transaction.
+type postgresRepository struct {
+	pool *pgxpool.Pool
+	cars cars.PostgresRepository
+	ids  entities.IDGenerator
+}
+
+// NewPostgresRepo returns a repository backed by pool, selling the cars of
+// carRepository, which must use the same database. The schema must be up
+// to date, see NewPostgresMigrator.
+func NewPostgresRepo(pool *pgxpool.Pool, carRepository cars.PostgresRepository, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &postgresRepository{pool: pool, cars: carRepository, ids: o.IDs}
+}
+
+// NewPostgresMigrator returns the migrator for the orders table of the
+// database behind pool. Orders reference customers, so the customers
+// migrations must be applied first.
+func NewPostgresMigrator(pool *pgxpool.Pool) migrate.Migrator {
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		panic(err)
+	}
+	return migrate.NewPostgres(pool, migrations)
+}
+
+func (r *postgresRepository) PlaceOrder(ctx context.Context, order *entities.Order, carVersion int64, sale *entities.CarPatch) (*entities.Order, error) {
+	discounts, err := json.Marshal(order.Discounts)
+	if err != nil {
+		return nil, err
+	}
+
+	order.ID = newOrderID(r.ids)
+	_, err = r.cars.UpdateCarWith(ctx, order.CarID, carVersion, sale, func(tx pgx.Tx, _ *entities.Car) error {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO orders ("+postgresColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+			order.ID, order.CustomerID, order.CarID, order.CarName, order.Company, order.VIN, order.Currency,
+			order.Subtotal, discounts, order.Discount, order.TaxRate, order.Tax, order.Total, order.PlacedAt)
+		return postgresError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *postgresRepository) GetOrderByID(ctx context.Context, ID entities.OrderID) (*entities.Order, error) {
+	orderId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrder(r.pool.QueryRow(ctx, "SELECT "+postgresColumns+" FROM orders WHERE id = $1", orderId))
+}
+
+func (r *postgresRepository) ListOrders(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	var args []any
+	where := ""
+	if !query.CustomerID.IsZero() {
+		args = append(args, query.CustomerID)
+		where = " WHERE customer_id = $1"
+	}
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT count(*) FROM orders"+where, args...).Scan(&total); err != nil {
+		return nil, postgresError(err)
+	}
+
+	// A NULL limit means no limit, matching a zero limit elsewhere.
+	var limit *int64
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	args = append(args, limit, query.Offset)
+	page := fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.pool.Query(ctx,
+		"SELECT "+postgresColumns+" FROM orders"+where+` ORDER BY placed_at DESC, id COLLATE "C" DESC`+page, args...)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	result := &entities.OrderPage{Total: total}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Orders = append(result.Orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+	return result, nil
+}
+
+// scanOrder reads a row of postgresColumns. A missing row is reported as
+// ErrOrderNotFound.
+func scanOrder(row pgx.Row) (*entities.Order, error) {
+	var order entities.Order
+	var discounts []byte
+	err := row.Scan(&order.ID, &order.CustomerID, &order.CarID, &order.CarName, &order.Company, &order.VIN, &order.Currency,
+		&order.Subtotal, &discounts, &order.Discount, &order.TaxRate, &order.Tax, &order.Total, &order.PlacedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	if err := json.Unmarshal(discounts, &order.Discounts); err != nil {
+		return nil, fmt.Errorf("decoding stored discounts: %w", err)
+	}
+	order.PlacedAt = order.PlacedAt.UTC()
+	return &order, nil
+}
+
+// postgresError classifies pgx errors like the cars repository does. The
+// foreign key to customers rejects an order for a customer who is gone.
+func postgresError(err error) error {
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return err
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == "23503":
+			return fmt.Errorf("%w: %w", customers.ErrCustomerNotFound, err)
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "53300", strings.HasPrefix(pgErr.Code, "57P"):
+			return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+		}
+	case errors.As(err, &netErr), pgconn.SafeToRetry(err):
+		return fmt.Errorf("%w: %w", cars.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package orders
+
+import "testingfiber/pkg/entities"
+
+// basisPoints is the whole of a rate given in basis points.
+const basisPoints = 10000
+
+// price works out what order costs from its subtotal, its discounts and
+// its tax rate. The discounts come off in the order given: a percentage
+// is a share of what is left after the earlier ones, and no discount
+// takes the price below zero. The amount each discount took off is
+// recorded on it. Shares are rounded half up to the minor unit.
+func price(order *entities.Order) {
+	remaining := order.Subtotal
+	for i := range order.Discounts {
+		discount := &order.Discounts[i]
+		off := discount.Amount
+		if discount.Percent > 0 {
+			off = share(remaining, discount.Percent)
+		}
+		if off > remaining {
+			off = remaining
+		}
+		discount.Amount = off
+		remaining -= off
+	}
+
+	order.Discount = order.Subtotal - remaining
+	order.Tax = share(remaining, order.TaxRate)
+	order.Total = remaining + order.Tax
+}
+
+// share is rate basis points of amount, rounded half up. Amounts are at
+// most cars.MaxListPrice and rates at most basisPoints, so the product
+// cannot overflow.
+func share(amount, rate int64) int64 {
+	return (amount*rate + basisPoints/2) / basisPoints
+}
@@ -0,0 +1,90 @@
+package orders
+
+import (
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    entities.Order
+		amounts  []int64
+		discount int64
+		tax      int64
+		total    int64
+	}{
+		{
+			name:  "NoDiscounts",
+			order: entities.Order{Subtotal: 2000000, TaxRate: 825},
+			tax:   165000,
+			total: 2165000,
+		},
+		{
+			name: "PercentThenAmount",
+			order: entities.Order{Subtotal: 2000000, TaxRate: 1000, Discounts: []entities.Discount{
+				{Code: "SPRING", Percent: 500},
+				{Code: "LOYALTY", Amount: 50000},
+			}},
+			amounts:  []int64{100000, 50000},
+			discount: 150000,
+			tax:      185000,
+			total:    2035000,
+		},
+		{
+			name:  "HighestListPrice",
+			order: entities.Order{Subtotal: cars.MaxListPrice, TaxRate: basisPoints},
+			tax:   cars.MaxListPrice,
+			total: 2 * cars.MaxListPrice,
+		},
+		{
+			name: "PercentOfWhatIsLeft",
+			order: entities.Order{Subtotal: 10000, Discounts: []entities.Discount{
+				{Amount: 5000},
+				{Percent: 1000},
+			}},
+			amounts:  []int64{5000, 500},
+			discount: 5500,
+			total:    4500,
+		},
+		{
+			name: "RoundsHalfUp",
+			order: entities.Order{Subtotal: 105, TaxRate: 500, Discounts: []entities.Discount{
+				{Percent: 5000},
+			}},
+			amounts:  []int64{53},
+			discount: 53,
+			tax:      3,
+			total:    55,
+		},
+		{
+			name: "NeverBelowZero",
+			order: entities.Order{Subtotal: 10000, TaxRate: 825, Discounts: []entities.Discount{
+				{Amount: 8000},
+				{Amount: 8000},
+				{Percent: 10000},
+			}},
+			amounts:  []int64{8000, 2000, 0},
+			discount: 10000,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order := test.order
+			price(&order)
+
+			var amounts []int64
+			for _, discount := range order.Discounts {
+				amounts = append(amounts, discount.Amount)
+			}
+			assert.Equal(t, test.amounts, amounts)
+			assert.Equal(t, test.discount, order.Discount)
+			assert.Equal(t, test.tax, order.Tax)
+			assert.Equal(t, test.total, order.Total)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/internal/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores orders. Orders are never changed once placed.
+//
+// PlaceOrder stores order and applies sale to the car it buys, on the
+// condition that the car is still at carVersion: either both writes
+// happen or neither does. It assigns the order its ID, and fails with the
+// errors of cars.Repository.UpdateCar when the car cannot be updated.
+//
+// ListOrders orders orders newest first, by the time they were placed and
+// then by ID.
+type Repository interface {
+	PlaceOrder(ctx context.Context, order *entities.Order, carVersion int64, sale *entities.CarPatch) (*entities.Order, error)
+	GetOrderByID(ctx context.Context, ID entities.OrderID) (*entities.Order, error)
+	ListOrders(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error)
+}
+
+// RepositoryOption customises the repositories built by NewMongoRepo,
+// NewMemoryRepo, NewPostgresRepo and NewBoltRepo.
+type RepositoryOption = store.Option
+
+// WithIDGenerator sets how new orders are identified, like
+// cars.WithIDGenerator does for cars.
+func WithIDGenerator(ids entities.IDGenerator) RepositoryOption {
+	return store.WithIDGenerator(ids)
+}
+
+func newOrderID(ids entities.IDGenerator) entities.OrderID {
+	return entities.OrderID(ids.NewID())
+}
+
+func parseID(ID entities.OrderID) (entities.OrderID, error) {
+	return store.ParseID(ID, entities.ParseOrderID, ErrInvalidID)
+}
+
+// pageOf cuts the page that query asks for out of the matching orders.
+func pageOf(matched []entities.Order, query *entities.OrderQuery) *entities.OrderPage {
+	total := int64(len(matched))
+	start := query.Offset
+	if start > total {
+		start = total
+	}
+	end := start + query.Limit
+	if query.Limit <= 0 || end > total {
+		end = total
+	}
+
+	var orders []entities.Order
+	orders = append(orders, matched[start:end]...)
+	return &entities.OrderPage{Orders: orders, Total: total}
+}
+
+func matchesQuery(order *entities.Order, query *entities.OrderQuery) bool {
+	return query.CustomerID.IsZero() || order.CustomerID == query.CustomerID
+}
+
+// newerFirst orders orders newest first, and then by ID, descending.
+func newerFirst(a, b *entities.Order) bool {
+	if !a.PlacedAt.Equal(b.PlacedAt) {
+		return a.PlacedAt.After(b.PlacedAt)
+	}
+	return a.ID > b.ID
+}
+
+type mongoRepository struct {
+	collection *mongo.Collection
+	cars       cars.Repository
+	ids        entities.IDGenerator
+}
+
+// orderDocument is the stored form of an order.
+type orderDocument struct {
+	ID         entities.OrderID    `bson:"_id"`
+	CustomerID entities.CustomerID `bson:"customerId"`
+	CarID      entities.CarID      `bson:"carId"`
+	CarName    string              `bson:"carName"`
+	Company    string              `bson:"company"`
+	VIN        string              `bson:"vin,omitempty"`
+	Currency   string              `bson:"currency"`
+	Subtotal   int64               `bson:"subtotal"`
+	Discounts  []entities.Discount `bson:"discounts"`
+	Discount   int64               `bson:"discount"`
+	TaxRate    int64               `bson:"taxRate"`
+	Tax        int64               `bson:"tax"`
+	Total      int64               `bson:"total"`
+	PlacedAt   time.Time           `bson:"placedAt"`
+}
+
+func newOrderDocument(order *entities.Order) *orderDocument {
+	document := orderDocument(*order)
+	return &document
+}
+
+func (d *orderDocument) order() *entities.Order {
+	order := entities.Order(*d)
+	order.PlacedAt = order.PlacedAt.UTC()
+	return &order
+}
+
+// NewMongoRepo returns a repository keeping orders in collection, whose
+// indexes NewMongoMigrator creates. Placing an order updates the car
+// through carRepository inside a transaction, so carRepository must be a
+// Mongo repository on the same client, and the server a replica set or a
+// sharded cluster.
+func NewMongoRepo(collection *mongo.Collection, carRepository cars.Repository, opts ...RepositoryOption) Repository {
+	o := store.NewOptions(opts)
+	return &mongoRepository{collection: collection, cars: carRepository, ids: o.IDs}
+}
+
+func (r *mongoRepository) PlaceOrder(ctx context.Context, order *entities.Order, carVersion int64, sale *entities.CarPatch) (*entities.Order, error) {
+	session, err := r.collection.Database().Client().StartSession()
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer session.EndSession(ctx)
+
+	order.ID = newOrderID(r.ids)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		if _, err := r.cars.UpdateCar(sc, order.CarID, carVersion, sale); err != nil {
+			return nil, err
+		}
+		_, err := r.collection.InsertOne(sc, newOrderDocument(order))
+		return nil, err
+	})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return order, nil
+}
+
+func (r *mongoRepository) GetOrderByID(ctx context.Context, ID entities.OrderID) (*entities.Order, error) {
+	orderId, err := parseID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var document orderDocument
+	err = r.collection.FindOne(ctx, bson.M{"_id": orderId}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return document.order(), nil
+}
+
+func (r *mongoRepository) ListOrders(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	filter := bson.M{}
+	if !query.CustomerID.IsZero() {
+		filter["customerId"] = query.CustomerID
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "placedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(query.Offset)
+	if query.Limit > 0 {
+		findOptions.SetLimit(query.Limit)
+	}
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var documents []orderDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, mongoError(err)
+	}
+
+	page := &entities.OrderPage{Total: total}
+	for i := range documents {
+		page.Orders = append(page.Orders, *documents[i].order())
+	}
+	return page, nil
+}
+
+// mongoError classifies driver errors like the cars repository does.
+// Duplicate keys are left alone: errors of the car update pass through
+// untouched.
+var mongoError = store.MongoErrors{Unavailable: cars.ErrUnavailable}.Classify
@@ -0,0 +1,196 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+)
+
+// MaxDiscounts is the most discounts a single order may carry.
+const MaxDiscounts = 10
+
+// placeAttempts bounds how often an order without a car version is
+// retried when the car changes while it is being sold.
+const placeAttempts = 3
+
+type Service interface {
+	PlaceOrderService(ctx context.Context, request *entities.OrderRequest) (*entities.Order, error)
+	GetOrderByIDService(ctx context.Context, ID entities.OrderID) (*entities.Order, error)
+	ListOrdersService(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error)
+}
+
+type service struct {
+	repository Repository
+	customers  customers.Repository
+	cars       cars.Repository
+	validator  *validation.Validator
+	taxRate    int64
+	now        func() time.Time
+}
+
+// ServiceOption customises the Service built by NewService.
+type ServiceOption func(*service)
+
+// WithTaxRate sets the tax charged on orders, in basis points. By default
+// orders are not taxed.
+func WithTaxRate(rate int64) ServiceOption {
+	return func(s *service) {
+		s.taxRate = rate
+	}
+}
+
+// NewService returns the service placing the orders of r, for the
+// customers of customerRepository and the cars of carRepository.
+func NewService(r Repository, customerRepository customers.Repository, carRepository cars.Repository, opts ...ServiceOption) Service {
+	s := &service{
+		repository: r,
+		customers:  customerRepository,
+		cars:       carRepository,
+		validator:  validation.New(time.Now, nil),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// PlaceOrderService sells a car to a customer. The car must be on sale
+// and have a list price; the order records it as it is sold, prices it
+// with the requested discounts and the service's tax rate, and is stored
+// together with the sale of the car. A non-zero car version must match
+// the car's.
+func (s *service) PlaceOrderService(ctx context.Context, request *entities.OrderRequest) (*entities.Order, error) {
+	if err := s.validateRequest(request); err != nil {
+		return nil, err
+	}
+
+	customer, err := s.customers.GetCustomerByID(ctx, request.CustomerID)
+	if errors.Is(err, customers.ErrCustomerNotFound) || errors.Is(err, customers.ErrInvalidID) {
+		return nil, fieldError("customerId", "names no known customer")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		car, err := s.cars.GetCarByID(ctx, request.CarID)
+		if errors.Is(err, cars.ErrCarNotFound) || errors.Is(err, cars.ErrInvalidID) {
+			return nil, fieldError("carId", "names no known car")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if request.CarVersion != 0 && car.Version != request.CarVersion {
+			return nil, cars.ErrVersionMismatch
+		}
+
+		// Mongo keeps times to the millisecond; the order reads back as
+		// it was placed.
+		now := s.now().UTC().Truncate(time.Millisecond)
+		sale, err := cars.TransitionPatch(car, cars.Sell, now)
+		if err != nil {
+			return nil, err
+		}
+		if car.ListPrice <= 0 {
+			return nil, fieldError("carId", "names a car without a list price")
+		}
+		if car.ListPrice > cars.MaxListPrice {
+			// Stored before prices were capped; pricing it could overflow.
+			return nil, fieldError("carId", "names a car priced above the most an order can take")
+		}
+
+		order := &entities.Order{
+			CustomerID: customer.ID,
+			CarID:      car.ID,
+			CarName:    car.CarName,
+			Company:    car.Company,
+			VIN:        car.VIN,
+			Currency:   car.Currency,
+			Subtotal:   car.ListPrice,
+			Discounts:  append([]entities.Discount{}, request.Discounts...),
+			TaxRate:    s.taxRate,
+			PlacedAt:   now,
+		}
+		price(order)
+
+		placed, err := s.repository.PlaceOrder(ctx, order, car.Version, sale)
+		if errors.Is(err, cars.ErrVersionMismatch) && request.CarVersion == 0 && attempt < placeAttempts {
+			continue
+		}
+		return placed, err
+	}
+}
+
+// validateRequest checks what can be checked of request without reading
+// the stores. Every discount is either a percentage or an amount.
+func (s *service) validateRequest(request *entities.OrderRequest) error {
+	var fields []validation.FieldError
+	if request.CustomerID.IsZero() {
+		fields = append(fields, validation.FieldError{Field: "customerId", Message: "is required"})
+	}
+	if request.CarID.IsZero() {
+		fields = append(fields, validation.FieldError{Field: "carId", Message: "is required"})
+	}
+	if len(request.Discounts) > MaxDiscounts {
+		fields = append(fields, validation.FieldError{Field: "discounts", Message: fmt.Sprintf("must have at most %d items", MaxDiscounts)})
+	}
+
+	for i := range request.Discounts {
+		discount := &request.Discounts[i]
+		prefix := fmt.Sprintf("discounts[%d]", i)
+
+		if failed := s.validator.Validate(discount); len(failed) > 0 {
+			for _, field := range failed {
+				fields = append(fields, validation.FieldError{Field: prefix + "." + field.Field, Message: field.Message})
+			}
+			continue
+		}
+		if (discount.Percent == 0) == (discount.Amount == 0) {
+			fields = append(fields, validation.FieldError{Field: prefix, Message: "must have either a percent or an amount"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func fieldError(field, message string) error {
+	return &ValidationError{Fields: []validation.FieldError{{Field: field, Message: message}}}
+}
+
+func (s *service) GetOrderByIDService(ctx context.Context, ID entities.OrderID) (*entities.Order, error) {
+	return s.repository.GetOrderByID(ctx, ID)
+}
+
+// ListOrdersService lists orders newest first. A query for the history of
+// a customer fails with customers.ErrCustomerNotFound when there is no
+// such customer, rather than listing nothing.
+func (s *service) ListOrdersService(ctx context.Context, query *entities.OrderQuery) (*entities.OrderPage, error) {
+	cars.ClampPage(&query.Limit, &query.Offset)
+
+	if !query.CustomerID.IsZero() {
+		customer, err := s.customers.GetCustomerByID(ctx, query.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		query.CustomerID = customer.ID
+	}
+
+	page, err := s.repository.ListOrders(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Limit = query.Limit
+	page.Offset = query.Offset
+	page.NextCursor = cars.NextCursor(query.Offset, len(page.Orders), page.Total)
+	return page, nil
+}
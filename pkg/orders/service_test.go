@@ -0,0 +1,223 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testingfiber/pkg/cars"
+	"testingfiber/pkg/customers"
+	"testingfiber/pkg/entities"
+	"testingfiber/pkg/validation"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 3, 1, 10, 30, 0, 123456789, time.UTC)
+
+type testStores struct {
+	service   *service
+	orders    Repository
+	cars      cars.Repository
+	customers customers.Repository
+}
+
+func newTestService(t *testing.T, opts ...ServiceOption) testStores {
+	t.Helper()
+
+	carRepo := cars.NewMemoryRepo()
+	customerRepo := customers.NewMemoryRepo()
+	repo := NewMemoryRepo(carRepo)
+	s := NewService(repo, customerRepo, carRepo, opts...).(*service)
+	s.now = func() time.Time { return now }
+	return testStores{service: s, orders: repo, cars: carRepo, customers: customerRepo}
+}
+
+func (s testStores) insertCustomer(t *testing.T) *entities.Customer {
+	t.Helper()
+
+	customer, err := s.customers.InsertCustomer(context.Background(), &entities.Customer{Name: "Ada", Email: "ada@example.com"})
+	require.NoError(t, err)
+	return customer
+}
+
+func (s testStores) insertCar(t *testing.T, vin string, listPrice int64) *entities.Car {
+	t.Helper()
+
+	car, err := s.cars.InsertCar(context.Background(), &entities.Car{
+		CarName: "CX-5", Company: "Mazda", VIN: vin, ListPrice: listPrice, Currency: "USD", Status: entities.InStock,
+	})
+	require.NoError(t, err)
+	return car
+}
+
+func validationFields(t *testing.T, err error) []validation.FieldError {
+	t.Helper()
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "got %v", err)
+	return validationErr.Fields
+}
+
+func TestPlaceOrderService(t *testing.T) {
+	ctx := context.Background()
+	stores := newTestService(t, WithTaxRate(825))
+	customer := stores.insertCustomer(t)
+	car := stores.insertCar(t, "JM3KFBCM1N0123456", 3000000)
+
+	order, err := stores.service.PlaceOrderService(ctx, &entities.OrderRequest{
+		CustomerID: customer.ID,
+		CarID:      car.ID,
+		CarVersion: car.Version,
+		Discounts:  []entities.Discount{{Code: "SPRING", Percent: 500}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, customer.ID, order.CustomerID)
+	assert.Equal(t, "CX-5", order.CarName)
+	assert.Equal(t, "Mazda", order.Company)
+	assert.Equal(t, "JM3KFBCM1N0123456", order.VIN)
+	assert.Equal(t, "USD", order.Currency)
+	assert.Equal(t, int64(3000000), order.Subtotal)
+	assert.Equal(t, []entities.Discount{{Code: "SPRING", Percent: 500, Amount: 150000}}, order.Discounts)
+	assert.Equal(t, int64(150000), order.Discount)
+	assert.Equal(t, int64(825), order.TaxRate)
+	assert.Equal(t, int64(235125), order.Tax)
+	assert.Equal(t, int64(3085125), order.Total)
+	assert.Equal(t, now.Truncate(time.Millisecond), order.PlacedAt)
+
+	sold, err := stores.cars.GetCarByID(ctx, car.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.Sold, sold.Status)
+	assert.Equal(t, order.PlacedAt, sold.SoldAt)
+
+	// A sold car cannot be sold again.
+	_, err = stores.service.PlaceOrderService(ctx, &entities.OrderRequest{CustomerID: customer.ID, CarID: car.ID})
+	assert.ErrorIs(t, err, cars.ErrInvalidTransition)
+}
+
+func TestPlaceOrderServiceValidation(t *testing.T) {
+	ctx := context.Background()
+	stores := newTestService(t)
+	customer := stores.insertCustomer(t)
+	car := stores.insertCar(t, "", 3000000)
+	unpriced := stores.insertCar(t, "", 0)
+	overpriced := stores.insertCar(t, "", cars.MaxListPrice+1)
+	missing := entities.CarID(entities.NewObjectIDGenerator().NewID())
+
+	tests := []struct {
+		name    string
+		request entities.OrderRequest
+		fields  []validation.FieldError
+	}{
+		{
+			name:    "Required",
+			request: entities.OrderRequest{},
+			fields: []validation.FieldError{
+				{Field: "customerId", Message: "is required"},
+				{Field: "carId", Message: "is required"},
+			},
+		},
+		{
+			name: "Discounts",
+			request: entities.OrderRequest{CustomerID: customer.ID, CarID: car.ID, Discounts: []entities.Discount{
+				{Percent: 500, Amount: 100},
+				{Code: "SPRING"},
+				{Percent: 20000},
+			}},
+			fields: []validation.FieldError{
+				{Field: "discounts[0]", Message: "must have either a percent or an amount"},
+				{Field: "discounts[1]", Message: "must have either a percent or an amount"},
+				{Field: "discounts[2].percent", Message: "must be at most 10000"},
+			},
+		},
+		{
+			name:    "UnknownCustomer",
+			request: entities.OrderRequest{CustomerID: "not-an-id", CarID: car.ID},
+			fields:  []validation.FieldError{{Field: "customerId", Message: "names no known customer"}},
+		},
+		{
+			name:    "UnknownCar",
+			request: entities.OrderRequest{CustomerID: customer.ID, CarID: missing},
+			fields:  []validation.FieldError{{Field: "carId", Message: "names no known car"}},
+		},
+		{
+			name:    "NoListPrice",
+			request: entities.OrderRequest{CustomerID: customer.ID, CarID: unpriced.ID},
+			fields:  []validation.FieldError{{Field: "carId", Message: "names a car without a list price"}},
+		},
+		{
+			name:    "ListPriceTooHigh",
+			request: entities.OrderRequest{CustomerID: customer.ID, CarID: overpriced.ID},
+			fields:  []validation.FieldError{{Field: "carId", Message: "names a car priced above the most an order can take"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := stores.service.PlaceOrderService(ctx, &test.request)
+			assert.Equal(t, test.fields, validationFields(t, err))
+		})
+	}
+
+	page, err := stores.orders.ListOrders(ctx, &entities.OrderQuery{})
+	require.NoError(t, err)
+	assert.Zero(t, page.Total)
+}
+
+func TestPlaceOrderServiceStaleCarVersion(t *testing.T) {
+	ctx := context.Background()
+	stores := newTestService(t)
+	customer := stores.insertCustomer(t)
+	car := stores.insertCar(t, "", 3000000)
+
+	_, err := stores.service.PlaceOrderService(ctx, &entities.OrderRequest{CustomerID: customer.ID, CarID: car.ID, CarVersion: car.Version + 1})
+	assert.ErrorIs(t, err, cars.ErrVersionMismatch)
+
+	got, err := stores.cars.GetCarByID(ctx, car.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.InStock, got.Status)
+}
+
+func TestListOrdersService(t *testing.T) {
+	ctx := context.Background()
+	stores := newTestService(t)
+	customer := stores.insertCustomer(t)
+	car := stores.insertCar(t, "", 3000000)
+
+	order, err := stores.service.PlaceOrderService(ctx, &entities.OrderRequest{CustomerID: customer.ID, CarID: car.ID})
+	require.NoError(t, err)
+
+	page, err := stores.service.ListOrdersService(ctx, &entities.OrderQuery{CustomerID: customer.ID, Limit: 1000, Offset: -1})
+	require.NoError(t, err)
+	assert.Equal(t, cars.MaxListLimit, page.Limit)
+	assert.Zero(t, page.Offset)
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, order.ID, page.Orders[0].ID)
+
+	missing := entities.CustomerID(entities.NewObjectIDGenerator().NewID())
+	_, err = stores.service.ListOrdersService(ctx, &entities.OrderQuery{CustomerID: missing})
+	assert.ErrorIs(t, err, customers.ErrCustomerNotFound)
+}
+
+func TestListOrdersServiceNextCursor(t *testing.T) {
+	ctx := context.Background()
+	stores := newTestService(t)
+	customer := stores.insertCustomer(t)
+	for _, price := range []int64{3000000, 2000000} {
+		car := stores.insertCar(t, "", price)
+		_, err := stores.service.PlaceOrderService(ctx, &entities.OrderRequest{CustomerID: customer.ID, CarID: car.ID})
+		require.NoError(t, err)
+	}
+
+	page, err := stores.service.ListOrdersService(ctx, &entities.OrderQuery{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, cars.EncodeCursor(1), page.NextCursor)
+
+	page, err = stores.service.ListOrdersService(ctx, &entities.OrderQuery{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Len(t, page.Orders, 1)
+	assert.Empty(t, page.NextCursor)
+}
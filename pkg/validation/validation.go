@@ -0,0 +1,202 @@
+// Package validation checks payloads against the rules declared in their
+// `validate` struct tags. Every package keeps its own error type for the
+// field errors found, so that a failure names what was being validated.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes why a single field of a payload was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Rule checks field against param, the text after "=" in the tag, and
+// returns a message when it fails. Rules only see non-empty values.
+type Rule func(field reflect.Value, param string) string
+
+// Validator checks the rules of the `validate` tags of a struct. Rules are
+// comma separated and applied in order; the first rule a field breaks is
+// reported and every field is checked, so one call describes everything
+// wrong with a payload.
+//
+// Built-in rules:
+//
+//	required           the value must not be empty
+//	requiredwith=FIELD the value must not be empty when the sibling field
+//	                   FIELD is set
+//	min=N, max=N       string length in characters
+//	gte=N              the number must be at least N
+//	lte=N              the number must be at most N
+//	oneof=A B ...      the string must be one of the space separated values
+//	pattern=NAME       the string must match one of the named patterns
+//	past               the time must not be in the future
+//	after=FIELD        the time must not be before the sibling field FIELD
+//
+// Sibling fields are named by their JSON names. Apart from required and
+// requiredwith, rules ignore empty values.
+type Validator struct {
+	now   func() time.Time
+	rules map[string]Rule
+}
+
+// patterns are the character sets usable with the pattern rule.
+var patterns = map[string]*regexp.Regexp{
+	"name": regexp.MustCompile(`^[\p{L}\p{N} .,'&()/+-]*$`),
+	// An ISO 4217 currency code.
+	"currency": regexp.MustCompile(`^[A-Z]{3}$`),
+	// An ISO 3166-1 alpha-2 country code.
+	"country": regexp.MustCompile(`^[A-Z]{2}$`),
+	// A mailbox: something at a dotted domain.
+	"email": regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`),
+	// Digits, optionally led by + and grouped with spaces, dashes or
+	// parentheses.
+	"phone": regexp.MustCompile(`^\+?[0-9 ()-]*$`),
+}
+
+// New returns a Validator knowing the built-in rules and the given ones,
+// which are named like the tags use them. now tells the time for the past
+// rule.
+func New(now func() time.Time, rules map[string]Rule) *Validator {
+	return &Validator{now: now, rules: rules}
+}
+
+// Validate checks every tagged field of the struct that value points to
+// and returns the ones that fail, or nil.
+func (v *Validator) Validate(value interface{}) []FieldError {
+	parent := reflect.Indirect(reflect.ValueOf(value))
+	parentType := parent.Type()
+
+	var fields []FieldError
+	for i := 0; i < parentType.NumField(); i++ {
+		tag := parentType.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(rule, "=")
+			if message := v.check(name, param, parent.Field(i), parent); message != "" {
+				fields = append(fields, FieldError{
+					Field:   jsonName(parentType.Field(i)),
+					Message: message,
+				})
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// check applies one rule to field and returns a message when it fails.
+func (v *Validator) check(rule, param string, field, parent reflect.Value) string {
+	switch rule {
+	case "required":
+		if isEmpty(field) {
+			return "is required"
+		}
+		return ""
+	case "requiredwith":
+		if isEmpty(field) && !isEmpty(sibling(parent, param)) {
+			return "is required with " + param
+		}
+		return ""
+	}
+
+	if isEmpty(field) {
+		return ""
+	}
+
+	switch rule {
+	case "min":
+		if utf8.RuneCountInString(field.String()) < mustAtoi(param) {
+			return fmt.Sprintf("must be at least %s characters", param)
+		}
+	case "max":
+		if utf8.RuneCountInString(field.String()) > mustAtoi(param) {
+			return fmt.Sprintf("must be at most %s characters", param)
+		}
+	case "gte":
+		if field.Int() < int64(mustAtoi(param)) {
+			return "must be at least " + param
+		}
+	case "lte":
+		if field.Int() > int64(mustAtoi(param)) {
+			return "must be at most " + param
+		}
+	case "oneof":
+		values := strings.Fields(param)
+		for _, value := range values {
+			if field.String() == value {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	case "pattern":
+		pattern, ok := patterns[param]
+		if !ok {
+			panic("validation: unknown pattern " + param)
+		}
+		if !pattern.MatchString(field.String()) {
+			return "contains characters that are not allowed"
+		}
+	case "past":
+		if field.Interface().(time.Time).After(v.now()) {
+			return "must not be in the future"
+		}
+	case "after":
+		other := sibling(parent, param)
+		if !isEmpty(other) && field.Interface().(time.Time).Before(other.Interface().(time.Time)) {
+			return "must not be before " + param
+		}
+	default:
+		check, ok := v.rules[rule]
+		if !ok {
+			panic("validation: unknown rule " + rule)
+		}
+		return check(field, param)
+	}
+
+	return ""
+}
+
+func isEmpty(field reflect.Value) bool {
+	if field.Kind() == reflect.String {
+		return strings.TrimSpace(field.String()) == ""
+	}
+	return field.IsZero()
+}
+
+func mustAtoi(param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic("validation: invalid parameter " + param)
+	}
+	return n
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// sibling returns the field of parent with the given JSON name.
+func sibling(parent reflect.Value, name string) reflect.Value {
+	for i := 0; i < parent.NumField(); i++ {
+		if jsonName(parent.Type().Field(i)) == name {
+			return parent.Field(i)
+		}
+	}
+	panic("validation: unknown field " + name)
+}
@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type payload struct {
+	Name     string    `json:"name" validate:"required,max=8,pattern=name"`
+	Email    string    `json:"email" validate:"pattern=email"`
+	Code     string    `json:"code" validate:"even"`
+	Count    int64     `json:"count" validate:"gte=1,lte=3"`
+	Currency string    `json:"currency" validate:"requiredwith=count,pattern=currency"`
+	Since    time.Time `json:"since" validate:"past"`
+	Until    time.Time `json:"until" validate:"after=since"`
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2023, 7, 5, 12, 0, 0, 0, time.UTC)
+	validator := New(func() time.Time { return now }, map[string]Rule{
+		"even": func(field reflect.Value, _ string) string {
+			if len(field.String())%2 != 0 {
+				return "must have an even length"
+			}
+			return ""
+		},
+	})
+
+	tests := []struct {
+		description    string
+		payload        payload
+		expectedFields []FieldError
+	}{
+		{
+			description: "valid",
+			payload:     payload{Name: "Ada", Email: "ada@example.com", Code: "ab", Count: 2, Currency: "EUR", Since: now, Until: now},
+		},
+		{
+			description: "emptyOptionalFields",
+			payload:     payload{Name: "Ada"},
+		},
+		{
+			description: "invalid",
+			payload: payload{
+				Name: " ", Email: "ada", Code: "abc", Count: 4, Since: now.Add(time.Hour), Until: now,
+			},
+			expectedFields: []FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "email", Message: "contains characters that are not allowed"},
+				{Field: "code", Message: "must have an even length"},
+				{Field: "count", Message: "must be at most 3"},
+				{Field: "currency", Message: "is required with count"},
+				{Field: "since", Message: "must not be in the future"},
+				{Field: "until", Message: "must not be before since"},
+			},
+		},
+		{
+			description: "tooLong",
+			payload:     payload{Name: "Grand Touring"},
+			expectedFields: []FieldError{
+				{Field: "name", Message: "must be at most 8 characters"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expectedFields, validator.Validate(&test.payload))
+		})
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	assert.PanicsWithValue(t, "validation: unknown rule even", func() {
+		New(time.Now, nil).Validate(&payload{Code: "ab"})
+	})
+}